@@ -24,6 +24,7 @@ package plugin
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/gogo/protobuf/protoc-gen-gogo/descriptor"
@@ -194,7 +195,7 @@ func (g *validator) generateNumberField(field *generator.FieldDescriptor) {
 	}
 	if _, ok := tags[_required]; ok {
 		g.P("if int64(m.", fieldName, ") == 0 {")
-		g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' is required\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName()))
+		g.invalid(field, "required", `""`, "is required")
 		if len(tags) > 1 {
 			g.P("} else {")
 		}
@@ -212,37 +213,37 @@ func (g *validator) generateNumberField(field *generator.FieldDescriptor) {
 			value := strings.TrimPrefix(tag.Value, "[")
 			value = strings.TrimSuffix(value, "]")
 			g.P(fmt.Sprintf("if !%s.In([]float64{%s}, float64(m.%s)) {", g.isPkg.Use(), value, fieldName))
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' must in '%s'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), tag.Value))
+			g.invalid(field, "in", "m."+fieldName, fmt.Sprintf("must in '%s'", tag.Value))
 			g.P("}")
 		case _notIn:
 			value := strings.TrimPrefix(tag.Value, "[")
 			value = strings.TrimSuffix(value, "]")
 			g.P(fmt.Sprintf("if !%s.NotIn([]float64{%s}, float64(m.%s)) {", g.isPkg.Use(), value, fieldName))
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' must not in '%s'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), tag.Value))
+			g.invalid(field, "not_in", "m."+fieldName, fmt.Sprintf("must not in '%s'", tag.Value))
 			g.P("}")
 		case _eq:
 			g.P("if !(m.", fieldName, " == ", tag.Value, ") {")
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' must equal to '%s'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), tag.Value))
+			g.invalid(field, "eq", "m."+fieldName, fmt.Sprintf("must equal to '%s'", tag.Value))
 			g.P("}")
 		case _ne:
 			g.P("if !(m.", fieldName, " != ", tag.Value, ") {")
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' must not equal to '%s'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), tag.Value))
+			g.invalid(field, "ne", "m."+fieldName, fmt.Sprintf("must not equal to '%s'", tag.Value))
 			g.P("}")
 		case _lt:
 			g.P("if !(m.", fieldName, " < ", tag.Value, ") {")
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' must less than '%s'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), tag.Value))
+			g.invalid(field, "lt", "m."+fieldName, fmt.Sprintf("must less than '%s'", tag.Value))
 			g.P("}")
 		case _lte:
 			g.P("if !(m.", fieldName, " <= ", tag.Value, ") {")
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' must less than or equal to '%s'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), tag.Value))
+			g.invalid(field, "lte", "m."+fieldName, fmt.Sprintf("must less than or equal to '%s'", tag.Value))
 			g.P("}")
 		case _gt:
 			g.P("if !(m.", fieldName, " > ", tag.Value, ") {")
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' must great than '%s'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), tag.Value))
+			g.invalid(field, "gt", "m."+fieldName, fmt.Sprintf("must great than '%s'", tag.Value))
 			g.P("}")
 		case _gte:
 			g.P("if !(m.", fieldName, " >= ", tag.Value, ") {")
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' must great than or equal to '%s'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), tag.Value))
+			g.invalid(field, "gte", "m."+fieldName, fmt.Sprintf("must great than or equal to '%s'", tag.Value))
 			g.P("}")
 		}
 	}
@@ -255,7 +256,7 @@ func (g *validator) generateEnumField(field *generator.FieldDescriptor) {
 	tags := g.extractTags(field.Comments)
 	if _, ok := tags[_required]; ok {
 		g.P("if int32(m.", fieldName, ") == 0 {")
-		g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' is required\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName()))
+		g.invalid(field, "required", `""`, "is required")
 		if len(tags) > 1 {
 			g.P("} else {")
 		}
@@ -273,7 +274,7 @@ func (g *validator) generateEnumField(field *generator.FieldDescriptor) {
 		val = append(val, fmt.Sprintf("%d", item.GetNumber()))
 	}
 	g.P(fmt.Sprintf("if !%s.In([]int32{%s}, int32(m.%s)) {", g.isPkg.Use(), strings.Join(val, ","), fieldName))
-	g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' must in '[%s]'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), strings.Join(val, ", ")))
+	g.invalid(field, "in", "m."+fieldName, fmt.Sprintf("must in '[%s]'", strings.Join(val, ", ")))
 	g.P("}")
 	g.P("}")
 }
@@ -286,7 +287,7 @@ func (g *validator) generateStringField(field *generator.FieldDescriptor) {
 	}
 	if _, ok := tags[_required]; ok {
 		g.P("if len(m.", fieldName, ") == 0 {")
-		g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' is required\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName()))
+		g.invalid(field, "required", `""`, "is required")
 		if len(tags) > 1 {
 			g.P("} else {")
 		}
@@ -304,76 +305,76 @@ func (g *validator) generateStringField(field *generator.FieldDescriptor) {
 		case _enum, _in:
 			value := fullStringSlice(tag.Value)
 			g.P(fmt.Sprintf("if !%s.In([]string{%s}, string(m.%s)) {", g.isPkg.Use(), value, fieldName))
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' must in '[%s]'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), strings.ReplaceAll(value, "\"", "")))
+			g.invalid(field, "in", "m."+fieldName, fmt.Sprintf("must in '[%s]'", strings.ReplaceAll(value, "\"", "")))
 			g.P("}")
 		case _notIn:
 			value := fullStringSlice(tag.Value)
 			g.P(fmt.Sprintf("if !%s.NotIn([]string{%s}, string(m.%s)) {", g.isPkg.Use(), value, fieldName))
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' must not in '[%s]'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), strings.ReplaceAll(value, "\"", "")))
+			g.invalid(field, "not_in", "m."+fieldName, fmt.Sprintf("must not in '[%s]'", strings.ReplaceAll(value, "\"", "")))
 			g.P("}")
 		case _minLen:
 			g.P("if !(len(m.", fieldName, ") >= ", tag.Value, ") {")
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' length must great than '%s'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), tag.Value))
+			g.invalid(field, "min_len", "m."+fieldName, fmt.Sprintf("length must great than '%s'", tag.Value))
 			g.P("}")
 		case _maxLen:
 			g.P("if !(len(m.", fieldName, ") <= ", tag.Value, ") {")
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' length must less than '%s'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), tag.Value))
+			g.invalid(field, "max_len", "m."+fieldName, fmt.Sprintf("length must less than '%s'", tag.Value))
 			g.P("}")
 		case _prefix:
 			value := TrimString(tag.Value, "\"")
 			g.P("if !strings.HasPrefix(m.", fieldName, ", \"", value, "\") {")
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' must start with '%s'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), value))
+			g.invalid(field, "prefix", "m."+fieldName, fmt.Sprintf("must start with '%s'", value))
 			g.P("}")
 		case _suffix:
 			value := TrimString(tag.Value, "\"")
 			g.P("if !strings.HasSuffix(m.", fieldName, ", \"", value, "\") {")
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' must end with '%s'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), value))
+			g.invalid(field, "suffix", "m."+fieldName, fmt.Sprintf("must end with '%s'", value))
 			g.P("}")
 		case _contains:
 			value := TrimString(tag.Value, "\"")
 			g.P("if !strings.Contains(m.", fieldName, ", \"", value, "\") {")
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' must contain '%s'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), value))
+			g.invalid(field, "contains", "m."+fieldName, fmt.Sprintf("must contain '%s'", value))
 			g.P("}")
 		case _number:
 			g.P(fmt.Sprintf("if !%s.Number(m.%s) {", g.isPkg.Use(), fieldName))
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' is not a valid number\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName()))
+			g.invalid(field, "number", "m."+fieldName, "is not a valid number")
 			g.P("}")
 		case _email:
 			g.P(fmt.Sprintf("if !%s.Email(m.%s) {", g.isPkg.Use(), fieldName))
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' is not a valid email\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName()))
+			g.invalid(field, "email", "m."+fieldName, "is not a valid email")
 			g.P("}")
 		case _ip:
 			g.P(fmt.Sprintf("if !%s.IP(m.%s) {", g.isPkg.Use(), fieldName))
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' is not a valid ip\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName()))
+			g.invalid(field, "ip", "m."+fieldName, "is not a valid ip")
 			g.P("}")
 		case _ipv4:
 			g.P(fmt.Sprintf("if !%s.IPv4(m.%s) {", g.isPkg.Use(), fieldName))
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' is not a valid ipv4\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName()))
+			g.invalid(field, "ipv4", "m."+fieldName, "is not a valid ipv4")
 			g.P("}")
 		case _ipv6:
 			g.P(fmt.Sprintf("if !%s.IPv6(m.%s) {", g.isPkg.Use(), fieldName))
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' is not a valid ipv6\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName()))
+			g.invalid(field, "ipv6", "m."+fieldName, "is not a valid ipv6")
 			g.P("}")
 		case _crontab:
 			g.P(fmt.Sprintf("if !%s.Crontab(m.%s) {", g.isPkg.Use(), fieldName))
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' is not a valid crontab\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName()))
+			g.invalid(field, "crontab", "m."+fieldName, "is not a valid crontab")
 			g.P("}")
 		case _uuid:
 			g.P(fmt.Sprintf("if !%s.Uuid(m.%s) {", g.isPkg.Use(), fieldName))
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' is not a valid uuid\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName()))
+			g.invalid(field, "uuid", "m."+fieldName, "is not a valid uuid")
 			g.P("}")
 		case _uri:
 			g.P(fmt.Sprintf("if !%s.URL(m.%s) {", g.isPkg.Use(), fieldName))
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' is not a valid url\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName()))
+			g.invalid(field, "uri", "m."+fieldName, "is not a valid url")
 			g.P("}")
 		case _domain:
 			g.P(fmt.Sprintf("if !%s.Domain(m.%s) {", g.isPkg.Use(), fieldName))
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' is not a valid domain\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName()))
+			g.invalid(field, "domain", "m."+fieldName, "is not a valid domain")
 			g.P("}")
 		case _pattern:
 			value := TrimString(tag.Value, "`")
 			g.P(fmt.Sprintf("if !%s.Re(`%s`, m.%s) {", g.isPkg.Use(), value, fieldName))
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(`field '%%s%s' is not a valid pattern '%s'`, prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), value))
+			g.invalid(field, "pattern", "m."+fieldName, fmt.Sprintf("is not a valid pattern '%s'", value))
 			g.P("}")
 		}
 	}
@@ -388,7 +389,7 @@ func (g *validator) generateBytesField(field *generator.FieldDescriptor) {
 	}
 	if _, ok := tags[_required]; ok {
 		g.P("if len(m.", fieldName, ") == 0 {")
-		g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' is required\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName()))
+		g.invalid(field, "required", `""`, "is required")
 		if len(tags) > 1 {
 			g.P("} else {")
 		}
@@ -399,12 +400,12 @@ func (g *validator) generateBytesField(field *generator.FieldDescriptor) {
 		fieldName := generator.CamelCase(field.Proto.GetName())
 		switch tag.Key {
 		case _minBytes:
-			g.P("if !(len(m.", fieldName, ") <= ", tag.Value, ") {")
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' length must less than '%s'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), tag.Value))
+			g.P("if !(len(m.", fieldName, ") >= ", tag.Value, ") {")
+			g.invalid(field, "min_bytes", "len(m."+fieldName+")", fmt.Sprintf("length must great than '%s'", tag.Value))
 			g.P("}")
 		case _maxBytes:
-			g.P("if !(len(m.", fieldName, ") >= ", tag.Value, ") {")
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' length must great than '%s'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), tag.Value))
+			g.P("if !(len(m.", fieldName, ") <= ", tag.Value, ") {")
+			g.invalid(field, "max_bytes", "len(m."+fieldName+")", fmt.Sprintf("length must less than '%s'", tag.Value))
 			g.P("}")
 		}
 	}
@@ -419,7 +420,7 @@ func (g *validator) generateRepeatedField(field *generator.FieldDescriptor) {
 	}
 	if _, ok := tags[_required]; ok {
 		g.P("if len(m.", fieldName, ") == 0 {")
-		g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' is required\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName()))
+		g.invalid(field, "required", `""`, "is required")
 		if len(tags) > 1 {
 			g.P("} else {")
 		}
@@ -430,12 +431,12 @@ func (g *validator) generateRepeatedField(field *generator.FieldDescriptor) {
 		fieldName := generator.CamelCase(*field.Proto.Name)
 		switch tag.Key {
 		case _minLen:
-			g.P("if !(len(m.", fieldName, ") <= ", tag.Value, ") {")
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' length must less than '%s'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), tag.Value))
+			g.P("if !(len(m.", fieldName, ") >= ", tag.Value, ") {")
+			g.invalid(field, "min_len", "len(m."+fieldName+")", fmt.Sprintf("length must great than '%s'", tag.Value))
 			g.P("}")
 		case _maxLen:
-			g.P("if !(len(m.", fieldName, ") >= ", tag.Value, ") {")
-			g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' length must great than '%s'\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName(), tag.Value))
+			g.P("if !(len(m.", fieldName, ") <= ", tag.Value, ") {")
+			g.invalid(field, "max_len", "len(m."+fieldName+")", fmt.Sprintf("length must less than '%s'", tag.Value))
 			g.P("}")
 		}
 	}
@@ -477,13 +478,18 @@ func (g *validator) generateMessageField(field *generator.FieldDescriptor) {
 	if _, ok := tags[_required]; ok {
 		fname := generator.CamelCase(*field.Proto.Name)
 		g.P("if m.", fname, " == nil {")
-		g.P(fmt.Sprintf("errs = append(errs, %s.Errorf(\"field '%%s%s' is required\", prefix))", g.fmtPkg.Use(), field.Proto.GetJsonName()))
+		g.invalid(field, "required", `""`, "is required")
 		g.P("} else {")
 		g.P(fmt.Sprintf("errs = append(errs, m.%s.ValidateE(prefix+\"%s.\"))", fname, field.Proto.GetJsonName()))
 		g.P("}")
 	}
 }
 
+// invalid appends a violation of the given rule to the generated errs
+func (g *validator) invalid(field *generator.FieldDescriptor, rule, value, message string) {
+	g.P(fmt.Sprintf("errs = append(errs, %s.Invalid(prefix+\"%s\", \"%s\", %s, %s))", g.isPkg.Use(), field.Proto.GetJsonName(), rule, value, strconv.Quote(message)))
+}
+
 func (g *validator) ignoredMessage(msg *generator.MessageDescriptor) bool {
 	tags := g.extractTags(msg.Comments)
 	for _, c := range tags {
@@ -24,6 +24,7 @@ package plugin
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/vine-io/vine/cmd/generator"
@@ -54,6 +55,10 @@ const (
 	_summary  = "summary"
 	_security = "security"
 	_result   = "result"
+	_cache    = "cache"
+
+	_responseBody       = "response_body"
+	_additionalBindings = "additional_bindings"
 
 	// field common tag
 	_inline    = "inline"
@@ -82,11 +87,15 @@ const (
 	_uuid     = "uuid"
 	_uri      = "uri"
 	_hostname = "hostname"
+	_domain   = "domain"
 	_pattern  = "pattern"
+	_prefix   = "prefix"
+	_suffix   = "suffix"
+	_contains = "contains"
 
 	// int32, int64, uint32, uint64, float32, float64 tag
 	// _ne  = "ne"
-	// _eq  = "eq"
+	_eq  = "eq"
 	_lt  = "lt"
 	_lte = "lte"
 	_gt  = "gt"
@@ -172,12 +181,120 @@ func extractDesc(comments []*generator.Comment) []string {
 	return desc
 }
 
+// httpBinding is an additional binding of a method
+type httpBinding struct {
+	Method       string
+	Path         string
+	Body         string
+	ResponseBody string
+}
+
+// parseBindings parses the value of the additional_bindings tag, bindings are
+// separated by commas and written as method:path followed by the optional
+// body and response_body, like:
+//
+//	get:/v1/users/{id}, post:/v1/users:lookup body=* response_body=user
+func parseBindings(value string) ([]*httpBinding, error) {
+	bindings := make([]*httpBinding, 0)
+	for _, part := range strings.Split(value, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+
+		i := strings.Index(fields[0], ":")
+		if i <= 0 || i == len(fields[0])-1 {
+			return nil, fmt.Errorf("invalid binding '%s', expected method:path", part)
+		}
+		b := &httpBinding{Method: strings.ToUpper(fields[0][:i]), Path: fields[0][i+1:]}
+		switch b.Method {
+		case "GET", "POST", "PUT", "PATCH", "DELETE":
+		default:
+			return nil, fmt.Errorf("invalid method '%s' of binding '%s'", fields[0][:i], part)
+		}
+
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 || kv[1] == "" {
+				return nil, fmt.Errorf("invalid option '%s' of binding '%s'", field, part)
+			}
+			switch kv[0] {
+			case _body:
+				b.Body = kv[1]
+			case _responseBody:
+				b.ResponseBody = kv[1]
+			default:
+				return nil, fmt.Errorf("unknown option '%s' of binding '%s'", kv[0], part)
+			}
+		}
+		bindings = append(bindings, b)
+	}
+	return bindings, nil
+}
+
+// wellKnownSchema returns the type and format of the json mapping of the
+// google.protobuf well known types, wrappers are nullable scalars
+func wellKnownSchema(typeName string) (typ, format string, nullable, ok bool) {
+	switch strings.TrimPrefix(typeName, ".google.protobuf.") {
+	case "Timestamp":
+		return "string", "date-time", false, true
+	case "Duration":
+		return "string", "duration", false, true
+	case "FieldMask":
+		return "string", "field-mask", false, true
+	case "Struct", "Empty":
+		return "object", "", false, true
+	case "ListValue":
+		return "array", "", false, true
+	case "Value":
+		return "", "", false, true
+	case "DoubleValue":
+		return "number", "double", true, true
+	case "FloatValue":
+		return "number", "float", true, true
+	case "Int64Value":
+		return "integer", "int64", true, true
+	case "UInt64Value":
+		return "integer", "uint64", true, true
+	case "Int32Value":
+		return "integer", "int32", true, true
+	case "UInt32Value":
+		return "integer", "uint32", true, true
+	case "BoolValue":
+		return "boolean", "", true, true
+	case "StringValue":
+		return "string", "", true, true
+	case "BytesValue":
+		return "string", "byte", true, true
+	}
+	return "", "", false, false
+}
+
 func TrimString(s string, c string) string {
 	s = strings.TrimPrefix(s, c)
 	s = strings.TrimSuffix(s, c)
 	return s
 }
 
+// stringPattern returns the pattern which documents the string tags. openapi
+// holds a single pattern, so the first of pattern, prefix, suffix and contains
+// wins and the rest are left to Validate().
+func stringPattern(tags map[string]*Tag) string {
+	if tag, ok := tags[_pattern]; ok {
+		return TrimString(tag.Value, "`")
+	}
+	if tag, ok := tags[_prefix]; ok {
+		return "^" + regexp.QuoteMeta(TrimString(tag.Value, `"`))
+	}
+	if tag, ok := tags[_suffix]; ok {
+		return regexp.QuoteMeta(TrimString(tag.Value, `"`)) + "$"
+	}
+	if tag, ok := tags[_contains]; ok {
+		return regexp.QuoteMeta(TrimString(tag.Value, `"`))
+	}
+	return ""
+}
+
 // fullStringSlice converts [1,2,3] => `"1", "2", "3"`
 func fullStringSlice(s string) string {
 	s = strings.TrimPrefix(s, "[")
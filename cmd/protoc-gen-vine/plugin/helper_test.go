@@ -0,0 +1,22 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBindings(t *testing.T) {
+	bindings, err := parseBindings("get:/v1/users/{id}, post:/v1/users:lookup body=* response_body=user")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []*httpBinding{
+			{Method: "GET", Path: "/v1/users/{id}"},
+			{Method: "POST", Path: "/v1/users:lookup", Body: "*", ResponseBody: "user"},
+		}, bindings)
+	}
+
+	for _, value := range []string{"/v1/users", "head:/v1/users", "get:", "get:/v1/users body", "get:/v1/users foo=bar"} {
+		_, err = parseBindings(value)
+		assert.Error(t, err, value)
+	}
+}
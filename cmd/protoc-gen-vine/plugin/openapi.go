@@ -281,7 +281,12 @@ func (g *vine) generateParameters(svcName string, msg *generator.MessageDescript
 			}
 		}
 		if in != "path" {
-			g.P(`Style: "form",`)
+			if _, _, _, ok := wellKnownSchema(field.Proto.GetTypeName()); field.Proto.IsMessage() && !ok {
+				// nested fields are written as name.field=value
+				g.P(`Style: "deepObject",`)
+			} else {
+				g.P(`Style: "form",`)
+			}
 		}
 		g.P("Explode: true,")
 		g.P(fmt.Sprintf("Schema: &%s.Schema{", g.openApiPbPkg.Use()))
@@ -323,17 +328,14 @@ func (g *vine) buildQueryField(msg *generator.MessageDescriptor, ignores []strin
 		if in(ignores, field.Proto.GetJsonName()) {
 			continue
 		}
-		if field.Proto.IsEnum() || field.Proto.IsBytes() {
-			continue
-		}
-		if !field.Proto.IsMessage() {
-			*out = append(*out, field)
-		}
 		_, isInline := g.extractTags(field.Comments)[_inline]
 		if field.Proto.IsMessage() && isInline {
 			subMsg := g.gen.ExtractMessage(field.Proto.GetTypeName())
 			g.buildQueryField(subMsg, ignores, out)
+			continue
 		}
+		// enums are accepted by name or number, messages by the path of their fields
+		*out = append(*out, field)
 	}
 }
 
@@ -465,6 +467,7 @@ func (g *vine) generateComponents(svcName string) {
 						"position": &TMP.Schema{Type: "string", Description: "the code position for error"},
 						"child":    &TMP.Schema{Ref: "#/components/schemas/errors.Child"},
 						"stacks":   &TMP.Schema{Type: "array", Description: "external message", Items: &TMP.Schema{Ref: "#/components/schemas/errors.Stack"}},
+						"violations": &TMP.Schema{Type: "array", Description: "the fields which failed validation", Items: &TMP.Schema{Ref: "#/components/schemas/errors.Violation"}},
 					},
 				},
 				"errors.Child": &TMP.Model{
@@ -481,6 +484,14 @@ func (g *vine) generateComponents(svcName string) {
 						"detail":   &TMP.Schema{Type: "string", Description: "more message"},
 						"position": &TMP.Schema{Type: "string", Description: "the position for more message"},
 					},
+				},
+				"errors.Violation": &TMP.Model{
+					Type: "object",
+					Properties: map[string]*TMP.Schema{
+						"field": &TMP.Schema{Type: "string", Description: "the path of the field"},
+						"rule":  &TMP.Schema{Type: "string", Description: "the rule which failed"},
+						"value": &TMP.Schema{Type: "string", Description: "the rejected value"},
+					},
 				},`, "TMP", g.openApiPbPkg.Use(), -1))
 				return
 			}
@@ -567,6 +578,10 @@ func (g *vine) generateSchema(svcName string, field *generator.FieldDescriptor,
 			switch key {
 			case _enum, _in:
 				g.P(fmt.Sprintf(`Enum: []string{%s},`, fullStringSlice(tag.Value)))
+			case _eq:
+				if tags[_in] == nil && tags[_enum] == nil {
+					g.P(fmt.Sprintf(`Enum: []string{"%s"},`, tag.Value))
+				}
 			case _gt:
 				g.P("ExclusiveMinimum: true,")
 				g.P(fmt.Sprintf(`Minimum: %s,`, tag.Value))
@@ -619,7 +634,7 @@ func (g *vine) generateSchema(svcName string, field *generator.FieldDescriptor,
 				g.P(`Format: "uuid",`)
 			case _uri:
 				g.P(`Format: "uri",`)
-			case _hostname:
+			case _hostname, _domain:
 				g.P(`Format: "hostname",`)
 			case _ip, _ipv4:
 				g.P(`Format: "ipv4",`)
@@ -629,14 +644,15 @@ func (g *vine) generateSchema(svcName string, field *generator.FieldDescriptor,
 				g.P(`ReadOnly: true,`)
 			case _writeOnly:
 				g.P(`WriteOnly: true,`)
-			case _pattern:
-				g.P(fmt.Sprintf("Pattern: `'%s'`,", TrimString(tag.Value, "`")))
 			case _default:
 				g.P(fmt.Sprintf(`Default: "%s",`, TrimString(tag.Value, `"`)))
 			case _example:
 				g.P(fmt.Sprintf(`Example: "%s",`, TrimString(tag.Value, `"`)))
 			}
 		}
+		if pattern := stringPattern(tags); pattern != "" {
+			g.P(fmt.Sprintf("Pattern: `%s`,", pattern))
+		}
 	}
 
 	// generate map
@@ -705,7 +721,20 @@ func (g *vine) generateSchema(svcName string, field *generator.FieldDescriptor,
 			g.P(fmt.Sprintf(`Items: &%s.Schema{Type: "integer"},`, g.openApiPbPkg.Use()))
 		case descriptor.FieldDescriptorProto_TYPE_STRING:
 			g.P(fmt.Sprintf(`Items: &%s.Schema{Type: "string"},`, g.openApiPbPkg.Use()))
+		case descriptor.FieldDescriptorProto_TYPE_BYTES:
+			g.P(fmt.Sprintf(`Items: &%s.Schema{Type: "string", Format: "byte"},`, g.openApiPbPkg.Use()))
+		case descriptor.FieldDescriptorProto_TYPE_ENUM:
+			enums := g.gen.ExtractEnum(field.Proto.GetTypeName())
+			val := []string{}
+			for _, item := range enums.Value {
+				val = append(val, fmt.Sprintf(`"%d"`, item.GetNumber()))
+			}
+			g.P(fmt.Sprintf(`Items: &%s.Schema{Type: "integer", Format: "int32", Enum: []string{%s}},`, g.openApiPbPkg.Use(), strings.Join(val, ", ")))
 		case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+			if typ, format, _, ok := wellKnownSchema(field.Proto.GetTypeName()); ok {
+				g.P(fmt.Sprintf(`Items: &%s.Schema{Type: "%s", Format: "%s"},`, g.openApiPbPkg.Use(), typ, format))
+				return
+			}
 			msg := g.extractMessage(field.Proto.GetTypeName())
 			if msg == nil {
 				g.gen.Fail("couldn't found message: ", field.Proto.GetTypeName())
@@ -767,7 +796,32 @@ func (g *vine) generateSchema(svcName string, field *generator.FieldDescriptor,
 		g.P(`Type: "string",`)
 		generateString(g, field, tags)
 	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		g.P(`Type: "string",`)
+		g.P(`Format: "byte",`)
+		if _, ok := tags[_required]; ok {
+			if allowRequired {
+				g.P(`Required: true,`)
+			}
+		}
 	case descriptor.FieldDescriptorProto_TYPE_MESSAGE:
+		if typ, format, nullable, ok := wellKnownSchema(field.Proto.GetTypeName()); ok {
+			// well known types have their own json mapping
+			if typ != "" {
+				g.P(fmt.Sprintf(`Type: "%s",`, typ))
+			}
+			if format != "" {
+				g.P(fmt.Sprintf(`Format: "%s",`, format))
+			}
+			if nullable {
+				g.P(`Nullable: true,`)
+			}
+			if _, ok := tags[_required]; ok {
+				if allowRequired {
+					g.P(`Required: true,`)
+				}
+			}
+			return
+		}
 		g.P(`Type: "object",`)
 		if _, ok := tags[_required]; ok {
 			if allowRequired {
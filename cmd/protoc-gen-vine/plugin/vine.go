@@ -27,6 +27,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/vine-io/vine/cmd/generator"
 )
@@ -305,11 +306,42 @@ func (g *vine) generateEndpoint(servName string, method *generator.MethodDescrip
 	if v, ok := tags[_security]; ok {
 		g.P("Security:", fmt.Sprintf(`"%s",`, v.Value))
 	}
+	if v, ok := tags[_cache]; ok {
+		if _, err := time.ParseDuration(v.Value); err != nil {
+			g.gen.Fail("invalid cache duration:", v.Value)
+			return
+		}
+		g.P("Cache:", fmt.Sprintf(`"%s",`, v.Value))
+	}
 	if v, ok := tags[_body]; ok {
 		g.P("Body:", fmt.Sprintf(`"%s",`, v.Value))
 	} else {
 		g.P("Body:", `"*",`)
 	}
+	if v, ok := tags[_responseBody]; ok {
+		g.P("ResponseBody:", fmt.Sprintf(`"%s",`, v.Value))
+	}
+	if v, ok := tags[_additionalBindings]; ok {
+		bindings, err := parseBindings(v.Value)
+		if err != nil {
+			g.gen.Fail(err.Error())
+			return
+		}
+		g.P("AdditionalBindings: []*", g.apiPbPkg.Use(), ".Endpoint{")
+		for _, b := range bindings {
+			g.P("{")
+			g.P("Path:", fmt.Sprintf(`[]string{"%s"},`, b.Path))
+			g.P("Method:", fmt.Sprintf(`[]string{"%s"},`, b.Method))
+			if b.Body != "" {
+				g.P("Body:", fmt.Sprintf(`"%s",`, b.Body))
+			}
+			if b.ResponseBody != "" {
+				g.P("ResponseBody:", fmt.Sprintf(`"%s",`, b.ResponseBody))
+			}
+			g.P("},")
+		}
+		g.P("},")
+	}
 	if method.Proto.GetServerStreaming() && method.Proto.GetClientStreaming() {
 		g.P(fmt.Sprintf("Stream: string(%s.Bidirectional),", g.apiPkg.Use()))
 	} else if method.Proto.GetServerStreaming() {
@@ -24,6 +24,7 @@
 package api
 
 import (
+	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -32,10 +33,14 @@ import (
 	"github.com/vine-io/vine"
 	"github.com/vine-io/vine/cmd/vine/app/api/handler"
 	rrvine "github.com/vine-io/vine/cmd/vine/client/resolver/api"
+	vserver "github.com/vine-io/vine/core/server"
 	grpcServer "github.com/vine-io/vine/core/server/grpc"
+	"github.com/vine-io/vine/lib/api/apikey"
 	ahandler "github.com/vine-io/vine/lib/api/handler"
 	aapi "github.com/vine-io/vine/lib/api/handler/api"
 	"github.com/vine-io/vine/lib/api/handler/event"
+	"github.com/vine-io/vine/lib/api/handler/graphql"
+	agrpc "github.com/vine-io/vine/lib/api/handler/grpc"
 	ahttp "github.com/vine-io/vine/lib/api/handler/http"
 	"github.com/vine-io/vine/lib/api/handler/openapi"
 	arpc "github.com/vine-io/vine/lib/api/handler/rpc"
@@ -46,7 +51,16 @@ import (
 	"github.com/vine-io/vine/lib/api/resolver/path"
 	"github.com/vine-io/vine/lib/api/router"
 	regRouter "github.com/vine-io/vine/lib/api/router/registry"
+	"github.com/vine-io/vine/lib/api/router/traffic"
 	"github.com/vine-io/vine/lib/api/server"
+	apicache "github.com/vine-io/vine/lib/api/server/cache"
+	"github.com/vine-io/vine/lib/api/server/pipeline"
+	"github.com/vine-io/vine/lib/cache"
+	cfile "github.com/vine-io/vine/lib/cache/file"
+	"github.com/vine-io/vine/lib/config"
+	cmemory "github.com/vine-io/vine/lib/config/memory"
+	"github.com/vine-io/vine/lib/config/source/file"
+	featureflags "github.com/vine-io/vine/lib/flags"
 	log "github.com/vine-io/vine/lib/logger"
 	"github.com/vine-io/vine/util/helper"
 	"github.com/vine-io/vine/util/namespace"
@@ -65,8 +79,33 @@ var (
 	Type         = "api"
 	HeaderPrefix = "X-Vine-"
 	EnableRPC    = false
+	// EnableGRPCWeb serves gRPC-Web requests of browsers
+	EnableGRPCWeb = false
+	// EnableGRPCProxy proxies plain grpc calls to the backend services
+	EnableGRPCProxy = false
+	// EnableGraphQL serves a graphql schema of the services at GraphQLPath
+	EnableGraphQL = false
+	GraphQLPath   = "/graphql"
 )
 
+// keyCache returns the cache the api keys are kept in. The keys and the quotas
+// are lost with the cache and the memory cache evicts them with the responses,
+// so unless the service has been given a persistent cache they are kept in a
+// file cache under dir.
+func keyCache(c cache.Cache, dir string) cache.Cache {
+	switch c.String() {
+	case "memory", "noop":
+	default:
+		return c
+	}
+
+	if len(dir) == 0 {
+		dir = cfile.DefaultDir
+	}
+	log.Infof("The %s cache of the service does not persist, keeping the api keys in %s", c.String(), dir)
+	return cfile.NewCache(cfile.WithDir(dir))
+}
+
 func Run(cmd *cobra.Command, args []string, svcOpts ...vine.Option) {
 
 	flags := cmd.PersistentFlags()
@@ -85,6 +124,15 @@ func Run(cmd *cobra.Command, args []string, svcOpts ...vine.Option) {
 	if r, e := flags.GetBool("enable-rpc"); e == nil {
 		EnableRPC = r
 	}
+	if r, e := flags.GetBool("enable-grpc-web"); e == nil {
+		EnableGRPCWeb = r
+	}
+	if r, e := flags.GetBool("enable-grpc-proxy"); e == nil {
+		EnableGRPCProxy = r
+	}
+	if r, e := flags.GetBool("enable-graphql"); e == nil {
+		EnableGraphQL = r
+	}
 	if t, _ := flags.GetString("type"); len(t) > 0 {
 		Type = t
 	}
@@ -131,6 +179,68 @@ func Run(cmd *cobra.Command, args []string, svcOpts ...vine.Option) {
 	app := gin.New()
 	app.Use(gin.Recovery())
 
+	// the api keys are checked by the http handler below, and by the grpc
+	// and graphql handlers which call the services without routing the request
+	var km *apikey.Manager
+	var authOpts []ahandler.Option
+	if b, _ := flags.GetBool("enable-api-keys"); b {
+		token, _ := flags.GetString("api-keys-admin-token")
+		dir, _ := flags.GetString("api-keys-dir")
+		km = apikey.NewManager(
+			apikey.WithStore(apikey.NewStore(keyCache(svc.Options().Cache, dir))),
+			apikey.WithAdminToken(token),
+		)
+		authOpts = append(authOpts, ahandler.WithAuthorizer(km.Authorize))
+	}
+
+	// grpc calls are resolved by their full method e.g. /greeter.Greeter/Hello
+	var srvOpts []vserver.Option
+	if EnableGRPCWeb || EnableGRPCProxy {
+		rt := regRouter.NewRouter(
+			router.WithHandler(agrpc.Handler),
+			router.WithResolver(grpc.NewResolver()),
+			router.WithRegistry(svc.Options().Registry),
+		)
+		hopts := []ahandler.Option{
+			ahandler.WithNamespace(apiNamespace),
+			ahandler.WithRouter(rt),
+			ahandler.WithClient(svc.Client()),
+		}
+		hopts = append(hopts, authOpts...)
+
+		if EnableGRPCWeb {
+			log.Infof("Registering gRPC-Web Handler")
+			gw := agrpc.NewHandler(hopts...)
+			app.Use(func(c *gin.Context) {
+				if agrpc.IsGRPCWeb(c.Request) {
+					gw.Handle(c)
+					c.Abort()
+				}
+			})
+		}
+
+		if EnableGRPCProxy {
+			log.Infof("Registering gRPC Proxy")
+			srvOpts = append(srvOpts, vserver.WithRouter(agrpc.NewProxy(hopts...)))
+		}
+	}
+
+	if EnableGraphQL {
+		log.Infof("Registering GraphQL Handler at %s", GraphQLPath)
+		gopts := []ahandler.Option{
+			ahandler.WithNamespace(apiNamespace),
+			ahandler.WithClient(svc.Client()),
+		}
+		gq := graphql.NewHandler(append(gopts, authOpts...)...)
+		defer gq.Close()
+		app.Use(func(c *gin.Context) {
+			if c.Request.URL.Path == GraphQLPath {
+				gq.Handle(c)
+				c.Abort()
+			}
+		})
+	}
+
 	if b, _ := flags.GetBool("enable-stats"); b {
 		st := stats.New()
 		app.Any("/stats", st.StatsHandler)
@@ -142,6 +252,21 @@ func Run(cmd *cobra.Command, args []string, svcOpts ...vine.Option) {
 		openapi.RegisterOpenAPI(svc.Name(), svc.Client(), app)
 	}
 
+	// list the states of the feature flags read from the config
+	if b, _ := flags.GetBool("enable-flags"); b {
+		if token, _ := flags.GetString("admin-token"); len(token) > 0 {
+			fm := featureflags.NewManager(
+				featureflags.WithConfig(svc.Options().Config),
+				featureflags.WithAdminToken(token),
+			)
+			defer fm.Close()
+			log.Infof("Listing the feature flags at %s", featureflags.DefaultAdminPath)
+			featureflags.RegisterHandler(fm, app)
+		} else {
+			log.Warnf("Not listing the feature flags, set --admin-token to list them at %s", featureflags.DefaultAdminPath)
+		}
+	}
+
 	app.GET(APIPath, func(c *gin.Context) {
 		c.JSON(200, gin.H{"version": cmd.Version})
 		return
@@ -157,6 +282,10 @@ func Run(cmd *cobra.Command, args []string, svcOpts ...vine.Option) {
 		return
 	}
 
+	// split the traffic between service versions, the rules are read from the config
+	sp := traffic.NewSplitter(traffic.WithConfig(svc.Options().Config))
+	defer sp.Close()
+
 	// create the namespace resolver
 	nsResolver := namespace.NewResolver(Type, Namespace)
 
@@ -178,26 +307,33 @@ func Run(cmd *cobra.Command, args []string, svcOpts ...vine.Option) {
 		rr = grpc.NewResolver(ropts...)
 	}
 
+	var rt router.Router
 	switch Handler {
 	case "rpc":
 		log.Infof("Registering API RPC Handler at %s", APIPath)
-		rt := regRouter.NewRouter(
+		rt = regRouter.NewRouter(
 			router.WithHandler(arpc.Handler),
 			router.WithResolver(rr),
 			router.WithRegistry(svc.Options().Registry),
+			router.WithSplitter(sp),
 		)
-		rp := arpc.NewHandler(
+		hopts := []ahandler.Option{
 			ahandler.WithNamespace(apiNamespace),
 			ahandler.WithRouter(rt),
 			ahandler.WithClient(svc.Client()),
-		)
+		}
+		if b, _ := flags.GetBool("enable-validation"); b {
+			hopts = append(hopts, ahandler.WithValidation())
+		}
+		rp := arpc.NewHandler(hopts...)
 		app.Use(rp.Handle)
 	case "api":
 		log.Infof("Registering API Request Handler at %s", APIPath)
-		rt := regRouter.NewRouter(
+		rt = regRouter.NewRouter(
 			router.WithHandler(aapi.Handler),
 			router.WithResolver(rr),
 			router.WithRegistry(svc.Options().Registry),
+			router.WithSplitter(sp),
 		)
 		ap := aapi.NewHandler(
 			ahandler.WithNamespace(apiNamespace),
@@ -207,10 +343,11 @@ func Run(cmd *cobra.Command, args []string, svcOpts ...vine.Option) {
 		app.Use(ap.Handle)
 	case "event":
 		log.Infof("Registering API Event Handler at %s", APIPath)
-		rt := regRouter.NewRouter(
+		rt = regRouter.NewRouter(
 			router.WithHandler(event.Handler),
 			router.WithResolver(rr),
 			router.WithRegistry(svc.Options().Registry),
+			router.WithSplitter(sp),
 		)
 		ev := event.NewHandler(
 			ahandler.WithNamespace(apiNamespace),
@@ -220,10 +357,11 @@ func Run(cmd *cobra.Command, args []string, svcOpts ...vine.Option) {
 		app.Use(ev.Handle)
 	case "http", "proxy":
 		log.Infof("Registering API HTTP Handler at %s", ProxyPath)
-		rt := regRouter.NewRouter(
+		rt = regRouter.NewRouter(
 			router.WithHandler(ahttp.Handler),
 			router.WithResolver(rr),
 			router.WithRegistry(svc.Options().Registry),
+			router.WithSplitter(sp),
 		)
 		ht := ahttp.NewHandler(
 			ahandler.WithNamespace(apiNamespace),
@@ -233,10 +371,11 @@ func Run(cmd *cobra.Command, args []string, svcOpts ...vine.Option) {
 		app.Group(ProxyPath, ht.Handle)
 	case "web":
 		log.Infof("Registering API Web Handler at %s", APIPath)
-		rt := regRouter.NewRouter(
+		rt = regRouter.NewRouter(
 			router.WithHandler(aweb.Handler),
 			router.WithResolver(rr),
 			router.WithRegistry(svc.Options().Registry),
+			router.WithSplitter(sp),
 		)
 		w := aweb.NewHandler(
 			ahandler.WithNamespace(apiNamespace),
@@ -246,19 +385,70 @@ func Run(cmd *cobra.Command, args []string, svcOpts ...vine.Option) {
 		app.Group(ProxyPath, w.Handle)
 	default:
 		log.Infof("Registering API Default Handler at %s", APIPath)
-		rt := regRouter.NewRouter(
+		rt = regRouter.NewRouter(
 			router.WithResolver(rr),
 			router.WithRegistry(svc.Options().Registry),
+			router.WithSplitter(sp),
 		)
 		app.Group(ProxyPath, handler.Meta(svc, rt, nsResolver.ResolveWithType).Handle)
 	}
 
-	// create the auth wrapper and the server
-	// TODO: app middleware
-	if err := svc.Server().Init(grpcServer.HttpHandler(app)); err != nil {
+	var h http.Handler = app
+	if b, _ := flags.GetBool("enable-cache"); b {
+		ttl, _ := flags.GetDuration("cache-ttl")
+		token, _ := flags.GetString("admin-token")
+		if len(token) > 0 {
+			log.Infof("Caching API responses, purge them at %s", apicache.DefaultAdminPath)
+		} else {
+			log.Infof("Caching API responses, set --admin-token to purge them at %s", apicache.DefaultAdminPath)
+		}
+		h = apicache.New(
+			apicache.WithCache(svc.Options().Cache),
+			apicache.WithRouter(rt),
+			apicache.WithTTL(ttl),
+			apicache.WithAdminToken(token),
+		).Handler(h)
+	}
+
+	// check the api keys of the endpoints which require one, before the
+	// cache so cached responses are not served without a key
+	if km != nil {
+		km.Init(apikey.WithRouter(rt))
+		h = km.Handler(h)
+	}
+
+	// run the requests through the middleware pipeline, read from the config
+	// or from a dedicated file and reloaded when it changes
+	mc := svc.Options().Config
+	if f, _ := flags.GetString("middleware-file"); f != "" {
+		log.Infof("Loading the middleware pipeline from %s", f)
+		mc = cmemory.NewConfig(config.WithSource(file.NewSource(file.WithPath(f))))
+	}
+	pl := pipeline.NewPipeline(pipeline.WithConfig(mc))
+	defer pl.Close()
+	h = pl.Handler(h)
+
+	// create the server
+	srvOpts = append(srvOpts, grpcServer.HttpHandler(h))
+	if err := svc.Server().Init(srvOpts...); err != nil {
 		log.Fatal(err)
 	}
 
+	if km != nil {
+		if len(km.Options().AdminToken) == 0 {
+			log.Warnf("Not registering the api key admin service, set --api-keys-admin-token")
+		} else {
+			log.Infof("Registering the api key admin service")
+			h, err := apikey.NewHandler(km)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := apikey.RegisterKeysHandler(svc.Server(), h); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
 	// Run server
 	if err := svc.Run(); err != nil {
 		log.Fatal(err)
@@ -283,7 +473,21 @@ func Commands(options ...vine.Option) []*cobra.Command {
 	flags.String("resolver", "", "Set the hostname resolver used by the API {host, path, grpc}")
 	flags.Bool("enable-openapi", true, "Enable OpenAPI3")
 	flags.Bool("enable-rpc", false, "Enable call the backend directly via /rpc")
+	flags.Bool("enable-grpc-web", false, "Enable gRPC-Web, allowing browsers to call the grpc services")
+	flags.Bool("enable-grpc-proxy", false, "Enable proxying grpc calls to the backend services")
+	flags.Bool("enable-graphql", false, "Enable the graphql endpoint built from the registered services")
+	flags.Bool("enable-cache", false, "Enable caching the GET responses of the endpoints with a cache ttl")
+	flags.String("admin-token", "", "Set the bearer token required by the admin endpoints of the gateway, they are disabled without one")
+	flags.Duration("cache-ttl", 0, "Set the cache ttl of the endpoints without one, zero caches only those tagged with +gen:cache")
+	flags.Bool("enable-api-keys", false, "Enable checking the api keys of the endpoints secured with apiKeys")
+	flags.String("api-keys-dir", "", "Set the directory of the api keys when the cache of the service does not persist, $VINE_CACHE_DIR by default")
+	flags.String("api-keys-admin-token", "", "Set the token required by the api key admin service, it is not served without one")
+	flags.String("middleware-file", "", "Load the middleware pipeline from the file instead of the api.middleware config, e.g. middleware.yaml")
+	flags.Bool("enable-flags", false, "Enable listing the states of the feature flags of the config at /admin/flags, it requires --admin-token")
+	flags.Bool("enable-validation", false, "Enable rejecting the rpc requests which break the openapi schema of the service, the service still validates them")
 	flags.Bool("enable-cors", true, "Enable CORS, allowing the API to be called by frontend applications")
 
+	cmd.AddCommand(keysCommand(options...))
+
 	return []*cobra.Command{cmd}
 }
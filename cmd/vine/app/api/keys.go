@@ -0,0 +1,155 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package api
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vine-io/vine"
+	"github.com/vine-io/vine/lib/api/apikey"
+	"github.com/vine-io/vine/util/context/metadata"
+)
+
+// keysService returns the client of the key admin service of the gateway
+func keysService(cmd *cobra.Command, options ...vine.Option) (apikey.KeysService, context.Context) {
+	flags := cmd.Flags()
+	name, _ := flags.GetString("gateway")
+	token, _ := flags.GetString("admin-token")
+
+	ctx := context.Background()
+	if len(token) > 0 {
+		ctx = metadata.Set(ctx, "Authorization", "Bearer "+token)
+	}
+
+	svc := vine.NewService(options...)
+	return apikey.NewKeysService(name, svc.Client()), ctx
+}
+
+func formatTime(unix int64) string {
+	if unix == 0 {
+		return "-"
+	}
+	return time.Unix(unix, 0).Format(time.RFC3339)
+}
+
+func keysCommand(options ...vine.Option) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manage the api keys of the gateway",
+	}
+	pflags := cmd.PersistentFlags()
+	pflags.String("gateway", Name, "Set the name of the api gateway")
+	pflags.String("admin-token", "", "Set the token of the api key admin service")
+
+	create := &cobra.Command{
+		Use:          "create",
+		Short:        "Create an api key, its token is only printed once",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			req := &apikey.CreateRequest{}
+			req.Name, _ = flags.GetString("name")
+			req.Scopes, _ = flags.GetStringSlice("scope")
+			req.Quota, _ = flags.GetInt64("quota")
+			if d, _ := flags.GetDuration("period"); d > 0 {
+				req.Period = d.String()
+			}
+			if d, _ := flags.GetDuration("ttl"); d > 0 {
+				req.Ttl = d.String()
+			}
+
+			svc, ctx := keysService(cmd, options...)
+			rsp, err := svc.Create(ctx, req)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("id:    %s\n", rsp.Key.Id)
+			fmt.Printf("token: %s\n", rsp.Token)
+			return nil
+		},
+	}
+	flags := create.Flags()
+	flags.String("name", "", "Set the name of the key e.g. the partner using it")
+	flags.StringSlice("scope", nil, "Restrict the key to endpoints e.g. go.vine.api.greeter:Greeter.*")
+	flags.Int64("quota", 0, "Set the number of requests allowed per period, zero for unlimited")
+	flags.Duration("period", 24*time.Hour, "Set the period of the quota")
+	flags.Duration("ttl", 0, "Set the lifetime of the key, zero never expires")
+
+	list := &cobra.Command{
+		Use:          "list",
+		Short:        "List the api keys and their usage",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			svc, ctx := keysService(cmd, options...)
+			rsp, err := svc.List(ctx, &apikey.ListRequest{})
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tSCOPES\tQUOTA\tUSED\tTOTAL\tEXPIRES\tREVOKED")
+			for _, k := range rsp.Keys {
+				scopes, quota, used, total := "*", "-", "-", int64(0)
+				if len(k.Scopes) > 0 {
+					scopes = strings.Join(k.Scopes, ",")
+				}
+				if k.Quota > 0 {
+					quota = fmt.Sprintf("%d/%s", k.Quota, k.Period)
+				}
+				if k.Usage != nil {
+					total = k.Usage.Total
+					if k.Quota > 0 {
+						used = fmt.Sprintf("%d", k.Usage.Window)
+					}
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+					k.Id, k.Name, scopes, quota, used, total, formatTime(k.Expires), formatTime(k.Revoked))
+			}
+			return w.Flush()
+		},
+	}
+
+	revoke := &cobra.Command{
+		Use:          "revoke [id]",
+		Short:        "Revoke an api key",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			svc, ctx := keysService(cmd, options...)
+			if _, err := svc.Revoke(ctx, &apikey.RevokeRequest{Id: args[0]}); err != nil {
+				return err
+			}
+			fmt.Printf("revoked %s\n", args[0])
+			return nil
+		},
+	}
+
+	cmd.AddCommand(create, list, revoke)
+	return cmd
+}
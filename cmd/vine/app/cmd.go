@@ -32,6 +32,7 @@ import (
 	cliBuild "github.com/vine-io/vine/cmd/vine/app/cli/build"
 	cliMg "github.com/vine-io/vine/cmd/vine/app/cli/mg"
 	cliRun "github.com/vine-io/vine/cmd/vine/app/cli/run"
+	"github.com/vine-io/vine/cmd/vine/app/config"
 	"github.com/vine-io/vine/cmd/vine/version"
 	"github.com/vine-io/vine/lib/cmd"
 )
@@ -290,7 +291,7 @@ func Setup(root *cobra.Command, options ...vine.Option) {
 	// Add the various commands
 	//app.Commands = append(app.Commands, runtime.Commands(options...)...)
 	//app.Commands = append(app.Commands, store.Commands(options...)...)
-	//app.Commands = append(app.Commands, config.Commands(options...)...)
+	root.AddCommand(config.Commands(options...)...)
 	root.AddCommand(api.Commands(options...)...)
 	//app.Commands = append(app.Commands, broker.Commands(options...)...)
 	//app.Commands = append(app.Commands, health.Commands(options...)...)
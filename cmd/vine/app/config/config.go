@@ -0,0 +1,296 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package config manages the encrypted values of config files, renders the
+// effective config and runs the config service
+package config
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/vine-io/vine"
+	"github.com/vine-io/vine/lib/config"
+	"github.com/vine-io/vine/lib/config/memory"
+	"github.com/vine-io/vine/lib/config/reader"
+	"github.com/vine-io/vine/lib/config/reader/json"
+	"github.com/vine-io/vine/lib/config/secrets"
+	"github.com/vine-io/vine/lib/config/secrets/box"
+	"github.com/vine-io/vine/lib/config/secrets/secretbox"
+	"github.com/vine-io/vine/lib/config/source/file"
+)
+
+// keyFlags adds the flags of a key, prefixed for the new key of a rotation
+func keyFlags(flags *pflag.FlagSet, prefix, desc string) {
+	flags.String(prefix+"key", "", "Set the base64 "+desc+"secretbox key, $VINE_CONFIG_KEY by default")
+	flags.String(prefix+"key-file", "", "Read the base64 "+desc+"secretbox key from the file")
+	flags.String(prefix+"public-key", "", "Set the base64 "+desc+"box public key")
+	flags.String(prefix+"private-key", "", "Set the base64 "+desc+"box private key")
+}
+
+// newSecrets returns box secrets when a key pair is given, secretbox otherwise
+func newSecrets(flags *pflag.FlagSet, prefix string) (secrets.Secrets, error) {
+	decode := func(name, value string) ([]byte, error) {
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s: %v", name, err)
+		}
+		return b, nil
+	}
+
+	public, _ := flags.GetString(prefix + "public-key")
+	private, _ := flags.GetString(prefix + "private-key")
+	if len(public) > 0 || len(private) > 0 {
+		pub, err := decode(prefix+"public-key", public)
+		if err != nil {
+			return nil, err
+		}
+		priv, err := decode(prefix+"private-key", private)
+		if err != nil {
+			return nil, err
+		}
+		s := box.NewSecrets()
+		if err = s.Init(secrets.PublicKey(pub), secrets.PrivateKey(priv)); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	key, _ := flags.GetString(prefix + "key")
+	if path, _ := flags.GetString(prefix + "key-file"); len(key) == 0 && len(path) > 0 {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key = string(b)
+	}
+	if len(key) == 0 && len(prefix) == 0 {
+		key = os.Getenv("VINE_CONFIG_KEY")
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("no key given, set --%skey, --%skey-file or --%spublic-key and --%sprivate-key", prefix, prefix, prefix, prefix)
+	}
+	k, err := decode(prefix+"key", key)
+	if err != nil {
+		return nil, err
+	}
+	s := secretbox.NewSecrets()
+	if err = s.Init(secrets.Key(k)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// input returns the first argument or the first line of stdin
+func input(args []string) (string, error) {
+	if len(args) > 0 {
+		return args[0], nil
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && len(line) == 0 {
+		return "", fmt.Errorf("no value given: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func encryptCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:          "encrypt [value]",
+		Short:        "Encrypt a value, read from stdin without argument, and print it as ENC[...]",
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := newSecrets(cmd.Flags(), "")
+			if err != nil {
+				return err
+			}
+			value, err := input(args)
+			if err != nil {
+				return err
+			}
+			out, err := secrets.EncryptValue(s, []byte(value))
+			if err != nil {
+				return err
+			}
+			cmd.Println(out)
+			return nil
+		},
+	}
+}
+
+func decryptCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "decrypt [ENC[...]]",
+		Short:        "Decrypt a value, or the value at --path of --file, leaving the other values encrypted",
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			s, err := newSecrets(flags, "")
+			if err != nil {
+				return err
+			}
+
+			path, _ := flags.GetString("file")
+			if len(path) == 0 {
+				value, err := input(args)
+				if err != nil {
+					return err
+				}
+				out, err := secrets.DecryptValue(strings.TrimSpace(value), s)
+				if err != nil {
+					return err
+				}
+				cmd.Println(string(out))
+				return nil
+			}
+
+			key, _ := flags.GetString("path")
+			if len(key) == 0 {
+				return fmt.Errorf("--path is required with --file")
+			}
+			c := memory.NewConfig(config.WithReader(json.NewReader(reader.WithSecrets(s))))
+			defer c.Close()
+			if err = c.Load(file.NewSource(file.WithPath(path))); err != nil {
+				return err
+			}
+
+			var out interface{}
+			if err = c.Get(strings.Split(key, ".")...).Scan(&out); err != nil {
+				return err
+			}
+			if out == nil {
+				return fmt.Errorf("%s not found in %s", key, path)
+			}
+			if v, ok := out.(string); ok {
+				cmd.Println(v)
+				return nil
+			}
+			cmd.Println(string(c.Get(strings.Split(key, ".")...).Bytes()))
+			return nil
+		},
+	}
+	cmd.Flags().String("file", "", "Set the config file")
+	cmd.Flags().String("path", "", "Set the dotted path of the value in the file, e.g. db.password")
+	return cmd
+}
+
+// writeFile replaces the file at path with data without leaving it half
+// written: the data goes to a synced temporary file in the same directory
+// which is then renamed over path
+func writeFile(path string, data []byte, mode os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func rotateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "rotate-key",
+		Short:        "Encrypt the values of the files with a new key, the rest of the files is left untouched",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			old, err := newSecrets(flags, "")
+			if err != nil {
+				return err
+			}
+			current, err := newSecrets(flags, "new-")
+			if err != nil {
+				return err
+			}
+
+			paths, _ := flags.GetStringSlice("file")
+			paths = append(paths, args...)
+			if len(paths) == 0 {
+				return fmt.Errorf("no file given")
+			}
+
+			for _, path := range paths {
+				info, err := os.Stat(path)
+				if err != nil {
+					return err
+				}
+				text, err := ioutil.ReadFile(path)
+				if err != nil {
+					return err
+				}
+
+				n := 0
+				out, err := secrets.ReplaceValues(text, func(value string) (string, error) {
+					data, err := secrets.DecryptValue(value, old)
+					if err != nil {
+						return "", err
+					}
+					n++
+					return secrets.EncryptValue(current, data)
+				})
+				if err != nil {
+					return fmt.Errorf("%s: %v", path, err)
+				}
+				if err = writeFile(path, out, info.Mode()); err != nil {
+					return err
+				}
+				cmd.Printf("%s: %d values rotated\n", path, n)
+			}
+			return nil
+		},
+	}
+	keyFlags(cmd.Flags(), "new-", "new ")
+	cmd.Flags().StringSlice("file", nil, "Set the config files")
+	return cmd
+}
+
+func Commands(options ...vine.Option) []*cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the encrypted values of config files and render the effective config",
+	}
+	keyFlags(cmd.PersistentFlags(), "", "")
+
+	cmd.AddCommand(encryptCommand(), decryptCommand(), rotateCommand(), renderCommand())
+
+	return []*cobra.Command{cmd, serverCommand(options...)}
+}
@@ -0,0 +1,171 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	json "github.com/json-iterator/go"
+	"github.com/spf13/cobra"
+	"github.com/vine-io/vine/lib/config/reader"
+	jreader "github.com/vine-io/vine/lib/config/reader/json"
+	"github.com/vine-io/vine/lib/config/source"
+	"github.com/vine-io/vine/lib/config/source/env"
+	"github.com/vine-io/vine/lib/config/source/file"
+	"gopkg.in/yaml.v3"
+)
+
+// Render merges the sets like the config does and returns the effective config
+// with the source of each value, keyed by the dotted path of the value. The
+// value of a path comes from the last set which defines it.
+func Render(sets ...*source.ChangeSet) (map[string]interface{}, map[string]string, error) {
+	r := jreader.NewReader()
+	merged, err := r.Merge(sets...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if merged, err = reader.InterpolateSet(merged); err != nil {
+		return nil, nil, err
+	}
+	values := make(map[string]interface{})
+	if err = json.Unmarshal(merged.Data, &values); err != nil {
+		return nil, nil, err
+	}
+
+	sources := make(map[string]string, len(merged.Origins))
+	for path, o := range merged.Origins {
+		switch {
+		case len(o.File) > 0 && o.Line > 0:
+			sources[path] = fmt.Sprintf("%s:%d", o.File, o.Line)
+		case len(o.File) > 0:
+			sources[path] = o.File
+		default:
+			sources[path] = o.Source
+		}
+	}
+	return values, sources, nil
+}
+
+// yamlNode returns the node of the value, the values are commented with their source
+func yamlNode(path string, v interface{}, sources map[string]string) (*yaml.Node, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		n := &yaml.Node{}
+		if err := n.Encode(v); err != nil {
+			return nil, err
+		}
+		n.LineComment = sources[path]
+		return n, nil
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, k := range keys {
+		sub := k
+		if len(path) > 0 {
+			sub = path + "." + k
+		}
+		value, err := yamlNode(sub, m[k], sources)
+		if err != nil {
+			return nil, err
+		}
+		if value.Kind == yaml.MappingNode && len(value.Content) == 0 {
+			value.LineComment = sources[sub]
+		}
+		n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}, value)
+	}
+	return n, nil
+}
+
+func renderCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "render",
+		Short:        "Print the effective config merged from the files, the profile and the environment with the source of each value",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			path, _ := flags.GetString("file")
+			profile, _ := flags.GetString("profile")
+			if !flags.Changed("profile") {
+				profile = file.DefaultProfile
+			}
+
+			sets, err := file.Layers(file.WithPath(path), file.WithProfile(profile))
+			if err != nil {
+				return err
+			}
+
+			if prefix, _ := flags.GetString("env-prefix"); len(prefix) > 0 {
+				cs, err := env.NewSource(env.WithStrippedPrefix(prefix)).Read()
+				if err != nil {
+					return err
+				}
+				if string(cs.Data) != "null" {
+					cs.Source = "env " + prefix + "*"
+					sets = append(sets, cs)
+				}
+			}
+
+			values, sources, err := Render(sets...)
+			if err != nil {
+				return err
+			}
+
+			switch output, _ := flags.GetString("output"); output {
+			case "json":
+				b, err := json.MarshalIndent(map[string]interface{}{"config": values, "sources": sources}, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(b))
+			case "yaml":
+				n, err := yamlNode("", values, sources)
+				if err != nil {
+					return err
+				}
+				enc := yaml.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent(2)
+				if err = enc.Encode(n); err != nil {
+					return err
+				}
+				return enc.Close()
+			default:
+				return fmt.Errorf("unsupported output %s, use yaml or json", output)
+			}
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.String("file", "config.yaml", "Set the config file")
+	flags.String("profile", "", "Set the profile, its file e.g. config.prod.yaml is merged over the config file, $VINE_PROFILE by default")
+	flags.String("env-prefix", "", "Merge the environment variables with the prefix over the files, e.g. VINE_")
+	flags.String("output", "yaml", "Set the output format, yaml or json")
+	return cmd
+}
@@ -0,0 +1,118 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/vine-io/vine"
+	"github.com/vine-io/vine/lib/cache"
+	"github.com/vine-io/vine/lib/cache/file"
+	cs "github.com/vine-io/vine/lib/config/service"
+	log "github.com/vine-io/vine/lib/logger"
+)
+
+var (
+	// Name of the config service
+	Name = cs.DefaultName
+	// Address of the config service, a random port when empty
+	Address = ""
+)
+
+// persistent returns the cache the documents are kept in. The history and the
+// rollbacks are lost with the cache, so unless the service has been given a
+// persistent one they are kept in a file cache under dir.
+func persistent(c cache.Cache, dir string) cache.Cache {
+	switch c.String() {
+	case "memory", "noop":
+	default:
+		return c
+	}
+
+	if len(dir) == 0 {
+		dir = file.DefaultDir
+	}
+	log.Infof("The %s cache of the service does not persist, keeping the config in %s", c.String(), dir)
+	return file.NewCache(file.WithDir(dir))
+}
+
+// RunServer runs the config service, the documents are kept in the cache of
+// the service when it persists and in a file cache otherwise
+func RunServer(cmd *cobra.Command, args []string, svcOpts ...vine.Option) {
+	flags := cmd.Flags()
+	if name, _ := flags.GetString("name"); len(name) > 0 {
+		Name = name
+	}
+	if addr, _ := flags.GetString("address"); len(addr) > 0 {
+		Address = addr
+	}
+	interval, _ := flags.GetDuration("interval")
+	dir, _ := flags.GetString("dir")
+	token, _ := flags.GetString("admin-token")
+	if len(token) == 0 {
+		log.Warnf("Refusing the config updates and rollbacks, set --admin-token to allow them")
+	}
+
+	svcOpts = append(svcOpts, vine.Name(Name))
+	if len(Address) > 0 {
+		svcOpts = append(svcOpts, vine.Address(Address))
+	}
+
+	svc := vine.NewService(svcOpts...)
+
+	c := persistent(svc.Options().Cache, dir)
+	if c != svc.Options().Cache {
+		defer c.Close()
+	}
+
+	m := cs.NewManager(
+		cs.WithStore(cs.NewStore(c)),
+		cs.WithInterval(interval),
+		cs.WithAdminToken(token),
+	)
+	if err := cs.RegisterConfigHandler(svc.Server(), cs.NewHandler(m)); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := svc.Run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func serverCommand(options ...vine.Option) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "config-server",
+		Short:        "Run the config service which keeps the versions of the namespaced config",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			RunServer(cmd, args, options...)
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.String("name", Name, "Set the name of the config service")
+	flags.String("address", "", "Set the address of the config service e.g 0.0.0.0:8089")
+	flags.String("dir", "", "Set the directory of the config versions when the cache of the service does not persist, $VINE_CACHE_DIR by default")
+	flags.Duration("interval", cs.DefaultInterval, "Set the interval the watchers check for versions written by other instances, zero disables it")
+	flags.String("admin-token", "", "Set the bearer token required by the config updates and rollbacks, they are refused without one")
+	return cmd
+}
@@ -30,6 +30,7 @@ import (
 	"net"
 	"reflect"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -45,6 +46,7 @@ import (
 	"github.com/vine-io/vine/core/codec/bytes"
 	"github.com/vine-io/vine/core/registry"
 	verrs "github.com/vine-io/vine/lib/errors"
+	vctx "github.com/vine-io/vine/util/context"
 	"github.com/vine-io/vine/util/context/metadata"
 	mnet "github.com/vine-io/vine/util/net"
 )
@@ -61,6 +63,9 @@ var (
 
 	// DefaultMaxSendMsgSize maximum message that client can send (100 MB)
 	DefaultMaxSendMsgSize = 1024 * 1024 * 100
+
+	// DefaultLatencyWindow number of latency samples kept by endpoint
+	DefaultLatencyWindow = 100
 )
 
 func init() {
@@ -78,6 +83,9 @@ type grpcClient struct {
 	opts client.Options
 	pool *pool
 	once atomic.Value
+
+	// latencies of the endpoints used by hedged requests
+	latencies sync.Map
 }
 
 // secure returns the dial option for whether it's a secure or insecure connection
@@ -152,6 +160,10 @@ func (g *grpcClient) call(ctx context.Context, node *registry.Node, req client.R
 
 	// set timeout in nanoseconds
 	header["timeout"] = fmt.Sprintf("%d", opts.RequestTimeout)
+	// propagate the absolute deadline
+	if d, ok := ctx.Deadline(); ok {
+		header[strings.ToLower(vctx.DeadlineKey)] = vctx.FormatDeadline(d)
+	}
 	// set the content type for the request
 	header["x-content-type"] = req.ContentType()
 
@@ -230,6 +242,10 @@ func (g *grpcClient) stream(ctx context.Context, node *registry.Node, req client
 	if opts.StreamTimeout > time.Duration(0) {
 		header["timeout"] = fmt.Sprintf("%d", opts.StreamTimeout)
 	}
+	// propagate the absolute deadline
+	if d, ok := ctx.Deadline(); ok {
+		header[strings.ToLower(vctx.DeadlineKey)] = vctx.FormatDeadline(d)
+	}
 	// set the content type for the request
 	header["x-content-type"] = req.ContentType()
 
@@ -419,10 +435,17 @@ func (g *grpcClient) Call(ctx context.Context, req client.Request, rsp interface
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, callOpts.RequestTimeout)
 		defer cancel()
+	} else if remaining := time.Until(d); remaining <= 0 {
+		// the inherited deadline already expired, fail fast
+		return verrs.Timeout("go.vine.client", "%v", context.DeadlineExceeded)
+	} else if callOpts.RequestTimeout > 0 && callOpts.RequestTimeout < remaining {
+		// the request timeout is shorter than the inherited deadline
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, callOpts.RequestTimeout)
+		defer cancel()
 	} else {
-		// got a deadline so no need to setup context
-		// but we need to set the timeout we pass along
-		opt := client.WithRequestTimeout(time.Until(d))
+		// cap the timeout we pass along at the inherited deadline
+		opt := client.WithRequestTimeout(remaining)
 		opt(&callOpts)
 	}
 
@@ -441,21 +464,53 @@ func (g *grpcClient) Call(ctx context.Context, req client.Request, rsp interface
 		gcall = callOpts.CallWrappers[i-1](gcall)
 	}
 
+	// record the call in the retry budget
+	if callOpts.RetryBudget != nil {
+		callOpts.RetryBudget.Deposit()
+	}
+
+	// nodes used by the previous attempts, later attempts prefer other nodes
+	var mu sync.Mutex
+	used := make(map[string]struct{})
+
+	selectNode := func() (*registry.Node, error) {
+		var node *registry.Node
+		for j := 0; j < 3; j++ {
+			n, err := next()
+			if err != nil {
+				return nil, err
+			}
+			node = n
+
+			mu.Lock()
+			_, ok := used[node.Address]
+			mu.Unlock()
+			if !ok {
+				break
+			}
+		}
+
+		mu.Lock()
+		used[node.Address] = struct{}{}
+		mu.Unlock()
+		return node, nil
+	}
+
 	// return errors.Timeout("go.vine.client", "%v", ctx.Err())
-	call := func(i int) error {
+	call := func(ctx context.Context, i int, rsp interface{}) error {
 		// call backoff first. Someone may want an initial start delay
 		t, err := callOpts.Backoff(ctx, req, i)
 		if err != nil {
 			return verrs.InternalServerError("go.vine.client", err.Error())
 		}
 
-		// only sleep if greater than 0
-		if t.Seconds() > 0 {
+		// only sleep if greater than 0, hedged attempts have their own delay
+		if t.Seconds() > 0 && callOpts.Hedge == nil {
 			time.Sleep(t)
 		}
 
 		// select next node
-		node, err := next()
+		node, err := selectNode()
 		service := req.Service()
 		if err != nil {
 			if errors.Is(err, selector.ErrNotFound) {
@@ -464,9 +519,22 @@ func (g *grpcClient) Call(ctx context.Context, req client.Request, rsp interface
 			return verrs.InternalServerError("go.vine.client", "error selecting %s node: %s", service, err.Error())
 		}
 
+		// apply the timeout of the single attempt
+		opts := callOpts
+		if opts.PerTryTimeout > 0 && opts.PerTryTimeout < opts.RequestTimeout {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, opts.PerTryTimeout)
+			defer cancel()
+			opts.RequestTimeout = opts.PerTryTimeout
+		}
+
 		// make the call
-		err = gcall(ctx, node, req, rsp, callOpts)
+		start := time.Now()
+		err = gcall(ctx, node, req, rsp, opts)
 		g.opts.Selector.Mark(service, node, err)
+		if err == nil {
+			g.latency(req).Observe(time.Since(start))
+		}
 		var verr *verrs.Error
 		if errors.As(err, &verr) {
 			return verr
@@ -475,12 +543,21 @@ func (g *grpcClient) Call(ctx context.Context, req client.Request, rsp interface
 		return err
 	}
 
+	if h := callOpts.Hedge; h != nil && h.MaxAttempts > 1 && reflect.TypeOf(rsp).Kind() == reflect.Ptr {
+		return g.hedge(ctx, req, rsp, call, callOpts)
+	}
+
 	ch := make(chan error, callOpts.Retries+1)
 	var gerr error
 
 	for i := 0; i <= callOpts.Retries; i++ {
+		// retries are limited by the budget
+		if i > 0 && callOpts.RetryBudget != nil && !callOpts.RetryBudget.Withdraw() {
+			return gerr
+		}
+
 		go func(i int) {
-			ch <- call(i)
+			ch <- call(ctx, i, rsp)
 		}(i)
 
 		select {
@@ -508,6 +585,100 @@ func (g *grpcClient) Call(ctx context.Context, req client.Request, rsp interface
 	return gerr
 }
 
+// hedge sends a new attempt to another node each time the hedge delay expires
+// or an attempt fails with a retryable error, the first success wins.
+func (g *grpcClient) hedge(ctx context.Context, req client.Request, rsp interface{}, call func(context.Context, int, interface{}) error, callOpts client.CallOptions) error {
+	policy := callOpts.Hedge
+
+	delay := policy.Delay
+	if policy.Percentile > 0 {
+		if d, ok := g.latency(req).Percentile(policy.Percentile); ok {
+			delay = d
+		}
+	}
+	if delay <= 0 {
+		delay = callOpts.RequestTimeout
+	}
+
+	// cancel the attempts still in flight once done
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		rsp interface{}
+		err error
+	}
+
+	ch := make(chan result, policy.MaxAttempts)
+	send := func(i int) {
+		// every attempt decodes in its own response
+		r := reflect.New(reflect.TypeOf(rsp).Elem()).Interface()
+		go func() {
+			ch <- result{r, call(ctx, i, r)}
+		}()
+	}
+
+	canSend := func(sent int) bool {
+		if sent >= policy.MaxAttempts {
+			return false
+		}
+		return callOpts.RetryBudget == nil || callOpts.RetryBudget.Withdraw()
+	}
+
+	send(0)
+	sent, done := 1, 0
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var gerr error
+	for {
+		select {
+		case <-ctx.Done():
+			return verrs.Timeout("go.vine.client", "%v", ctx.Err())
+		case <-timer.C:
+			if canSend(sent) {
+				send(sent)
+				sent++
+				timer.Reset(delay)
+			}
+		case r := <-ch:
+			done++
+			if r.err == nil {
+				reflect.ValueOf(rsp).Elem().Set(reflect.ValueOf(r.rsp).Elem())
+				return nil
+			}
+
+			retry, rerr := callOpts.Retry(ctx, req, done-1, r.err)
+			if rerr != nil {
+				return rerr
+			}
+
+			if !retry {
+				return r.err
+			}
+
+			gerr = r.err
+			if canSend(sent) {
+				send(sent)
+				sent++
+			} else if done == sent {
+				return gerr
+			}
+		}
+	}
+}
+
+// latency returns the latency window of the endpoint
+func (g *grpcClient) latency(req client.Request) *client.LatencyWindow {
+	key := req.Service() + "." + req.Endpoint()
+	if v, ok := g.latencies.Load(key); ok {
+		return v.(*client.LatencyWindow)
+	}
+	v, _ := g.latencies.LoadOrStore(key, client.NewLatencyWindow(DefaultLatencyWindow))
+	return v.(*client.LatencyWindow)
+}
+
 func (g *grpcClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
 	// make a copy of call opts
 	callOpts := g.opts.CallOptions
@@ -521,6 +692,16 @@ func (g *grpcClient) Stream(ctx context.Context, req client.Request, opts ...cli
 	}
 
 	// #200 - streams shouldn't have a request timeout set on the context
+	// but they must honour the inherited deadline
+	if d, ok := ctx.Deadline(); ok {
+		remaining := time.Until(d)
+		if remaining <= 0 {
+			return nil, verrs.Timeout("go.vine.client", "%v", context.DeadlineExceeded)
+		}
+		if callOpts.StreamTimeout <= 0 || remaining < callOpts.StreamTimeout {
+			callOpts.StreamTimeout = remaining
+		}
+	}
 
 	// should we noop right here?
 	select {
@@ -560,7 +741,7 @@ func (g *grpcClient) Stream(ctx context.Context, req client.Request, opts ...cli
 
 		// make the call
 		stream := &grpcStream{}
-		err = g.stream(ctx, node, req, stream, callOpts)
+		err = gstream(ctx, node, req, stream, callOpts)
 
 		g.opts.Selector.Mark(service, node, err)
 		return stream, err
@@ -586,7 +767,12 @@ func (g *grpcClient) Stream(ctx context.Context, req client.Request, opts ...cli
 		case rsp := <-ch:
 			// if the call succeeded lets bail early
 			if rsp.err == nil {
-				return rsp.stream, nil
+				stream := rsp.stream
+				// wrap the stream in reverse so the first wrapper sees messages first
+				for i := len(callOpts.StreamWrappers); i > 0; i-- {
+					stream = callOpts.StreamWrappers[i-1](stream)
+				}
+				return stream, nil
 			}
 
 			retry, rerr := callOpts.Retry(ctx, req, i, err)
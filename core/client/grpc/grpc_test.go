@@ -0,0 +1,295 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpc
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	membroker "github.com/vine-io/vine/core/broker/memory"
+	"github.com/vine-io/vine/core/client"
+	"github.com/vine-io/vine/core/client/selector"
+	"github.com/vine-io/vine/core/registry"
+	regMemory "github.com/vine-io/vine/core/registry/memory"
+	"github.com/vine-io/vine/core/server"
+	sgrpc "github.com/vine-io/vine/core/server/grpc"
+	"github.com/vine-io/vine/lib/api"
+	"github.com/vine-io/vine/lib/errors"
+)
+
+type Test struct {
+	delay  time.Duration
+	fail   bool
+	calls  int32
+	active int32
+}
+
+func (h *Test) Echo(ctx context.Context, req *api.Pair, rsp *api.Pair) error {
+	atomic.AddInt32(&h.active, 1)
+	defer atomic.AddInt32(&h.active, -1)
+	atomic.AddInt32(&h.calls, 1)
+	if h.fail {
+		return errors.New("test", "unavailable", 503)
+	}
+	select {
+	case <-time.After(h.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	rsp.Key = req.Key
+	return nil
+}
+
+func (h *Test) Stream(ctx context.Context, stream server.Stream) error {
+	for {
+		req := &api.Pair{}
+		if err := stream.Recv(req); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := stream.Send(&api.Pair{Key: req.Key}); err != nil {
+			return err
+		}
+	}
+}
+
+func newServer(t *testing.T, r registry.Registry, id string, h *Test, opts ...server.Option) server.Server {
+	s := sgrpc.NewServer(append([]server.Option{
+		server.Name("test"),
+		server.Id(id),
+		server.Address("127.0.0.1:0"),
+		server.Registry(r),
+		server.Broker(membroker.NewBroker()),
+	}, opts...)...)
+	if err := s.Handle(s.NewHandler(h)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// ordered selects the servers in turn, starting with the first one
+func ordered(servers ...server.Server) client.CallOption {
+	return client.WithSelectOption(selector.WithStrategy(func(_ []*registry.Service) selector.Next {
+		var mu sync.Mutex
+		var i int
+		return func() (*registry.Node, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			s := servers[i%len(servers)]
+			i++
+			return &registry.Node{Id: s.Options().Id, Address: s.Options().Address}, nil
+		}
+	}))
+}
+
+func TestCallPolicies(t *testing.T) {
+	r := regMemory.NewRegistry()
+
+	slow := &Test{delay: time.Second}
+	ss := newServer(t, r, "slow", slow)
+	defer ss.Stop()
+	fast := &Test{}
+	fs := newServer(t, r, "fast", fast)
+	defer fs.Stop()
+	failing := &Test{fail: true}
+	es := newServer(t, r, "failing", failing)
+	defer es.Stop()
+
+	c := NewClient(client.Registry(r))
+	call := func(opts ...client.CallOption) (time.Duration, error) {
+		start := time.Now()
+		rsp := &api.Pair{}
+		err := c.Call(context.TODO(), c.NewRequest("test", "Test.Echo", &api.Pair{Key: "vine"}), rsp, opts...)
+		if err == nil {
+			assert.Equal(t, "vine", rsp.Key)
+		}
+		return time.Since(start), err
+	}
+	// settled waits for the calls to the slow server, the cancelled ones must
+	// have returned before the server is stopped
+	settled := func(t *testing.T, calls int32) {
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt32(&slow.calls) == calls && atomic.LoadInt32(&slow.active) == 0
+		}, time.Second*2, time.Millisecond*10)
+	}
+	reset := func() {
+		for _, h := range []*Test{slow, fast, failing} {
+			atomic.StoreInt32(&h.calls, 0)
+		}
+	}
+
+	t.Run("hedge", func(t *testing.T) {
+		reset()
+		d, err := call(ordered(ss, fs), client.WithHedge(&client.HedgePolicy{Delay: time.Millisecond * 20, MaxAttempts: 2}))
+		assert.Nil(t, err)
+		assert.True(t, d < time.Millisecond*500, d)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&fast.calls))
+		settled(t, 1)
+	})
+
+	t.Run("hedge after a failure", func(t *testing.T) {
+		reset()
+		d, err := call(ordered(es, fs), client.WithRetry(client.RetryOnError),
+			client.WithHedge(&client.HedgePolicy{Delay: time.Second, MaxAttempts: 2}))
+		assert.Nil(t, err)
+		assert.True(t, d < time.Millisecond*500, d)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&failing.calls))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&fast.calls))
+	})
+
+	t.Run("per try timeout", func(t *testing.T) {
+		reset()
+		d, err := call(ordered(ss), client.WithRetries(0), client.WithPerTryTimeout(time.Millisecond*50))
+		assert.NotNil(t, err)
+		assert.True(t, d < time.Millisecond*500, d)
+
+		// the next attempt is sent once the first one timed out
+		d, err = call(ordered(ss, fs), client.WithRetries(1), client.WithRetry(client.RetryAlways),
+			client.WithPerTryTimeout(time.Millisecond*50))
+		assert.Nil(t, err)
+		assert.True(t, d < time.Millisecond*500, d)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&fast.calls))
+		settled(t, 2)
+	})
+
+	t.Run("retry budget", func(t *testing.T) {
+		reset()
+		_, err := call(ordered(es), client.WithRetries(3), client.WithRetry(client.RetryAlways))
+		assert.NotNil(t, err)
+		assert.Equal(t, int32(4), atomic.LoadInt32(&failing.calls))
+
+		// an empty budget allows no retries
+		reset()
+		_, err = call(ordered(es), client.WithRetries(3), client.WithRetry(client.RetryAlways),
+			client.WithRetryBudget(client.NewRetryBudget(0, 0)))
+		assert.NotNil(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&failing.calls))
+
+		// nor hedged attempts
+		reset()
+		_, err = call(ordered(es, fs), client.WithRetry(client.RetryAlways),
+			client.WithHedge(&client.HedgePolicy{Delay: time.Millisecond * 20, MaxAttempts: 2}),
+			client.WithRetryBudget(client.NewRetryBudget(0, 0)))
+		assert.NotNil(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&failing.calls))
+		assert.Equal(t, int32(0), atomic.LoadInt32(&fast.calls))
+	})
+
+	t.Run("latency percentile", func(t *testing.T) {
+		reset()
+		// the observed latency of the fast server is the hedge delay
+		for i := 0; i < 10; i++ {
+			_, err := call(ordered(fs))
+			assert.Nil(t, err)
+		}
+		d, err := call(ordered(ss, fs), client.WithHedge(&client.HedgePolicy{Delay: time.Second * 5, Percentile: 0.9, MaxAttempts: 2}))
+		assert.Nil(t, err)
+		assert.True(t, d < time.Millisecond*500, d)
+		settled(t, 1)
+	})
+}
+
+// tagServerStream appends its tag to the keys it sends and receives
+type tagServerStream struct {
+	server.Stream
+	tag string
+}
+
+func (s *tagServerStream) Send(m interface{}) error {
+	m.(*api.Pair).Key += "." + s.tag
+	return s.Stream.Send(m)
+}
+
+func (s *tagServerStream) Recv(m interface{}) error {
+	if err := s.Stream.Recv(m); err != nil {
+		return err
+	}
+	m.(*api.Pair).Key += "." + s.tag
+	return nil
+}
+
+// tagClientStream appends its tag to the keys it sends and receives
+type tagClientStream struct {
+	client.Stream
+	tag string
+}
+
+func (s *tagClientStream) Send(m interface{}) error {
+	m.(*api.Pair).Key += "." + s.tag
+	return s.Stream.Send(m)
+}
+
+func (s *tagClientStream) Recv(m interface{}) error {
+	if err := s.Stream.Recv(m); err != nil {
+		return err
+	}
+	m.(*api.Pair).Key += "." + s.tag
+	return nil
+}
+
+func TestStreamWrappers(t *testing.T) {
+	r := regMemory.NewRegistry()
+	tagServer := func(tag string) server.Option {
+		return server.WrapStream(func(st server.Stream) server.Stream {
+			return &tagServerStream{Stream: st, tag: tag}
+		})
+	}
+	s := newServer(t, r, "test", &Test{}, tagServer("s1"), tagServer("s2"))
+	defer s.Stop()
+
+	tagClient := func(tag string) client.StreamWrapper {
+		return func(st client.Stream) client.Stream {
+			return &tagClientStream{Stream: st, tag: tag}
+		}
+	}
+	c := NewClient(client.Registry(r), client.WrapStream(tagClient("c1"), tagClient("c2")))
+
+	stream, err := c.Stream(context.TODO(), c.NewRequest("test", "Test.Stream", &api.Pair{}))
+	if !assert.Nil(t, err) {
+		return
+	}
+	// the first wrapper sees the messages sent first and the ones received last
+	for _, key := range []string{"a", "b"} {
+		if !assert.Nil(t, stream.Send(&api.Pair{Key: key})) {
+			return
+		}
+		out := &api.Pair{}
+		if assert.Nil(t, stream.Recv(out)) {
+			assert.Equal(t, key+".c1.c2.s2.s1.s1.s2.c2.c1", out.Key)
+		}
+	}
+	assert.Nil(t, stream.CloseSend())
+	assert.Equal(t, io.EOF, stream.Recv(&api.Pair{}))
+	_ = stream.Close()
+}
@@ -57,6 +57,16 @@ func (r *response) Header() map[string]string {
 	return hdr
 }
 
+// Trailer reads the trailer, it is only set once the stream has ended
+func (r *response) Trailer() map[string]string {
+	md := r.stream.Trailer()
+	tr := make(map[string]string, len(md))
+	for k, v := range md {
+		tr[k] = strings.Join(v, ",")
+	}
+	return tr
+}
+
 // Read the undecoded response
 func (r *response) Read() ([]byte, error) {
 	f := &bytes.Frame{}
@@ -0,0 +1,144 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package client
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HedgePolicy describes how hedged requests are sent. When the previous attempt
+// hasn't completed after the hedge delay, a new attempt is sent to another node
+// and the first successful response wins.
+type HedgePolicy struct {
+	// Delay before sending the next attempt
+	Delay time.Duration
+	// Percentile of the observed latency (e.g. 0.95) used as the delay
+	// once enough calls have been seen. Delay is used until then.
+	Percentile float64
+	// MaxAttempts is the number of attempts in flight, including the first
+	MaxAttempts int
+}
+
+// RetryBudget limits the retries and hedged attempts to a ratio of the calls
+// made, so that retries can't amplify an outage. The budget is shared by all
+// the calls using it.
+type RetryBudget struct {
+	sync.Mutex
+	ratio   float64
+	min     float64
+	max     float64
+	balance float64
+	last    time.Time
+}
+
+// NewRetryBudget creates a RetryBudget, every call deposits ratio to the budget
+// and every retry withdraws 1 from it. minPerSecond retries are always allowed.
+func NewRetryBudget(ratio float64, minPerSecond int) *RetryBudget {
+	max := math.Max(10, float64(minPerSecond)*10)
+	return &RetryBudget{
+		ratio:   ratio,
+		min:     float64(minPerSecond),
+		max:     max,
+		balance: float64(minPerSecond),
+		last:    time.Now(),
+	}
+}
+
+func (b *RetryBudget) refill() {
+	now := time.Now()
+	b.balance = math.Min(b.max, b.balance+now.Sub(b.last).Seconds()*b.min)
+	b.last = now
+}
+
+// Deposit records a new call
+func (b *RetryBudget) Deposit() {
+	b.Lock()
+	defer b.Unlock()
+	b.refill()
+	b.balance = math.Min(b.max, b.balance+b.ratio)
+}
+
+// Withdraw reports whether a retry is allowed and records it
+func (b *RetryBudget) Withdraw() bool {
+	b.Lock()
+	defer b.Unlock()
+	b.refill()
+	if b.balance < 1 {
+		return false
+	}
+	b.balance--
+	return true
+}
+
+// LatencyWindow keeps the latency of the last calls to compute percentiles
+type LatencyWindow struct {
+	sync.Mutex
+	samples []time.Duration
+	next    int
+	full    bool
+}
+
+// NewLatencyWindow creates a LatencyWindow holding size samples
+func NewLatencyWindow(size int) *LatencyWindow {
+	return &LatencyWindow{samples: make([]time.Duration, size)}
+}
+
+// Observe adds a sample to the window
+func (w *LatencyWindow) Observe(d time.Duration) {
+	w.Lock()
+	defer w.Unlock()
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % len(w.samples)
+	if w.next == 0 {
+		w.full = true
+	}
+}
+
+// Percentile returns the given percentile of the samples, false if there are too few samples
+func (w *LatencyWindow) Percentile(p float64) (time.Duration, bool) {
+	w.Lock()
+	n := w.next
+	if w.full {
+		n = len(w.samples)
+	}
+	if n < 10 {
+		w.Unlock()
+		return 0, false
+	}
+	samples := make([]time.Duration, n)
+	copy(samples, w.samples[:n])
+	w.Unlock()
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	idx := int(math.Ceil(p*float64(n))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= n {
+		idx = n - 1
+	}
+	return samples[idx], true
+}
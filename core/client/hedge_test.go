@@ -0,0 +1,94 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBudget(t *testing.T) {
+	// every call deposits half a retry
+	b := NewRetryBudget(0.5, 0)
+	assert.False(t, b.Withdraw())
+
+	b.Deposit()
+	assert.False(t, b.Withdraw())
+	b.Deposit()
+	assert.True(t, b.Withdraw())
+	assert.False(t, b.Withdraw())
+
+	// the balance is capped
+	b = NewRetryBudget(1, 0)
+	for i := 0; i < 20; i++ {
+		b.Deposit()
+	}
+	for i := 0; i < 10; i++ {
+		assert.True(t, b.Withdraw())
+	}
+	assert.False(t, b.Withdraw())
+
+	// the minimum is refilled over time
+	b = NewRetryBudget(0, 100)
+	for b.Withdraw() {
+	}
+	time.Sleep(time.Millisecond * 50)
+	assert.True(t, b.Withdraw())
+}
+
+func TestLatencyWindow(t *testing.T) {
+	w := NewLatencyWindow(100)
+	for i := 1; i < 10; i++ {
+		w.Observe(time.Duration(i) * time.Millisecond)
+	}
+	// too few samples
+	_, ok := w.Percentile(0.5)
+	assert.False(t, ok)
+
+	for i := 10; i <= 100; i++ {
+		w.Observe(time.Duration(i) * time.Millisecond)
+	}
+	for p, want := range map[float64]time.Duration{
+		0:    time.Millisecond,
+		0.5:  time.Millisecond * 50,
+		0.95: time.Millisecond * 95,
+		1:    time.Millisecond * 100,
+	} {
+		d, ok := w.Percentile(p)
+		if assert.True(t, ok) {
+			assert.Equal(t, want, d, p)
+		}
+	}
+
+	// the oldest samples are replaced
+	w = NewLatencyWindow(10)
+	for i := 1; i <= 20; i++ {
+		w.Observe(time.Duration(i) * time.Millisecond)
+	}
+	d, ok := w.Percentile(0.5)
+	if assert.True(t, ok) {
+		assert.Equal(t, time.Millisecond*15, d)
+	}
+}
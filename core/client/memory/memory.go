@@ -0,0 +1,369 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package memory provides a client calling the services served in process by
+// the memory server directly through their router, skipping the network.
+// Requests to other services go through the fallback client.
+package memory
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/vine-io/vine/core/broker"
+	"github.com/vine-io/vine/core/client"
+	"github.com/vine-io/vine/core/codec/bytes"
+	"github.com/vine-io/vine/core/registry"
+	"github.com/vine-io/vine/core/server"
+	smemory "github.com/vine-io/vine/core/server/memory"
+	verrs "github.com/vine-io/vine/lib/errors"
+	"github.com/vine-io/vine/util/context/metadata"
+)
+
+type memoryClient struct {
+	opts client.Options
+	once atomic.Value
+}
+
+func newClient(opts ...client.Option) client.Client {
+	options := client.NewOptions()
+	options.ContentType = "application/json"
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	rc := &memoryClient{opts: options}
+	rc.once.Store(false)
+
+	c := client.Client(rc)
+
+	// wrap in reverse
+	for i := len(options.Wrappers); i > 0; i-- {
+		c = options.Wrappers[i-1](c)
+	}
+
+	return c
+}
+
+func (m *memoryClient) fallback() client.Client {
+	if m.opts.Context == nil {
+		return nil
+	}
+	c, _ := m.opts.Context.Value(fallbackKey{}).(client.Client)
+	return c
+}
+
+func (m *memoryClient) roundTrip() bool {
+	if m.opts.Context == nil {
+		return false
+	}
+	v, _ := m.opts.Context.Value(roundTripKey{}).(bool)
+	return v
+}
+
+// copier returns the function handing values over between the client and the server
+func (m *memoryClient) copier(contentType string) func(dst, src interface{}) error {
+	if m.roundTrip() {
+		return func(dst, src interface{}) error {
+			return smemory.RoundTrip(contentType, dst, src)
+		}
+	}
+	return func(dst, src interface{}) error {
+		return smemory.Copy(contentType, dst, src)
+	}
+}
+
+// next returns the in-process node of the service. The nodes of the registry
+// served in process are preferred, the services started but not yet registered
+// are used next.
+func (m *memoryClient) next(ctx context.Context, req client.Request, opts client.CallOptions) (*registry.Node, bool) {
+	if len(opts.Address) > 0 {
+		for _, addr := range opts.Address {
+			if _, ok := smemory.Lookup(addr); ok {
+				return &registry.Node{Address: addr}, true
+			}
+		}
+		return nil, false
+	}
+
+	if m.opts.Registry != nil {
+		services, _ := m.opts.Registry.GetService(ctx, req.Service())
+		for _, svc := range services {
+			for _, node := range svc.Nodes {
+				if _, ok := smemory.Lookup(node.Address); ok {
+					return node, true
+				}
+			}
+		}
+	}
+
+	for _, addr := range smemory.Addresses(req.Service()) {
+		return &registry.Node{Address: addr}, true
+	}
+
+	return nil, false
+}
+
+func (m *memoryClient) call(ctx context.Context, node *registry.Node, req client.Request, rsp interface{}, opts client.CallOptions) error {
+	router, ok := smemory.Lookup(node.Address)
+	if !ok {
+		return verrs.InternalServerError("go.vine.client", "service %s: %s not found", req.Service(), node.Address)
+	}
+
+	copier := m.copier(req.ContentType())
+
+	body := req.Body()
+	if m.roundTrip() {
+		b := &bytes.Frame{}
+		if err := smemory.RoundTrip(req.ContentType(), b, body); err != nil {
+			return verrs.InternalServerError("go.vine.client", err.Error())
+		}
+		body = b
+	}
+
+	header := make(map[string]string)
+	if md, ok := metadata.FromContext(ctx); ok {
+		for k, v := range md {
+			header[k] = v
+		}
+	}
+
+	// the reply is only handed to rsp once the handler returned in time, a
+	// handler which outlives the call never writes to it
+	var reply interface{}
+	sreq := &serverRequest{request: req, header: header, body: body, codec: &funcCodec{}}
+	srsp := &serverResponse{
+		header: make(map[string]string),
+		codec: &funcCodec{write: func(v interface{}) error {
+			reply = v
+			return nil
+		}},
+	}
+
+	ch := make(chan error, 1)
+	go func() {
+		ch <- router.ServeRequest(ctx, sreq, srsp)
+	}()
+
+	select {
+	case err := <-ch:
+		if err != nil {
+			return vineError(err)
+		}
+		if err := copier(rsp, reply); err != nil {
+			return verrs.InternalServerError("go.vine.client", err.Error())
+		}
+		return nil
+	case <-ctx.Done():
+		return verrs.Timeout("go.vine.client", "%v", ctx.Err())
+	}
+}
+
+func (m *memoryClient) Init(opts ...client.Option) error {
+	for _, o := range opts {
+		o(&m.opts)
+	}
+	return nil
+}
+
+func (m *memoryClient) Options() client.Options {
+	return m.opts
+}
+
+func (m *memoryClient) NewMessage(topic string, msg interface{}, opts ...client.MessageOption) client.Message {
+	return newMessage(topic, msg, m.opts.ContentType, opts...)
+}
+
+func (m *memoryClient) NewRequest(service, method string, req interface{}, reqOpts ...client.RequestOption) client.Request {
+	return newRequest(service, method, req, m.opts.ContentType, reqOpts...)
+}
+
+func (m *memoryClient) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) error {
+	if req == nil {
+		return verrs.InternalServerError("go.vine.client", "req is nil")
+	} else if rsp == nil {
+		return verrs.InternalServerError("go.vine.client", "rsp is nil")
+	}
+
+	// make a copy of call opts
+	callOpts := m.opts.CallOptions
+	for _, opt := range opts {
+		opt(&callOpts)
+	}
+
+	node, ok := m.next(ctx, req, callOpts)
+	if !ok {
+		if fc := m.fallback(); fc != nil {
+			return fc.Call(ctx, req, rsp, opts...)
+		}
+		return verrs.InternalServerError("go.vine.client", "service %s: not found", req.Service())
+	}
+
+	// check if we already have a deadline
+	d, ok := ctx.Deadline()
+	if !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, callOpts.RequestTimeout)
+		defer cancel()
+	} else if remaining := time.Until(d); remaining <= 0 {
+		return verrs.Timeout("go.vine.client", "%v", context.DeadlineExceeded)
+	} else if callOpts.RequestTimeout > 0 && callOpts.RequestTimeout < remaining {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, callOpts.RequestTimeout)
+		defer cancel()
+	}
+
+	// make copy of call method
+	mcall := m.call
+
+	// wrap the call in reverse
+	for i := len(callOpts.CallWrappers); i > 0; i-- {
+		mcall = callOpts.CallWrappers[i-1](mcall)
+	}
+
+	return mcall(ctx, node, req, rsp, callOpts)
+}
+
+func (m *memoryClient) Stream(ctx context.Context, req client.Request, opts ...client.CallOption) (client.Stream, error) {
+	// make a copy of call opts
+	callOpts := m.opts.CallOptions
+	for _, opt := range opts {
+		opt(&callOpts)
+	}
+
+	node, ok := m.next(ctx, req, callOpts)
+	if !ok {
+		if fc := m.fallback(); fc != nil {
+			return fc.Stream(ctx, req, opts...)
+		}
+		return nil, verrs.InternalServerError("go.vine.client", "service %s: not found", req.Service())
+	}
+
+	router, ok := smemory.Lookup(node.Address)
+	if !ok {
+		return nil, verrs.InternalServerError("go.vine.client", "service %s: %s not found", req.Service(), node.Address)
+	}
+
+	// streams shouldn't have a request timeout set on the context but they
+	// must honour the inherited deadline
+	if d, ok := ctx.Deadline(); ok {
+		remaining := time.Until(d)
+		if remaining <= 0 {
+			return nil, verrs.Timeout("go.vine.client", "%v", context.DeadlineExceeded)
+		}
+		if callOpts.StreamTimeout <= 0 || remaining < callOpts.StreamTimeout {
+			callOpts.StreamTimeout = remaining
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, verrs.Timeout("go.vine.client", "%v", err)
+	}
+
+	header := make(map[string]string)
+	if md, ok := metadata.FromContext(ctx); ok {
+		for k, v := range md {
+			header[k] = v
+		}
+	}
+
+	stream := newStream(ctx, req, m.copier(req.ContentType()), callOpts.StreamTimeout)
+	sc := stream.serverCodec()
+	sreq := &serverRequest{request: req, header: header, codec: sc}
+	srsp := &serverResponse{header: make(map[string]string), codec: sc}
+
+	go func() {
+		stream.finish(vineError(router.ServeRequest(stream.Context(), sreq, srsp)))
+	}()
+
+	var cs client.Stream = stream
+	// wrap the stream in reverse so the first wrapper sees messages first
+	for i := len(callOpts.StreamWrappers); i > 0; i-- {
+		cs = callOpts.StreamWrappers[i-1](cs)
+	}
+
+	return cs, nil
+}
+
+func (m *memoryClient) Publish(ctx context.Context, p client.Message, opts ...client.PublishOption) error {
+	var options client.PublishOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	md, ok := metadata.FromContext(ctx)
+	if !ok {
+		md = make(map[string]string)
+	}
+	md["Content-Type"] = p.ContentType()
+	md["Vine-Topic"] = p.Topic()
+
+	var body []byte
+	if err := smemory.RoundTrip(p.ContentType(), &body, p.Payload()); err != nil {
+		return verrs.InternalServerError("go.vine.client", err.Error())
+	}
+
+	if !m.once.Load().(bool) {
+		if err := m.opts.Broker.Connect(); err != nil {
+			return verrs.InternalServerError("go.vine.client", err.Error())
+		}
+		m.once.Store(true)
+	}
+
+	topic := p.Topic()
+
+	// get the exchange
+	if len(options.Exchange) > 0 {
+		topic = options.Exchange
+	}
+
+	return m.opts.Broker.Publish(ctx, topic, &broker.Message{
+		Header: md,
+		Body:   body,
+	})
+}
+
+func (m *memoryClient) String() string {
+	return "memory"
+}
+
+func vineError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var verr *verrs.Error
+	if errors.As(err, &verr) {
+		return verr
+	}
+	return verrs.InternalServerError("go.vine.client", err.Error())
+}
+
+// NewClient returns a new in-process client
+func NewClient(opts ...client.Option) client.Client {
+	return newClient(opts...)
+}
+
+var _ server.Request = (*serverRequest)(nil)
+var _ server.Response = (*serverResponse)(nil)
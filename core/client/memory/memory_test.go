@@ -0,0 +1,279 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package memory
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	membroker "github.com/vine-io/vine/core/broker/memory"
+	"github.com/vine-io/vine/core/client"
+	regMemory "github.com/vine-io/vine/core/registry/memory"
+	"github.com/vine-io/vine/core/server"
+	smemory "github.com/vine-io/vine/core/server/memory"
+	"github.com/vine-io/vine/lib/errors"
+)
+
+type Request struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+type Response struct {
+	Msg string `json:"msg"`
+}
+
+type Greeter struct{}
+
+func (g *Greeter) Hello(ctx context.Context, req *Request, rsp *Response) error {
+	if req.Name == "" {
+		return errors.BadRequest("greeter", "name is empty")
+	}
+	rsp.Msg = "hello " + req.Name
+	return nil
+}
+
+// Late answers after the deadline of the caller
+func (g *Greeter) Late(ctx context.Context, req *Request, rsp *Response) error {
+	<-ctx.Done()
+	time.Sleep(time.Millisecond * 20)
+	rsp.Msg = "late"
+	return nil
+}
+
+// Mutate changes the request it was given
+func (g *Greeter) Mutate(ctx context.Context, req *Request, rsp *Response) error {
+	for i := range req.Tags {
+		req.Tags[i] = "mutated"
+	}
+	rsp.Msg = strings.Join(req.Tags, ",")
+	return nil
+}
+
+func (g *Greeter) Echo(ctx context.Context, stream server.Stream) error {
+	for {
+		req := &Request{}
+		if err := stream.Recv(req); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := stream.Send(&Response{Msg: req.Name}); err != nil {
+			return err
+		}
+	}
+}
+
+func TestMemoryClient(t *testing.T) {
+	r := regMemory.NewRegistry()
+	b := membroker.NewBroker()
+	s := smemory.NewServer(server.Name("greeter"), server.Registry(r), server.Broker(b))
+	if err := s.Handle(s.NewHandler(&Greeter{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	for _, roundTrip := range []bool{false, true} {
+		opts := []client.Option{client.Registry(r)}
+		if roundTrip {
+			opts = append(opts, RoundTrip())
+		}
+		c := NewClient(opts...)
+
+		rsp := &Response{}
+		err := c.Call(context.TODO(), c.NewRequest("greeter", "Greeter.Hello", &Request{Name: "vine"}), rsp)
+		if assert.Nil(t, err) {
+			assert.Equal(t, "hello vine", rsp.Msg)
+		}
+
+		err = c.Call(context.TODO(), c.NewRequest("greeter", "Greeter.Hello", &Request{}), rsp)
+		assert.Equal(t, errors.StatusBadRequest, errors.FromErr(err).Code)
+
+		stream, err := c.Stream(context.TODO(), c.NewRequest("greeter", "Greeter.Echo", &Request{}))
+		if !assert.Nil(t, err) {
+			continue
+		}
+		for _, name := range []string{"a", "b"} {
+			assert.Nil(t, stream.Send(&Request{Name: name}))
+			out := &Response{}
+			assert.Nil(t, stream.Recv(out))
+			assert.Equal(t, name, out.Msg)
+		}
+		assert.Nil(t, stream.CloseSend())
+		assert.Equal(t, io.EOF, stream.Recv(&Response{}))
+		_ = stream.Close()
+	}
+
+	// services which aren't served in process
+	c := NewClient()
+	err := c.Call(context.TODO(), c.NewRequest("unknown", "Greeter.Hello", &Request{}), &Response{})
+	assert.NotNil(t, err)
+}
+
+type upperStream struct {
+	server.Stream
+}
+
+func (s *upperStream) Send(m interface{}) error {
+	if rsp, ok := m.(*Response); ok {
+		rsp.Msg = strings.ToUpper(rsp.Msg)
+	}
+	return s.Stream.Send(m)
+}
+
+type countStream struct {
+	client.Stream
+	sent, recv *int32
+}
+
+func (s *countStream) Send(m interface{}) error {
+	atomic.AddInt32(s.sent, 1)
+	return s.Stream.Send(m)
+}
+
+func (s *countStream) Recv(m interface{}) error {
+	err := s.Stream.Recv(m)
+	if err == nil {
+		atomic.AddInt32(s.recv, 1)
+	}
+	return err
+}
+
+func TestCallIsolation(t *testing.T) {
+	r := regMemory.NewRegistry()
+	b := membroker.NewBroker()
+	s := smemory.NewServer(server.Name("greeter"), server.Registry(r), server.Broker(b))
+	if err := s.Handle(s.NewHandler(&Greeter{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	c := NewClient(client.Registry(r))
+
+	// a handler outliving the call never writes to the response
+	rsp := &Response{}
+	err := c.Call(context.TODO(), c.NewRequest("greeter", "Greeter.Late", &Request{}), rsp, client.WithRequestTimeout(time.Millisecond*20))
+	assert.Equal(t, errors.StatusTimeout, errors.FromErr(err).Code)
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, "", rsp.Msg)
+
+	// the handler doesn't share memory with the request of the caller
+	req := &Request{Tags: []string{"a", "b"}}
+	rsp = &Response{}
+	if assert.Nil(t, c.Call(context.TODO(), c.NewRequest("greeter", "Greeter.Mutate", req), rsp)) {
+		assert.Equal(t, "mutated,mutated", rsp.Msg)
+	}
+	assert.Equal(t, []string{"a", "b"}, req.Tags)
+}
+
+func TestStreamDeadline(t *testing.T) {
+	r := regMemory.NewRegistry()
+	b := membroker.NewBroker()
+	s := smemory.NewServer(server.Name("greeter"), server.Registry(r), server.Broker(b))
+	if err := s.Handle(s.NewHandler(&Greeter{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	c := NewClient(client.Registry(r))
+	req := c.NewRequest("greeter", "Greeter.Echo", &Request{})
+
+	// an expired deadline fails the stream right away
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err := c.Stream(ctx, req)
+	assert.Equal(t, errors.StatusTimeout, errors.FromErr(err).Code)
+
+	// the inherited deadline ends the stream
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	stream, err := c.Stream(ctx, req)
+	if assert.Nil(t, err) {
+		assert.Equal(t, errors.StatusTimeout, errors.FromErr(stream.Recv(&Response{})).Code)
+		_ = stream.Close()
+	}
+
+	// as does the stream timeout
+	stream, err = c.Stream(context.Background(), req, client.WithStreamTimeout(time.Millisecond*50))
+	if assert.Nil(t, err) {
+		assert.Equal(t, errors.StatusTimeout, errors.FromErr(stream.Recv(&Response{})).Code)
+		_ = stream.Close()
+	}
+}
+
+func TestStreamWrappers(t *testing.T) {
+	r := regMemory.NewRegistry()
+	s := smemory.NewServer(
+		server.Name("greeter"),
+		server.Registry(r),
+		server.Broker(membroker.NewBroker()),
+		server.WrapStream(func(st server.Stream) server.Stream {
+			return &upperStream{st}
+		}),
+	)
+	if err := s.Handle(s.NewHandler(&Greeter{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	var sent, recv int32
+	c := NewClient(client.Registry(r), client.WrapStream(func(st client.Stream) client.Stream {
+		return &countStream{Stream: st, sent: &sent, recv: &recv}
+	}))
+
+	stream, err := c.Stream(context.TODO(), c.NewRequest("greeter", "Greeter.Echo", &Request{}))
+	if !assert.Nil(t, err) {
+		return
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		assert.Nil(t, stream.Send(&Request{Name: name}))
+		out := &Response{}
+		assert.Nil(t, stream.Recv(out))
+		assert.Equal(t, strings.ToUpper(name), out.Msg)
+	}
+	assert.Nil(t, stream.CloseSend())
+	assert.Equal(t, io.EOF, stream.Recv(&Response{}))
+	_ = stream.Close()
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&sent))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&recv))
+}
@@ -0,0 +1,222 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package memory
+
+import (
+	"io"
+
+	"github.com/vine-io/vine/core/client"
+	"github.com/vine-io/vine/core/codec"
+	"github.com/vine-io/vine/core/codec/bytes"
+)
+
+type memoryRequest struct {
+	service     string
+	method      string
+	contentType string
+	request     interface{}
+	opts        client.RequestOptions
+}
+
+func newRequest(service, method string, request interface{}, contentType string, reqOpts ...client.RequestOption) client.Request {
+	var opts client.RequestOptions
+	for _, o := range reqOpts {
+		o(&opts)
+	}
+
+	// set the content-type specified
+	if len(opts.ContentType) > 0 {
+		contentType = opts.ContentType
+	}
+
+	return &memoryRequest{
+		service:     service,
+		method:      method,
+		request:     request,
+		contentType: contentType,
+		opts:        opts,
+	}
+}
+
+func (r *memoryRequest) ContentType() string {
+	return r.contentType
+}
+
+func (r *memoryRequest) Service() string {
+	return r.service
+}
+
+func (r *memoryRequest) Method() string {
+	return r.method
+}
+
+func (r *memoryRequest) Endpoint() string {
+	return r.method
+}
+
+func (r *memoryRequest) Codec() codec.Writer {
+	return nil
+}
+
+func (r *memoryRequest) Body() interface{} {
+	return r.request
+}
+
+func (r *memoryRequest) Stream() bool {
+	return r.opts.Stream
+}
+
+type memoryMessage struct {
+	topic       string
+	contentType string
+	payload     interface{}
+}
+
+func newMessage(topic string, payload interface{}, contentType string, opts ...client.MessageOption) client.Message {
+	var options client.MessageOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if len(options.ContentType) > 0 {
+		contentType = options.ContentType
+	}
+
+	return &memoryMessage{
+		payload:     payload,
+		topic:       topic,
+		contentType: contentType,
+	}
+}
+
+func (m *memoryMessage) ContentType() string {
+	return m.contentType
+}
+
+func (m *memoryMessage) Topic() string {
+	return m.topic
+}
+
+func (m *memoryMessage) Payload() interface{} {
+	return m.payload
+}
+
+// serverRequest is the server.Request handed to the router of the server
+type serverRequest struct {
+	request client.Request
+	header  map[string]string
+	body    interface{}
+	codec   codec.Codec
+}
+
+func (r *serverRequest) Service() string {
+	return r.request.Service()
+}
+
+func (r *serverRequest) Method() string {
+	return r.request.Method()
+}
+
+func (r *serverRequest) Endpoint() string {
+	return r.request.Endpoint()
+}
+
+func (r *serverRequest) ContentType() string {
+	return r.request.ContentType()
+}
+
+func (r *serverRequest) Header() map[string]string {
+	return r.header
+}
+
+func (r *serverRequest) Body() interface{} {
+	return r.body
+}
+
+func (r *serverRequest) Read() ([]byte, error) {
+	f := &bytes.Frame{}
+	if err := r.codec.ReadBody(f); err != nil {
+		return nil, err
+	}
+	return f.Data, nil
+}
+
+func (r *serverRequest) Codec() codec.Reader {
+	return r.codec
+}
+
+func (r *serverRequest) Stream() bool {
+	return r.request.Stream()
+}
+
+// serverResponse is the server.Response handed to the router of the server
+type serverResponse struct {
+	header map[string]string
+	codec  codec.Codec
+}
+
+func (r *serverResponse) Codec() codec.Writer {
+	return r.codec
+}
+
+func (r *serverResponse) WriteHeader(hdr map[string]string) {
+	for k, v := range hdr {
+		r.header[k] = v
+	}
+}
+
+func (r *serverResponse) Write(b []byte) error {
+	return r.codec.Write(&codec.Message{Header: r.header, Body: b}, b)
+}
+
+// funcCodec is a codec.Codec handing the values over to functions
+type funcCodec struct {
+	read  func(interface{}) error
+	write func(interface{}) error
+}
+
+func (c *funcCodec) ReadHeader(*codec.Message, codec.MessageType) error {
+	return nil
+}
+
+func (c *funcCodec) ReadBody(v interface{}) error {
+	if c.read == nil {
+		return io.EOF
+	}
+	return c.read(v)
+}
+
+func (c *funcCodec) Write(_ *codec.Message, v interface{}) error {
+	if c.write == nil {
+		return io.ErrClosedPipe
+	}
+	return c.write(v)
+}
+
+func (c *funcCodec) Close() error {
+	return nil
+}
+
+func (c *funcCodec) String() string {
+	return "memory"
+}
@@ -0,0 +1,204 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package memory
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/vine-io/vine/core/client"
+	"github.com/vine-io/vine/core/codec"
+	"github.com/vine-io/vine/core/codec/bytes"
+	verrs "github.com/vine-io/vine/lib/errors"
+)
+
+// memoryStream implements a client side Stream, the messages are handed over
+// to the server through channels
+type memoryStream struct {
+	sync.RWMutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	request client.Request
+	copy    func(dst, src interface{}) error
+
+	// client to server messages
+	send chan interface{}
+	// server to client messages
+	recv chan interface{}
+	// closed when the client is done sending
+	sendDone chan struct{}
+	sendOnce sync.Once
+	// closed when the server handler returns
+	done chan struct{}
+	err  error
+}
+
+// newStream returns a stream of the request, it is cancelled once the timeout
+// elapses unless the timeout is zero
+func newStream(ctx context.Context, req client.Request, copier func(dst, src interface{}) error, timeout time.Duration) *memoryStream {
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	return &memoryStream{
+		ctx:      ctx,
+		cancel:   cancel,
+		request:  req,
+		copy:     copier,
+		send:     make(chan interface{}),
+		recv:     make(chan interface{}),
+		sendDone: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// serverCodec returns the codec used by the server side of the stream
+func (s *memoryStream) serverCodec() codec.Codec {
+	return &funcCodec{
+		read: func(v interface{}) error {
+			select {
+			case m := <-s.send:
+				return s.copy(v, m)
+			case <-s.sendDone:
+				return io.EOF
+			case <-s.ctx.Done():
+				return s.ctx.Err()
+			}
+		},
+		write: func(v interface{}) error {
+			select {
+			case s.recv <- v:
+				return nil
+			case <-s.ctx.Done():
+				return s.ctx.Err()
+			}
+		},
+	}
+}
+
+// finish records the result of the server handler
+func (s *memoryStream) finish(err error) {
+	s.Lock()
+	s.err = err
+	s.Unlock()
+	close(s.done)
+}
+
+func (s *memoryStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *memoryStream) Request() client.Request {
+	return s.request
+}
+
+func (s *memoryStream) Response() client.Response {
+	return &memoryResponse{stream: s}
+}
+
+func (s *memoryStream) Send(v interface{}) error {
+	select {
+	case <-s.sendDone:
+		return io.ErrClosedPipe
+	default:
+	}
+
+	select {
+	case s.send <- v:
+		return nil
+	case <-s.done:
+		if err := s.Error(); err != nil {
+			return err
+		}
+		return io.EOF
+	case <-s.ctx.Done():
+		return s.ctxErr()
+	}
+}
+
+func (s *memoryStream) Recv(v interface{}) error {
+	select {
+	case m := <-s.recv:
+		return s.copy(v, m)
+	case <-s.done:
+		if err := s.Error(); err != nil {
+			return err
+		}
+		return io.EOF
+	case <-s.ctx.Done():
+		return s.ctxErr()
+	}
+}
+
+// ctxErr returns the error of the done context, a timeout once the deadline
+// of the stream has passed
+func (s *memoryStream) ctxErr() error {
+	err := s.ctx.Err()
+	if err == context.DeadlineExceeded {
+		return verrs.Timeout("go.vine.client", "%v", err)
+	}
+	return err
+}
+
+func (s *memoryStream) Error() error {
+	s.RLock()
+	defer s.RUnlock()
+	return s.err
+}
+
+func (s *memoryStream) CloseSend() error {
+	s.sendOnce.Do(func() {
+		close(s.sendDone)
+	})
+	return nil
+}
+
+func (s *memoryStream) Close() error {
+	_ = s.CloseSend()
+	s.cancel()
+	return nil
+}
+
+type memoryResponse struct {
+	stream *memoryStream
+}
+
+func (r *memoryResponse) Codec() codec.Reader {
+	return &funcCodec{read: r.stream.Recv}
+}
+
+func (r *memoryResponse) Header() map[string]string {
+	return map[string]string{}
+}
+
+func (r *memoryResponse) Read() ([]byte, error) {
+	f := &bytes.Frame{}
+	if err := r.stream.Recv(f); err != nil {
+		return nil, err
+	}
+	return f.Data, nil
+}
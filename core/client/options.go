@@ -75,6 +75,12 @@ type CallOptions struct {
 	Retries int
 	// Request/Response timeout
 	RequestTimeout time.Duration
+	// Timeout of every single attempt, RequestTimeout still covers the whole call
+	PerTryTimeout time.Duration
+	// Hedge sends concurrent attempts to other nodes
+	Hedge *HedgePolicy
+	// RetryBudget limits the retries and hedged attempts
+	RetryBudget *RetryBudget
 	// Stream timeout for the stream
 	StreamTimeout time.Duration
 	// Use the services own auth token
@@ -84,6 +90,8 @@ type CallOptions struct {
 
 	// Middleware for low level call func
 	CallWrappers []CallWrapper
+	// Middleware for the messages of a stream
+	StreamWrappers []StreamWrapper
 
 	// Other options for implementations of the interface
 	// can be stored in a context
@@ -216,6 +224,13 @@ func WrapCall(cw ...CallWrapper) Option {
 	}
 }
 
+// WrapStream adds a Wrapper to the list of Stream wrappers
+func WrapStream(sw ...StreamWrapper) Option {
+	return func(o *Options) {
+		o.CallOptions.StreamWrappers = append(o.CallOptions.StreamWrappers, sw...)
+	}
+}
+
 // Backoff is used to set the backoff function used
 // when retrying Calls
 func Backoff(fn BackoffFunc) Option {
@@ -247,6 +262,27 @@ func RequestTimeout(d time.Duration) Option {
 	}
 }
 
+// PerTryTimeout sets the timeout of every single attempt of a call
+func PerTryTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.CallOptions.PerTryTimeout = d
+	}
+}
+
+// Hedge enables hedged requests for the calls
+func Hedge(p *HedgePolicy) Option {
+	return func(o *Options) {
+		o.CallOptions.Hedge = p
+	}
+}
+
+// Budget limits the retries and hedged attempts of the calls to the given budget
+func Budget(b *RetryBudget) Option {
+	return func(o *Options) {
+		o.CallOptions.RetryBudget = b
+	}
+}
+
 // StreamTimeout sets the stream timeout
 func StreamTimeout(d time.Duration) Option {
 	return func(o *Options) {
@@ -290,6 +326,13 @@ func WithCallWrapper(cw ...CallWrapper) CallOption {
 	}
 }
 
+// WithStreamWrapper is a CallOption which adds to the existing Stream wrappers
+func WithStreamWrapper(sw ...StreamWrapper) CallOption {
+	return func(o *CallOptions) {
+		o.StreamWrappers = append(o.StreamWrappers, sw...)
+	}
+}
+
 // WithBackoff is a CallOption which overrides that which
 // set in Options.CallOptions
 func WithBackoff(fn BackoffFunc) CallOption {
@@ -322,6 +365,30 @@ func WithRequestTimeout(d time.Duration) CallOption {
 	}
 }
 
+// WithPerTryTimeout is a CallOption which overrides that which
+// set in Options.CallOptions
+func WithPerTryTimeout(d time.Duration) CallOption {
+	return func(o *CallOptions) {
+		o.PerTryTimeout = d
+	}
+}
+
+// WithHedge is a CallOption which overrides that which
+// set in Options.CallOptions
+func WithHedge(p *HedgePolicy) CallOption {
+	return func(o *CallOptions) {
+		o.Hedge = p
+	}
+}
+
+// WithRetryBudget is a CallOption which overrides that which
+// set in Options.CallOptions
+func WithRetryBudget(b *RetryBudget) CallOption {
+	return func(o *CallOptions) {
+		o.RetryBudget = b
+	}
+}
+
 // WithStreamTimeout sets the stream timeout
 func WithStreamTimeout(d time.Duration) CallOption {
 	return func(o *CallOptions) {
@@ -52,6 +52,8 @@ func vineError(err *errors.Error) codes.Code {
 		return codes.Unauthenticated
 	case http.StatusPreconditionFailed:
 		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
 	case http.StatusNotImplemented:
 		return codes.Unimplemented
 	case http.StatusInternalServerError:
@@ -52,10 +52,12 @@ import (
 	"github.com/vine-io/vine/core/broker"
 	"github.com/vine-io/vine/core/registry"
 	"github.com/vine-io/vine/core/server"
+	"github.com/vine-io/vine/core/server/internal/rpc"
 	"github.com/vine-io/vine/lib/errors"
 	log "github.com/vine-io/vine/lib/logger"
 	"github.com/vine-io/vine/util/addr"
 	"github.com/vine-io/vine/util/backoff"
+	vctx "github.com/vine-io/vine/util/context"
 	meta "github.com/vine-io/vine/util/context/metadata"
 	mnet "github.com/vine-io/vine/util/net"
 )
@@ -85,7 +87,7 @@ type grpcServer struct {
 	sync.RWMutex
 	opts        server.Options
 	handlers    map[string]server.Handler
-	subscribers map[*subscriber][]broker.Subscriber
+	subscribers map[*rpc.Subscriber][]broker.Subscriber
 	// marks the serve as started
 	started bool
 	// used for first registration
@@ -93,6 +95,9 @@ type grpcServer struct {
 
 	// registry service instance
 	rsvc *registry.Service
+
+	// rate and concurrency limits
+	limiter *limiter
 }
 
 func newGRPCServer(opts ...server.Option) server.Server {
@@ -102,10 +107,10 @@ func newGRPCServer(opts ...server.Option) server.Server {
 	svc := &grpcServer{
 		opts: options,
 		rpc: &rServer{
-			serviceMap: make(map[string]*service),
+			serviceMap: make(map[string]*rpc.Service),
 		},
 		handlers:    make(map[string]server.Handler),
-		subscribers: make(map[*subscriber][]broker.Subscriber),
+		subscribers: make(map[*rpc.Subscriber][]broker.Subscriber),
 		exit:        make(chan chan error),
 		wg:          wait(options.Context),
 	}
@@ -160,6 +165,7 @@ func (g *grpcServer) configure(opts ...server.Option) {
 
 	g.rsvc = nil
 	g.svc = grpc.NewServer(gopts...)
+	g.limiter = g.getLimiter()
 }
 
 func (g *grpcServer) getMaxMsgSize() int {
@@ -197,6 +203,19 @@ func (g *grpcServer) getGrpcOptions() []grpc.ServerOption {
 	return opts
 }
 
+func (g *grpcServer) getLimiter() *limiter {
+	if g.opts.Context == nil {
+		return nil
+	}
+
+	global, _ := g.opts.Context.Value(rateLimitKey{}).(*rateLimit)
+	endpoints, _ := g.opts.Context.Value(endpointRateLimitKey{}).(map[string]rateLimit)
+	caller, _ := g.opts.Context.Value(callerRateLimitKey{}).(*callerRateLimit)
+	concurrency, _ := g.opts.Context.Value(concurrencyLimitKey{}).(*concurrencyLimit)
+
+	return newLimiter(g.opts.Name, global, endpoints, caller, concurrency)
+}
+
 func (g *grpcServer) getListener() net.Listener {
 	if g.opts.Context == nil {
 		return nil
@@ -236,8 +255,8 @@ func (g *grpcServer) handler(svc interface{}, stream grpc.ServerStream) error {
 		md.Set(k, strings.Join(v, ", "))
 	}
 
-	// timeout for server deadline
-	to, _ := md.Get("timeout")
+	// deadline propagated by the caller
+	deadline, hasDeadline := vctx.DeadlineFromMetadata(md)
 
 	// get content type
 	ct := DefaultContentType
@@ -250,7 +269,8 @@ func (g *grpcServer) handler(svc interface{}, stream grpc.ServerStream) error {
 	}
 
 	md.Delete("x-content-type")
-	md.Delete("timeout")
+	md.Delete(vctx.TimeoutKey)
+	md.Delete(vctx.DeadlineKey)
 
 	// create new context
 	ctx := meta.NewContext(stream.Context(), md)
@@ -261,13 +281,30 @@ func (g *grpcServer) handler(svc interface{}, stream grpc.ServerStream) error {
 		ctx = peer.NewContext(ctx, p)
 	}
 
-	// set the timeout if we have it
-	if len(to) > 0 {
-		if n, err := strconv.ParseUint(to, 10, 64); err == nil {
-			var cancel context.CancelFunc
-			ctx, cancel = context.WithTimeout(ctx, time.Duration(n))
-			defer cancel()
+	// set the deadline if we have it, the handler context expires
+	// with the earliest of it and the grpc deadline
+	if hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	// the caller already gave up
+	if err := ctx.Err(); err != nil {
+		return status.New(codes.DeadlineExceeded, err.Error()).Err()
+	}
+
+	// apply the rate and concurrency limits
+	if g.limiter != nil {
+		release, verr := g.limiter.Acquire(ctx, fmt.Sprintf("%s.%s", serviceName, methodName))
+		if verr != nil {
+			errStatus, serr := status.New(vineError(verr), verr.Error()).WithDetails(verr)
+			if serr != nil {
+				return serr
+			}
+			return errStatus.Err()
 		}
+		defer release()
 	}
 
 	// process via router
@@ -330,13 +367,13 @@ func (g *grpcServer) handler(svc interface{}, stream grpc.ServerStream) error {
 		return status.New(codes.Unimplemented, fmt.Sprintf("unknown service %s", serviceName)).Err()
 	}
 
-	mtype := s.method[methodName]
+	mtype := s.Method[methodName]
 	if mtype == nil {
 		return status.New(codes.Unimplemented, fmt.Sprintf("unknown service %s.%s", serviceName, methodName)).Err()
 	}
 
 	// process unary
-	if !mtype.stream {
+	if !mtype.Stream {
 		return g.processRequest(stream, s, mtype, ct, ctx)
 	}
 
@@ -344,7 +381,7 @@ func (g *grpcServer) handler(svc interface{}, stream grpc.ServerStream) error {
 	return g.processStream(stream, s, mtype, ct, ctx)
 }
 
-func (g *grpcServer) processRequest(stream grpc.ServerStream, service *service, mtype *methodType, ct string, ctx context.Context) error {
+func (g *grpcServer) processRequest(stream grpc.ServerStream, service *rpc.Service, mtype *rpc.MethodType, ct string, ctx context.Context) error {
 	fullMethod, ok := grpc.MethodFromServerStream(stream)
 	if !ok {
 		return status.Errorf(codes.Internal, "method does not exist in context")
@@ -392,7 +429,7 @@ func (g *grpcServer) processRequest(stream grpc.ServerStream, service *service,
 		// reply value
 		replyv = reflect.New(mtype.ReplyType.Elem())
 
-		function := mtype.method.Func
+		function := mtype.Method.Func
 		var returnValues []reflect.Value
 
 		cc, err := g.newGRPCCodec(ct)
@@ -415,7 +452,7 @@ func (g *grpcServer) processRequest(stream grpc.ServerStream, service *service,
 		// create a client.Request
 		r := &rpcRequest{
 			service:     g.opts.Name,
-			method:      fmt.Sprintf("%s.%s", service.name, mtype.method.Name),
+			method:      fmt.Sprintf("%s.%s", service.Name, mtype.Method.Name),
 			contentType: ct,
 			codec:       codec,
 			body:        b,
@@ -431,7 +468,7 @@ func (g *grpcServer) processRequest(stream grpc.ServerStream, service *service,
 					err = errors.InternalServerError(server.DefaultName, "panic recovered: %v", r)
 				}
 			}()
-			returnValues = function.Call([]reflect.Value{service.rcvr, mtype.prepareContext(ctx), reflect.ValueOf(argv.Interface()), reflect.ValueOf(rsp)})
+			returnValues = function.Call([]reflect.Value{service.Rcvr, mtype.PrepareContext(ctx), reflect.ValueOf(argv.Interface()), reflect.ValueOf(rsp)})
 
 			// The return value for the method is an error.
 			if rerr := returnValues[0].Interface(); rerr != nil {
@@ -485,27 +522,32 @@ func (g *grpcServer) processRequest(stream grpc.ServerStream, service *service,
 	}
 }
 
-func (g *grpcServer) processStream(stream grpc.ServerStream, service *service, mtype *methodType, ct string, ctx context.Context) error {
+func (g *grpcServer) processStream(stream grpc.ServerStream, service *rpc.Service, mtype *rpc.MethodType, ct string, ctx context.Context) error {
 	opts := g.opts
 
 	r := &rpcRequest{
 		service:     opts.Name,
 		contentType: ct,
-		method:      fmt.Sprintf("%s.%s", service.name, mtype.method.Name),
+		method:      fmt.Sprintf("%s.%s", service.Name, mtype.Method.Name),
 		stream:      true,
 	}
 
-	ss := &rpcStream{
+	var ss server.Stream = &rpcStream{
 		request: r,
 		s:       stream,
 	}
 
-	function := mtype.method.Func
+	// wrap the stream in reverse so the first wrapper sees messages first
+	for i := len(opts.StreamWrappers); i > 0; i-- {
+		ss = opts.StreamWrappers[i-1](ss)
+	}
+
+	function := mtype.Method.Func
 	var returnValues []reflect.Value
 
 	// Invoke the method, providing a new value for the reply.
 	fn := func(ctx context.Context, req server.Request, stream interface{}) error {
-		returnValues = function.Call([]reflect.Value{service.rcvr, mtype.prepareContext(ctx), reflect.ValueOf(stream)})
+		returnValues = function.Call([]reflect.Value{service.Rcvr, mtype.PrepareContext(ctx), reflect.ValueOf(stream)})
 		if err := returnValues[0].Interface(); err != nil {
 			return err.(error)
 		}
@@ -582,7 +624,7 @@ func (g *grpcServer) Init(opts ...server.Option) error {
 }
 
 func (g *grpcServer) NewHandler(h interface{}, opts ...server.HandlerOption) server.Handler {
-	return newRpcHandler(h, opts...)
+	return rpc.NewHandler(h, opts...)
 }
 
 func (g *grpcServer) Handle(h server.Handler) error {
@@ -595,19 +637,19 @@ func (g *grpcServer) Handle(h server.Handler) error {
 }
 
 func (g *grpcServer) NewSubscriber(topic string, sb interface{}, opts ...server.SubscriberOption) server.Subscriber {
-	return newSubscriber(topic, sb, opts...)
+	return rpc.NewSubscriber(topic, sb, opts...)
 }
 
 func (g *grpcServer) Subscribe(sb server.Subscriber) error {
-	sub, ok := sb.(*subscriber)
+	sub, ok := sb.(*rpc.Subscriber)
 	if !ok {
 		return fmt.Errorf("invalid subscriber: expected *subscriber")
 	}
-	if len(sub.handlers) == 0 {
+	if len(sub.Handlers) == 0 {
 		return fmt.Errorf("invalid subscriber: no handler functions")
 	}
 
-	if err := validateSubscriber(sb); err != nil {
+	if err := rpc.ValidateSubscriber(sb); err != nil {
 		return err
 	}
 
@@ -718,7 +760,7 @@ func (g *grpcServer) Register() error {
 	}
 	sort.Strings(handlerList)
 
-	var subscriberList []*subscriber
+	var subscriberList []*rpc.Subscriber
 	for e := range g.subscribers {
 		// Only advertise non-internal subscribers
 		if !e.Options().Internal {
@@ -726,7 +768,7 @@ func (g *grpcServer) Register() error {
 		}
 	}
 	sort.Slice(subscriberList, func(i, j int) bool {
-		return subscriberList[i].topic > subscriberList[j].topic
+		return subscriberList[i].Topic() > subscriberList[j].Topic()
 	})
 
 	endpoints := make([]*registry.Endpoint, 0, len(handlerList)+len(subscriberList))
@@ -0,0 +1,325 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpc
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/vine-io/vine/lib/errors"
+	meta "github.com/vine-io/vine/util/context/metadata"
+)
+
+var (
+	// DefaultMaxCallers is the maximum number of per caller buckets kept in memory
+	DefaultMaxCallers = 10000
+	// DefaultAdaptiveTolerance is the latency ratio against the observed minimum
+	// latency beyond which the adaptive limit starts to shrink
+	DefaultAdaptiveTolerance = 2.0
+	// DefaultAdaptiveBackoff is the multiplicative factor applied to the limit
+	// when latency rises over the tolerance
+	DefaultAdaptiveBackoff = 0.9
+)
+
+// rateLimit describes a token bucket, rate is tokens per second
+type rateLimit struct {
+	rate  float64
+	burst int
+}
+
+type callerRateLimit struct {
+	key string
+	rateLimit
+}
+
+type concurrencyLimit struct {
+	limit    int
+	min      int
+	max      int
+	timeout  time.Duration
+	adaptive bool
+}
+
+// tokenBucket is a simple token bucket implementation
+type tokenBucket struct {
+	sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(l rateLimit) *tokenBucket {
+	burst := float64(l.burst)
+	if burst < 1 {
+		burst = math.Max(1, l.rate)
+	}
+	return &tokenBucket{
+		rate:   l.rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+		b.last = now
+	}
+}
+
+// Allow takes a token from the bucket if there is one
+func (b *tokenBucket) Allow() bool {
+	b.Lock()
+	defer b.Unlock()
+	b.refill(time.Now())
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// full reports whether the bucket is back to its burst, then it holds no state
+func (b *tokenBucket) full(now time.Time) bool {
+	b.Lock()
+	defer b.Unlock()
+	b.refill(now)
+	return b.tokens >= b.burst
+}
+
+// concurrencyLimiter bounds the number of in-flight requests. Requests over the
+// limit wait in a FIFO queue up to the timeout. When adaptive the limit follows
+// the observed latency using an AIMD algorithm.
+type concurrencyLimiter struct {
+	sync.Mutex
+	concurrencyLimit
+	inflight int
+	waiters  *list.List
+	// smoothed minimal latency observed
+	minRTT time.Duration
+}
+
+func newConcurrencyLimiter(c concurrencyLimit) *concurrencyLimiter {
+	if c.adaptive {
+		if c.min <= 0 {
+			c.min = 1
+		}
+		if c.max < c.min {
+			c.max = c.min
+		}
+		if c.limit < c.min || c.limit > c.max {
+			c.limit = c.min
+		}
+	}
+	return &concurrencyLimiter{concurrencyLimit: c, waiters: list.New()}
+}
+
+// Acquire takes a slot, the returned func must be called when the request is done
+func (c *concurrencyLimiter) Acquire(ctx context.Context) (func(), bool) {
+	c.Lock()
+	if c.inflight < c.limit {
+		c.inflight++
+		c.Unlock()
+		return c.releaseFunc(), true
+	}
+	if c.timeout <= 0 {
+		c.Unlock()
+		return nil, false
+	}
+	ch := make(chan struct{})
+	e := c.waiters.PushBack(ch)
+	c.Unlock()
+
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-ch:
+		return c.releaseFunc(), true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	select {
+	case <-ch:
+		// the slot was handed over while we were timing out, give it back
+		c.inflight--
+		c.next()
+	default:
+		c.waiters.Remove(e)
+	}
+	return nil, false
+}
+
+func (c *concurrencyLimiter) releaseFunc() func() {
+	start := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.Lock()
+			defer c.Unlock()
+			c.inflight--
+			if c.adaptive {
+				c.adjust(time.Since(start))
+			}
+			c.next()
+		})
+	}
+}
+
+// next hands free slots over to the waiting requests, must be called with lock held
+func (c *concurrencyLimiter) next() {
+	for c.inflight < c.limit && c.waiters.Len() > 0 {
+		e := c.waiters.Front()
+		c.waiters.Remove(e)
+		c.inflight++
+		close(e.Value.(chan struct{}))
+	}
+}
+
+// adjust updates the limit using the latency of a finished request, must be called with lock held
+func (c *concurrencyLimiter) adjust(rtt time.Duration) {
+	if rtt <= 0 {
+		rtt = time.Microsecond
+	}
+	if c.minRTT == 0 || rtt < c.minRTT {
+		c.minRTT = rtt
+	} else {
+		// let the minimum drift slowly so that it follows a changing baseline
+		c.minRTT += (rtt - c.minRTT) / 100
+	}
+
+	if float64(rtt) > float64(c.minRTT)*DefaultAdaptiveTolerance {
+		c.limit = int(float64(c.limit) * DefaultAdaptiveBackoff)
+	} else if c.inflight+1 >= c.limit {
+		// only grow when the limit is actually in use
+		c.limit++
+	}
+
+	if c.limit < c.min {
+		c.limit = c.min
+	}
+	if c.limit > c.max {
+		c.limit = c.max
+	}
+}
+
+// limiter applies the rate and concurrency limits of the server
+type limiter struct {
+	name string
+
+	global    *tokenBucket
+	endpoints map[string]*tokenBucket
+
+	caller  *callerRateLimit
+	mu      sync.Mutex
+	callers map[string]*tokenBucket
+
+	concurrency *concurrencyLimiter
+}
+
+func newLimiter(name string, global *rateLimit, endpoints map[string]rateLimit, caller *callerRateLimit, cl *concurrencyLimit) *limiter {
+	if global == nil && len(endpoints) == 0 && caller == nil && cl == nil {
+		return nil
+	}
+
+	l := &limiter{
+		name:      name,
+		endpoints: make(map[string]*tokenBucket),
+		callers:   make(map[string]*tokenBucket),
+		caller:    caller,
+	}
+	if global != nil {
+		l.global = newTokenBucket(*global)
+	}
+	for endpoint, rl := range endpoints {
+		l.endpoints[endpoint] = newTokenBucket(rl)
+	}
+	if cl != nil {
+		l.concurrency = newConcurrencyLimiter(*cl)
+	}
+
+	return l
+}
+
+func (l *limiter) callerBucket(id string) *tokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.callers[id]
+	if ok {
+		return b
+	}
+
+	if len(l.callers) >= DefaultMaxCallers {
+		// drop the buckets which are full again, they carry no state
+		now := time.Now()
+		for k, v := range l.callers {
+			if v.full(now) {
+				delete(l.callers, k)
+			}
+		}
+	}
+
+	b = newTokenBucket(l.caller.rateLimit)
+	l.callers[id] = b
+	return b
+}
+
+// Acquire checks the limits for the given endpoint. The returned func releases
+// the concurrency slot and must be called once the request is done.
+func (l *limiter) Acquire(ctx context.Context, endpoint string) (func(), *errors.Error) {
+	if l.global != nil && !l.global.Allow() {
+		return nil, errors.TooManyRequests(l.name, "rate limit exceeded")
+	}
+
+	if b, ok := l.endpoints[endpoint]; ok && !b.Allow() {
+		return nil, errors.TooManyRequests(l.name, "rate limit exceeded for %s", endpoint)
+	}
+
+	if l.caller != nil {
+		if id, ok := meta.Get(ctx, l.caller.key); ok && len(id) > 0 {
+			if !l.callerBucket(id).Allow() {
+				return nil, errors.TooManyRequests(l.name, "rate limit exceeded for caller %s", id)
+			}
+		}
+	}
+
+	if l.concurrency != nil {
+		release, ok := l.concurrency.Acquire(ctx)
+		if !ok {
+			return nil, errors.TooManyRequests(l.name, "too many concurrent requests")
+		}
+		return release, nil
+	}
+
+	return func() {}, nil
+}
@@ -0,0 +1,104 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vine-io/vine/lib/errors"
+	meta "github.com/vine-io/vine/util/context/metadata"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(rateLimit{rate: 10, burst: 2})
+
+	assert.True(t, b.Allow())
+	assert.True(t, b.Allow())
+	assert.False(t, b.Allow())
+
+	time.Sleep(120 * time.Millisecond)
+	assert.True(t, b.Allow())
+}
+
+func TestLimiterRate(t *testing.T) {
+	l := newLimiter("test", nil, map[string]rateLimit{"Foo.Bar": {rate: 1, burst: 1}}, &callerRateLimit{key: "Caller", rateLimit: rateLimit{rate: 1, burst: 1}}, nil)
+
+	ctx := context.Background()
+	_, err := l.Acquire(ctx, "Foo.Bar")
+	assert.Nil(t, err)
+	_, err = l.Acquire(ctx, "Foo.Bar")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, errors.StatusTooManyRequests, err.Code)
+	}
+	_, err = l.Acquire(ctx, "Foo.Baz")
+	assert.Nil(t, err)
+
+	a := meta.Set(ctx, "Caller", "a")
+	b := meta.Set(ctx, "Caller", "b")
+	_, err = l.Acquire(a, "Foo.Baz")
+	assert.Nil(t, err)
+	_, err = l.Acquire(a, "Foo.Baz")
+	assert.NotNil(t, err)
+	_, err = l.Acquire(b, "Foo.Baz")
+	assert.Nil(t, err)
+}
+
+func TestLimiterConcurrency(t *testing.T) {
+	l := newLimiter("test", nil, nil, nil, &concurrencyLimit{limit: 1, timeout: 50 * time.Millisecond})
+
+	ctx := context.Background()
+	release, err := l.Acquire(ctx, "Foo.Bar")
+	assert.Nil(t, err)
+
+	// queue timeout
+	_, err = l.Acquire(ctx, "Foo.Bar")
+	assert.NotNil(t, err)
+
+	// the queued request gets the slot once released
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		release()
+	}()
+	release, err = l.Acquire(ctx, "Foo.Bar")
+	if assert.Nil(t, err) {
+		release()
+	}
+}
+
+func TestAdaptiveConcurrency(t *testing.T) {
+	c := newConcurrencyLimiter(concurrencyLimit{min: 2, max: 10, adaptive: true})
+	assert.Equal(t, 2, c.limit)
+
+	c.adjust(time.Millisecond)
+	c.inflight = c.limit
+	c.adjust(time.Millisecond)
+	assert.Equal(t, 3, c.limit)
+
+	// latency over the tolerance shrinks the limit
+	c.adjust(10 * time.Millisecond)
+	assert.Equal(t, 2, c.limit)
+}
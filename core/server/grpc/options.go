@@ -27,6 +27,7 @@ import (
 	"crypto/tls"
 	"net"
 	"net/http"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/encoding"
@@ -42,6 +43,10 @@ type maxConnKey struct{}
 type tlsAuth struct{}
 type grpcServerWrapKey struct{}
 type grpcWithHttp struct{}
+type rateLimitKey struct{}
+type endpointRateLimitKey struct{}
+type callerRateLimitKey struct{}
+type concurrencyLimitKey struct{}
 
 type ServerWrapFn func(s *grpc.Server) error
 
@@ -95,3 +100,47 @@ func MaxMsgSize(s int) server.Option {
 func WrapGRPCServer(fn ServerWrapFn) server.Option {
 	return setServerOption(grpcServerWrapKey{}, fn)
 }
+
+// RateLimit limits the requests served by the server with a token bucket,
+// rate is the number of requests per second and burst the bucket size.
+// Rejected requests return errors.TooManyRequests.
+func RateLimit(rate float64, burst int) server.Option {
+	return setServerOption(rateLimitKey{}, &rateLimit{rate: rate, burst: burst})
+}
+
+// EndpointRateLimit limits the requests of the given endpoint (e.g. Greeter.Hello)
+// with its own token bucket
+func EndpointRateLimit(endpoint string, rate float64, burst int) server.Option {
+	return func(o *server.Options) {
+		limits := make(map[string]rateLimit)
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		if v, ok := o.Context.Value(endpointRateLimitKey{}).(map[string]rateLimit); ok && v != nil {
+			limits = v
+		}
+		limits[endpoint] = rateLimit{rate: rate, burst: burst}
+		o.Context = context.WithValue(o.Context, endpointRateLimitKey{}, limits)
+	}
+}
+
+// CallerRateLimit limits the requests of every caller with its own token bucket.
+// The caller is identified by the value of the given metadata key
+// (e.g. Vine-From-Service), requests without it aren't limited.
+func CallerRateLimit(key string, rate float64, burst int) server.Option {
+	return setServerOption(callerRateLimitKey{}, &callerRateLimit{key: key, rateLimit: rateLimit{rate: rate, burst: burst}})
+}
+
+// MaxConcurrent limits the number of requests handled at the same time. Requests
+// over the limit wait up to timeout for a free slot before being rejected.
+func MaxConcurrent(n int, timeout time.Duration) server.Option {
+	return setServerOption(concurrencyLimitKey{}, &concurrencyLimit{limit: n, timeout: timeout})
+}
+
+// AdaptiveConcurrency limits the number of requests handled at the same time,
+// the limit moves between min and max following the latency of the handlers:
+// it grows by one while latency stays close to the observed minimum and shrinks
+// multiplicatively when it rises. Requests over the limit wait up to timeout.
+func AdaptiveConcurrency(min, max int, timeout time.Duration) server.Option {
+	return setServerOption(concurrencyLimitKey{}, &concurrencyLimit{limit: min, min: min, max: max, timeout: timeout, adaptive: true})
+}
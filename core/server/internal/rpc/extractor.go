@@ -20,7 +20,7 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
 // SOFTWARE.
 
-package grpc
+package rpc
 
 import (
 	"fmt"
@@ -85,7 +85,9 @@ func extractValue(v reflect.Type, d int) *registry.Value {
 	return arg
 }
 
-func extractEndpoint(method reflect.Method) *registry.Endpoint {
+// ExtractEndpoint returns the endpoint of the method of a handler, nil when
+// the method is not exported or takes the wrong number of args
+func ExtractEndpoint(method reflect.Method) *registry.Endpoint {
 	if method.PkgPath != "" {
 		return nil
 	}
@@ -130,7 +132,8 @@ func extractEndpoint(method reflect.Method) *registry.Endpoint {
 	return ep
 }
 
-func extractSubValue(typ reflect.Type) *registry.Value {
+// ExtractSubValue returns the value of the message of a subscriber func or method
+func ExtractSubValue(typ reflect.Type) *registry.Value {
 	var reqType reflect.Type
 	switch typ.NumIn() {
 	case 1:
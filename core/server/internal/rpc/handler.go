@@ -20,7 +20,7 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
 // SOFTWARE.
 
-package grpc
+package rpc
 
 import (
 	"reflect"
@@ -36,7 +36,8 @@ type rpcHandler struct {
 	opts      server.HandlerOptions
 }
 
-func newRpcHandler(handler interface{}, opts ...server.HandlerOption) server.Handler {
+// NewHandler returns the handler of the exported methods of handler
+func NewHandler(handler interface{}, opts ...server.HandlerOption) server.Handler {
 	options := server.HandlerOptions{
 		Metadata: make(map[string]map[string]string),
 	}
@@ -52,7 +53,7 @@ func newRpcHandler(handler interface{}, opts ...server.HandlerOption) server.Han
 	var endpoints []*registry.Endpoint
 
 	for m := 0; m < typ.NumMethod(); m++ {
-		if e := extractEndpoint(typ.Method(m)); e != nil {
+		if e := ExtractEndpoint(typ.Method(m)); e != nil {
 			e.Name = name + "." + e.Name
 
 			for k, v := range options.Metadata[e.Name] {
@@ -0,0 +1,197 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package rpc holds the reflection of the handlers and subscribers shared by
+// the server implementations
+package rpc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/vine-io/vine/core/server"
+	log "github.com/vine-io/vine/lib/logger"
+)
+
+var (
+	// Precompute the reflect type for error. Can't use error directly
+	// because Typeof takes an empty interface value. This is annoying.
+	typeOfError = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// MethodType is an endpoint of a handler
+type MethodType struct {
+	Method      reflect.Method
+	ArgType     reflect.Type
+	ReplyType   reflect.Type
+	ContextType reflect.Type
+	Stream      bool
+}
+
+// Service is a handler and its endpoints
+type Service struct {
+	Name   string                 // name of service
+	Rcvr   reflect.Value          // receiver of methods for the service
+	Typ    reflect.Type           // type of the receiver
+	Method map[string]*MethodType // registered methods
+}
+
+// Is this an exported - upper case - name?
+func isExported(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r)
+}
+
+// Is this type exported or a builtin?
+func isExportedOrBuiltinType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	// PkgPath will be non-empty even for an exported type,
+	// so we need to check the type name as well.
+	return isExported(t.Name()) || t.PkgPath() == ""
+}
+
+// PrepareEndpoint returns a MethodType for the provided method or nil
+// in case if the method was unsuitable. The args of the local methods, which
+// are called in process, need not be exported.
+func PrepareEndpoint(method reflect.Method, local bool) *MethodType {
+	mtype := method.Type
+	mname := method.Name
+	var replyType, argType, contextType reflect.Type
+	var stream bool
+
+	// Endpoint() must be exported.
+	if method.PkgPath != "" {
+		return nil
+	}
+
+	switch mtype.NumIn() {
+	case 3:
+		// assuming streaming
+		argType = mtype.In(2)
+		contextType = mtype.In(1)
+		stream = true
+	case 4:
+		// method that takes a context
+		argType = mtype.In(2)
+		replyType = mtype.In(3)
+		contextType = mtype.In(1)
+	default:
+		log.Errorf("method %v of %v has wrong number of ins: %v", mname, mtype, mtype.NumIn())
+		return nil
+	}
+
+	if stream {
+		// check stream type
+		streamType := reflect.TypeOf((*server.Stream)(nil)).Elem()
+		if !argType.Implements(streamType) {
+			log.Errorf("%v argument does not implement Streamer interface: %v", mname, argType)
+			return nil
+		}
+	} else {
+		// if not stream check the replyType
+
+		// First arg need not be a pointer.
+		if !local && !isExportedOrBuiltinType(argType) {
+			log.Errorf("%v argument type not exported: %v", mname, argType)
+			return nil
+		}
+
+		if replyType.Kind() != reflect.Ptr {
+			log.Errorf("method %v reply type not a pointer: %v", mname, replyType)
+			return nil
+		}
+
+		// Reply type must of exported.
+		if !local && !isExportedOrBuiltinType(replyType) {
+			log.Errorf("method %v reply type not exported: %v", mname, replyType)
+			return nil
+		}
+	}
+
+	// Endpoint() needs one out.
+	if mtype.NumOut() != 1 {
+		log.Errorf("method %v has wrong number of outs: %v", mname, mtype.NumOut())
+		return nil
+	}
+	// The return type of the method must be error.
+	if returnType := mtype.Out(0); returnType != typeOfError {
+		log.Errorf("method %v returns %v not error", mname, returnType.String())
+		return nil
+	}
+	return &MethodType{Method: method, ArgType: argType, ReplyType: replyType, ContextType: contextType, Stream: stream}
+}
+
+// NewService returns the service of the exported methods of the receiver,
+// local services are called in process
+func NewService(rcvr interface{}, local bool) (*Service, error) {
+	s := new(Service)
+	s.Typ = reflect.TypeOf(rcvr)
+	s.Rcvr = reflect.ValueOf(rcvr)
+	sname := reflect.Indirect(s.Rcvr).Type().Name()
+	if sname == "" {
+		log.Fatalf("rpc: no service name for type %v", s.Typ.String())
+	}
+	if !isExported(sname) {
+		s := "rpc Register: type " + sname + " is not exported"
+		log.Error(s)
+		return nil, errors.New(s)
+	}
+	s.Name = sname
+	s.Method = make(map[string]*MethodType)
+
+	// Install the methods
+	for m := 0; m < s.Typ.NumMethod(); m++ {
+		method := s.Typ.Method(m)
+		if mt := PrepareEndpoint(method, local); mt != nil {
+			s.Method[method.Name] = mt
+		}
+	}
+
+	if len(s.Method) == 0 {
+		s := "rpc Register: type " + sname + " has no exported methods of suitable type"
+		log.Error(s)
+		return nil, errors.New(s)
+	}
+	return s, nil
+}
+
+// Call calls the method of the service with the context and the args
+func (s *Service) Call(ctx context.Context, mt *MethodType, args ...reflect.Value) error {
+	in := append([]reflect.Value{s.Rcvr, mt.PrepareContext(ctx)}, args...)
+	if err := mt.Method.Func.Call(in)[0].Interface(); err != nil {
+		return err.(error)
+	}
+	return nil
+}
+
+// PrepareContext returns the value of the context arg of the method
+func (m *MethodType) PrepareContext(ctx context.Context) reflect.Value {
+	if contextv := reflect.ValueOf(ctx); contextv.IsValid() {
+		return contextv
+	}
+	return reflect.Zero(m.ContextType)
+}
@@ -0,0 +1,216 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/vine-io/vine/core/registry"
+	"github.com/vine-io/vine/core/server"
+)
+
+const (
+	subSig = "func(context.Context, interface{}) error"
+)
+
+// SubHandler is a func or method of a subscriber
+type SubHandler struct {
+	Method  reflect.Value
+	ReqType reflect.Type
+	CtxType reflect.Type
+}
+
+// Subscriber is a subscriber and its handlers
+type Subscriber struct {
+	topic      string
+	Rcvr       reflect.Value
+	Typ        reflect.Type
+	subscriber interface{}
+	Handlers   []*SubHandler
+	endpoints  []*registry.Endpoint
+	opts       server.SubscriberOptions
+}
+
+// NewSubscriber returns the subscriber of the topic, sub is a func or the
+// receiver of methods
+func NewSubscriber(topic string, sub interface{}, opts ...server.SubscriberOption) *Subscriber {
+	options := server.SubscriberOptions{AutoAck: true}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	var endpoints []*registry.Endpoint
+	var handlers []*SubHandler
+
+	if typ := reflect.TypeOf(sub); typ.Kind() == reflect.Func {
+		h := &SubHandler{Method: reflect.ValueOf(sub)}
+
+		switch typ.NumIn() {
+		case 1:
+			h.ReqType = typ.In(0)
+		case 2:
+			h.CtxType = typ.In(0)
+			h.ReqType = typ.In(1)
+		}
+
+		handlers = append(handlers, h)
+
+		endpoints = append(endpoints, &registry.Endpoint{
+			Name:    "Func",
+			Request: ExtractSubValue(typ),
+			Metadata: map[string]string{
+				"topic":      topic,
+				"subscriber": "true",
+			},
+		})
+	} else {
+		hdlr := reflect.ValueOf(sub)
+		name := reflect.Indirect(hdlr).Type().Name()
+
+		for m := 0; m < typ.NumMethod(); m++ {
+			method := typ.Method(m)
+			h := &SubHandler{Method: method.Func}
+
+			switch method.Type.NumIn() {
+			case 2:
+				h.ReqType = method.Type.In(1)
+			case 3:
+				h.CtxType = method.Type.In(1)
+				h.ReqType = method.Type.In(2)
+			}
+
+			handlers = append(handlers, h)
+
+			endpoints = append(endpoints, &registry.Endpoint{
+				Name:    name + "." + method.Name,
+				Request: ExtractSubValue(method.Type),
+				Metadata: map[string]string{
+					"topic":      topic,
+					"subscriber": "true",
+				},
+			})
+		}
+	}
+
+	return &Subscriber{
+		Rcvr:       reflect.ValueOf(sub),
+		Typ:        reflect.TypeOf(sub),
+		topic:      topic,
+		subscriber: sub,
+		Handlers:   handlers,
+		endpoints:  endpoints,
+		opts:       options,
+	}
+}
+
+// ValidateSubscriber checks the signatures of the handlers of the subscriber
+func ValidateSubscriber(sub server.Subscriber) error {
+	typ := reflect.TypeOf(sub.Subscriber())
+	var argType reflect.Type
+
+	if typ.Kind() == reflect.Func {
+		name := "Func"
+		switch typ.NumIn() {
+		case 2:
+			argType = typ.In(1)
+		default:
+			return fmt.Errorf("subscriber %v takes wrong number of args: %v required signature %s", name, typ.NumIn(), subSig)
+		}
+		if !isExportedOrBuiltinType(argType) {
+			return fmt.Errorf("subscriber %v argument type not exported: %v", name, argType)
+		}
+		if typ.NumOut() != 1 {
+			return fmt.Errorf("subscriber %v has wrong number of outs: %v require signature %s",
+				name, typ.NumOut(), subSig)
+		}
+		if returnType := typ.Out(0); returnType != typeOfError {
+			return fmt.Errorf("subscriber %v returns %v not error", name, returnType.String())
+		}
+	} else {
+		hdlr := reflect.ValueOf(sub.Subscriber())
+		name := reflect.Indirect(hdlr).Type().Name()
+
+		for m := 0; m < typ.NumMethod(); m++ {
+			method := typ.Method(m)
+
+			switch method.Type.NumIn() {
+			case 3:
+				argType = method.Type.In(2)
+			default:
+				return fmt.Errorf("subscriber %v.%v takes wrong number of args: %v required signature %s",
+					name, method.Name, method.Type.NumIn(), subSig)
+			}
+
+			if !isExportedOrBuiltinType(argType) {
+				return fmt.Errorf("%v argument type not exported: %v", name, argType)
+			}
+			if method.Type.NumOut() != 1 {
+				return fmt.Errorf("subscriber %v.%v has wrong number of outs: %v require signature %s",
+					name, method.Name, method.Type.NumOut(), subSig)
+			}
+			if returnType := method.Type.Out(0); returnType != typeOfError {
+				return fmt.Errorf("subscriber %v.%v returns %v not error", name, method.Name, returnType.String())
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Subscriber) Topic() string {
+	return s.topic
+}
+
+func (s *Subscriber) Subscriber() interface{} {
+	return s.subscriber
+}
+
+func (s *Subscriber) Endpoints() []*registry.Endpoint {
+	return s.endpoints
+}
+
+func (s *Subscriber) Options() server.SubscriberOptions {
+	return s.opts
+}
+
+// Call calls the handler of the subscriber with the payload of the message
+func (s *Subscriber) Call(ctx context.Context, h *SubHandler, msg server.Message) error {
+	var vals []reflect.Value
+	if s.Typ.Kind() != reflect.Func {
+		vals = append(vals, s.Rcvr)
+	}
+	if h.CtxType != nil {
+		vals = append(vals, reflect.ValueOf(ctx))
+	}
+
+	vals = append(vals, reflect.ValueOf(msg.Payload()))
+
+	returnValues := h.Method.Call(vals)
+	if rerr := returnValues[0].Interface(); rerr != nil {
+		return rerr.(error)
+	}
+	return nil
+}
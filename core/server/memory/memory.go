@@ -0,0 +1,522 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package memory provides an in-process server. The requests of the memory
+// client are dispatched directly through the router of the server, skipping
+// the network completely.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vine-io/vine/core/broker"
+	"github.com/vine-io/vine/core/codec"
+	"github.com/vine-io/vine/core/registry"
+	"github.com/vine-io/vine/core/server"
+	"github.com/vine-io/vine/core/server/internal/rpc"
+	"github.com/vine-io/vine/lib/errors"
+	log "github.com/vine-io/vine/lib/logger"
+	meta "github.com/vine-io/vine/util/context/metadata"
+)
+
+var (
+	// Scheme prefixes the address of the in-process servers
+	Scheme = "memory://"
+
+	DefaultContentType = "application/json"
+)
+
+type memoryServer struct {
+	sync.RWMutex
+	opts        server.Options
+	handlers    map[string]server.Handler
+	services    map[string]*rpc.Service
+	subscribers map[*rpc.Subscriber][]broker.Subscriber
+	exit        chan chan error
+	wg          *sync.WaitGroup
+	// marks the serve as started
+	started bool
+	// used for first registration
+	registered bool
+}
+
+func newServer(opts ...server.Option) server.Server {
+	options := server.NewOptions(opts...)
+
+	return &memoryServer{
+		opts:        options,
+		handlers:    make(map[string]server.Handler),
+		services:    make(map[string]*rpc.Service),
+		subscribers: make(map[*rpc.Subscriber][]broker.Subscriber),
+		exit:        make(chan chan error),
+		wg:          wait(options.Context),
+	}
+}
+
+func (s *memoryServer) Init(opts ...server.Option) error {
+	s.Lock()
+	defer s.Unlock()
+	for _, o := range opts {
+		o(&s.opts)
+	}
+	return nil
+}
+
+func (s *memoryServer) Options() server.Options {
+	s.RLock()
+	defer s.RUnlock()
+	return s.opts
+}
+
+func (s *memoryServer) address() string {
+	return Scheme + s.opts.Id
+}
+
+func (s *memoryServer) Handle(h server.Handler) error {
+	svc, err := rpc.NewService(h.Handler(), true)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.services[svc.Name]; ok {
+		return fmt.Errorf("service already defined: %s", svc.Name)
+	}
+	s.services[svc.Name] = svc
+	s.handlers[h.Name()] = h
+	return nil
+}
+
+func (s *memoryServer) NewHandler(h interface{}, opts ...server.HandlerOption) server.Handler {
+	return rpc.NewHandler(h, opts...)
+}
+
+func (s *memoryServer) NewSubscriber(topic string, sb interface{}, opts ...server.SubscriberOption) server.Subscriber {
+	return rpc.NewSubscriber(topic, sb, opts...)
+}
+
+func (s *memoryServer) Subscribe(sb server.Subscriber) error {
+	sub, ok := sb.(*rpc.Subscriber)
+	if !ok {
+		return fmt.Errorf("invalid subscriber: expected *subscriber")
+	}
+	if len(sub.Handlers) == 0 {
+		return fmt.Errorf("invalid subscriber: no handler functions")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if _, ok = s.subscribers[sub]; ok {
+		return fmt.Errorf("subscriber %v already exists", sub)
+	}
+	s.subscribers[sub] = nil
+	return nil
+}
+
+// ProcessMessage delivers the message to the subscribers of its topic
+func (s *memoryServer) ProcessMessage(ctx context.Context, msg server.Message) error {
+	s.RLock()
+	opts := s.opts
+	var subs []*rpc.Subscriber
+	for sb := range s.subscribers {
+		if sb.Topic() == msg.Topic() {
+			subs = append(subs, sb)
+		}
+	}
+	s.RUnlock()
+
+	if opts.Router != nil {
+		return opts.Router.ProcessMessage(ctx, msg)
+	}
+
+	var errs []string
+	for _, sb := range subs {
+		if err := process(ctx, sb, msg, opts.SubWrappers); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// ServeRequest dispatches the request to the handler of its endpoint. The reply
+// is written to the codec of the response, streams read from the codec of the
+// request and write to the codec of the response.
+func (s *memoryServer) ServeRequest(ctx context.Context, req server.Request, rsp server.Response) error {
+	if s.wg != nil {
+		s.wg.Add(1)
+		defer s.wg.Done()
+	}
+
+	s.RLock()
+	opts := s.opts
+	s.RUnlock()
+
+	if opts.Router != nil {
+		h := func(ctx context.Context, req server.Request, rsp interface{}) error {
+			return opts.Router.ServeRequest(ctx, req, rsp.(server.Response))
+		}
+		for i := len(opts.HdlrWrappers); i > 0; i-- {
+			h = opts.HdlrWrappers[i-1](h)
+		}
+		return h(ctx, req, rsp)
+	}
+
+	parts := strings.Split(req.Endpoint(), ".")
+	if len(parts) != 2 {
+		return errors.BadRequest(opts.Name, "malformed endpoint: %s", req.Endpoint())
+	}
+
+	s.RLock()
+	svc := s.services[parts[0]]
+	s.RUnlock()
+	if svc == nil {
+		return errors.NotImplemented(opts.Name, "unknown service %s", parts[0])
+	}
+	mt := svc.Method[parts[1]]
+	if mt == nil {
+		return errors.NotImplemented(opts.Name, "unknown service %s", req.Endpoint())
+	}
+
+	// copy the metadata of the caller
+	if md, ok := meta.FromContext(ctx); ok {
+		md = meta.Copy(md)
+		md.Set("Remote", s.address())
+		ctx = meta.NewContext(ctx, md)
+	}
+
+	if mt.Stream {
+		return s.serveStream(ctx, svc, mt, req, rsp, opts)
+	}
+
+	argv := reflect.New(mt.ArgType)
+	if mt.ArgType.Kind() == reflect.Ptr {
+		argv = reflect.New(mt.ArgType.Elem())
+	}
+	if err := Copy(req.ContentType(), argv.Interface(), req.Body()); err != nil {
+		return errors.BadRequest(opts.Name, "decode request: %v", err)
+	}
+	if mt.ArgType.Kind() != reflect.Ptr {
+		argv = argv.Elem()
+	}
+	replyv := reflect.New(mt.ReplyType.Elem())
+
+	fn := func(ctx context.Context, req server.Request, rsp interface{}) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("panic recovered: ", r)
+				log.Error(string(debug.Stack()))
+				err = errors.InternalServerError(server.DefaultName, "panic recovered: %v", r)
+			}
+		}()
+		return svc.Call(ctx, mt, argv, reflect.ValueOf(rsp))
+	}
+
+	for i := len(opts.HdlrWrappers); i > 0; i-- {
+		fn = opts.HdlrWrappers[i-1](fn)
+	}
+
+	if err := fn(ctx, &payload{Request: req, body: argv.Interface()}, replyv.Interface()); err != nil {
+		return err
+	}
+
+	return rsp.Codec().Write(&codec.Message{
+		Type:     codec.Response,
+		Target:   req.Service(),
+		Method:   req.Method(),
+		Endpoint: req.Endpoint(),
+	}, replyv.Interface())
+}
+
+// payload exposes the decoded request to handler wrappers
+type payload struct {
+	server.Request
+
+	body interface{}
+}
+
+func (p *payload) Body() interface{} {
+	return p.body
+}
+
+func (s *memoryServer) serveStream(ctx context.Context, svc *rpc.Service, mt *rpc.MethodType, req server.Request, rsp server.Response, opts server.Options) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var stream server.Stream = &memoryStream{ctx: ctx, request: req, response: rsp}
+
+	// wrap the stream in reverse so the first wrapper sees messages first
+	for i := len(opts.StreamWrappers); i > 0; i-- {
+		stream = opts.StreamWrappers[i-1](stream)
+	}
+
+	fn := func(ctx context.Context, req server.Request, stream interface{}) error {
+		return svc.Call(ctx, mt, reflect.ValueOf(stream))
+	}
+
+	for i := len(opts.HdlrWrappers); i > 0; i-- {
+		fn = opts.HdlrWrappers[i-1](fn)
+	}
+
+	return fn(ctx, req, stream)
+}
+
+func (s *memoryServer) Register() error {
+	s.RLock()
+	config := s.opts
+
+	var handlerList []string
+	for n, e := range s.handlers {
+		// Only advertise non-internal handlers
+		if !e.Options().Internal {
+			handlerList = append(handlerList, n)
+		}
+	}
+	sort.Strings(handlerList)
+
+	var endpoints []*registry.Endpoint
+	for _, h := range handlerList {
+		endpoints = append(endpoints, s.handlers[h].Endpoints()...)
+	}
+	for sb := range s.subscribers {
+		if !sb.Options().Internal {
+			endpoints = append(endpoints, sb.Endpoints()...)
+		}
+	}
+	registered := s.registered
+	s.RUnlock()
+
+	md := meta.Copy(config.Metadata)
+	md["broker"] = config.Broker.String()
+	md["registry"] = config.Registry.String()
+	md["server"] = s.String()
+	md["transport"] = s.String()
+	md["protocol"] = s.String()
+
+	node := &registry.Node{
+		Id:       config.Id,
+		Address:  s.address(),
+		Metadata: md,
+	}
+
+	svc := &registry.Service{
+		Name:      config.Name,
+		Version:   config.Version,
+		Nodes:     []*registry.Node{node},
+		Endpoints: endpoints,
+	}
+
+	if !registered {
+		log.Infof("Registry [%s] Registering node: %s", config.Registry.String(), node.Id)
+	}
+
+	rOpts := []registry.RegisterOption{registry.RegisterTTL(config.RegisterTTL)}
+	if err := config.Registry.Register(context.TODO(), svc, rOpts...); err != nil {
+		return err
+	}
+
+	if registered {
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for sb := range s.subscribers {
+		var opts []broker.SubscribeOption
+		if queue := sb.Options().Queue; len(queue) > 0 {
+			opts = append(opts, broker.Queue(queue))
+		}
+		if cx := sb.Options().Context; cx != nil {
+			opts = append(opts, broker.SubscribeContext(cx))
+		}
+		if !sb.Options().AutoAck {
+			opts = append(opts, broker.DisableAutoAck())
+		}
+
+		log.Infof("Subscribing to topic: %s", sb.Topic())
+		sub, err := config.Broker.Subscribe(sb.Topic(), s.createSubHandler(sb), opts...)
+		if err != nil {
+			return err
+		}
+		s.subscribers[sb] = []broker.Subscriber{sub}
+	}
+
+	s.registered = true
+	return nil
+}
+
+func (s *memoryServer) Deregister() error {
+	s.RLock()
+	config := s.opts
+	s.RUnlock()
+
+	svc := &registry.Service{
+		Name:    config.Name,
+		Version: config.Version,
+		Nodes:   []*registry.Node{{Id: config.Id, Address: s.address()}},
+	}
+
+	log.Infof("Deregistering node: %s", config.Id)
+	if err := config.Registry.Deregister(context.TODO(), svc); err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if !s.registered {
+		return nil
+	}
+	s.registered = false
+
+	for sb, subs := range s.subscribers {
+		for _, sub := range subs {
+			log.Infof("unsubscribing from topic: %s", sub.Topic())
+			_ = sub.Unsubscribe()
+		}
+		s.subscribers[sb] = nil
+	}
+
+	return nil
+}
+
+func (s *memoryServer) Start() error {
+	s.RLock()
+	if s.started {
+		s.RUnlock()
+		return nil
+	}
+	config := s.opts
+	hasSubscribers := len(s.subscribers) > 0
+	s.RUnlock()
+
+	log.Infof("Server [memory] Listening on %s", s.address())
+
+	if hasSubscribers {
+		if err := config.Broker.Connect(); err != nil {
+			log.Errorf("Broker [%s] connect error: %v", config.Broker.String(), err)
+			return err
+		}
+		log.Infof("Broker [%s] Connected to %s", config.Broker.String(), config.Broker.Address())
+	}
+
+	routes.add(config.Name, s.address(), s)
+
+	// announce self to the world
+	if err := s.Register(); err != nil {
+		log.Errorf("Server register error: %v", err)
+	}
+
+	go func() {
+		t := new(time.Ticker)
+		if config.RegisterInterval > time.Duration(0) {
+			t = time.NewTicker(config.RegisterInterval)
+		}
+
+		var ch chan error
+	Loop:
+		for {
+			select {
+			case <-t.C:
+				if err := s.Register(); err != nil {
+					log.Errorf("Server register error: %v", err)
+				}
+			case ch = <-s.exit:
+				break Loop
+			}
+		}
+
+		if err := s.Deregister(); err != nil {
+			log.Errorf("Server deregister error: %v", err)
+		}
+
+		routes.remove(config.Name, s.address())
+
+		// wait for the requests in flight
+		if s.wg != nil {
+			s.wg.Wait()
+		}
+
+		ch <- nil
+
+		if hasSubscribers {
+			if err := config.Broker.Disconnect(); err != nil {
+				log.Errorf("Broker [%s] disconnect error: %v", config.Broker.String(), err)
+			}
+		}
+	}()
+
+	s.Lock()
+	s.started = true
+	s.Unlock()
+
+	return nil
+}
+
+func (s *memoryServer) Stop() error {
+	s.RLock()
+	if !s.started {
+		s.RUnlock()
+		return nil
+	}
+	s.RUnlock()
+
+	ch := make(chan error)
+	s.exit <- ch
+	err := <-ch
+
+	s.Lock()
+	s.started = false
+	s.Unlock()
+
+	return err
+}
+
+func (s *memoryServer) String() string {
+	return "memory"
+}
+
+func wait(ctx context.Context) *sync.WaitGroup {
+	if ctx == nil {
+		return nil
+	}
+	wg, ok := ctx.Value("wait").(*sync.WaitGroup)
+	if !ok {
+		return nil
+	}
+	return wg
+}
+
+// NewServer returns a new in-process server
+func NewServer(opts ...server.Option) server.Server {
+	return newServer(opts...)
+}
@@ -0,0 +1,67 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package memory
+
+import (
+	"context"
+
+	"github.com/vine-io/vine/core/codec"
+	"github.com/vine-io/vine/core/server"
+)
+
+// memoryStream implements a server side Stream on top of the codecs of the
+// request and the response
+type memoryStream struct {
+	ctx      context.Context
+	request  server.Request
+	response server.Response
+}
+
+func (m *memoryStream) Context() context.Context {
+	return m.ctx
+}
+
+func (m *memoryStream) Request() server.Request {
+	return m.request
+}
+
+func (m *memoryStream) Send(v interface{}) error {
+	return m.response.Codec().Write(&codec.Message{
+		Type:     codec.Response,
+		Target:   m.request.Service(),
+		Method:   m.request.Method(),
+		Endpoint: m.request.Endpoint(),
+	}, v)
+}
+
+func (m *memoryStream) Recv(v interface{}) error {
+	return m.request.Codec().ReadBody(v)
+}
+
+func (m *memoryStream) Error() error {
+	return nil
+}
+
+func (m *memoryStream) Close() error {
+	return nil
+}
@@ -0,0 +1,142 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package memory
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/vine-io/vine/core/broker"
+	"github.com/vine-io/vine/core/codec"
+	"github.com/vine-io/vine/core/server"
+	"github.com/vine-io/vine/core/server/internal/rpc"
+	"github.com/vine-io/vine/util/context/metadata"
+)
+
+type message struct {
+	topic       string
+	contentType string
+	payload     interface{}
+	header      map[string]string
+	body        []byte
+}
+
+func (m *message) Topic() string {
+	return m.topic
+}
+
+func (m *message) Payload() interface{} {
+	return m.payload
+}
+
+func (m *message) ContentType() string {
+	return m.contentType
+}
+
+func (m *message) Header() map[string]string {
+	return m.header
+}
+
+func (m *message) Body() []byte {
+	return m.body
+}
+
+func (m *message) Codec() codec.Reader {
+	return nil
+}
+
+// process delivers the message to every handler of the subscriber
+func process(ctx context.Context, s *rpc.Subscriber, msg server.Message, wrappers []server.SubscriberWrapper) error {
+	var errs []string
+	for _, h := range s.Handlers {
+		if h.ReqType == nil {
+			continue
+		}
+
+		req := reflect.New(h.ReqType)
+		if h.ReqType.Kind() == reflect.Ptr {
+			req = reflect.New(h.ReqType.Elem())
+		}
+		if err := RoundTrip(msg.ContentType(), req.Interface(), msg.Body()); err != nil {
+			return err
+		}
+		if h.ReqType.Kind() != reflect.Ptr {
+			req = req.Elem()
+		}
+
+		handler := h
+		fn := func(ctx context.Context, msg server.Message) error {
+			return s.Call(ctx, handler, msg)
+		}
+
+		for i := len(wrappers); i > 0; i-- {
+			fn = wrappers[i-1](fn)
+		}
+
+		m := &message{
+			topic:       s.Topic(),
+			contentType: msg.ContentType(),
+			payload:     req.Interface(),
+			header:      msg.Header(),
+			body:        msg.Body(),
+		}
+		if err := fn(ctx, m); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("subscriber error: %s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+func (s *memoryServer) createSubHandler(sb *rpc.Subscriber) broker.Handler {
+	return func(p broker.Event) error {
+		msg := p.Message()
+		if msg.Header == nil {
+			msg.Header = make(map[string]string)
+		}
+
+		ct := msg.Header["Content-Type"]
+		if len(ct) == 0 {
+			ct = DefaultContentType
+		}
+
+		hdr := make(map[string]string, len(msg.Header))
+		for k, v := range msg.Header {
+			hdr[k] = v
+		}
+		delete(hdr, "Content-Type")
+		ctx := metadata.NewContext(context.Background(), hdr)
+
+		return s.ProcessMessage(ctx, &message{
+			topic:       p.Topic(),
+			contentType: ct,
+			header:      msg.Header,
+			body:        msg.Body,
+		})
+	}
+}
@@ -0,0 +1,156 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package memory
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/vine-io/vine/core/codec"
+	"github.com/vine-io/vine/core/codec/bytes"
+	"github.com/vine-io/vine/core/codec/json"
+	"github.com/vine-io/vine/core/codec/proto"
+	"github.com/vine-io/vine/core/server"
+)
+
+// routes holds the in-process servers which are started
+var routes = &table{
+	addresses: make(map[string]server.Router),
+	services:  make(map[string][]string),
+}
+
+type table struct {
+	sync.RWMutex
+	addresses map[string]server.Router
+	// addresses of the servers by service name
+	services map[string][]string
+}
+
+func (t *table) add(name, address string, r server.Router) {
+	t.Lock()
+	defer t.Unlock()
+	if _, ok := t.addresses[address]; !ok {
+		t.services[name] = append(t.services[name], address)
+	}
+	t.addresses[address] = r
+}
+
+func (t *table) remove(name, address string) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.addresses, address)
+	addrs := t.services[name]
+	for i, a := range addrs {
+		if a == address {
+			addrs = append(addrs[:i], addrs[i+1:]...)
+			break
+		}
+	}
+	if len(addrs) == 0 {
+		delete(t.services, name)
+	} else {
+		t.services[name] = addrs
+	}
+}
+
+// Lookup returns the router of the in-process server listening on the address
+func Lookup(address string) (server.Router, bool) {
+	routes.RLock()
+	defer routes.RUnlock()
+	r, ok := routes.addresses[address]
+	return r, ok
+}
+
+// Addresses returns the addresses of the in-process servers of the service
+func Addresses(service string) []string {
+	routes.RLock()
+	defer routes.RUnlock()
+	return append([]string{}, routes.services[service]...)
+}
+
+// IsLocal reports whether the address is served in process
+func IsLocal(address string) bool {
+	return strings.HasPrefix(address, Scheme)
+}
+
+// Copy copies src into dst without sharing memory. Values of the same type
+// are copied with their generated DeepCopyInto, other values are round-tripped
+// through the marshaler of the content type.
+func Copy(contentType string, dst, src interface{}) error {
+	if src == nil || dst == nil {
+		return nil
+	}
+
+	dv := reflect.ValueOf(dst)
+	sv := reflect.ValueOf(src)
+	if dv.Kind() == reflect.Ptr && !dv.IsNil() && sv.Type() == dv.Type() {
+		if sv.IsNil() {
+			return nil
+		}
+		if m := sv.MethodByName("DeepCopyInto"); m.IsValid() &&
+			m.Type().NumIn() == 1 && m.Type().In(0) == dv.Type() && m.Type().NumOut() == 0 {
+			m.Call([]reflect.Value{dv})
+			return nil
+		}
+	}
+
+	return RoundTrip(contentType, dst, src)
+}
+
+// RoundTrip marshals src and unmarshals the result into dst using the marshaler
+// of the content type, dst never shares memory with src.
+func RoundTrip(contentType string, dst, src interface{}) error {
+	m := marshaler(contentType)
+
+	var b []byte
+	switch v := src.(type) {
+	case *bytes.Frame:
+		b = v.Data
+	case []byte:
+		b = v
+	default:
+		var err error
+		if b, err = m.Marshal(src); err != nil {
+			return err
+		}
+	}
+
+	switch v := dst.(type) {
+	case *bytes.Frame:
+		v.Data = b
+		return nil
+	case *[]byte:
+		*v = b
+		return nil
+	}
+
+	return m.Unmarshal(b, dst)
+}
+
+func marshaler(contentType string) codec.Marshaler {
+	if strings.Contains(contentType, "json") {
+		return json.Marshaler{}
+	}
+	return proto.Marshaler{}
+}
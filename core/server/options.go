@@ -45,6 +45,8 @@ type Options struct {
 	Version      string
 	HdlrWrappers []HandlerWrapper
 	SubWrappers  []SubscriberWrapper
+	// StreamWrappers wrap the Stream handed to streaming handlers
+	StreamWrappers []StreamWrapper
 
 	// RegisterCheck runs a check function before registering the service
 	RegisterCheck func(context.Context) error
@@ -246,3 +248,11 @@ func WrapSubscriber(w SubscriberWrapper) Option {
 		o.SubWrappers = append(o.SubWrappers, w)
 	}
 }
+
+// WrapStream adds a stream Wrapper to a list of options passed into the server.
+// Stream wrappers see every message sent and received by a streaming handler.
+func WrapStream(w StreamWrapper) Option {
+	return func(o *Options) {
+		o.StreamWrappers = append(o.StreamWrappers, w)
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"regexp"
 	"strings"
 
+	json "github.com/json-iterator/go"
 	"github.com/vine-io/vine/core/server"
 )
 
@@ -89,6 +90,22 @@ func Encode(e *Endpoint) map[string]string {
 	set("security", e.Security)
 	set("host", strings.Join(e.Host, ","))
 	set("stream", string(e.Stream))
+	set("cache", e.Cache)
+	set("body", e.Body)
+	set("response_body", e.ResponseBody)
+
+	if len(e.AdditionalBindings) > 0 {
+		bindings := make([]*Endpoint, 0, len(e.AdditionalBindings))
+		for _, b := range e.AdditionalBindings {
+			if b == nil {
+				continue
+			}
+			bindings = append(bindings, &Endpoint{Method: b.Method, Path: b.Path, Body: b.Body, ResponseBody: b.ResponseBody})
+		}
+		if b, err := json.Marshal(bindings); err == nil {
+			set("additional_bindings", string(b))
+		}
+	}
 
 	return ep
 }
@@ -100,16 +117,32 @@ func Decode(e map[string]string) *Endpoint {
 	}
 
 	return &Endpoint{
-		Name:        e["endpoint"],
-		Description: e["description"],
-		Handler:     e["handler"],
-		Method:      slice(e["method"]),
-		Path:        slice(e["path"]),
-		Entity:      e["entity"],
-		Security:    e["security"],
-		Host:        slice(e["host"]),
-		Stream:      e["stream"],
+		Name:               e["endpoint"],
+		Description:        e["description"],
+		Handler:            e["handler"],
+		Method:             slice(e["method"]),
+		Path:               slice(e["path"]),
+		Entity:             e["entity"],
+		Security:           e["security"],
+		Host:               slice(e["host"]),
+		Stream:             e["stream"],
+		Cache:              e["cache"],
+		Body:               e["body"],
+		ResponseBody:       e["response_body"],
+		AdditionalBindings: bindings(e["additional_bindings"]),
+	}
+}
+
+// bindings decodes the additional bindings, they are encoded as a json list
+func bindings(s string) []*Endpoint {
+	if len(s) == 0 {
+		return nil
+	}
+	var eps []*Endpoint
+	if err := json.Unmarshal([]byte(s), &eps); err != nil {
+		return nil
 	}
+	return eps
 }
 
 // Validate validates an endpoint to guarantee it won't blow up when being served
@@ -122,7 +155,30 @@ func Validate(e *Endpoint) error {
 		return errors.New("name required")
 	}
 
-	for _, p := range e.Path {
+	if err := validatePaths(e.Path); err != nil {
+		return err
+	}
+	for _, b := range e.AdditionalBindings {
+		if b == nil || len(b.Method) == 0 || len(b.Path) == 0 {
+			return errors.New("invalid additional binding")
+		}
+		if err := validatePaths(b.Path); err != nil {
+			return err
+		}
+	}
+
+	if len(e.Handler) == 0 {
+		return errors.New("invalid handler")
+	}
+
+	return nil
+}
+
+func validatePaths(paths []string) error {
+	for _, p := range paths {
+		if len(p) == 0 {
+			return errors.New("invalid path")
+		}
 		ps := p[0]
 		pe := p[len(p)-1]
 
@@ -138,10 +194,6 @@ func Validate(e *Endpoint) error {
 		}
 	}
 
-	if len(e.Handler) == 0 {
-		return errors.New("invalid handler")
-	}
-
 	return nil
 }
 
@@ -167,7 +219,8 @@ func NewGateway() Gateway {
 }
 */
 
-// WithEndpoint returns a server.HandlerOption with endpoint metadata set
+// WithEndpoint returns a server.HandlerOption with endpoint metadata set.
+// Flags set on the endpoint before, e.g. by WithSSE, are kept.
 //
 // Usage:
 //
@@ -178,5 +231,35 @@ func NewGateway() Gateway {
 //		},
 //	))
 func WithEndpoint(e *Endpoint) server.HandlerOption {
-	return server.EndpointMetadata(e.Name, Encode(e))
+	return func(o *server.HandlerOptions) {
+		md := Encode(e)
+		for k, v := range o.Metadata[e.Name] {
+			if _, ok := md[k]; !ok {
+				md[k] = v
+			}
+		}
+		server.EndpointMetadata(e.Name, md)(o)
+	}
+}
+
+// WithSSE returns a server.HandlerOption which flags the server streaming endpoint
+// to be served as Server-Sent Events by the rpc handler, whatever the Accept header.
+//
+// Usage:
+//
+//	proto.RegisterHandler(service.Server(), new(Handler), api.WithSSE("Greeter.Watch"))
+func WithSSE(name string) server.HandlerOption {
+	return withFlag(name, SSE, "true")
+}
+
+// withFlag sets a single key in the metadata of the named endpoint
+func withFlag(name, key, value string) server.HandlerOption {
+	return func(o *server.HandlerOptions) {
+		md, ok := o.Metadata[name]
+		if !ok {
+			md = make(map[string]string)
+			o.Metadata[name] = md
+		}
+		md[key] = value
+	}
 }
@@ -49,6 +49,15 @@ type Endpoint struct {
 	Body string `protobuf:"bytes,9,opt,name=body,proto3" json:"body,omitempty"`
 	// Stream flag
 	Stream string `protobuf:"bytes,10,opt,name=stream,proto3" json:"stream,omitempty"`
+	// Cache the time responses are cached by the gateway e.g. 60s
+	Cache string `protobuf:"bytes,11,opt,name=cache,proto3" json:"cache,omitempty"`
+	// Response body source
+	// "" - the whole response message
+	// "string" - inner message value
+	ResponseBody string `protobuf:"bytes,12,opt,name=response_body,json=responseBody,proto3" json:"response_body,omitempty"`
+	// Additional bindings of the method, each with its own
+	// method, path, body and response body
+	AdditionalBindings []*Endpoint `protobuf:"bytes,13,rep,name=additional_bindings,json=additionalBindings,proto3" json:"additional_bindings,omitempty"`
 }
 
 func (m *Endpoint) Reset()         { *m = Endpoint{} }
@@ -401,50 +410,53 @@ func init() {
 }
 
 var fileDescriptor_406ea03108675ff4 = []byte{
-	// 677 bytes of a gzipped FileDescriptorProto
+	// 733 bytes of a gzipped FileDescriptorProto
 	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x55, 0xcd, 0x6e, 0xd3, 0x4a,
-	0x14, 0x8e, 0xe3, 0xc4, 0x71, 0x4e, 0xee, 0xbd, 0xea, 0x1d, 0x41, 0x34, 0x44, 0xc8, 0x8d, 0xb2,
-	0xa1, 0x45, 0x6a, 0x52, 0x0a, 0x42, 0x08, 0x89, 0x05, 0xa5, 0x85, 0x2e, 0x2b, 0xb3, 0x63, 0xe7,
-	0xd8, 0xa7, 0xf1, 0xa8, 0x89, 0xc7, 0x78, 0xc6, 0x41, 0xe1, 0x29, 0x78, 0x13, 0x96, 0xbc, 0x42,
-	0x97, 0x95, 0xd8, 0xb0, 0x84, 0xf6, 0x2d, 0x58, 0xa1, 0xf9, 0xa9, 0x9b, 0x42, 0x2a, 0xa4, 0xaa,
-	0x8b, 0xaa, 0xe7, 0xe7, 0x3b, 0xdf, 0xcc, 0x39, 0xe7, 0xf3, 0x04, 0x36, 0x27, 0x4c, 0xa6, 0xe5,
-	0x78, 0x18, 0xf3, 0xd9, 0x68, 0xce, 0x32, 0xdc, 0x62, 0x5c, 0xff, 0x1f, 0x4d, 0xd9, 0x78, 0x14,
-	0xe5, 0x4c, 0xfd, 0x0d, 0xf3, 0x82, 0x4b, 0x4e, 0xdc, 0x28, 0x67, 0xbd, 0x27, 0xd7, 0xe1, 0x63,
-	0x5e, 0xe0, 0xa8, 0xc0, 0x09, 0x13, 0xb2, 0x58, 0x54, 0x86, 0x29, 0x1d, 0xfc, 0x74, 0xc0, 0xdf,
-	0xcf, 0x92, 0x9c, 0xb3, 0x4c, 0x12, 0x02, 0x8d, 0x2c, 0x9a, 0x21, 0x75, 0xfa, 0xce, 0x46, 0x3b,
-	0xd4, 0x36, 0xe9, 0x43, 0x27, 0x41, 0x11, 0x17, 0x2c, 0x97, 0x8c, 0x67, 0xb4, 0xae, 0x53, 0xcb,
-	0x21, 0x42, 0xa1, 0x95, 0x46, 0x59, 0x32, 0xc5, 0x82, 0xba, 0x3a, 0x7b, 0xe1, 0x2a, 0xbe, 0x94,
-	0x0b, 0x49, 0x1b, 0x7d, 0x57, 0xf1, 0x29, 0x9b, 0x74, 0xc1, 0x9b, 0xa1, 0x4c, 0x79, 0x42, 0x9b,
-	0x3a, 0x6a, 0x3d, 0x85, 0xcd, 0x23, 0x99, 0x52, 0xcf, 0x60, 0x95, 0xad, 0xb0, 0x98, 0x49, 0x26,
-	0x17, 0xb4, 0xa5, 0x89, 0xad, 0x47, 0x7a, 0xe0, 0x0b, 0x8c, 0xcb, 0x42, 0x65, 0x7c, 0x9d, 0xa9,
-	0x7c, 0xc5, 0x33, 0xe6, 0xc9, 0x82, 0xb6, 0x4d, 0x0f, 0xca, 0x56, 0x3c, 0x42, 0x16, 0x18, 0xcd,
-	0x28, 0x18, 0x1e, 0xe3, 0x0d, 0xbe, 0x3a, 0xd0, 0xdc, 0x9f, 0xe3, 0x35, 0x9d, 0xff, 0x07, 0x75,
-	0x96, 0xd8, 0x86, 0xeb, 0x2c, 0x21, 0xf7, 0xa1, 0x2d, 0xd9, 0x0c, 0x85, 0x8c, 0x66, 0xb9, 0xee,
-	0xd4, 0x0d, 0x2f, 0x03, 0x64, 0x08, 0x5e, 0x8a, 0x51, 0x82, 0x85, 0xee, 0xb6, 0xb3, 0xd3, 0x1d,
-	0xaa, 0xfd, 0x68, 0xf6, 0xe1, 0x81, 0x4e, 0xec, 0x67, 0xb2, 0x58, 0x84, 0x16, 0xa5, 0x4e, 0x4c,
-	0x22, 0x19, 0xd1, 0xa6, 0x39, 0x51, 0xd9, 0xbd, 0x3d, 0xe8, 0x2c, 0x41, 0xc9, 0x1a, 0xb8, 0xc7,
-	0xb8, 0xb0, 0x77, 0x52, 0x26, 0x59, 0x87, 0xe6, 0x3c, 0x9a, 0x96, 0xa8, 0x6f, 0xd5, 0xd9, 0x69,
-	0xeb, 0x33, 0x0e, 0x23, 0x56, 0x84, 0x26, 0xfe, 0xbc, 0xfe, 0xcc, 0x19, 0x3c, 0x05, 0xff, 0x35,
-	0x9b, 0xe2, 0x1e, 0x8a, 0x78, 0x65, 0x5f, 0x5d, 0xf0, 0xf8, 0xd1, 0x91, 0x40, 0xa9, 0x59, 0xdc,
-	0xd0, 0x7a, 0x83, 0x31, 0x80, 0xaa, 0x3b, 0xa8, 0xee, 0xf7, 0x47, 0x25, 0x81, 0x86, 0x60, 0x1f,
-	0xd1, 0xd6, 0x69, 0x5b, 0xb1, 0x4d, 0x31, 0x9b, 0xc8, 0xd4, 0x8e, 0xc4, 0x7a, 0xe4, 0x0e, 0x34,
-	0xe3, 0xb4, 0xcc, 0x8e, 0x69, 0xa3, 0xef, 0x6c, 0xfc, 0x13, 0x1a, 0x67, 0xb0, 0x0d, 0x0d, 0x75,
-	0xdd, 0x15, 0xad, 0x75, 0xc1, 0xd3, 0x2d, 0x08, 0x5a, 0x37, 0xba, 0x30, 0xde, 0xe0, 0xb3, 0x0b,
-	0xad, 0x10, 0xdf, 0x97, 0x78, 0x45, 0x3b, 0xa6, 0xf0, 0x77, 0xed, 0x98, 0x5d, 0x19, 0xed, 0x6c,
-	0x57, 0xfb, 0x70, 0xf5, 0x3e, 0xa8, 0x9e, 0x95, 0x65, 0x5a, 0xb9, 0x91, 0x07, 0xe0, 0x4e, 0x50,
-	0xda, 0xf5, 0xdd, 0xbd, 0x02, 0x7f, 0x83, 0xd2, 0x60, 0x15, 0x82, 0x3c, 0x84, 0x46, 0xae, 0x64,
-	0xdd, 0x5c, 0x5a, 0xf4, 0x05, 0xf2, 0x90, 0x0b, 0x0b, 0xd5, 0x98, 0x4a, 0x8e, 0xde, 0x92, 0x1c,
-	0xd7, 0xc0, 0x2d, 0x8b, 0xa9, 0xd5, 0xb4, 0x32, 0x6f, 0x67, 0xf1, 0xbd, 0x97, 0xe0, 0x5f, 0x5c,
-	0xf4, 0xa6, 0x14, 0xbb, 0xd0, 0xae, 0x3a, 0xb8, 0xa9, 0xfe, 0xbe, 0x38, 0xe0, 0x87, 0x28, 0x72,
-	0x9e, 0x09, 0x24, 0x01, 0x80, 0x90, 0x91, 0x2c, 0xc5, 0x2b, 0x9e, 0x18, 0x31, 0x35, 0xc3, 0xa5,
-	0x08, 0x79, 0x54, 0xad, 0xa9, 0xae, 0xa7, 0x79, 0xcf, 0x4e, 0xd3, 0x94, 0x5f, 0xf7, 0xe5, 0xe8,
-	0x91, 0xba, 0x97, 0x23, 0xbd, 0xa5, 0x2f, 0xe7, 0x03, 0xb4, 0xde, 0x62, 0x31, 0x67, 0x31, 0xae,
-	0x94, 0xff, 0x26, 0xf8, 0x68, 0x9f, 0x4a, 0x4b, 0xf3, 0xaf, 0xf9, 0xc8, 0x6d, 0x30, 0xac, 0xd2,
-	0x64, 0x4b, 0xbd, 0x50, 0x9a, 0x49, 0x58, 0xfd, 0xfd, 0x3f, 0xac, 0x5e, 0x5e, 0x7b, 0x46, 0x58,
-	0x41, 0x76, 0x5f, 0x9c, 0xfc, 0x08, 0x6a, 0x27, 0x67, 0x81, 0x73, 0x7a, 0x16, 0x38, 0xdf, 0xcf,
-	0x02, 0xe7, 0xd3, 0x79, 0x50, 0x3b, 0x3d, 0x0f, 0x6a, 0xdf, 0xce, 0x83, 0xda, 0xbb, 0xf5, 0xbf,
-	0xfc, 0x12, 0x8c, 0x3d, 0xfd, 0x96, 0x3f, 0xfe, 0x15, 0x00, 0x00, 0xff, 0xff, 0x91, 0x43, 0x87,
-	0x3e, 0x33, 0x06, 0x00, 0x00,
+	0x14, 0x8e, 0xe3, 0xfc, 0x9e, 0xb4, 0x57, 0xbd, 0x73, 0x2f, 0xd1, 0x10, 0x21, 0x37, 0x0a, 0x0b,
+	0x5a, 0xa4, 0x26, 0xa5, 0x20, 0x84, 0x90, 0x40, 0x22, 0xb4, 0xd0, 0x65, 0x65, 0x76, 0x6c, 0xaa,
+	0x89, 0x7d, 0x1a, 0x8f, 0x9a, 0xd8, 0xc6, 0x33, 0x09, 0x0a, 0x4f, 0xc1, 0x9b, 0xb0, 0xe4, 0x15,
+	0xba, 0xac, 0xc4, 0x86, 0x25, 0xb4, 0xcf, 0xc0, 0x1e, 0xcd, 0x4f, 0xdc, 0xb4, 0xa4, 0x42, 0xaa,
+	0xba, 0x88, 0x72, 0x7e, 0xbe, 0xf9, 0x66, 0xe6, 0x3b, 0xe7, 0x8c, 0x61, 0x73, 0xc8, 0x65, 0x34,
+	0x19, 0x74, 0x83, 0x64, 0xdc, 0x9b, 0xf2, 0x18, 0xb7, 0x78, 0xa2, 0xff, 0x7b, 0x23, 0x3e, 0xe8,
+	0xb1, 0x94, 0xab, 0x5f, 0x37, 0xcd, 0x12, 0x99, 0x10, 0x97, 0xa5, 0xbc, 0xf5, 0xe4, 0x3a, 0x7c,
+	0x90, 0x64, 0xd8, 0xcb, 0x70, 0xc8, 0x85, 0xcc, 0x66, 0xb9, 0x61, 0x96, 0x76, 0x7e, 0x15, 0xa1,
+	0xb6, 0x17, 0x87, 0x69, 0xc2, 0x63, 0x49, 0x08, 0x94, 0x62, 0x36, 0x46, 0xea, 0xb4, 0x9d, 0x8d,
+	0xba, 0xaf, 0x6d, 0xd2, 0x86, 0x46, 0x88, 0x22, 0xc8, 0x78, 0x2a, 0x79, 0x12, 0xd3, 0xa2, 0x4e,
+	0x2d, 0x86, 0x08, 0x85, 0x6a, 0xc4, 0xe2, 0x70, 0x84, 0x19, 0x75, 0x75, 0x76, 0xee, 0x2a, 0xbe,
+	0x28, 0x11, 0x92, 0x96, 0xda, 0xae, 0xe2, 0x53, 0x36, 0x69, 0x42, 0x65, 0x8c, 0x32, 0x4a, 0x42,
+	0x5a, 0xd6, 0x51, 0xeb, 0x29, 0x6c, 0xca, 0x64, 0x44, 0x2b, 0x06, 0xab, 0x6c, 0x85, 0xc5, 0x58,
+	0x72, 0x39, 0xa3, 0x55, 0x4d, 0x6c, 0x3d, 0xd2, 0x82, 0x9a, 0xc0, 0x60, 0x92, 0xa9, 0x4c, 0x4d,
+	0x67, 0x72, 0x5f, 0xf1, 0x0c, 0x92, 0x70, 0x46, 0xeb, 0xe6, 0x0e, 0xca, 0x56, 0x3c, 0x42, 0x66,
+	0xc8, 0xc6, 0x14, 0x0c, 0x8f, 0xf1, 0xc8, 0xff, 0x50, 0x0e, 0x58, 0x10, 0x21, 0x6d, 0xe8, 0xb0,
+	0x71, 0xc8, 0x7d, 0x58, 0xcd, 0x50, 0xa4, 0x49, 0x2c, 0xf0, 0x50, 0x53, 0xad, 0xe8, 0xec, 0xca,
+	0x3c, 0xd8, 0x57, 0x94, 0x2f, 0xe1, 0x3f, 0x16, 0x86, 0x5c, 0x09, 0xc0, 0x46, 0x87, 0x03, 0x1e,
+	0x87, 0x3c, 0x1e, 0x0a, 0xba, 0xda, 0x76, 0x37, 0x1a, 0x3b, 0xab, 0x5d, 0x55, 0x9b, 0xb9, 0xac,
+	0x3e, 0xb9, 0x40, 0xf6, 0x2d, 0xb0, 0xf3, 0xcd, 0x81, 0xf2, 0xde, 0x14, 0xaf, 0x11, 0xfd, 0x1f,
+	0x28, 0xf2, 0xd0, 0x6a, 0x5d, 0xe4, 0x21, 0xb9, 0x07, 0x75, 0xc9, 0xc7, 0x28, 0x24, 0x1b, 0xa7,
+	0x5a, 0x64, 0xd7, 0xbf, 0x08, 0x90, 0x2e, 0x54, 0x22, 0x64, 0x21, 0x66, 0x5a, 0xe8, 0xc6, 0x4e,
+	0xd3, 0x6c, 0xaf, 0xd8, 0xbb, 0xfb, 0x3a, 0xb1, 0x17, 0xcb, 0x6c, 0xe6, 0x5b, 0x94, 0xda, 0x31,
+	0x64, 0x92, 0xd1, 0xb2, 0xd9, 0x51, 0xd9, 0xad, 0x5d, 0x68, 0x2c, 0x40, 0xc9, 0x1a, 0xb8, 0xc7,
+	0x38, 0xb3, 0x67, 0x52, 0x26, 0x59, 0x87, 0xf2, 0x94, 0x8d, 0x26, 0xa8, 0x4f, 0xd5, 0xd8, 0xa9,
+	0xeb, 0x3d, 0x0e, 0x18, 0xcf, 0x7c, 0x13, 0x7f, 0x5e, 0x7c, 0xe6, 0x74, 0x9e, 0x42, 0xed, 0x0d,
+	0x1f, 0xe1, 0x2e, 0x8a, 0x60, 0xe9, 0xbd, 0x9a, 0x50, 0x49, 0x8e, 0x8e, 0x04, 0x4a, 0xcd, 0xe2,
+	0xfa, 0xd6, 0xeb, 0x0c, 0x00, 0xd4, 0xba, 0xfd, 0xfc, 0x7c, 0x7f, 0xac, 0x24, 0x50, 0x12, 0xfc,
+	0x13, 0xda, 0x75, 0xda, 0x56, 0x6c, 0x23, 0x8c, 0x87, 0x32, 0xb2, 0x92, 0x58, 0x4f, 0x97, 0x35,
+	0x9a, 0xc4, 0xc7, 0xb4, 0xd4, 0x76, 0x36, 0x56, 0x7c, 0xe3, 0x74, 0xb6, 0xa1, 0xa4, 0x8e, 0xbb,
+	0xe4, 0x6a, 0x4d, 0xa8, 0xe8, 0x2b, 0x08, 0x5a, 0x34, 0x2d, 0x69, 0xbc, 0xce, 0x17, 0x17, 0xaa,
+	0x3e, 0x7e, 0x98, 0xe0, 0xa5, 0xb6, 0x35, 0x0b, 0xaf, 0xb6, 0xad, 0xa9, 0x95, 0xb6, 0xc9, 0x76,
+	0x5e, 0x0f, 0x57, 0xd7, 0x83, 0x6a, 0xad, 0x2c, 0xd3, 0xd2, 0x8a, 0x3c, 0x00, 0x77, 0x88, 0xd2,
+	0x96, 0xef, 0xce, 0x25, 0xf8, 0x5b, 0x94, 0x06, 0xab, 0x10, 0xe4, 0x21, 0x94, 0x52, 0x35, 0x51,
+	0xe5, 0x85, 0x42, 0xcf, 0x91, 0x07, 0x89, 0xb0, 0x50, 0x8d, 0xc9, 0x27, 0xa1, 0xb2, 0x30, 0x09,
+	0x6b, 0xe0, 0x4e, 0xb2, 0x91, 0x1d, 0x27, 0x65, 0xde, 0x4e, 0xe1, 0x5b, 0xaf, 0xa0, 0x36, 0x3f,
+	0xe8, 0x4d, 0x29, 0xfa, 0x50, 0xcf, 0x6f, 0x70, 0xd3, 0xfe, 0xfb, 0xea, 0x40, 0xcd, 0xb7, 0x63,
+	0x4a, 0x3c, 0x00, 0x21, 0x99, 0x9c, 0x88, 0xd7, 0x49, 0x68, 0x9a, 0xa9, 0xec, 0x2f, 0x44, 0xc8,
+	0xa3, 0xbc, 0x4c, 0x45, 0xad, 0xe6, 0x5d, 0xab, 0xa6, 0x59, 0x7e, 0xdd, 0xe4, 0x68, 0x49, 0xdd,
+	0x0b, 0x49, 0x6f, 0x69, 0x72, 0x3e, 0x42, 0xf5, 0x1d, 0x66, 0x53, 0x1e, 0xe0, 0xd2, 0xf6, 0xdf,
+	0x84, 0x1a, 0xda, 0xe7, 0xc4, 0xd2, 0x5c, 0x79, 0x63, 0xf2, 0x34, 0xd9, 0x52, 0x8f, 0xa3, 0x66,
+	0x12, 0xb6, 0xff, 0xfe, 0xed, 0xe6, 0x8f, 0xbe, 0xdd, 0xc3, 0xcf, 0x21, 0xfd, 0x17, 0x27, 0x3f,
+	0xbd, 0xc2, 0xc9, 0x99, 0xe7, 0x9c, 0x9e, 0x79, 0xce, 0x8f, 0x33, 0xcf, 0xf9, 0x7c, 0xee, 0x15,
+	0x4e, 0xcf, 0xbd, 0xc2, 0xf7, 0x73, 0xaf, 0xf0, 0x7e, 0xfd, 0x2f, 0x1f, 0xa1, 0x41, 0x45, 0x7f,
+	0x46, 0x1e, 0xff, 0x1e, 0x00, 0xbb, 0x38, 0xa8, 0xfe, 0xae, 0x06, 0x00, 0x00,
 }
 
 func (m *Endpoint) XSize() (n int) {
@@ -499,6 +511,20 @@ func (m *Endpoint) XSize() (n int) {
 	if l > 0 {
 		n += 1 + l + sovApi(uint64(l))
 	}
+	l = len(m.Cache)
+	if l > 0 {
+		n += 1 + l + sovApi(uint64(l))
+	}
+	l = len(m.ResponseBody)
+	if l > 0 {
+		n += 1 + l + sovApi(uint64(l))
+	}
+	if len(m.AdditionalBindings) > 0 {
+		for _, e := range m.AdditionalBindings {
+			l = e.XSize()
+			n += 1 + l + sovApi(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -739,6 +765,34 @@ func (m *Endpoint) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.AdditionalBindings) > 0 {
+		for iNdEx := len(m.AdditionalBindings) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.AdditionalBindings[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintApi(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x6a
+		}
+	}
+	if len(m.ResponseBody) > 0 {
+		i -= len(m.ResponseBody)
+		copy(dAtA[i:], m.ResponseBody)
+		i = encodeVarintApi(dAtA, i, uint64(len(m.ResponseBody)))
+		i--
+		dAtA[i] = 0x62
+	}
+	if len(m.Cache) > 0 {
+		i -= len(m.Cache)
+		copy(dAtA[i:], m.Cache)
+		i = encodeVarintApi(dAtA, i, uint64(len(m.Cache)))
+		i--
+		dAtA[i] = 0x5a
+	}
 	if len(m.Stream) > 0 {
 		i -= len(m.Stream)
 		copy(dAtA[i:], m.Stream)
@@ -1620,6 +1674,104 @@ func (m *Endpoint) Unmarshal(dAtA []byte) error {
 			}
 			m.Stream = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 11:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Cache", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowApi
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthApi
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Cache = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 12:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ResponseBody", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowApi
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthApi
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ResponseBody = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 13:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field AdditionalBindings", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowApi
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthApi
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthApi
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.AdditionalBindings = append(m.AdditionalBindings, &Endpoint{})
+			if err := m.AdditionalBindings[len(m.AdditionalBindings)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipApi(dAtA[iNdEx:])
@@ -0,0 +1,383 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package apikey issues api keys to the clients of the gateway and checks
+// them on the endpoints whose security includes the apiKeys scheme.
+//
+// A key is sent as "<id>.<secret>" in the X-API-Key header or the api_key
+// query parameter. Its scopes are patterns matched against
+// "<service>:<endpoint>" e.g. "go.vine.greeter:Greeter.*", a bare service
+// name allows every endpoint of the service.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	json "github.com/json-iterator/go"
+	"github.com/vine-io/vine/lib/api"
+	"github.com/vine-io/vine/lib/api/server"
+	"github.com/vine-io/vine/lib/cache"
+	"github.com/vine-io/vine/lib/errors"
+	log "github.com/vine-io/vine/lib/logger"
+)
+
+const errorId = "go.vine.api"
+
+// verifiedKey marks the context of a request whose key has been checked
+type verifiedKey struct{}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func random(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Allows reports whether the scopes of the key allow the endpoint of the service
+func (m *Key) Allows(service, endpoint string) bool {
+	if len(m.Scopes) == 0 {
+		return true
+	}
+	name := service + ":" + endpoint
+	for _, s := range m.Scopes {
+		if s == "*" || s == service || s == name {
+			return true
+		}
+		if ok, _ := path.Match(s, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// window returns the start of the current quota period and its length
+func (m *Key) window(now time.Time) (time.Time, time.Duration) {
+	d, err := time.ParseDuration(m.Period)
+	if err != nil || d <= 0 {
+		d = 24 * time.Hour
+	}
+	return now.Truncate(d), d
+}
+
+// Manager issues and checks api keys
+type Manager struct {
+	opts Options
+}
+
+// Init applies the options, it must be called before the Manager is used
+func (m *Manager) Init(opts ...Option) {
+	for _, o := range opts {
+		o(&m.opts)
+	}
+}
+
+func (m *Manager) Options() Options {
+	return m.opts
+}
+
+// Create issues a key, the token returned is the only copy of its secret
+func (m *Manager) Create(ctx context.Context, req *CreateRequest) (*Key, string, error) {
+	if len(req.Name) == 0 {
+		return nil, "", errors.BadRequest(errorId, "missing key name")
+	}
+	if req.Quota < 0 {
+		return nil, "", errors.BadRequest(errorId, "invalid quota %d", req.Quota)
+	}
+	if len(req.Period) > 0 {
+		if d, err := time.ParseDuration(req.Period); err != nil || d <= 0 {
+			return nil, "", errors.BadRequest(errorId, "invalid period %q", req.Period)
+		}
+	}
+	for _, s := range req.Scopes {
+		if _, err := path.Match(s, ""); err != nil {
+			return nil, "", errors.BadRequest(errorId, "invalid scope %q", s)
+		}
+	}
+
+	now := time.Now()
+	k := &Key{
+		Name:    req.Name,
+		Scopes:  req.Scopes,
+		Quota:   req.Quota,
+		Period:  req.Period,
+		Created: now.Unix(),
+	}
+	if req.Quota > 0 && len(k.Period) == 0 {
+		k.Period = "24h"
+	}
+	if len(req.Ttl) > 0 {
+		d, err := time.ParseDuration(req.Ttl)
+		if err != nil || d <= 0 {
+			return nil, "", errors.BadRequest(errorId, "invalid ttl %q", req.Ttl)
+		}
+		k.Expires = now.Add(d).Unix()
+	}
+
+	id, err := random(8)
+	if err != nil {
+		return nil, "", err
+	}
+	b, err := random(24)
+	if err != nil {
+		return nil, "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b)
+	k.Id = hex.EncodeToString(id)
+	k.Hash = hash(secret)
+
+	if err := m.opts.Store.Write(ctx, k); err != nil {
+		return nil, "", err
+	}
+
+	return k, k.Id + "." + secret, nil
+}
+
+// List returns the keys with their usage, the hashes are left out
+func (m *Manager) List(ctx context.Context) ([]*Key, error) {
+	keys, err := m.opts.Store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, k := range keys {
+		k.Hash = ""
+		if k.Usage, err = m.usage(ctx, k, now); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// Revoke disables the key, it is kept to report its usage
+func (m *Manager) Revoke(ctx context.Context, id string) (*Key, error) {
+	k, err := m.opts.Store.Read(ctx, id)
+	if err == cache.ErrNotFound {
+		return nil, errors.NotFound(errorId, "key %s not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if k.Revoked == 0 {
+		k.Revoked = time.Now().Unix()
+		if err := m.opts.Store.Write(ctx, k); err != nil {
+			return nil, err
+		}
+	}
+	k.Hash = ""
+	return k, nil
+}
+
+func (m *Manager) usage(ctx context.Context, k *Key, now time.Time) (*Usage, error) {
+	u := &Usage{}
+	var err error
+	if u.Total, err = m.opts.Store.Count(ctx, k.Id); err != nil {
+		return nil, err
+	}
+	if k.Quota > 0 {
+		start, d := k.window(now)
+		if u.Window, err = m.opts.Store.Count(ctx, k.Id+"/"+strconv.FormatInt(start.Unix(), 10)); err != nil {
+			return nil, err
+		}
+		u.ResetAt = start.Add(d).Unix()
+	}
+	return u, nil
+}
+
+// Verify checks the token may call the endpoint of the service and counts the request
+func (m *Manager) Verify(ctx context.Context, token, service, endpoint string) (*Key, error) {
+	id, secret := token, ""
+	if i := strings.Index(token, "."); i > 0 {
+		id, secret = token[:i], token[i+1:]
+	}
+	if len(secret) == 0 {
+		return nil, errors.Unauthorized(errorId, "invalid api key")
+	}
+
+	k, err := m.opts.Store.Read(ctx, id)
+	if err == cache.ErrNotFound {
+		return nil, errors.Unauthorized(errorId, "invalid api key")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hash(secret))) != 1 {
+		return nil, errors.Unauthorized(errorId, "invalid api key")
+	}
+
+	now := time.Now()
+	switch {
+	case k.Revoked > 0:
+		return nil, errors.Unauthorized(errorId, "api key revoked")
+	case k.Expires > 0 && now.Unix() >= k.Expires:
+		return nil, errors.Unauthorized(errorId, "api key expired")
+	case !k.Allows(service, endpoint):
+		return nil, errors.Forbidden(errorId, "api key not allowed to call %s", endpoint)
+	}
+
+	if _, err := m.opts.Store.Incr(ctx, k.Id, 0); err != nil {
+		return nil, err
+	}
+
+	k.Usage = &Usage{}
+	if k.Quota > 0 {
+		start, d := k.window(now)
+		n, err := m.opts.Store.Incr(ctx, k.Id+"/"+strconv.FormatInt(start.Unix(), 10), d)
+		if err != nil {
+			return nil, err
+		}
+		k.Usage.Window = n
+		k.Usage.ResetAt = start.Add(d).Unix()
+		if n > k.Quota {
+			return k, errors.TooManyRequests(errorId, "api key quota of %d requests exceeded", k.Quota)
+		}
+	}
+
+	return k, nil
+}
+
+// secured reports whether the security of the endpoint includes the scheme
+func (m *Manager) secured(e *api.Endpoint) bool {
+	for _, s := range strings.Split(e.Security, ",") {
+		if strings.TrimSpace(s) == m.opts.Scheme {
+			return true
+		}
+	}
+	return false
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	ce := errors.FromErr(err)
+	if ce.Code == 0 {
+		ce = errors.InternalServerError(errorId, err.Error())
+	}
+	b, _ := json.Marshal(ce)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(int(ce.Code))
+	w.Write(b)
+}
+
+// Handler checks the key of the requests to the endpoints requiring one before h
+func (m *Manager) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.opts.Router == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// the router may change the request, so route a copy
+		svc, err := m.opts.Router.Route(r.Clone(r.Context()))
+		if err != nil || svc == nil || svc.Endpoint == nil || !m.secured(svc.Endpoint) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		token := r.Header.Get(m.opts.Header)
+		if len(token) == 0 && len(m.opts.Query) > 0 {
+			token = r.URL.Query().Get(m.opts.Query)
+		}
+		if len(token) == 0 {
+			writeError(w, errors.Unauthorized(errorId, "missing api key"))
+			return
+		}
+
+		k, err := m.Verify(r.Context(), token, svc.Name, svc.Endpoint.Name)
+		if k != nil && k.Quota > 0 && k.Usage != nil {
+			remaining := k.Quota - k.Usage.Window
+			if remaining < 0 {
+				remaining = 0
+			}
+			w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(k.Quota, 10))
+			w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(k.Usage.ResetAt, 10))
+		}
+		if err != nil {
+			if ce := errors.FromErr(err); ce.Code == errors.StatusTooManyRequests {
+				w.Header().Set("Retry-After", strconv.FormatInt(k.Usage.ResetAt-time.Now().Unix(), 10))
+			} else if ce.Code == 0 {
+				log.Errorf("unable to verify api key: %v", err)
+			}
+			writeError(w, err)
+			return
+		}
+
+		// the handlers which check the calls themselves don't count it again
+		r = r.WithContext(context.WithValue(r.Context(), verifiedKey{}, svc.Name+":"+svc.Endpoint.Name))
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Authorize checks the key in the metadata of a call to the endpoint of the
+// service when the endpoint requires one. It is the handler.Authorizer of the
+// graphql and grpc handlers, which only read the key from the header.
+func (m *Manager) Authorize(ctx context.Context, service string, ep *api.Endpoint, md map[string]string) error {
+	if ep == nil || !m.secured(ep) {
+		return nil
+	}
+	if v, ok := ctx.Value(verifiedKey{}).(string); ok && v == service+":"+ep.Name {
+		return nil
+	}
+
+	var token string
+	for k, v := range md {
+		if strings.EqualFold(k, m.opts.Header) {
+			token = v
+			break
+		}
+	}
+	if len(token) == 0 {
+		return errors.Unauthorized(errorId, "missing api key")
+	}
+
+	_, err := m.Verify(ctx, token, service, ep.Name)
+	if err != nil && errors.FromErr(err).Code == 0 {
+		log.Errorf("unable to verify api key: %v", err)
+	}
+	return err
+}
+
+// Wrapper returns the key check as a server.Wrapper
+func (m *Manager) Wrapper() server.Wrapper {
+	return m.Handler
+}
+
+// NewManager returns a Manager
+func NewManager(opts ...Option) *Manager {
+	return &Manager{opts: NewOptions(opts...)}
+}
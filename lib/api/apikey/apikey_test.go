@@ -0,0 +1,247 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package apikey
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	proto "github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	membroker "github.com/vine-io/vine/core/broker/memory"
+	"github.com/vine-io/vine/core/client"
+	cmemory "github.com/vine-io/vine/core/client/memory"
+	regMemory "github.com/vine-io/vine/core/registry/memory"
+	"github.com/vine-io/vine/core/server"
+	smemory "github.com/vine-io/vine/core/server/memory"
+	"github.com/vine-io/vine/lib/api"
+	"github.com/vine-io/vine/lib/api/router"
+	"github.com/vine-io/vine/lib/cache/memory"
+	"github.com/vine-io/vine/lib/errors"
+	"github.com/vine-io/vine/util/context/metadata"
+)
+
+// testRouter routes every request to the endpoint
+type testRouter struct {
+	ep *api.Endpoint
+}
+
+func (t *testRouter) Options() router.Options                        { return router.Options{} }
+func (t *testRouter) Close() error                                   { return nil }
+func (t *testRouter) Register(ep *api.Endpoint) error                { return nil }
+func (t *testRouter) Deregister(ep *api.Endpoint) error              { return nil }
+func (t *testRouter) Endpoint(r *http.Request) (*api.Service, error) { return t.Route(r) }
+
+func (t *testRouter) Route(r *http.Request) (*api.Service, error) {
+	return &api.Service{Name: "go.vine.api.greeter", Endpoint: t.ep}, nil
+}
+
+func code(err error) errors.StatusCode {
+	return errors.FromErr(err).Code
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(WithStore(NewStore(memory.NewCache())))
+
+	_, _, err := m.Create(ctx, &CreateRequest{})
+	assert.Equal(t, errors.StatusBadRequest, code(err))
+	_, _, err = m.Create(ctx, &CreateRequest{Name: "a", Period: "soon"})
+	assert.Equal(t, errors.StatusBadRequest, code(err))
+
+	k, token, err := m.Create(ctx, &CreateRequest{Name: "partner", Scopes: []string{"go.vine.api.greeter:Greeter.*"}, Quota: 2, Period: "1h"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotContains(t, k.Hash, token)
+
+	_, err = m.Verify(ctx, token, "go.vine.api.greeter", "Greeter.Call")
+	assert.NoError(t, err)
+	_, err = m.Verify(ctx, token, "go.vine.api.other", "Other.Call")
+	assert.Equal(t, errors.StatusForbidden, code(err))
+	_, err = m.Verify(ctx, k.Id+".wrong", "go.vine.api.greeter", "Greeter.Call")
+	assert.Equal(t, errors.StatusUnauthorized, code(err))
+	_, err = m.Verify(ctx, "unknown.secret", "go.vine.api.greeter", "Greeter.Call")
+	assert.Equal(t, errors.StatusUnauthorized, code(err))
+
+	_, err = m.Verify(ctx, token, "go.vine.api.greeter", "Greeter.Call")
+	assert.NoError(t, err)
+	_, err = m.Verify(ctx, token, "go.vine.api.greeter", "Greeter.Call")
+	assert.Equal(t, errors.StatusTooManyRequests, code(err))
+
+	keys, err := m.List(ctx)
+	if assert.NoError(t, err) && assert.Len(t, keys, 1) {
+		assert.Empty(t, keys[0].Hash)
+		assert.Equal(t, int64(3), keys[0].Usage.Total)
+		assert.Equal(t, int64(3), keys[0].Usage.Window)
+	}
+
+	_, err = m.Revoke(ctx, k.Id)
+	assert.NoError(t, err)
+	_, err = m.Verify(ctx, token, "go.vine.api.greeter", "Greeter.Call")
+	assert.Equal(t, errors.StatusUnauthorized, code(err))
+	_, err = m.Revoke(ctx, "unknown")
+	assert.Equal(t, errors.StatusNotFound, code(err))
+
+	// expired keys are rejected
+	k, token, err = m.Create(ctx, &CreateRequest{Name: "short", Ttl: "1h"})
+	if assert.NoError(t, err) {
+		k.Expires = time.Now().Add(-time.Second).Unix()
+		assert.NoError(t, m.opts.Store.Write(ctx, k))
+		_, err = m.Verify(ctx, token, "go.vine.api.greeter", "Greeter.Call")
+		assert.Equal(t, errors.StatusUnauthorized, code(err))
+	}
+}
+
+func TestAllows(t *testing.T) {
+	k := &Key{}
+	assert.True(t, k.Allows("go.vine.api.greeter", "Greeter.Call"))
+
+	k.Scopes = []string{"go.vine.api.greeter"}
+	assert.True(t, k.Allows("go.vine.api.greeter", "Greeter.Call"))
+	assert.False(t, k.Allows("go.vine.api.other", "Greeter.Call"))
+
+	k.Scopes = []string{"go.vine.api.greeter:Greeter.Call", "*:Health.*"}
+	assert.True(t, k.Allows("go.vine.api.greeter", "Greeter.Call"))
+	assert.False(t, k.Allows("go.vine.api.greeter", "Greeter.Stream"))
+	assert.True(t, k.Allows("go.vine.api.other", "Health.Check"))
+}
+
+func TestHandler(t *testing.T) {
+	ep := &api.Endpoint{Name: "Greeter.Call", Security: "bearer,apiKeys"}
+	m := NewManager(WithStore(NewStore(memory.NewCache())), WithRouter(&testRouter{ep: ep}))
+	_, token, err := m.Create(context.Background(), &CreateRequest{Name: "partner", Quota: 10})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(target string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rsp := httptest.NewRecorder()
+		h.ServeHTTP(rsp, req)
+		return rsp
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serve("/greeter/call", nil).Code)
+	rsp := serve("/greeter/call", map[string]string{"X-API-Key": token})
+	assert.Equal(t, http.StatusOK, rsp.Code)
+	assert.Equal(t, "10", rsp.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "9", rsp.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, http.StatusOK, serve("/greeter/call?api_key="+token, nil).Code)
+
+	// endpoints without the scheme are not checked
+	ep.Security = "bearer"
+	assert.Equal(t, http.StatusOK, serve("/greeter/call", nil).Code)
+}
+
+func TestAuthorize(t *testing.T) {
+	ctx := context.Background()
+	c := memory.NewCache()
+	m := NewManager(WithStore(NewStore(c)))
+	_, token, err := m.Create(ctx, &CreateRequest{Name: "partner", Scopes: []string{"go.vine.api.greeter"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the keys are kept apart from the other records of the cache
+	names, err := c.List(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+
+	ep := &api.Endpoint{Name: "Greeter.Call", Security: "apiKeys"}
+	assert.NoError(t, m.Authorize(ctx, "go.vine.api.greeter", &api.Endpoint{Name: "Greeter.Call"}, nil))
+	assert.NoError(t, m.Authorize(ctx, "go.vine.api.greeter", nil, nil))
+	assert.Equal(t, errors.StatusUnauthorized, code(m.Authorize(ctx, "go.vine.api.greeter", ep, nil)))
+	assert.Equal(t, errors.StatusUnauthorized, code(m.Authorize(ctx, "go.vine.api.greeter", ep, map[string]string{"x-api-key": "unknown.secret"})))
+	assert.Equal(t, errors.StatusForbidden, code(m.Authorize(ctx, "go.vine.api.other", ep, map[string]string{"x-api-key": token})))
+	assert.NoError(t, m.Authorize(ctx, "go.vine.api.greeter", ep, map[string]string{"x-api-key": token}))
+
+	// the calls checked by the http handler are not counted again
+	cx := context.WithValue(ctx, verifiedKey{}, "go.vine.api.greeter:Greeter.Call")
+	assert.NoError(t, m.Authorize(cx, "go.vine.api.greeter", ep, nil))
+	keys, err := m.List(ctx)
+	if assert.NoError(t, err) && assert.Len(t, keys, 1) {
+		assert.Equal(t, int64(1), keys[0].Usage.Total)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	r := regMemory.NewRegistry()
+	b := membroker.NewBroker()
+	s := smemory.NewServer(server.Name("go.vine.api"), server.Id("keys"), server.Registry(r), server.Broker(b))
+	_, err := NewHandler(NewManager(WithStore(NewStore(memory.NewCache()))))
+	assert.Error(t, err)
+
+	m := NewManager(WithStore(NewStore(memory.NewCache())), WithAdminToken("admin"))
+	h, err := NewHandler(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterKeysHandler(s, h); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	svc := NewKeysService("go.vine.api", cmemory.NewClient(client.Registry(r), client.Broker(b)))
+
+	_, err = svc.List(context.Background(), &ListRequest{})
+	assert.Equal(t, errors.StatusUnauthorized, code(err))
+
+	ctx := metadata.Set(context.Background(), "Authorization", "Bearer admin")
+	created, err := svc.Create(ctx, &CreateRequest{Name: "partner", Scopes: []string{"go.vine.api.greeter"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotEmpty(t, created.Token)
+	assert.Empty(t, created.Key.Hash)
+
+	list, err := svc.List(ctx, &ListRequest{})
+	if assert.NoError(t, err) && assert.Len(t, list.Keys, 1) {
+		assert.Equal(t, "partner", list.Keys[0].Name)
+	}
+
+	revoked, err := svc.Revoke(ctx, &RevokeRequest{Id: created.Key.Id})
+	if assert.NoError(t, err) {
+		assert.NotZero(t, revoked.Key.Revoked)
+	}
+
+	// the messages go over the wire as protobuf
+	data, err := proto.Marshal(list)
+	if assert.NoError(t, err) {
+		out := &ListResponse{}
+		assert.NoError(t, proto.Unmarshal(data, out))
+		assert.Equal(t, list.Keys[0].Scopes, out.Keys[0].Scopes)
+		assert.Equal(t, list.Keys[0].Usage, out.Keys[0].Usage)
+	}
+}
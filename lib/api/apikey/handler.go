@@ -0,0 +1,96 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package apikey
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"github.com/vine-io/vine/lib/errors"
+	"github.com/vine-io/vine/util/context/metadata"
+)
+
+// handler is the key admin service, the calls carry the admin token of the
+// manager as a bearer Authorization
+type handler struct {
+	m *Manager
+}
+
+// NewHandler returns the key admin service of the manager, serve it with
+// RegisterKeysHandler. The manager must have an admin token.
+func NewHandler(m *Manager) (KeysHandler, error) {
+	if len(m.opts.AdminToken) == 0 {
+		return nil, fmt.Errorf("the key admin service requires an admin token")
+	}
+	return &handler{m}, nil
+}
+
+func (h *handler) authorize(ctx context.Context) error {
+	token := h.m.opts.AdminToken
+	v, _ := metadata.Get(ctx, "Authorization")
+	if len(token) == 0 || !strings.HasPrefix(v, "Bearer ") ||
+		subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(v, "Bearer ")), []byte(token)) != 1 {
+		return errors.Unauthorized(errorId, "invalid admin token")
+	}
+	return nil
+}
+
+func (h *handler) Create(ctx context.Context, in *CreateRequest, out *CreateResponse) error {
+	if err := h.authorize(ctx); err != nil {
+		return err
+	}
+	k, token, err := h.m.Create(ctx, in)
+	if err != nil {
+		return err
+	}
+	k.Hash = ""
+	out.Key = k
+	out.Token = token
+	return nil
+}
+
+func (h *handler) List(ctx context.Context, in *ListRequest, out *ListResponse) error {
+	if err := h.authorize(ctx); err != nil {
+		return err
+	}
+	keys, err := h.m.List(ctx)
+	if err != nil {
+		return err
+	}
+	out.Keys = keys
+	return nil
+}
+
+func (h *handler) Revoke(ctx context.Context, in *RevokeRequest, out *RevokeResponse) error {
+	if err := h.authorize(ctx); err != nil {
+		return err
+	}
+	k, err := h.m.Revoke(ctx, in.Id)
+	if err != nil {
+		return err
+	}
+	out.Key = k
+	return nil
+}
@@ -0,0 +1,113 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package apikey
+
+import (
+	"github.com/vine-io/vine/lib/api/router"
+	"github.com/vine-io/vine/lib/cache"
+)
+
+var (
+	// DefaultHeader is the request header carrying the key
+	DefaultHeader = "X-API-Key"
+	// DefaultQuery is the query parameter carrying the key when there is no header
+	DefaultQuery = "api_key"
+	// DefaultScheme is the security scheme of the endpoints which require a key
+	DefaultScheme = "apiKeys"
+)
+
+type Options struct {
+	// Store keeps the keys and their usage
+	Store Store
+	// Router resolves the endpoint of the requests
+	Router router.Router
+	// Header and Query carry the key
+	Header string
+	Query  string
+	// Scheme of api.Endpoint.Security which requires a key
+	Scheme string
+	// AdminToken is required by the admin rpc, which is not served without one
+	AdminToken string
+}
+
+type Option func(o *Options)
+
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Header: DefaultHeader,
+		Query:  DefaultQuery,
+		Scheme: DefaultScheme,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.Store == nil {
+		options.Store = NewStore(cache.DefaultCache)
+	}
+
+	return options
+}
+
+// WithStore sets the store of the keys
+func WithStore(s Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
+// WithRouter sets the router used to resolve the endpoint of the requests
+func WithRouter(r router.Router) Option {
+	return func(o *Options) {
+		o.Router = r
+	}
+}
+
+// WithHeader sets the request header carrying the key
+func WithHeader(h string) Option {
+	return func(o *Options) {
+		o.Header = h
+	}
+}
+
+// WithQuery sets the query parameter carrying the key
+func WithQuery(q string) Option {
+	return func(o *Options) {
+		o.Query = q
+	}
+}
+
+// WithScheme sets the security scheme of the endpoints which require a key
+func WithScheme(s string) Option {
+	return func(o *Options) {
+		o.Scheme = s
+	}
+}
+
+// WithAdminToken protects the admin rpc with the token
+func WithAdminToken(t string) Option {
+	return func(o *Options) {
+		o.AdminToken = t
+	}
+}
@@ -0,0 +1,145 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package apikey
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	json "github.com/json-iterator/go"
+	"github.com/vine-io/vine/lib/cache"
+)
+
+// Store keeps the keys and counts their usage
+type Store interface {
+	Read(ctx context.Context, id string) (*Key, error)
+	Write(ctx context.Context, k *Key) error
+	List(ctx context.Context) ([]*Key, error)
+	// Incr adds one to the counter and returns its value, the counter
+	// is dropped after the ttl when one is given
+	Incr(ctx context.Context, counter string, ttl time.Duration) (int64, error)
+	// Count returns the value of the counter
+	Count(ctx context.Context, counter string) (int64, error)
+}
+
+var (
+	// DefaultDatabase and DefaultTable keep the keys and their usage apart
+	// from the records of the other users of the cache
+	DefaultDatabase = "vine"
+	DefaultTable    = "apikeys"
+	// DefaultPrefix of the records in the cache
+	DefaultPrefix = "apikey:"
+)
+
+type cacheStore struct {
+	sync.Mutex
+	c               cache.Cache
+	database, table string
+	prefix          string
+}
+
+func (s *cacheStore) Read(ctx context.Context, id string) (*Key, error) {
+	recs, err := s.c.Get(ctx, s.prefix+"key/"+id, cache.GetFrom(s.database, s.table))
+	if err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, cache.ErrNotFound
+	}
+	k := &Key{}
+	if err := json.Unmarshal(recs[0].Value, k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (s *cacheStore) Write(ctx context.Context, k *Key) error {
+	b, err := json.Marshal(k)
+	if err != nil {
+		return err
+	}
+	return s.c.Put(ctx, &cache.Record{Key: s.prefix + "key/" + k.Id, Value: b}, cache.PutTo(s.database, s.table))
+}
+
+func (s *cacheStore) List(ctx context.Context) ([]*Key, error) {
+	names, err := s.c.List(ctx, cache.ListFrom(s.database, s.table), cache.ListPrefix(s.prefix+"key/"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*Key, 0, len(names))
+	for _, name := range names {
+		k, err := s.Read(ctx, strings.TrimPrefix(name, s.prefix+"key/"))
+		if err == cache.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// Incr is atomic within the process, gateways sharing a cache may lose increments
+func (s *cacheStore) Incr(ctx context.Context, counter string, ttl time.Duration) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	n, err := s.Count(ctx, counter)
+	if err != nil {
+		return 0, err
+	}
+	n++
+
+	opts := []cache.PutOption{cache.PutTo(s.database, s.table)}
+	if ttl > 0 {
+		opts = append(opts, cache.PutTTL(ttl))
+	}
+	r := &cache.Record{Key: s.prefix + "usage/" + counter, Value: []byte(strconv.FormatInt(n, 10))}
+	if err := s.c.Put(ctx, r, opts...); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *cacheStore) Count(ctx context.Context, counter string) (int64, error) {
+	recs, err := s.c.Get(ctx, s.prefix+"usage/"+counter, cache.GetFrom(s.database, s.table))
+	if err == cache.ErrNotFound || (err == nil && len(recs) == 0) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(recs[0].Value), 10, 64)
+}
+
+// NewStore returns a Store keeping the keys in a table of the cache. The keys
+// and the quotas are lost with the records, so the cache must persist and must
+// not evict them: the memory cache does neither.
+func NewStore(c cache.Cache) Store {
+	return &cacheStore{c: c, database: DefaultDatabase, table: DefaultTable, prefix: DefaultPrefix}
+}
@@ -69,6 +69,11 @@ func (a *apiHandler) Handle(c *gin.Context) {
 		cx = metadata.Set(cx, k, v)
 	}
 
+	// honour the deadline sent by the caller, the context is
+	// also cancelled when the client disconnects
+	cx, cancel := ctx.WithMetadataDeadline(cx)
+	defer cancel()
+
 	r := c.Request.Clone(cx)
 	if a.s != nil {
 		// we were given the service
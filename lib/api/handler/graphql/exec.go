@@ -0,0 +1,561 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	json "github.com/json-iterator/go"
+	"github.com/vine-io/vine/lib/errors"
+)
+
+// request is a graphql request sent over http
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// response is the result of a graphql request
+type response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*gqlError `json:"errors,omitempty"`
+}
+
+type gqlError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// orderedMap keeps the fields of an object in the order they were selected
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newOrderedMap() *orderedMap {
+	return &orderedMap{values: map[string]interface{}{}}
+}
+
+func (m *orderedMap) set(key string, v interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = v
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBufferString("{")
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// resolver resolves a field of a root type
+type resolver func(ctx context.Context, f *fieldDef, args map[string]interface{}) (interface{}, error)
+
+// group is the fields selected under the same response key
+type group struct {
+	key    string
+	fields []*field
+}
+
+func (g *group) selections() []selection {
+	if len(g.fields) == 1 {
+		return g.fields[0].selections
+	}
+	var sels []selection
+	for _, f := range g.fields {
+		sels = append(sels, f.selections...)
+	}
+	return sels
+}
+
+type executor struct {
+	schema *schema
+	doc    *document
+	op     *operation
+	vars   map[string]interface{}
+	errs   []*gqlError
+}
+
+// prepare parses and validates the request and coerces its variables
+func prepare(s *schema, req *request) (*executor, []*gqlError) {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return nil, []*gqlError{{Message: err.Error()}}
+	}
+
+	e := &executor{schema: s, doc: doc, vars: map[string]interface{}{}}
+	for _, op := range doc.operations {
+		if len(req.OperationName) == 0 || op.name == req.OperationName {
+			if e.op != nil {
+				return nil, []*gqlError{{Message: "must provide operation name if query contains multiple operations"}}
+			}
+			e.op = op
+		}
+	}
+	if e.op == nil {
+		return nil, []*gqlError{{Message: fmt.Sprintf("unknown operation named \"%s\"", req.OperationName)}}
+	}
+
+	root := e.root()
+	if len(root.fields) == 0 {
+		e.errorf(nil, "schema is not configured for %ss", e.op.typ)
+		return nil, e.errs
+	}
+
+	defined := map[string]bool{}
+	for _, v := range e.op.variables {
+		defined[v.name] = true
+	}
+	e.validate(root, e.op.selections, defined, map[string]bool{}, 1)
+	if len(e.errs) > 0 {
+		return nil, e.errs
+	}
+
+	for _, v := range e.op.variables {
+		value, ok := req.Variables[v.name]
+		if !ok && v.def != nil {
+			value, ok = e.value(v.def)
+		}
+		if (!ok || value == nil) && v.typ.nonNull {
+			e.errorf(nil, "variable \"$%s\" of required type \"%s\" was not provided", v.name, v.typ)
+			continue
+		}
+		if ok {
+			e.vars[v.name] = value
+		}
+	}
+	if len(e.errs) > 0 {
+		return nil, e.errs
+	}
+
+	if e.op.typ == "subscription" {
+		if fields := e.rootFields(); len(fields) != 1 || fields[0].fields[0].name == "__typename" {
+			e.errorf(nil, "subscription must select only one top level field")
+			return nil, e.errs
+		}
+	}
+
+	return e, nil
+}
+
+func (e *executor) root() *objectType {
+	switch e.op.typ {
+	case "mutation":
+		return e.schema.mutation
+	case "subscription":
+		return e.schema.subscription
+	}
+	return e.schema.query
+}
+
+func (e *executor) errorf(path []interface{}, format string, args ...interface{}) {
+	e.errs = append(e.errs, &gqlError{Message: fmt.Sprintf(format, args...), Path: path})
+}
+
+// fail adds the error returned by a service
+func (e *executor) fail(err error, path []interface{}) {
+	ve := errors.FromErr(err)
+	// the status message may carry the encoded error of the backend
+	if pe := errors.Parse(ve.Detail); pe.Code != 0 {
+		ve = pe
+	}
+	if len(ve.Detail) == 0 {
+		ve.Detail = err.Error()
+	}
+
+	ext := map[string]interface{}{}
+	if ve.Code != 0 {
+		ext["code"] = ve.Code
+		ext["status"] = ve.Status
+	}
+	if len(ve.Id) > 0 {
+		ext["id"] = ve.Id
+	}
+	if len(ve.Violations) > 0 {
+		ext["violations"] = ve.Violations
+	}
+	if len(ext) == 0 {
+		ext = nil
+	}
+	e.errs = append(e.errs, &gqlError{Message: ve.Detail, Path: path, Extensions: ext})
+}
+
+// validate checks the selections of the object at depth, the fragments spread in
+// each other can nest the fields deeper than the parser allows so it is checked again
+func (e *executor) validate(obj *objectType, sels []selection, defined, visiting map[string]bool, depth int) {
+	for _, sel := range sels {
+		switch s := sel.(type) {
+		case *field:
+			e.validateDirectives(s.directives, defined)
+			if s.name == "__typename" {
+				if len(s.selections) > 0 {
+					e.errorf(nil, "field \"__typename\" must not have a selection since type \"String!\" has no subfields")
+				}
+				continue
+			}
+
+			def, ok := obj.fields[s.name]
+			if !ok {
+				e.errorf(nil, "cannot query field \"%s\" on type \"%s\"", s.name, obj.name)
+				continue
+			}
+
+			provided := map[string]bool{}
+			for _, a := range s.args {
+				provided[a.name] = true
+				if def.arg(a.name) == nil {
+					e.errorf(nil, "unknown argument \"%s\" on field \"%s.%s\"", a.name, obj.name, s.name)
+				}
+				e.validateValue(a.value, defined)
+			}
+			for _, a := range def.args {
+				if a.typ.nonNull && !provided[a.name] {
+					e.errorf(nil, "field \"%s\" argument \"%s\" of type \"%s\" is required, but it was not provided", s.name, a.name, a.typ)
+				}
+			}
+
+			named := def.typ.named()
+			if sub, ok := e.schema.types[named]; ok {
+				if len(s.selections) == 0 {
+					e.errorf(nil, "field \"%s\" of type \"%s\" must have a selection of subfields", s.name, def.typ)
+					continue
+				}
+				if depth >= DefaultMaxDepth {
+					e.errorf(nil, "query exceeds the maximum depth of %d", DefaultMaxDepth)
+					continue
+				}
+				e.validate(sub, s.selections, defined, visiting, depth+1)
+			} else if len(s.selections) > 0 {
+				e.errorf(nil, "field \"%s\" must not have a selection since type \"%s\" has no subfields", s.name, def.typ)
+			}
+		case *fragmentSpread:
+			e.validateDirectives(s.directives, defined)
+			f, ok := e.doc.fragments[s.name]
+			if !ok {
+				e.errorf(nil, "unknown fragment \"%s\"", s.name)
+				continue
+			}
+			if visiting[s.name] {
+				e.errorf(nil, "cannot spread fragment \"%s\" within itself", s.name)
+				continue
+			}
+			if f.on != obj.name {
+				e.errorf(nil, "fragment \"%s\" cannot be spread here as objects of type \"%s\" can never be of type \"%s\"", s.name, obj.name, f.on)
+				continue
+			}
+			visiting[s.name] = true
+			e.validate(obj, f.selections, defined, visiting, depth)
+			delete(visiting, s.name)
+		case *inlineFragment:
+			e.validateDirectives(s.directives, defined)
+			if len(s.on) > 0 && s.on != obj.name {
+				e.errorf(nil, "fragment cannot be spread here as objects of type \"%s\" can never be of type \"%s\"", obj.name, s.on)
+				continue
+			}
+			e.validate(obj, s.selections, defined, visiting, depth)
+		}
+	}
+}
+
+func (e *executor) validateDirectives(dirs []*directive, defined map[string]bool) {
+	for _, d := range dirs {
+		if d.name != "skip" && d.name != "include" {
+			e.errorf(nil, "unknown directive \"@%s\"", d.name)
+			continue
+		}
+		for _, a := range d.args {
+			e.validateValue(a.value, defined)
+		}
+	}
+}
+
+func (e *executor) validateValue(v *value, defined map[string]bool) {
+	switch v.kind {
+	case variableValue:
+		if !defined[v.raw] {
+			e.errorf(nil, "variable \"$%s\" is not defined", v.raw)
+		}
+	case listValue:
+		for _, item := range v.list {
+			e.validateValue(item, defined)
+		}
+	case objectValue:
+		for _, item := range v.fields {
+			e.validateValue(item.value, defined)
+		}
+	}
+}
+
+// value converts the literal, it returns false for variables which were not provided
+func (e *executor) value(v *value) (interface{}, bool) {
+	switch v.kind {
+	case variableValue:
+		value, ok := e.vars[v.raw]
+		return value, ok
+	case intValue:
+		n, err := strconv.ParseInt(v.raw, 10, 64)
+		if err != nil {
+			f, _ := strconv.ParseFloat(v.raw, 64)
+			return f, true
+		}
+		return n, true
+	case floatValue:
+		f, _ := strconv.ParseFloat(v.raw, 64)
+		return f, true
+	case booleanValue:
+		return v.raw == "true", true
+	case nullValue:
+		return nil, true
+	case listValue:
+		list := make([]interface{}, 0, len(v.list))
+		for _, item := range v.list {
+			value, _ := e.value(item)
+			list = append(list, value)
+		}
+		return list, true
+	case objectValue:
+		m := map[string]interface{}{}
+		for _, item := range v.fields {
+			if value, ok := e.value(item.value); ok {
+				m[item.name] = value
+			}
+		}
+		return m, true
+	}
+	// strings and enums
+	return v.raw, true
+}
+
+func (e *executor) args(f *field) map[string]interface{} {
+	args := map[string]interface{}{}
+	for _, a := range f.args {
+		if v, ok := e.value(a.value); ok {
+			args[a.name] = v
+		}
+	}
+	return args
+}
+
+// skipped applies the @skip and @include directives
+func (e *executor) skipped(dirs []*directive) bool {
+	for _, d := range dirs {
+		for _, a := range d.args {
+			if a.name != "if" {
+				continue
+			}
+			v, _ := e.value(a.value)
+			b, _ := v.(bool)
+			if (d.name == "skip" && b) || (d.name == "include" && !b) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// collect groups the selected fields of the object by their response key
+func (e *executor) collect(obj *objectType, sels []selection) []*group {
+	var groups []*group
+	index := map[string]*group{}
+
+	var walk func(sels []selection, visited map[string]bool)
+	walk = func(sels []selection, visited map[string]bool) {
+		for _, sel := range sels {
+			switch s := sel.(type) {
+			case *field:
+				if e.skipped(s.directives) {
+					continue
+				}
+				g, ok := index[s.key()]
+				if !ok {
+					g = &group{key: s.key()}
+					index[g.key] = g
+					groups = append(groups, g)
+				}
+				g.fields = append(g.fields, s)
+			case *fragmentSpread:
+				f, ok := e.doc.fragments[s.name]
+				if !ok || visited[s.name] || e.skipped(s.directives) || f.on != obj.name {
+					continue
+				}
+				visited[s.name] = true
+				walk(f.selections, visited)
+			case *inlineFragment:
+				if e.skipped(s.directives) || (len(s.on) > 0 && s.on != obj.name) {
+					continue
+				}
+				walk(s.selections, visited)
+			}
+		}
+	}
+	walk(sels, map[string]bool{})
+
+	return groups
+}
+
+func (e *executor) rootFields() []*group {
+	return e.collect(e.root(), e.op.selections)
+}
+
+// execute resolves the root fields one after another
+func (e *executor) execute(ctx context.Context, resolve resolver) *response {
+	root := e.root()
+	data := newOrderedMap()
+	for _, g := range e.rootFields() {
+		f := g.fields[0]
+		if f.name == "__typename" {
+			data.set(g.key, root.name)
+			continue
+		}
+
+		def := root.fields[f.name]
+		v, err := resolve(ctx, def, e.args(f))
+		if err != nil {
+			e.fail(err, []interface{}{g.key})
+			data.set(g.key, nil)
+			continue
+		}
+		data.set(g.key, e.complete(def.typ, g.selections(), v, []interface{}{g.key}))
+	}
+
+	return &response{Data: data, Errors: e.errs}
+}
+
+// result completes a value of the subscribed root field
+func (e *executor) result(g *group, v interface{}) *response {
+	e.errs = nil
+	def := e.root().fields[g.fields[0].name]
+	data := newOrderedMap()
+	data.set(g.key, e.complete(def.typ, g.selections(), v, []interface{}{g.key}))
+	return &response{Data: data, Errors: e.errs}
+}
+
+// complete selects the requested fields of the value, scalars are returned as
+// they were encoded by the service
+func (e *executor) complete(t *typeRef, sels []selection, v interface{}, path []interface{}) interface{} {
+	if v == nil {
+		if t.nonNull {
+			e.errorf(path, "cannot return null for non-nullable field")
+		}
+		return nil
+	}
+
+	if t.elem != nil {
+		items, ok := v.([]interface{})
+		if !ok {
+			e.errorf(path, "expected a list of %s", t.elem)
+			return nil
+		}
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			out[i] = e.complete(t.elem, sels, item, appendPath(path, i))
+		}
+		return out
+	}
+
+	obj, ok := e.schema.types[t.name]
+	if !ok {
+		return v
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		e.errorf(path, "expected an object of type \"%s\"", t.name)
+		return nil
+	}
+
+	out := newOrderedMap()
+	for _, g := range e.collect(obj, sels) {
+		f := g.fields[0]
+		if f.name == "__typename" {
+			out.set(g.key, obj.name)
+			continue
+		}
+		def := obj.fields[f.name]
+		out.set(g.key, e.complete(def.typ, g.selections(), lookup(m, f.name), appendPath(path, g.key)))
+	}
+	return out
+}
+
+func appendPath(path []interface{}, elem interface{}) []interface{} {
+	p := make([]interface{}, len(path), len(path)+1)
+	copy(p, path)
+	return append(p, elem)
+}
+
+// lookup reads the field of the json object, accepting both the original and
+// the camel case name of the proto field
+func lookup(m map[string]interface{}, name string) interface{} {
+	if v, ok := m[name]; ok {
+		return v
+	}
+	if v, ok := m[camelCase(name)]; ok {
+		return v
+	}
+	return m[snakeCase(name)]
+}
+
+func camelCase(s string) string {
+	parts := strings.Split(s, "_")
+	for i := 1; i < len(parts); i++ {
+		if len(parts[i]) > 0 {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+func snakeCase(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' {
+			if i > 0 {
+				sb.WriteByte('_')
+			}
+			c += 'a' - 'A'
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,667 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package graphql serves a graphql endpoint whose schema is built from the
+// OpenAPI documents and the registry endpoints of the services
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	json "github.com/json-iterator/go"
+	"github.com/vine-io/vine/core/broker"
+	"github.com/vine-io/vine/core/client"
+	"github.com/vine-io/vine/core/registry"
+	"github.com/vine-io/vine/lib/api/handler"
+	pb "github.com/vine-io/vine/lib/api/handler/openapi/proto"
+	log "github.com/vine-io/vine/lib/logger"
+	ctx "github.com/vine-io/vine/util/context"
+	"github.com/vine-io/vine/util/context/metadata"
+)
+
+const (
+	Handler = "graphql"
+)
+
+var (
+	// DefaultHeartbeat is the interval on which an idle subscription is kept alive
+	DefaultHeartbeat = time.Second * 15
+	// DefaultDocTimeout bounds fetching the OpenAPI document of a service
+	DefaultDocTimeout = time.Second * 5
+	// DefaultMaxDepth bounds the nesting of the selection sets and values of a query
+	DefaultMaxDepth = 64
+	// DefaultMaxQuerySize bounds the size of a query in bytes
+	DefaultMaxQuerySize = 1 << 20
+	// DefaultDebounce is how long the changes of the registry are gathered
+	// before the schema is built again
+	DefaultDebounce = time.Millisecond * 100
+)
+
+// Closer is the graphql handler, Close stops following the registry
+type Closer interface {
+	handler.Handler
+	io.Closer
+}
+
+// openapi is the OpenAPI document of a service and the versions it was fetched for
+type openapi struct {
+	versions string
+	doc      *pb.OpenAPI
+}
+
+type graphqlHandler struct {
+	sync.RWMutex
+
+	opts   handler.Options
+	once   sync.Once
+	schema *schema
+
+	// the documents of the services and the services the schema was built
+	// from, only read and written by build
+	docs     map[string]*openapi
+	services string
+
+	exit      chan struct{}
+	closeOnce sync.Once
+}
+
+// Handle serves queries and mutations as json, and subscriptions as
+// Server-Sent Events. A GET request with the sdl parameter returns the schema.
+func (h *graphqlHandler) Handle(c *gin.Context) {
+	h.once.Do(h.start)
+
+	s := h.current()
+	r := c.Request
+
+	if _, ok := r.URL.Query()["sdl"]; ok && r.Method == http.MethodGet {
+		c.String(http.StatusOK, s.String())
+		return
+	}
+
+	req, err := h.request(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, &response{Errors: []*gqlError{{Message: err.Error()}}})
+		return
+	}
+
+	e, errs := prepare(s, req)
+	if len(errs) > 0 {
+		c.JSON(http.StatusOK, &response{Errors: errs})
+		return
+	}
+
+	if r.Method == http.MethodGet && e.op.typ != "query" {
+		c.Header("Allow", "POST")
+		c.JSON(http.StatusMethodNotAllowed, &response{Errors: []*gqlError{{Message: fmt.Sprintf("can only perform a %s operation from a POST request", e.op.typ)}}})
+		return
+	}
+
+	cx := ctx.FromRequest(r)
+	for k, v := range h.opts.Metadata {
+		cx = metadata.Set(cx, k, v)
+	}
+
+	// honour the deadline of the caller
+	cx, cancel := ctx.WithMetadataDeadline(cx)
+	defer cancel()
+
+	if e.op.typ == "subscription" {
+		h.subscribe(c, cx, e)
+		return
+	}
+
+	c.JSON(http.StatusOK, e.execute(cx, h.resolve))
+}
+
+func (h *graphqlHandler) String() string {
+	return Handler
+}
+
+func (h *graphqlHandler) request(c *gin.Context) (*request, error) {
+	r := c.Request
+	req := &request{}
+
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); len(v) > 0 {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				return nil, fmt.Errorf("variables are invalid json")
+			}
+		}
+	} else {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, r.Body, h.opts.MaxRecvSize))
+		if err != nil {
+			return nil, err
+		}
+
+		ct := r.Header.Get("Content-Type")
+		if idx := strings.IndexRune(ct, ';'); idx >= 0 {
+			ct = ct[:idx]
+		}
+		if ct == "application/graphql" {
+			req.Query = string(body)
+		} else if err := json.Unmarshal(body, req); err != nil {
+			return nil, fmt.Errorf("body is invalid json")
+		}
+	}
+
+	if len(strings.TrimSpace(req.Query)) == 0 {
+		return nil, fmt.Errorf("must provide query string")
+	}
+	if len(req.Query) > DefaultMaxQuerySize {
+		return nil, fmt.Errorf("query exceeds the maximum size of %d bytes", DefaultMaxQuerySize)
+	}
+	return req, nil
+}
+
+// resolve calls the unary rpc of the root field
+func (h *graphqlHandler) resolve(cx context.Context, f *fieldDef, args map[string]interface{}) (interface{}, error) {
+	cc := h.opts.Client
+
+	if err := h.authorize(cx, f); err != nil {
+		return nil, err
+	}
+
+	request, err := payload(f, args)
+	if err != nil {
+		return nil, err
+	}
+
+	req := cc.NewRequest(f.service, f.endpoint, &request, client.WithContentType("application/json"))
+
+	var rsp json.RawMessage
+	if err = cc.Call(cx, req, &rsp); err != nil {
+		return nil, err
+	}
+
+	return decode(rsp)
+}
+
+// authorize checks the call of the rpc of the field with the metadata of the request
+func (h *graphqlHandler) authorize(cx context.Context, f *fieldDef) error {
+	if h.opts.Authorize == nil {
+		return nil
+	}
+	md, _ := metadata.FromContext(cx)
+	return h.opts.Authorize(cx, f.service, f.gateway, md)
+}
+
+// payload is the json request of the rpc
+func payload(f *fieldDef, args map[string]interface{}) (json.RawMessage, error) {
+	var v interface{} = args
+	if f.input {
+		v = args["input"]
+		if v == nil {
+			v = map[string]interface{}{}
+		}
+	}
+	return json.Marshal(v)
+}
+
+func decode(b []byte) (interface{}, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// next is a value of a subscription or the error which ended it
+type next struct {
+	v   interface{}
+	err error
+}
+
+// subscribe streams the values of the subscribed field as next events,
+// the stream ends with a complete event
+func (h *graphqlHandler) subscribe(c *gin.Context, cx context.Context, e *executor) {
+	cx, cancel := context.WithCancel(cx)
+	defer cancel()
+
+	g := e.rootFields()[0]
+	f := g.fields[0]
+	def := e.root().fields[f.name]
+
+	var values <-chan next
+	var err error
+	switch def.kind {
+	case topicField:
+		values, err = h.topic(cx, def)
+	default:
+		values, err = h.stream(cx, def, e.args(f))
+	}
+	if err != nil {
+		e.fail(err, []interface{}{g.key})
+		c.JSON(http.StatusOK, &response{Errors: e.errs})
+		return
+	}
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// disable response buffering of nginx
+	header.Set("X-Accel-Buffering", "no")
+	c.Writer.WriteHeader(http.StatusOK)
+	c.Writer.Flush()
+
+	write := func(event string, v interface{}) error {
+		buf := bytes.NewBufferString("event: " + event + "\ndata: ")
+		if v != nil {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			buf.Write(data)
+		}
+		buf.WriteString("\n\n")
+		if _, err := c.Writer.Write(buf.Bytes()); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}
+
+	heartbeat := DefaultHeartbeat
+	if heartbeat <= 0 {
+		heartbeat = time.Second * 15
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-cx.Done():
+			return
+		case <-ticker.C:
+			if _, err := c.Writer.Write([]byte(": heartbeat\n\n")); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case n, ok := <-values:
+			if !ok {
+				_ = write("complete", nil)
+				return
+			}
+			if n.err != nil {
+				e.errs = nil
+				e.fail(n.err, []interface{}{g.key})
+				_ = write("next", &response{Errors: e.errs})
+				_ = write("complete", nil)
+				return
+			}
+			if err := write("next", e.result(g, n.v)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// stream calls the server streaming rpc of the field
+func (h *graphqlHandler) stream(cx context.Context, f *fieldDef, args map[string]interface{}) (<-chan next, error) {
+	cc := h.opts.Client
+
+	if err := h.authorize(cx, f); err != nil {
+		return nil, err
+	}
+
+	request, err := payload(f, args)
+	if err != nil {
+		return nil, err
+	}
+
+	req := cc.NewRequest(
+		f.service,
+		f.endpoint,
+		&request,
+		client.WithContentType("application/json"),
+		client.StreamingRequest(),
+	)
+
+	stream, err := cc.Stream(cx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err = stream.Send(&request); err != nil {
+		stream.Close()
+		return nil, err
+	}
+	_ = stream.CloseSend()
+
+	values := make(chan next)
+	go func() {
+		defer close(values)
+		defer stream.Close()
+
+		rsp := stream.Response()
+		for {
+			buf, err := rsp.Read()
+			if err == io.EOF {
+				return
+			}
+
+			n := next{err: err}
+			if err == nil {
+				n.v, n.err = decode(buf)
+			}
+			select {
+			case values <- n:
+			case <-cx.Done():
+				return
+			}
+			if n.err != nil {
+				return
+			}
+		}
+	}()
+
+	return values, nil
+}
+
+// topic subscribes to the broker topic of the field
+func (h *graphqlHandler) topic(cx context.Context, f *fieldDef) (<-chan next, error) {
+	values := make(chan next)
+
+	sub, err := h.opts.Client.Options().Broker.Subscribe(f.topic, func(p broker.Event) error {
+		select {
+		case values <- next{v: event(p.Topic(), p.Message())}:
+		case <-cx.Done():
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-cx.Done()
+		if err := sub.Unsubscribe(); err != nil {
+			log.Warnf("unsubscribe from %s: %v", f.topic, err)
+		}
+	}()
+
+	return values, nil
+}
+
+// event converts a broker message, the body is decoded when it is json
+func event(topic string, msg *broker.Message) map[string]interface{} {
+	header := map[string]interface{}{}
+	for k, v := range msg.Header {
+		header[k] = v
+	}
+
+	var body interface{}
+	if strings.Contains(msg.Header["Content-Type"], "json") {
+		body, _ = decode(msg.Body)
+	}
+	if body == nil && len(msg.Body) > 0 {
+		body = base64.StdEncoding.EncodeToString(msg.Body)
+	}
+
+	return map[string]interface{}{
+		"topic":  topic,
+		"header": header,
+		"body":   body,
+	}
+}
+
+func (h *graphqlHandler) current() *schema {
+	h.RLock()
+	defer h.RUnlock()
+	return h.schema
+}
+
+// start builds the schema and rebuilds it whenever the registry changes
+func (h *graphqlHandler) start() {
+	changes := make(chan struct{}, 1)
+
+	// the registry is watched before the first build, so no change is missed
+	reg := h.opts.Client.Options().Registry
+	w, err := reg.Watch(context.Background())
+	if err != nil {
+		log.Warnf("graphql: watch registry: %v", err)
+	}
+
+	h.build()
+
+	go h.watch(w, changes)
+	go h.rebuild(changes)
+}
+
+// watch signals the changes of the registry until the handler is closed
+func (h *graphqlHandler) watch(w registry.Watcher, changes chan<- struct{}) {
+	reg := h.opts.Client.Options().Registry
+	for {
+		if w == nil {
+			var err error
+			if w, err = reg.Watch(context.Background()); err != nil {
+				log.Warnf("graphql: watch registry: %v", err)
+				select {
+				case <-h.exit:
+					return
+				case <-time.After(time.Second):
+				}
+				continue
+			}
+			// a change may have been missed
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+
+		stop := sync.OnceFunc(w.Stop)
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-h.exit:
+				stop()
+			case <-done:
+			}
+		}()
+
+		for {
+			if _, err := w.Next(); err != nil {
+				break
+			}
+			// coalesce the changes which arrive during a build
+			select {
+			case changes <- struct{}{}:
+			default:
+			}
+		}
+		close(done)
+		stop()
+		w = nil
+
+		select {
+		case <-h.exit:
+			return
+		default:
+		}
+	}
+}
+
+// rebuild builds the schema once the changes have settled for DefaultDebounce
+func (h *graphqlHandler) rebuild(changes <-chan struct{}) {
+	var settled <-chan time.Time
+	for {
+		select {
+		case <-h.exit:
+			return
+		case <-changes:
+			settled = time.After(DefaultDebounce)
+		case <-settled:
+			settled = nil
+			h.build()
+		}
+	}
+}
+
+// build builds the schema from the services of the namespace. The documents
+// are only fetched for the services whose versions changed, and the schema is
+// kept when none did.
+func (h *graphqlHandler) build() {
+	cx := context.Background()
+	reg := h.opts.Client.Options().Registry
+
+	services, err := reg.ListServices(cx)
+	if err != nil {
+		log.Warnf("graphql: list services: %v", err)
+	}
+
+	names := make([]string, 0, len(services))
+	seen := map[string]bool{}
+	for _, item := range services {
+		if seen[item.Name] || !strings.HasPrefix(item.Name, h.opts.Namespace+".") {
+			continue
+		}
+		seen[item.Name] = true
+		names = append(names, item.Name)
+	}
+	sort.Strings(names)
+
+	infos := make([]*serviceInfo, 0, len(names))
+	docs := make(map[string]*openapi, len(names))
+	all := make([]string, 0, len(names))
+	for _, name := range names {
+		versions, err := reg.GetService(cx, name)
+		if err != nil {
+			continue
+		}
+
+		info := &serviceInfo{name: name}
+		endpoints := map[string]bool{}
+		for _, version := range versions {
+			for _, ep := range version.Endpoints {
+				if !endpoints[ep.Name] {
+					endpoints[ep.Name] = true
+					info.endpoints = append(info.endpoints, ep)
+				}
+			}
+		}
+
+		key := fingerprint(versions)
+		d, ok := h.docs[name]
+		if !ok || d.versions != key {
+			d = &openapi{versions: key, doc: h.document(cx, name)}
+		}
+		docs[name] = d
+		info.doc = d.doc
+		infos = append(infos, info)
+		all = append(all, name+"@"+key)
+	}
+	h.docs = docs
+
+	key := strings.Join(all, "\n")
+	if h.current() != nil && key == h.services {
+		return
+	}
+	h.services = key
+
+	s := buildSchema(h.opts.Namespace, infos)
+
+	h.Lock()
+	h.schema = s
+	h.Unlock()
+}
+
+// fingerprint identifies the versions of a service and their endpoints
+func fingerprint(versions []*registry.Service) string {
+	items := make([]string, 0, len(versions))
+	for _, version := range versions {
+		eps := make([]string, 0, len(version.Endpoints))
+		for _, ep := range version.Endpoints {
+			md := make([]string, 0, len(ep.Metadata))
+			for k, v := range ep.Metadata {
+				md = append(md, k+"="+v)
+			}
+			sort.Strings(md)
+			eps = append(eps, ep.Name+"{"+strings.Join(md, ",")+"}")
+		}
+		sort.Strings(eps)
+		items = append(items, version.Version+":"+strings.Join(eps, ";"))
+	}
+	sort.Strings(items)
+	return strings.Join(items, "|")
+}
+
+// document fetches the OpenAPI document of the service, nil when it has none
+func (h *graphqlHandler) document(cx context.Context, name string) *pb.OpenAPI {
+	cx, cancel := context.WithTimeout(cx, DefaultDocTimeout)
+	defer cancel()
+
+	rsp, err := pb.NewOpenAPIService(name, h.opts.Client).GetOpenAPIDoc(cx, &pb.GetOpenAPIDocRequest{})
+	if err != nil || len(rsp.Apis) == 0 {
+		return nil
+	}
+
+	doc := &pb.OpenAPI{
+		Paths:      map[string]*pb.OpenAPIPath{},
+		Components: &pb.OpenAPIComponents{Schemas: map[string]*pb.Model{}},
+	}
+	for _, item := range rsp.Apis {
+		if item == nil {
+			continue
+		}
+		for k, v := range item.Paths {
+			doc.Paths[k] = v
+		}
+		if item.Components != nil {
+			for k, v := range item.Components.Schemas {
+				doc.Components.Schemas[k] = v
+			}
+		}
+	}
+	return doc
+}
+
+// Close stops following the changes of the registry
+func (h *graphqlHandler) Close() error {
+	h.closeOnce.Do(func() {
+		close(h.exit)
+	})
+	return nil
+}
+
+// NewHandler returns the graphql handler, the schema is built on the first request
+func NewHandler(opts ...handler.Option) Closer {
+	return &graphqlHandler{
+		opts: handler.NewOptions(opts...),
+		exit: make(chan struct{}),
+	}
+}
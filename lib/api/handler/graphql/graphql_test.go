@@ -0,0 +1,412 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vine-io/vine/core/broker"
+	membroker "github.com/vine-io/vine/core/broker/memory"
+	"github.com/vine-io/vine/core/client"
+	cmemory "github.com/vine-io/vine/core/client/memory"
+	"github.com/vine-io/vine/core/registry"
+	regMemory "github.com/vine-io/vine/core/registry/memory"
+	"github.com/vine-io/vine/core/server"
+	smemory "github.com/vine-io/vine/core/server/memory"
+	"github.com/vine-io/vine/lib/api"
+	"github.com/vine-io/vine/lib/api/handler"
+	pb "github.com/vine-io/vine/lib/api/handler/openapi/proto"
+	"github.com/vine-io/vine/lib/errors"
+	"github.com/vine-io/vine/util/context/metadata"
+)
+
+type getRequest struct {
+	Id string `json:"id"`
+}
+
+type signupRequest struct {
+	Name    string   `json:"name"`
+	Profile *profile `json:"profile"`
+}
+
+type profile struct {
+	Age  int32    `json:"age"`
+	Tags []string `json:"tags"`
+}
+
+type account struct {
+	Id      string   `json:"id"`
+	Name    string   `json:"name"`
+	Profile *profile `json:"profile,omitempty"`
+}
+
+type Account struct{}
+
+func (a *Account) Get(ctx context.Context, req *getRequest, rsp *account) error {
+	if req.Id != "1" {
+		return errors.NotFound("go.vine.api.account", "account %s not found", req.Id)
+	}
+	rsp.Id = "1"
+	rsp.Name = "vine"
+	rsp.Profile = &profile{Age: 3, Tags: []string{"a", "b"}}
+	return nil
+}
+
+func (a *Account) Signup(ctx context.Context, req *signupRequest, rsp *account) error {
+	rsp.Id = "2"
+	rsp.Name = req.Name
+	rsp.Profile = req.Profile
+	return nil
+}
+
+func (a *Account) Deadline(ctx context.Context, req *getRequest, rsp *account) error {
+	if d, ok := ctx.Deadline(); ok && time.Until(d) < time.Second*10 {
+		rsp.Name = "deadline"
+	}
+	return nil
+}
+
+func (a *Account) Watch(ctx context.Context, stream server.Stream) error {
+	req := &getRequest{}
+	if err := stream.Recv(req); err != nil {
+		return err
+	}
+	for i := 0; i < 2; i++ {
+		if err := stream.Send(&account{Id: req.Id, Name: fmt.Sprintf("vine-%d", i)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type accountDoc struct {
+	// fetched counts the requests of the document
+	fetched int32
+}
+
+func (d *accountDoc) GetOpenAPIDoc(ctx context.Context, req *pb.GetOpenAPIDocRequest, rsp *pb.GetOpenAPIDocResponse) error {
+	atomic.AddInt32(&d.fetched, 1)
+	result := &pb.PathResponse{Content: &pb.PathRequestBodyContent{
+		ApplicationJson: &pb.ApplicationContent{Schema: &pb.Schema{Ref: "#/components/schemas/account.Account"}},
+	}}
+	rsp.Apis = []*pb.OpenAPI{{
+		Paths: map[string]*pb.OpenAPIPath{
+			"/api/account": {Get: &pb.OpenAPIPathDocs{
+				OperationId: "AccountGet",
+				Summary:     "Get an account",
+				Parameters: []*pb.PathParameters{
+					{In: "query", Name: "id", Required: true, Schema: &pb.Schema{Type: "string"}},
+				},
+				Responses: map[string]*pb.PathResponse{"200": result},
+			}},
+			"/api/signup": {Post: &pb.OpenAPIPathDocs{
+				OperationId: "AccountSignup",
+				RequestBody: &pb.PathRequestBody{Content: &pb.PathRequestBodyContent{
+					ApplicationJson: &pb.ApplicationContent{Schema: &pb.Schema{Ref: "#/components/schemas/account.SignupRequest"}},
+				}},
+				Responses: map[string]*pb.PathResponse{"200": result},
+			}},
+			"/api/watch": {Post: &pb.OpenAPIPathDocs{
+				OperationId: "AccountWatch",
+				Parameters: []*pb.PathParameters{
+					{In: "query", Name: "id", Schema: &pb.Schema{Type: "string"}},
+				},
+				Responses: map[string]*pb.PathResponse{"200": result},
+			}},
+		},
+		Components: &pb.OpenAPIComponents{Schemas: map[string]*pb.Model{
+			"account.SignupRequest": {
+				Type: "object",
+				Properties: map[string]*pb.Schema{
+					"name":    {Type: "string"},
+					"profile": {Ref: "#/components/schemas/account.Profile"},
+				},
+				Required: []string{"name"},
+			},
+			"account.Account": {
+				Type: "object",
+				Properties: map[string]*pb.Schema{
+					"id":      {Type: "string"},
+					"name":    {Type: "string"},
+					"profile": {Ref: "#/components/schemas/account.Profile"},
+				},
+			},
+			"account.Profile": {
+				Type: "object",
+				Properties: map[string]*pb.Schema{
+					"age":  {Type: "integer", Format: "int32"},
+					"tags": {Type: "array", Items: &pb.Schema{Type: "string"}},
+				},
+			},
+		}},
+	}}
+	return nil
+}
+
+func (d *accountDoc) GetEndpoint(ctx context.Context, req *pb.GetEndpointRequest, rsp *pb.GetEndpointResponse) error {
+	return nil
+}
+
+type testGateway struct {
+	r   registry.Registry
+	b   broker.Broker
+	h   Closer
+	doc *accountDoc
+}
+
+func newGateway(t *testing.T, opts ...handler.Option) (*testGateway, func()) {
+	gin.SetMode(gin.TestMode)
+
+	r := regMemory.NewRegistry()
+	b := membroker.NewBroker()
+	s := smemory.NewServer(server.Name("go.vine.api.account"), server.Registry(r), server.Broker(b))
+	if err := s.Handle(s.NewHandler(&Account{}, api.WithEndpoint(&api.Endpoint{Name: "Account.Get", Security: "apiKeys"}))); err != nil {
+		t.Fatal(err)
+	}
+	doc := &accountDoc{}
+	if err := pb.RegisterOpenAPIServiceHandler(s, doc); err != nil {
+		t.Fatal(err)
+	}
+	sub := s.NewSubscriber("go.vine.api.events", func(ctx context.Context, msg *account) error { return nil })
+	if err := s.Subscribe(sub); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHandler(append([]handler.Option{
+		handler.WithNamespace("go.vine.api"),
+		handler.WithClient(cmemory.NewClient(client.Registry(r), client.Broker(b))),
+	}, opts...)...)
+	return &testGateway{r: r, b: b, h: h, doc: doc}, func() {
+		h.Close()
+		s.Stop()
+	}
+}
+
+func (g *testGateway) serve(method, body string, header ...string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	if method == http.MethodGet {
+		c.Request = httptest.NewRequest(method, "/graphql?"+body, nil)
+	} else {
+		c.Request = httptest.NewRequest(method, "/graphql", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		c.Request.Header.Set(header[i], header[i+1])
+	}
+	g.h.Handle(c)
+	return w
+}
+
+func (g *testGateway) query(query string, variables map[string]interface{}) string {
+	b, _ := json.Marshal(&request{Query: query, Variables: variables})
+	return g.serve(http.MethodPost, string(b)).Body.String()
+}
+
+func TestSchema(t *testing.T) {
+	g, stop := newGateway(t)
+	defer stop()
+
+	w := g.serve(http.MethodGet, "sdl")
+	assert.Equal(t, http.StatusOK, w.Code)
+	sdl := w.Body.String()
+	for _, s := range []string{
+		"schema {\n  query: Query\n  mutation: Mutation\n  subscription: Subscription\n}",
+		"type Query {\n  \"\"\"Get an account\"\"\"\n  account_Account_Get(id: String!): account_Account\n}",
+		"account_Account_Signup(name: String!, profile: account_ProfileInput): account_Account",
+		"account_Account_Watch(id: String): account_Account",
+		"topic_go_vine_api_events: BrokerEvent!",
+		"type account_Profile {\n  age: Int\n  tags: [String]\n}",
+		"input account_ProfileInput {\n  age: Int\n  tags: [String]\n}",
+	} {
+		assert.Contains(t, sdl, s)
+	}
+	assert.NotContains(t, sdl, "OpenAPIService")
+}
+
+func TestQuery(t *testing.T) {
+	g, stop := newGateway(t)
+	defer stop()
+
+	assert.JSONEq(t, `{"data":{"a":{"name":"vine","__typename":"account_Account","profile":{"tags":["a","b"]}}}}`,
+		g.query(`query Get($id: String!) {
+			a: account_Account_Get(id: $id) { ...names profile { tags } }
+		}
+		fragment names on account_Account { name __typename }`, map[string]interface{}{"id": "1"}))
+
+	// fields are returned in the order they were selected
+	assert.Equal(t, `{"data":{"account_Account_Get":{"profile":{"age":3},"id":"1"}}}`,
+		strings.TrimSpace(g.query(`{ account_Account_Get(id: "1") { profile { age } id name @skip(if: true) } }`, nil)))
+
+	assert.JSONEq(t, `{"data":{"account_Account_Signup":{"id":"2","name":"vine","profile":{"age":18}}}}`,
+		g.query(`mutation { account_Account_Signup(name: "vine", profile: {age: 18}) { id name profile { age } } }`, nil))
+
+	// the errors of the services carry their code
+	rsp := &response{}
+	assert.NoError(t, json.Unmarshal([]byte(g.query(`{ a: account_Account_Get(id: "2") { id } }`, nil)), rsp))
+	if assert.Len(t, rsp.Errors, 1) {
+		assert.Equal(t, "account 2 not found", rsp.Errors[0].Message)
+		assert.Equal(t, []interface{}{"a"}, rsp.Errors[0].Path)
+		assert.Equal(t, float64(404), rsp.Errors[0].Extensions["code"])
+	}
+
+	// invalid queries are not executed
+	rsp = &response{}
+	assert.NoError(t, json.Unmarshal([]byte(g.query(`{ account_Account_Get { email } }`, nil)), rsp))
+	assert.Nil(t, rsp.Data)
+	assert.Equal(t, []*gqlError{
+		{Message: `field "account_Account_Get" argument "id" of type "String!" is required, but it was not provided`},
+		{Message: `cannot query field "email" on type "account_Account"`},
+	}, rsp.Errors)
+
+	assert.JSONEq(t, `{"data":{"__typename":"Query"}}`, g.query(`{ __typename }`, nil))
+
+	w := g.serve(http.MethodGet, `query=`+`{account_Account_Get(id:"1"){id}}`)
+	assert.JSONEq(t, `{"data":{"account_Account_Get":{"id":"1"}}}`, w.Body.String())
+
+	w = g.serve(http.MethodGet, `query=`+`mutation{account_Account_Signup(name:"vine"){id}}`)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	b, _ := json.Marshal(&request{Query: strings.Repeat(" ", DefaultMaxQuerySize) + "{ __typename }"})
+	w = g.serve(http.MethodPost, string(b))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "query exceeds the maximum size")
+}
+
+func TestDeadline(t *testing.T) {
+	g, stop := newGateway(t)
+	defer stop()
+
+	b, _ := json.Marshal(&request{Query: `mutation { account_Account_Deadline(input: {}) }`})
+	assert.JSONEq(t, `{"data":{"account_Account_Deadline":{"id":"","name":""}}}`, g.serve(http.MethodPost, string(b)).Body.String())
+	assert.JSONEq(t, `{"data":{"account_Account_Deadline":{"id":"","name":"deadline"}}}`,
+		g.serve(http.MethodPost, string(b), "Grpc-Timeout", "5S").Body.String())
+}
+
+func TestSubscription(t *testing.T) {
+	g, stop := newGateway(t)
+	defer stop()
+
+	b, _ := json.Marshal(&request{Query: `subscription { account_Account_Watch(id: "1") { name } }`})
+	w := g.serve(http.MethodPost, string(b))
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "event: next\ndata: {\"data\":{\"account_Account_Watch\":{\"name\":\"vine-0\"}}}\n\n"+
+		"event: next\ndata: {\"data\":{\"account_Account_Watch\":{\"name\":\"vine-1\"}}}\n\n"+
+		"event: complete\ndata: \n\n", w.Body.String())
+
+	// broker topics are streamed until the client goes away
+	cx, cancel := context.WithCancel(context.Background())
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	b, _ = json.Marshal(&request{Query: `subscription { topic_go_vine_api_events { topic body } }`})
+	c.Request = httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(b))).WithContext(cx)
+	done := make(chan struct{})
+	go func() {
+		g.h.Handle(c)
+		close(done)
+	}()
+
+	msg := &broker.Message{Header: map[string]string{"Content-Type": "application/json"}, Body: []byte(`{"id":"1"}`)}
+	assert.Eventually(t, func() bool {
+		_ = g.b.Publish(context.TODO(), "go.vine.api.events", msg)
+		select {
+		case <-done:
+			return true
+		case <-time.After(time.Millisecond * 20):
+		}
+		cancel()
+		<-done
+		return strings.Contains(rec.Body.String(), "event: next")
+	}, time.Second, time.Millisecond*50)
+	assert.True(t, strings.HasPrefix(rec.Body.String(),
+		"event: next\ndata: {\"data\":{\"topic_go_vine_api_events\":{\"topic\":\"go.vine.api.events\",\"body\":{\"id\":\"1\"}}}}\n\n"), rec.Body.String())
+}
+
+func TestAuthorize(t *testing.T) {
+	g, stop := newGateway(t, handler.WithAuthorizer(func(ctx context.Context, service string, ep *api.Endpoint, md map[string]string) error {
+		if ep == nil || ep.Security != "apiKeys" {
+			return nil
+		}
+		if service != "go.vine.api.account" || ep.Name != "Account.Get" {
+			return errors.Forbidden("test", "unexpected call of %s %s", service, ep.Name)
+		}
+		if v, _ := metadata.Metadata(md).Get("X-Api-Key"); v != "secret" {
+			return errors.Unauthorized("test", "missing api key")
+		}
+		return nil
+	}))
+	defer stop()
+
+	rsp := &response{}
+	assert.NoError(t, json.Unmarshal([]byte(g.query(`{ account_Account_Get(id: "1") { id } }`, nil)), rsp))
+	if assert.Len(t, rsp.Errors, 1) {
+		assert.Equal(t, "missing api key", rsp.Errors[0].Message)
+		assert.Equal(t, float64(401), rsp.Errors[0].Extensions["code"])
+	}
+
+	// the endpoints without security are not checked
+	assert.JSONEq(t, `{"data":{"account_Account_Signup":{"id":"2"}}}`,
+		g.query(`mutation { account_Account_Signup(name: "vine") { id } }`, nil))
+
+	b, _ := json.Marshal(&request{Query: `{ account_Account_Get(id: "1") { id } }`})
+	w := g.serve(http.MethodPost, string(b), "X-API-Key", "secret")
+	assert.JSONEq(t, `{"data":{"account_Account_Get":{"id":"1"}}}`, w.Body.String())
+}
+
+func TestRebuild(t *testing.T) {
+	g, stop := newGateway(t)
+	defer stop()
+
+	assert.Contains(t, g.query(`{ other_Account_Get(id: "1") { id } }`, nil), `cannot query field`)
+
+	s := smemory.NewServer(server.Name("go.vine.api.other"), server.Id("other"), server.Registry(g.r), server.Broker(g.b))
+	if err := s.Handle(s.NewHandler(&Account{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	// without a document the request and response are JSON
+	assert.Eventually(t, func() bool {
+		return strings.Contains(g.query(`mutation { other_Account_Get(input: {id: "1"}) }`, nil), `"name":"vine"`)
+	}, time.Second*2, time.Millisecond*20)
+
+	// the document of the unchanged service is not fetched again
+	assert.Equal(t, int32(1), atomic.LoadInt32(&g.doc.fetched))
+}
@@ -0,0 +1,812 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a parsed executable graphql document
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	typ        string
+	name       string
+	variables  []*variableDef
+	directives []*directive
+	selections []selection
+}
+
+type variableDef struct {
+	name string
+	typ  *typeRef
+	def  *value
+}
+
+// typeRef is a named type, a list of elem or either of them non null
+type typeRef struct {
+	name    string
+	elem    *typeRef
+	nonNull bool
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.elem != nil {
+		s = "[" + t.elem.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+// named returns the name of the innermost type
+func (t *typeRef) named() string {
+	if t.elem != nil {
+		return t.elem.named()
+	}
+	return t.name
+}
+
+type fragment struct {
+	name       string
+	on         string
+	directives []*directive
+	selections []selection
+}
+
+// selection is a *field, *fragmentSpread or *inlineFragment
+type selection interface{}
+
+type field struct {
+	alias      string
+	name       string
+	args       []*argument
+	directives []*directive
+	selections []selection
+}
+
+// key is the name of the field in the response
+func (f *field) key() string {
+	if len(f.alias) > 0 {
+		return f.alias
+	}
+	return f.name
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []*directive
+}
+
+type inlineFragment struct {
+	on         string
+	directives []*directive
+	selections []selection
+}
+
+type argument struct {
+	name  string
+	value *value
+}
+
+type directive struct {
+	name string
+	args []*argument
+}
+
+type valueKind int
+
+const (
+	variableValue valueKind = iota
+	intValue
+	floatValue
+	stringValue
+	booleanValue
+	nullValue
+	enumValue
+	listValue
+	objectValue
+)
+
+type value struct {
+	kind   valueKind
+	raw    string
+	list   []*value
+	fields []*argument
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// lexer splits the source into tokens, commas and comments are ignored
+type lexer struct {
+	src string
+	pos int
+	tok token
+}
+
+func (l *lexer) errorf(format string, args ...interface{}) error {
+	line, col := 1, 1
+	for _, r := range l.src[:l.tok.pos] {
+		if r == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return fmt.Errorf("syntax error: %s (line %d, column %d)", fmt.Sprintf(format, args...), line, col)
+}
+
+func (l *lexer) next() error {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			l.pos++
+			continue
+		}
+		if c == '#' {
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+			continue
+		}
+		if strings.HasPrefix(l.src[l.pos:], "\ufeff") {
+			l.pos += len("\ufeff")
+			continue
+		}
+		break
+	}
+
+	l.tok = token{pos: l.pos}
+	if l.pos >= len(l.src) {
+		l.tok.kind = tokenEOF
+		return nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+		l.pos++
+		l.tok.kind, l.tok.value = tokenPunct, string(c)
+	case c == '.':
+		if !strings.HasPrefix(l.src[l.pos:], "...") {
+			return l.errorf("unexpected '.'")
+		}
+		l.pos += 3
+		l.tok.kind, l.tok.value = tokenPunct, "..."
+	case c == '_' || isLetter(c):
+		start := l.pos
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		l.tok.kind, l.tok.value = tokenName, l.src[start:l.pos]
+	case c == '-' || isDigit(c):
+		return l.number()
+	case c == '"':
+		return l.string()
+	default:
+		r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+		return l.errorf("unexpected character %q", r)
+	}
+	return nil
+}
+
+func (l *lexer) number() error {
+	start := l.pos
+	kind := tokenInt
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	digits := func() int {
+		n := 0
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+			n++
+		}
+		return n
+	}
+	if digits() == 0 {
+		return l.errorf("invalid number")
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		l.pos++
+		kind = tokenFloat
+		if digits() == 0 {
+			return l.errorf("invalid number")
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		l.pos++
+		kind = tokenFloat
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		if digits() == 0 {
+			return l.errorf("invalid number")
+		}
+	}
+	l.tok.kind, l.tok.value = kind, l.src[start:l.pos]
+	return nil
+}
+
+func (l *lexer) string() error {
+	if strings.HasPrefix(l.src[l.pos:], `"""`) {
+		end := strings.Index(l.src[l.pos+3:], `"""`)
+		for end >= 0 && l.src[l.pos+3+end-1] == '\\' {
+			next := strings.Index(l.src[l.pos+3+end+3:], `"""`)
+			if next < 0 {
+				end = -1
+				break
+			}
+			end += 3 + next
+		}
+		if end < 0 {
+			return l.errorf("unterminated string")
+		}
+		raw := l.src[l.pos+3 : l.pos+3+end]
+		l.pos += end + 6
+		l.tok.kind, l.tok.value = tokenString, blockString(strings.ReplaceAll(raw, `\"""`, `"""`))
+		return nil
+	}
+
+	var sb strings.Builder
+	l.pos++
+	for {
+		if l.pos >= len(l.src) || l.src[l.pos] == '\n' {
+			return l.errorf("unterminated string")
+		}
+		c := l.src[l.pos]
+		if c == '"' {
+			l.pos++
+			break
+		}
+		if c != '\\' {
+			sb.WriteByte(c)
+			l.pos++
+			continue
+		}
+		if l.pos+1 >= len(l.src) {
+			return l.errorf("unterminated string")
+		}
+		switch e := l.src[l.pos+1]; e {
+		case '"', '\\', '/':
+			sb.WriteByte(e)
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'u':
+			if l.pos+6 > len(l.src) {
+				return l.errorf("invalid unicode escape")
+			}
+			r, err := strconv.ParseUint(l.src[l.pos+2:l.pos+6], 16, 32)
+			if err != nil {
+				return l.errorf("invalid unicode escape")
+			}
+			sb.WriteRune(rune(r))
+			l.pos += 4
+		default:
+			return l.errorf("invalid escape \\%c", e)
+		}
+		l.pos += 2
+	}
+	l.tok.kind, l.tok.value = tokenString, sb.String()
+	return nil
+}
+
+// blockString removes the common indentation and the blank first and last lines
+func blockString(raw string) string {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if len(trimmed) == 0 {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = strings.TrimLeft(lines[i], " \t")
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+type parser struct {
+	lex   *lexer
+	depth int
+}
+
+// parse parses an executable document, type system definitions are rejected
+func parse(src string) (*document, error) {
+	p := &parser{lex: &lexer{src: src}}
+	if err := p.lex.next(); err != nil {
+		return nil, err
+	}
+
+	doc := &document{fragments: map[string]*fragment{}}
+	for p.lex.tok.kind != tokenEOF {
+		switch {
+		case p.peek("{"):
+			sels, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &operation{typ: "query", selections: sels})
+		case p.peekName("query"), p.peekName("mutation"), p.peekName("subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case p.peekName("fragment"):
+			f, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.fragments[f.name]; ok {
+				return nil, fmt.Errorf("there can be only one fragment named \"%s\"", f.name)
+			}
+			doc.fragments[f.name] = f
+		default:
+			return nil, p.unexpected()
+		}
+	}
+
+	if len(doc.operations) == 0 {
+		return nil, fmt.Errorf("document contains no operations")
+	}
+
+	return doc, nil
+}
+
+func (p *parser) peek(punct string) bool {
+	return p.lex.tok.kind == tokenPunct && p.lex.tok.value == punct
+}
+
+func (p *parser) peekName(name string) bool {
+	return p.lex.tok.kind == tokenName && p.lex.tok.value == name
+}
+
+func (p *parser) unexpected() error {
+	if p.lex.tok.kind == tokenEOF {
+		return p.lex.errorf("unexpected <EOF>")
+	}
+	return p.lex.errorf("unexpected \"%s\"", p.lex.tok.value)
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.peek(punct) {
+		if p.lex.tok.kind == tokenEOF {
+			return p.lex.errorf("expected \"%s\", found <EOF>", punct)
+		}
+		return p.lex.errorf("expected \"%s\", found \"%s\"", punct, p.lex.tok.value)
+	}
+	return p.lex.next()
+}
+
+// enter counts a nesting level, the deep documents are rejected before they exhaust the stack
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > DefaultMaxDepth {
+		return p.lex.errorf("document exceeds the maximum depth of %d", DefaultMaxDepth)
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) name() (string, error) {
+	if p.lex.tok.kind != tokenName {
+		return "", p.unexpected()
+	}
+	name := p.lex.tok.value
+	return name, p.lex.next()
+}
+
+func (p *parser) operation() (*operation, error) {
+	op := &operation{typ: p.lex.tok.value}
+	if err := p.lex.next(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if p.lex.tok.kind == tokenName {
+		if op.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek("(") {
+		if err = p.lex.next(); err != nil {
+			return nil, err
+		}
+		for !p.peek(")") {
+			v, err := p.variableDef()
+			if err != nil {
+				return nil, err
+			}
+			op.variables = append(op.variables, v)
+		}
+		if err = p.lex.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if op.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if op.selections, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+func (p *parser) variableDef() (*variableDef, error) {
+	if err := p.expect("$"); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(":"); err != nil {
+		return nil, err
+	}
+	v := &variableDef{name: name}
+	if v.typ, err = p.typeRef(); err != nil {
+		return nil, err
+	}
+	if p.peek("=") {
+		if err = p.lex.next(); err != nil {
+			return nil, err
+		}
+		if v.def, err = p.value(true); err != nil {
+			return nil, err
+		}
+	}
+	// directives of variables have no meaning here
+	if _, err = p.directives(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (p *parser) typeRef() (*typeRef, error) {
+	t := &typeRef{}
+	if p.peek("[") {
+		if err := p.lex.next(); err != nil {
+			return nil, err
+		}
+		elem, err := p.typeRef()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect("]"); err != nil {
+			return nil, err
+		}
+		t.elem = elem
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		t.name = name
+	}
+	if p.peek("!") {
+		t.nonNull = true
+		if err := p.lex.next(); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (p *parser) fragment() (*fragment, error) {
+	if err := p.lex.next(); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, p.lex.errorf("unexpected \"on\"")
+	}
+	if !p.peekName("on") {
+		return nil, p.unexpected()
+	}
+	if err = p.lex.next(); err != nil {
+		return nil, err
+	}
+	f := &fragment{name: name}
+	if f.on, err = p.name(); err != nil {
+		return nil, err
+	}
+	if f.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if f.selections, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var sels []selection
+	for !p.peek("}") {
+		s, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, s)
+	}
+	if len(sels) == 0 {
+		return nil, p.lex.errorf("selection set can't be empty")
+	}
+	return sels, p.lex.next()
+}
+
+func (p *parser) selection() (selection, error) {
+	var err error
+	if p.peek("...") {
+		if err = p.lex.next(); err != nil {
+			return nil, err
+		}
+		if p.lex.tok.kind == tokenName && !p.peekName("on") {
+			s := &fragmentSpread{}
+			if s.name, err = p.name(); err != nil {
+				return nil, err
+			}
+			if s.directives, err = p.directives(); err != nil {
+				return nil, err
+			}
+			return s, nil
+		}
+
+		s := &inlineFragment{}
+		if p.peekName("on") {
+			if err = p.lex.next(); err != nil {
+				return nil, err
+			}
+			if s.on, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		if s.directives, err = p.directives(); err != nil {
+			return nil, err
+		}
+		if s.selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	f := &field{}
+	if f.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if p.peek(":") {
+		if err = p.lex.next(); err != nil {
+			return nil, err
+		}
+		f.alias = f.name
+		if f.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if f.args, err = p.arguments(false); err != nil {
+		return nil, err
+	}
+	if f.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.peek("{") {
+		if f.selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (p *parser) arguments(constant bool) ([]*argument, error) {
+	if !p.peek("(") {
+		return nil, nil
+	}
+	if err := p.lex.next(); err != nil {
+		return nil, err
+	}
+	var args []*argument
+	for !p.peek(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.value(constant)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, &argument{name: name, value: v})
+	}
+	if len(args) == 0 {
+		return nil, p.lex.errorf("argument list can't be empty")
+	}
+	return args, p.lex.next()
+}
+
+func (p *parser) directives() ([]*directive, error) {
+	var dirs []*directive
+	for p.peek("@") {
+		if err := p.lex.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.arguments(false)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, &directive{name: name, args: args})
+	}
+	return dirs, nil
+}
+
+func (p *parser) value(constant bool) (*value, error) {
+	tok := p.lex.tok
+	switch tok.kind {
+	case tokenInt:
+		return &value{kind: intValue, raw: tok.value}, p.lex.next()
+	case tokenFloat:
+		return &value{kind: floatValue, raw: tok.value}, p.lex.next()
+	case tokenString:
+		return &value{kind: stringValue, raw: tok.value}, p.lex.next()
+	case tokenName:
+		v := &value{kind: enumValue, raw: tok.value}
+		switch tok.value {
+		case "true", "false":
+			v.kind = booleanValue
+		case "null":
+			v.kind = nullValue
+		}
+		return v, p.lex.next()
+	}
+
+	if p.peek("[") || p.peek("{") {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+	}
+
+	switch {
+	case p.peek("$") && !constant:
+		if err := p.lex.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		return &value{kind: variableValue, raw: name}, nil
+	case p.peek("["):
+		if err := p.lex.next(); err != nil {
+			return nil, err
+		}
+		v := &value{kind: listValue}
+		for !p.peek("]") {
+			item, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			v.list = append(v.list, item)
+		}
+		return v, p.lex.next()
+	case p.peek("{"):
+		if err := p.lex.next(); err != nil {
+			return nil, err
+		}
+		v := &value{kind: objectValue}
+		for !p.peek("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect(":"); err != nil {
+				return nil, err
+			}
+			item, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			v.fields = append(v.fields, &argument{name: name, value: item})
+		}
+		return v, p.lex.next()
+	}
+
+	return nil, p.unexpected()
+}
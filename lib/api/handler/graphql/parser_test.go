@@ -0,0 +1,116 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package graphql
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := parse(`
+		# comment
+		query Get($id: ID! = "1", $tags: [String!]) @cached {
+			a: get(id: $id, filter: {tags: $tags, min: -1.5e3, on: true, kind: FULL, none: null}) {
+				...fields
+				... on Account @include(if: true) { name }
+				... { id }
+			}
+		}
+		fragment fields on Account { id, description(format: """
+			block
+			  string
+		""") }
+		subscription { watch }
+	`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Len(t, doc.operations, 2)
+	op := doc.operations[0]
+	assert.Equal(t, "query", op.typ)
+	assert.Equal(t, "Get", op.name)
+	assert.Equal(t, "ID!", op.variables[0].typ.String())
+	assert.Equal(t, "1", op.variables[0].def.raw)
+	assert.Equal(t, "[String!]", op.variables[1].typ.String())
+	assert.Equal(t, "cached", op.directives[0].name)
+
+	f := op.selections[0].(*field)
+	assert.Equal(t, "a", f.key())
+	assert.Equal(t, "get", f.name)
+	assert.Equal(t, variableValue, f.args[0].value.kind)
+	filter := f.args[1].value
+	assert.Equal(t, objectValue, filter.kind)
+	assert.Equal(t, []valueKind{variableValue, floatValue, booleanValue, enumValue, nullValue},
+		[]valueKind{filter.fields[0].value.kind, filter.fields[1].value.kind, filter.fields[2].value.kind, filter.fields[3].value.kind, filter.fields[4].value.kind})
+	assert.Equal(t, "fields", f.selections[0].(*fragmentSpread).name)
+	assert.Equal(t, "Account", f.selections[1].(*inlineFragment).on)
+	assert.Equal(t, "", f.selections[2].(*inlineFragment).on)
+
+	desc := doc.fragments["fields"].selections[1].(*field)
+	assert.Equal(t, "block\n  string", desc.args[0].value.raw)
+	assert.Equal(t, "subscription", doc.operations[1].typ)
+
+	doc, err = parse(`{ say(text: "a\"bé\n") }`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "a\"bé\n", doc.operations[0].selections[0].(*field).args[0].value.raw)
+	}
+
+	for query, msg := range map[string]string{
+		`{ a `:                `syntax error: unexpected <EOF> (line 1, column 5)`,
+		"{\n  a(b: ) }":       `syntax error: unexpected ")" (line 2, column 8)`,
+		`{ a(b: "c) }`:        `syntax error: unterminated string (line 1, column 8)`,
+		`type Query { a }`:    `syntax error: unexpected "type" (line 1, column 1)`,
+		`fragment a on B {a}`: `document contains no operations`,
+		`{}`:                  `syntax error: selection set can't be empty (line 1, column 2)`,
+	} {
+		_, err := parse(query)
+		if assert.Error(t, err, query) {
+			assert.Equal(t, msg, err.Error(), query)
+		}
+	}
+}
+
+func TestParseDepth(t *testing.T) {
+	nested := func(open, close string, n int) string {
+		return strings.Repeat(open, n) + strings.Repeat(close, n)
+	}
+
+	_, err := parse(nested("{a", "}", DefaultMaxDepth))
+	assert.NoError(t, err)
+
+	// deep documents are rejected instead of exhausting the stack
+	for _, query := range []string{
+		nested("{a", "}", 1<<20),
+		"{a(b: " + nested("[", "]", 1<<20) + ")}",
+		"{a(b: " + nested("{c:", "}", 1<<20) + ")}",
+	} {
+		_, err := parse(query)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "document exceeds the maximum depth of 64")
+		}
+	}
+}
@@ -0,0 +1,500 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package graphql
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/vine-io/vine/core/registry"
+	"github.com/vine-io/vine/lib/api"
+	pb "github.com/vine-io/vine/lib/api/handler/openapi/proto"
+)
+
+const (
+	// JSON is the scalar of the values without a documented shape
+	JSON = "JSON"
+	// EventType is the type of the messages received from a broker topic
+	EventType = "BrokerEvent"
+)
+
+var scalars = map[string]bool{
+	"String":  true,
+	"Int":     true,
+	"Float":   true,
+	"Boolean": true,
+	"ID":      true,
+	JSON:      true,
+}
+
+type fieldKind int
+
+const (
+	// resolvedField is read from the value of the parent
+	resolvedField fieldKind = iota
+	// unaryField calls a unary rpc
+	unaryField
+	// streamField calls a server streaming rpc
+	streamField
+	// topicField subscribes to a broker topic
+	topicField
+)
+
+type schema struct {
+	query        *objectType
+	mutation     *objectType
+	subscription *objectType
+	types        map[string]*objectType
+	inputs       map[string]*inputType
+}
+
+type objectType struct {
+	name        string
+	description string
+	fields      map[string]*fieldDef
+}
+
+func newObjectType(name string) *objectType {
+	return &objectType{name: name, fields: map[string]*fieldDef{}}
+}
+
+type fieldDef struct {
+	name        string
+	description string
+	typ         *typeRef
+	args        []*argDef
+	kind        fieldKind
+	service     string
+	endpoint    string
+	topic       string
+	// gateway is the api endpoint of the rpc, it carries its security
+	gateway *api.Endpoint
+	// input means the request is passed as the JSON argument "input"
+	input bool
+}
+
+func (f *fieldDef) arg(name string) *argDef {
+	for _, a := range f.args {
+		if a.name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+type argDef struct {
+	name        string
+	description string
+	typ         *typeRef
+}
+
+type inputType struct {
+	name   string
+	fields []*argDef
+}
+
+// serviceInfo is what the schema of a service is built from
+type serviceInfo struct {
+	name      string
+	endpoints []*registry.Endpoint
+	doc       *pb.OpenAPI
+}
+
+// builder converts the OpenAPI documents of the services to graphql types
+type builder struct {
+	s *schema
+	// names maps the service and component name to the type name
+	names map[string]string
+}
+
+// buildSchema builds the schema of the services. Unary endpoints documented as GET
+// become queries and the others mutations, server streaming endpoints and broker
+// topics become subscriptions. Endpoints missing from the document of the service
+// take their request as the JSON argument "input" and return JSON.
+func buildSchema(namespace string, services []*serviceInfo) *schema {
+	b := &builder{
+		s: &schema{
+			query:        newObjectType("Query"),
+			mutation:     newObjectType("Mutation"),
+			subscription: newObjectType("Subscription"),
+			types:        map[string]*objectType{},
+			inputs:       map[string]*inputType{},
+		},
+		names: map[string]string{},
+	}
+
+	event := newObjectType(EventType)
+	event.fields["topic"] = &fieldDef{name: "topic", typ: &typeRef{name: "String", nonNull: true}}
+	event.fields["header"] = &fieldDef{name: "header", typ: &typeRef{name: JSON}}
+	event.fields["body"] = &fieldDef{name: "body", typ: &typeRef{name: JSON}, description: "The decoded json message, or the message encoded as base64"}
+	b.s.types[event.name] = event
+
+	sort.Slice(services, func(i, j int) bool { return services[i].name < services[j].name })
+	for _, svc := range services {
+		b.service(namespace, svc)
+	}
+
+	for _, root := range []*objectType{b.s.query, b.s.mutation, b.s.subscription} {
+		b.s.types[root.name] = root
+	}
+
+	return b.s
+}
+
+func (b *builder) service(namespace string, svc *serviceInfo) {
+	prefix := fieldName(strings.TrimPrefix(svc.name, namespace+"."))
+
+	for _, ep := range svc.endpoints {
+		if ep.Metadata["subscriber"] == "true" {
+			topic := ep.Metadata["topic"]
+			if len(topic) == 0 {
+				continue
+			}
+			name := "topic_" + fieldName(topic)
+			b.s.subscription.fields[name] = &fieldDef{
+				name:        name,
+				description: fmt.Sprintf("Messages published to the topic %s", topic),
+				typ:         &typeRef{name: EventType, nonNull: true},
+				kind:        topicField,
+				topic:       topic,
+			}
+			continue
+		}
+
+		// the documents are served by every service
+		if strings.HasPrefix(ep.Name, "OpenAPIService.") {
+			continue
+		}
+
+		// servers without the api metadata only flag that the endpoint streams
+		stream := ep.Metadata["stream"]
+		streaming := stream == string(api.Server) || stream == "true"
+		if len(stream) > 0 && !streaming {
+			continue
+		}
+
+		f := &fieldDef{
+			name:     prefix + "_" + fieldName(ep.Name),
+			service:  svc.name,
+			endpoint: ep.Name,
+			kind:     unaryField,
+			gateway:  api.Decode(ep.Metadata),
+		}
+		if f.gateway != nil {
+			f.gateway.Name = ep.Name
+		}
+
+		method, op := findOperation(svc.doc, ep.Name)
+		if op == nil {
+			f.input = true
+			f.args = []*argDef{{name: "input", typ: &typeRef{name: JSON}}}
+			f.typ = &typeRef{name: JSON}
+		} else {
+			f.description = op.Summary
+			if len(f.description) == 0 {
+				f.description = op.Description
+			}
+			f.args = b.args(svc.name, svc.doc, requestModel(svc.doc, op))
+			f.typ = &typeRef{name: JSON}
+			if s := responseSchema(op); s != nil {
+				f.typ = b.output(svc.name, svc.doc, s)
+			}
+		}
+
+		switch {
+		case streaming:
+			f.kind = streamField
+			b.s.subscription.fields[f.name] = f
+		case method == "GET":
+			b.s.query.fields[f.name] = f
+		default:
+			b.s.mutation.fields[f.name] = f
+		}
+	}
+}
+
+func (b *builder) args(service string, doc *pb.OpenAPI, model *pb.Model) []*argDef {
+	if model == nil {
+		return nil
+	}
+
+	required := map[string]bool{}
+	for _, name := range model.Required {
+		required[name] = true
+	}
+
+	args := make([]*argDef, 0, len(model.Properties))
+	for _, name := range sortedKeys(model.Properties) {
+		s := model.Properties[name]
+		t := b.input(service, doc, s)
+		if required[name] || s.Required {
+			t.nonNull = true
+		}
+		args = append(args, &argDef{name: name, description: s.Description, typ: t})
+	}
+	return args
+}
+
+// typeName returns the unique name of the component of the service
+func (b *builder) typeName(service, component, suffix string) (string, bool) {
+	key := service + "/" + component + suffix
+	if name, ok := b.names[key]; ok {
+		return name, true
+	}
+
+	name := fieldName(component) + suffix
+	if _, ok := b.s.types[name]; ok || scalars[name] || b.s.inputs[name] != nil {
+		name = fieldName(service) + "_" + name
+	}
+	b.names[key] = name
+	return name, false
+}
+
+func (b *builder) output(service string, doc *pb.OpenAPI, s *pb.Schema) *typeRef {
+	if s == nil {
+		return &typeRef{name: JSON}
+	}
+	if len(s.Ref) > 0 {
+		return &typeRef{name: b.object(service, doc, refName(s.Ref))}
+	}
+	if s.Type == "array" {
+		return &typeRef{elem: b.output(service, doc, s.Items)}
+	}
+	return &typeRef{name: scalar(s)}
+}
+
+func (b *builder) object(service string, doc *pb.OpenAPI, component string) string {
+	model := docModel(doc, component)
+	if model == nil {
+		return JSON
+	}
+
+	name, ok := b.typeName(service, component, "")
+	if ok {
+		return name
+	}
+
+	obj := newObjectType(name)
+	// registered before the fields for recursive messages
+	b.s.types[name] = obj
+	for _, prop := range sortedKeys(model.Properties) {
+		s := model.Properties[prop]
+		obj.fields[prop] = &fieldDef{
+			name:        prop,
+			description: s.Description,
+			typ:         b.output(service, doc, s),
+		}
+	}
+	return name
+}
+
+func (b *builder) input(service string, doc *pb.OpenAPI, s *pb.Schema) *typeRef {
+	if s == nil {
+		return &typeRef{name: JSON}
+	}
+	if len(s.Ref) > 0 {
+		return &typeRef{name: b.inputObject(service, doc, refName(s.Ref))}
+	}
+	if s.Type == "array" {
+		return &typeRef{elem: b.input(service, doc, s.Items)}
+	}
+	return &typeRef{name: scalar(s)}
+}
+
+func (b *builder) inputObject(service string, doc *pb.OpenAPI, component string) string {
+	model := docModel(doc, component)
+	if model == nil {
+		return JSON
+	}
+
+	name, ok := b.typeName(service, component, "Input")
+	if ok {
+		return name
+	}
+
+	in := &inputType{name: name}
+	b.s.inputs[name] = in
+	in.fields = b.args(service, doc, model)
+	return name
+}
+
+// scalar maps the OpenAPI type to a graphql scalar, maps and objects without
+// a model are JSON
+func scalar(s *pb.Schema) string {
+	switch s.Type {
+	case "string":
+		return "String"
+	case "integer":
+		return "Int"
+	case "number":
+		return "Float"
+	case "boolean":
+		return "Boolean"
+	}
+	return JSON
+}
+
+// findOperation finds the operation of the endpoint, the operation id is generated
+// as the service name followed by the method name.
+func findOperation(doc *pb.OpenAPI, endpoint string) (string, *pb.OpenAPIPathDocs) {
+	if doc == nil {
+		return "", nil
+	}
+	id := strings.Replace(endpoint, ".", "", 1)
+	for _, key := range sortedKeys(doc.Paths) {
+		path := doc.Paths[key]
+		for method, op := range map[string]*pb.OpenAPIPathDocs{
+			"GET":    path.Get,
+			"POST":   path.Post,
+			"PUT":    path.Put,
+			"PATCH":  path.Patch,
+			"DELETE": path.Delete,
+		} {
+			if op != nil && op.OperationId == id {
+				return method, op
+			}
+		}
+	}
+	return "", nil
+}
+
+// requestModel returns the model of the request body or of the parameters
+func requestModel(doc *pb.OpenAPI, op *pb.OpenAPIPathDocs) *pb.Model {
+	if body := op.RequestBody; body != nil && body.Content != nil &&
+		body.Content.ApplicationJson != nil && body.Content.ApplicationJson.Schema != nil {
+		return docModel(doc, refName(body.Content.ApplicationJson.Schema.Ref))
+	}
+
+	model := &pb.Model{Type: "object", Properties: map[string]*pb.Schema{}}
+	for _, p := range op.Parameters {
+		if p.Schema == nil {
+			continue
+		}
+		model.Properties[p.Name] = p.Schema
+		if p.Required {
+			model.Required = append(model.Required, p.Name)
+		}
+	}
+	return model
+}
+
+func responseSchema(op *pb.OpenAPIPathDocs) *pb.Schema {
+	rsp, ok := op.Responses["200"]
+	if !ok || rsp.Content == nil || rsp.Content.ApplicationJson == nil {
+		return nil
+	}
+	return rsp.Content.ApplicationJson.Schema
+}
+
+func docModel(doc *pb.OpenAPI, name string) *pb.Model {
+	if doc == nil || doc.Components == nil {
+		return nil
+	}
+	return doc.Components.Schemas[name]
+}
+
+func refName(ref string) string {
+	return strings.TrimPrefix(ref, "#/components/schemas/")
+}
+
+// fieldName replaces the characters which are not allowed in graphql names
+func fieldName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c != '_' && !isLetter(c) && !isDigit(c) {
+			b[i] = '_'
+		}
+	}
+	if len(b) > 0 && isDigit(b[0]) {
+		return "_" + string(b)
+	}
+	return string(b)
+}
+
+// sortedKeys returns the keys of the map with string keys in order
+func sortedKeys(m interface{}) []string {
+	v := reflect.ValueOf(m)
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// String prints the schema in the schema definition language
+func (s *schema) String() string {
+	var sb strings.Builder
+	sb.WriteString("scalar JSON\n\nschema {\n")
+	for _, root := range []*objectType{s.query, s.mutation, s.subscription} {
+		if len(root.fields) > 0 {
+			fmt.Fprintf(&sb, "  %s: %s\n", strings.ToLower(root.name), root.name)
+		}
+	}
+	sb.WriteString("}\n")
+
+	for _, name := range sortedKeys(s.types) {
+		t := s.types[name]
+		if len(t.fields) == 0 {
+			continue
+		}
+		sb.WriteString("\n")
+		description(&sb, "", t.description)
+		fmt.Fprintf(&sb, "type %s {\n", t.name)
+		for _, fname := range sortedKeys(t.fields) {
+			f := t.fields[fname]
+			description(&sb, "  ", f.description)
+			fmt.Fprintf(&sb, "  %s", f.name)
+			if len(f.args) > 0 {
+				args := make([]string, 0, len(f.args))
+				for _, a := range f.args {
+					args = append(args, a.name+": "+a.typ.String())
+				}
+				fmt.Fprintf(&sb, "(%s)", strings.Join(args, ", "))
+			}
+			fmt.Fprintf(&sb, ": %s\n", f.typ)
+		}
+		sb.WriteString("}\n")
+	}
+
+	for _, name := range sortedKeys(s.inputs) {
+		in := s.inputs[name]
+		fmt.Fprintf(&sb, "\ninput %s {\n", in.name)
+		for _, f := range in.fields {
+			description(&sb, "  ", f.description)
+			fmt.Fprintf(&sb, "  %s: %s\n", f.name, f.typ)
+		}
+		sb.WriteString("}\n")
+	}
+
+	return sb.String()
+}
+
+func description(sb *strings.Builder, indent, s string) {
+	if len(s) == 0 {
+		return
+	}
+	fmt.Fprintf(sb, "%s\"\"\"%s\"\"\"\n", indent, strings.ReplaceAll(s, `"""`, `\"""`))
+}
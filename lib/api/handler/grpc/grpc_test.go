@@ -0,0 +1,314 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpc
+
+import (
+	gbytes "bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	membroker "github.com/vine-io/vine/core/broker/memory"
+	"github.com/vine-io/vine/core/client"
+	cgrpc "github.com/vine-io/vine/core/client/grpc"
+	"github.com/vine-io/vine/core/registry"
+	regMemory "github.com/vine-io/vine/core/registry/memory"
+	"github.com/vine-io/vine/core/server"
+	sgrpc "github.com/vine-io/vine/core/server/grpc"
+	"github.com/vine-io/vine/lib/api"
+	"github.com/vine-io/vine/lib/api/handler"
+	rgrpc "github.com/vine-io/vine/lib/api/resolver/grpc"
+	"github.com/vine-io/vine/lib/api/router"
+	regRouter "github.com/vine-io/vine/lib/api/router/registry"
+	"github.com/vine-io/vine/lib/errors"
+	meta "github.com/vine-io/vine/util/context/metadata"
+)
+
+type Greeter struct{}
+
+func (g *Greeter) Hello(ctx context.Context, req *api.Pair, rsp *api.Pair) error {
+	if req.Key == "" {
+		return errors.BadRequest("greeter", "key is empty")
+	}
+	rsp.Key = "hello " + req.Key
+	if v, ok := meta.Get(ctx, "X-Name"); ok {
+		rsp.Values = append(rsp.Values, v)
+	}
+	if _, ok := ctx.Deadline(); ok {
+		rsp.Values = append(rsp.Values, "deadline")
+	}
+	_ = grpc.SetTrailer(ctx, metadata.Pairs("x-trailer", "done"))
+	return nil
+}
+
+func (g *Greeter) Watch(ctx context.Context, stream server.Stream) error {
+	req := &api.Pair{}
+	if err := stream.Recv(req); err != nil {
+		return err
+	}
+	for _, v := range []string{"a", "b"} {
+		if err := stream.Send(&api.Pair{Key: req.Key + v}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newServer(t *testing.T, r registry.Registry, name string) server.Server {
+	s := sgrpc.NewServer(
+		server.Name(name),
+		server.Address("127.0.0.1:0"),
+		server.Registry(r),
+		server.Broker(membroker.NewBroker()),
+	)
+	if err := s.Handle(s.NewHandler(&Greeter{}, api.WithEndpoint(&api.Endpoint{Name: "Greeter.Hello", Security: "apiKeys"}))); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func newRouter(r registry.Registry) router.Router {
+	return regRouter.NewRouter(
+		router.WithHandler(Handler),
+		router.WithResolver(rgrpc.NewResolver()),
+		router.WithRegistry(r),
+	)
+}
+
+func TestProxy(t *testing.T) {
+	r := regMemory.NewRegistry()
+	backend := newServer(t, r, "greeter")
+	defer backend.Stop()
+
+	rt := newRouter(r)
+	defer rt.Close()
+	// the gateway has no handlers of its own
+	gateway := sgrpc.NewServer(
+		server.Name("gateway"),
+		server.Address("127.0.0.1:0"),
+		server.Registry(regMemory.NewRegistry()),
+		server.Broker(membroker.NewBroker()),
+		server.WithRouter(NewProxy(handler.WithRouter(rt), handler.WithClient(cgrpc.NewClient(client.Registry(r))))),
+	)
+	if err := gateway.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Stop()
+
+	c := cgrpc.NewClient(client.Registry(r))
+	addr := client.WithAddress(gateway.Options().Address)
+
+	cx, cancel := context.WithTimeout(meta.Set(context.TODO(), "X-Name", "vine"), time.Second*5)
+	defer cancel()
+
+	rsp := &api.Pair{}
+	err := c.Call(cx, c.NewRequest("greeter", "Greeter.Hello", &api.Pair{Key: "vine"}), rsp, addr)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "hello vine", rsp.Key)
+		assert.Equal(t, []string{"vine", "deadline"}, rsp.Values)
+	}
+
+	err = c.Call(cx, c.NewRequest("greeter", "Greeter.Hello", &api.Pair{}), rsp, addr)
+	assert.Equal(t, errors.StatusBadRequest, errors.FromErr(err).Code)
+
+	stream, err := c.Stream(cx, c.NewRequest("greeter", "Greeter.Watch", &api.Pair{}), addr)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer stream.Close()
+	assert.Nil(t, stream.Send(&api.Pair{Key: "vine"}))
+	for _, v := range []string{"a", "b"} {
+		out := &api.Pair{}
+		assert.Nil(t, stream.Recv(out))
+		assert.Equal(t, "vine"+v, out.Key)
+	}
+	assert.Equal(t, io.EOF, stream.Recv(&api.Pair{}))
+}
+
+func TestWebHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := regMemory.NewRegistry()
+	backend := newServer(t, r, "greeter")
+	defer backend.Stop()
+
+	rt := newRouter(r)
+	defer rt.Close()
+	h := NewHandler(handler.WithRouter(rt), handler.WithClient(cgrpc.NewClient(client.Registry(r))))
+
+	call := func(method, ct string, in proto.Message) (*httptest.ResponseRecorder, [][]byte, string) {
+		b, _ := proto.Marshal(in)
+		body := gbytes.NewBuffer(nil)
+		if isText(ct) {
+			// the frame header and the message are encoded as separately padded chunks
+			frame := gbytes.NewBuffer(nil)
+			_ = writeFrame(frame, dataFrame, b, false)
+			body.WriteString(base64.StdEncoding.EncodeToString(frame.Bytes()[:5]))
+			body.WriteString(base64.StdEncoding.EncodeToString(frame.Bytes()[5:]))
+		} else {
+			_ = writeFrame(body, dataFrame, b, false)
+		}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, method, body)
+		c.Request.Header.Set("Content-Type", ct)
+		c.Request.Header.Set("Grpc-Timeout", "5S")
+		c.Request.Header.Set("X-Name", "vine")
+		assert.True(t, IsGRPCWeb(c.Request))
+		h.Handle(c)
+
+		var out io.Reader = w.Body
+		if isText(ct) {
+			out = &textReader{r: out}
+		}
+		var frames [][]byte
+		var tr string
+		for {
+			flag, data, err := readFrame(out)
+			if err != nil {
+				break
+			}
+			if flag&trailerFrame != 0 {
+				tr = string(data)
+				continue
+			}
+			frames = append(frames, data)
+		}
+		return w, frames, tr
+	}
+
+	for _, ct := range []string{"application/grpc-web+proto", "application/grpc-web-text"} {
+		w, frames, tr := call("/greeter.Greeter/Hello", ct, &api.Pair{Key: "vine"})
+		assert.Equal(t, webContentType(ct), w.Header().Get("Content-Type"))
+		if assert.Equal(t, 1, len(frames)) {
+			rsp := &api.Pair{}
+			assert.Nil(t, proto.Unmarshal(frames[0], rsp))
+			assert.Equal(t, "hello vine", rsp.Key)
+			assert.Equal(t, []string{"vine", "deadline"}, rsp.Values)
+		}
+		assert.True(t, strings.HasPrefix(tr, "grpc-status: 0\r\n"))
+		assert.Contains(t, tr, "x-trailer: done\r\n")
+	}
+
+	_, frames, _ := call("/greeter.Greeter/Watch", "application/grpc-web+proto", &api.Pair{Key: "vine"})
+	assert.Equal(t, 2, len(frames))
+
+	_, frames, tr := call("/greeter.Greeter/Hello", "application/grpc-web+proto", &api.Pair{})
+	assert.Equal(t, 0, len(frames))
+	assert.True(t, strings.HasPrefix(tr, "grpc-status: 3\r\n"))
+
+	_, _, tr = call("/unknown.Unknown/Hello", "application/grpc-web+proto", &api.Pair{})
+	assert.True(t, strings.HasPrefix(tr, "grpc-status: 12\r\n"))
+}
+
+func TestAuthorize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := regMemory.NewRegistry()
+	backend := newServer(t, r, "greeter")
+	defer backend.Stop()
+
+	rt := newRouter(r)
+	defer rt.Close()
+
+	var calls []string
+	authorize := handler.WithAuthorizer(func(ctx context.Context, service string, ep *api.Endpoint, md map[string]string) error {
+		calls = append(calls, service+":"+ep.Name+":"+ep.Security)
+		if v, _ := meta.Metadata(md).Get("X-Api-Key"); v != "secret" {
+			return errors.Unauthorized("test", "missing api key")
+		}
+		return nil
+	})
+	opts := []handler.Option{handler.WithRouter(rt), handler.WithClient(cgrpc.NewClient(client.Registry(r))), authorize}
+
+	gateway := sgrpc.NewServer(
+		server.Name("gateway"),
+		server.Address("127.0.0.1:0"),
+		server.Registry(regMemory.NewRegistry()),
+		server.Broker(membroker.NewBroker()),
+		server.WithRouter(NewProxy(opts...)),
+	)
+	if err := gateway.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer gateway.Stop()
+
+	c := cgrpc.NewClient(client.Registry(r))
+	addr := client.WithAddress(gateway.Options().Address)
+
+	cx, cancel := context.WithTimeout(context.TODO(), time.Second*5)
+	defer cancel()
+
+	rsp := &api.Pair{}
+	err := c.Call(cx, c.NewRequest("greeter", "Greeter.Hello", &api.Pair{Key: "vine"}), rsp, addr)
+	assert.Equal(t, errors.StatusUnauthorized, errors.FromErr(err).Code)
+	err = c.Call(meta.Set(cx, "X-Api-Key", "secret"), c.NewRequest("greeter", "Greeter.Hello", &api.Pair{Key: "vine"}), rsp, addr)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "hello vine", rsp.Key)
+	}
+
+	h := NewHandler(opts...)
+	call := func(key string) string {
+		b, _ := proto.Marshal(&api.Pair{Key: "vine"})
+		body := gbytes.NewBuffer(nil)
+		_ = writeFrame(body, dataFrame, b, false)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/greeter.Greeter/Hello", body)
+		c.Request.Header.Set("Content-Type", "application/grpc-web+proto")
+		if len(key) > 0 {
+			c.Request.Header.Set("X-Api-Key", key)
+		}
+		h.Handle(c)
+
+		for {
+			flag, data, err := readFrame(w.Body)
+			if err != nil {
+				return ""
+			}
+			if flag&trailerFrame != 0 {
+				return string(data)
+			}
+		}
+	}
+	assert.True(t, strings.HasPrefix(call(""), "grpc-status: 16\r\n"))
+	assert.True(t, strings.HasPrefix(call("secret"), "grpc-status: 0\r\n"))
+
+	assert.Equal(t, []string{"greeter:Greeter.Hello:apiKeys", "greeter:Greeter.Hello:apiKeys", "greeter:Greeter.Hello:apiKeys", "greeter:Greeter.Hello:apiKeys"}, calls)
+}
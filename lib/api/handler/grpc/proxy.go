@@ -0,0 +1,191 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package grpc is a gRPC-Web handler and a transparent gRPC reverse proxy
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/vine-io/vine/core/client"
+	"github.com/vine-io/vine/core/client/selector"
+	"github.com/vine-io/vine/core/codec/bytes"
+	"github.com/vine-io/vine/core/registry"
+	"github.com/vine-io/vine/lib/api"
+	"github.com/vine-io/vine/lib/api/handler"
+	verrs "github.com/vine-io/vine/lib/errors"
+	meta "github.com/vine-io/vine/util/context/metadata"
+)
+
+const (
+	Handler = "grpc"
+)
+
+var (
+	// reserved metadata is set by the grpc transport and never forwarded
+	reserved = map[string]bool{
+		"connection":        true,
+		"content-length":    true,
+		"content-type":      true,
+		"host":              true,
+		"keep-alive":        true,
+		"method":            true,
+		"remote":            true,
+		"te":                true,
+		"timeout":           true,
+		"trailer":           true,
+		"transfer-encoding": true,
+		"upgrade":           true,
+		"user-agent":        true,
+		"vine-api-path":     true,
+		"vine-deadline":     true,
+		"x-grpc-web":        true,
+		"x-user-agent":      true,
+	}
+)
+
+// subtype returns the codec name of a grpc content type, e.g. application/grpc+json is json
+func subtype(ct string) string {
+	if idx := strings.IndexRune(ct, ';'); idx >= 0 {
+		ct = ct[:idx]
+	}
+	if idx := strings.IndexRune(ct, '+'); idx >= 0 {
+		return strings.TrimSpace(ct[idx+1:])
+	}
+	return "proto"
+}
+
+// outgoing returns the metadata which is forwarded to the backend
+func outgoing(md map[string]string) meta.Metadata {
+	out := meta.Metadata{}
+	for k, v := range md {
+		k = strings.ToLower(k)
+		if reserved[k] || strings.HasPrefix(k, ":") || strings.HasPrefix(k, "grpc-") {
+			continue
+		}
+		out.Set(k, v)
+	}
+	return out
+}
+
+// header returns the header metadata of the backend response
+func header(stream client.Stream) metadata.MD {
+	return metadata.New(stream.Response().Header())
+}
+
+// trailers returns the trailer metadata of the backend response, it is only
+// set once the stream has ended
+func trailers(stream client.Stream) metadata.MD {
+	if r, ok := stream.Response().(interface{ Trailer() map[string]string }); ok {
+		return metadata.New(r.Trailer())
+	}
+	return metadata.MD{}
+}
+
+// proxy forwards raw grpc calls to the nodes of a service with the client of the
+// handler, so the tls settings, connection pool and wrappers of the client apply
+type proxy struct {
+	opts handler.Options
+}
+
+// route returns the service of the full grpc method e.g. /greeter.Greeter/Hello
+func (p *proxy) route(ctx context.Context, host, method string) (*api.Service, error) {
+	if p.opts.Router == nil {
+		return nil, errors.New("no route found")
+	}
+
+	req := &http.Request{
+		Method: http.MethodPost,
+		Host:   host,
+		URL:    &url.URL{Path: method},
+		Header: make(http.Header),
+	}
+	return p.opts.Router.Route(req.WithContext(ctx))
+}
+
+// endpoint returns the api endpoint of the grpc method e.g. Greeter.Hello of
+// /greeter.Greeter/Hello, the routes of the grpc methods only carry their path
+// so the metadata of the endpoint is read from the nodes of the service
+func endpoint(service *api.Service, method string) *api.Endpoint {
+	name := method
+	if parts := strings.Split(strings.TrimPrefix(method, "/"), "/"); len(parts) == 2 {
+		name = parts[0][strings.LastIndex(parts[0], ".")+1:] + "." + parts[1]
+	}
+
+	for _, s := range service.Services {
+		for _, ep := range s.Endpoints {
+			if ep.Name != name {
+				continue
+			}
+			if e := api.Decode(ep.Metadata); e != nil {
+				e.Name = name
+				return e
+			}
+		}
+	}
+	return &api.Endpoint{Name: name}
+}
+
+// authorize checks the call of the grpc method, nil when it is allowed. The
+// message of the status is the error, as returned by the grpc server.
+func (p *proxy) authorize(ctx context.Context, service *api.Service, method string, md map[string]string) *status.Status {
+	if p.opts.Authorize == nil {
+		return nil
+	}
+	err := p.opts.Authorize(ctx, service.Name, endpoint(service, method), md)
+	if err == nil {
+		return nil
+	}
+	ce := verrs.FromErr(err)
+	if ce.Code == 0 {
+		ce = verrs.InternalServerError("go.vine.api", err.Error())
+	}
+	return status.New(ce.ToGRPC().Code(), ce.Error())
+}
+
+// stream opens a stream for the grpc method to a node of the service, the
+// messages are sent and received as raw frames
+func (p *proxy) stream(ctx context.Context, service *api.Service, method, ct string, md map[string]string) (client.Stream, error) {
+	// the nodes are those of the routed service
+	so := selector.WithStrategy(func(_ []*registry.Service) selector.Next {
+		if p.opts.Strategy != nil {
+			return p.opts.Strategy(service.Services)
+		}
+		return selector.Random(service.Services)
+	})
+
+	req := p.opts.Client.NewRequest(service.Name, method, &bytes.Frame{}, client.WithContentType(ct))
+	return p.opts.Client.Stream(meta.NewContext(ctx, outgoing(md)), req, client.WithSelectOption(so))
+}
+
+func newProxy(opts handler.Options) *proxy {
+	return &proxy{
+		opts: opts,
+	}
+}
@@ -0,0 +1,156 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpc
+
+import (
+	"context"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/vine-io/vine/core/codec"
+	"github.com/vine-io/vine/core/codec/bytes"
+	"github.com/vine-io/vine/core/server"
+	"github.com/vine-io/vine/lib/api"
+	"github.com/vine-io/vine/lib/api/handler"
+)
+
+// proxyRouter is a server.Router which forwards every grpc call to the backend
+type proxyRouter struct {
+	*proxy
+
+	// set with different initializer
+	s *api.Service
+}
+
+// ProcessMessage is a noop, only calls are proxied
+func (r *proxyRouter) ProcessMessage(ctx context.Context, msg server.Message) error {
+	return nil
+}
+
+func (r *proxyRouter) ServeRequest(ctx context.Context, req server.Request, rsp server.Response) error {
+	method, ok := grpc.Method(ctx)
+	if !ok {
+		return status.Error(codes.Internal, "method does not exist in context")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	var authority string
+	if v := md.Get(":authority"); len(v) > 0 {
+		authority = v[0]
+	}
+
+	service := r.s
+	if service == nil {
+		var err error
+		service, err = r.route(ctx, authority, method)
+		if err != nil {
+			return status.Errorf(codes.Unimplemented, "unknown service %s: %v", method, err)
+		}
+	}
+
+	hdr := make(map[string]string, len(md))
+	for k, v := range md {
+		if len(v) > 0 {
+			hdr[k] = v[0]
+		}
+	}
+
+	if st := r.authorize(ctx, service, method, hdr); st != nil {
+		return st.Err()
+	}
+
+	// the deadline of ctx is passed on by the grpc transport
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := r.stream(ctx, service, method, req.ContentType(), hdr)
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "%s: %v", method, err)
+	}
+	defer stream.Close()
+
+	// client to backend
+	go func() {
+		for {
+			f := &bytes.Frame{}
+			if err := req.Codec().ReadBody(f); err != nil {
+				if err == io.EOF {
+					_ = stream.CloseSend()
+				} else {
+					cancel()
+				}
+				return
+			}
+			if err := stream.Send(f); err != nil {
+				return
+			}
+		}
+	}()
+
+	// backend to client
+	if h := header(stream); len(h) > 0 {
+		_ = grpc.SendHeader(ctx, h)
+	}
+
+	for {
+		f := &bytes.Frame{}
+		err := stream.Recv(f)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			_ = grpc.SetTrailer(ctx, trailers(stream))
+			return err
+		}
+		if err = rsp.Codec().Write(&codec.Message{Body: f.Data}, nil); err != nil {
+			return err
+		}
+	}
+
+	_ = grpc.SetTrailer(ctx, trailers(stream))
+	return nil
+}
+
+// NewProxy returns a server.Router which transparently proxies grpc calls to the
+// services resolved by the router, e.g. server.WithRouter(grpc.NewProxy(...))
+func NewProxy(opts ...handler.Option) server.Router {
+	options := handler.NewOptions(opts...)
+
+	return &proxyRouter{
+		proxy: newProxy(options),
+	}
+}
+
+// NewServiceProxy returns a server.Router which proxies all grpc calls to the service
+func NewServiceProxy(s *api.Service, opts ...handler.Option) server.Router {
+	options := handler.NewOptions(opts...)
+
+	return &proxyRouter{
+		proxy: newProxy(options),
+		s:     s,
+	}
+}
@@ -0,0 +1,333 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package grpc
+
+import (
+	gbytes "bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	gproto "google.golang.org/protobuf/proto"
+
+	"github.com/vine-io/vine/core/codec/bytes"
+	"github.com/vine-io/vine/lib/api"
+	"github.com/vine-io/vine/lib/api/handler"
+	"github.com/vine-io/vine/lib/logger"
+	ctx "github.com/vine-io/vine/util/context"
+	meta "github.com/vine-io/vine/util/context/metadata"
+)
+
+const (
+	// dataFrame and trailerFrame are the flags of gRPC-Web frames
+	dataFrame    byte = 0x00
+	trailerFrame byte = 0x80
+)
+
+// IsGRPCWeb returns true if the request is a gRPC-Web request
+func IsGRPCWeb(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc-web")
+}
+
+// isText returns true for the base64 encoded gRPC-Web framing
+func isText(ct string) bool {
+	return strings.HasPrefix(ct, "application/grpc-web-text")
+}
+
+type webHandler struct {
+	*proxy
+
+	// set with different initializer
+	s *api.Service
+}
+
+func (h *webHandler) Handle(c *gin.Context) {
+	r := c.Request
+	ct := r.Header.Get("Content-Type")
+	text := isText(ct)
+
+	bsize := handler.DefaultMaxRecvSize
+	if h.opts.MaxRecvSize > 0 {
+		bsize = h.opts.MaxRecvSize
+	}
+	var body io.Reader = http.MaxBytesReader(c.Writer, r.Body, bsize)
+	if text {
+		body = &textReader{r: body}
+	}
+
+	// create context
+	cx := ctx.FromRequest(r)
+	for k, v := range h.opts.Metadata {
+		cx = meta.Set(cx, k, v)
+	}
+
+	// honour the grpc-timeout of the caller
+	cx, cancel := ctx.WithMetadataDeadline(cx)
+	defer cancel()
+
+	md, _ := meta.FromContext(cx)
+
+	service := h.s
+	if service == nil {
+		var err error
+		service, err = h.route(cx, r.Host, r.URL.Path)
+		if err != nil {
+			h.writeStatus(c, text, status.Newf(codes.Unimplemented, "unknown service %s: %v", r.URL.Path, err), nil)
+			return
+		}
+	}
+
+	if st := h.authorize(cx, service, r.URL.Path, md); st != nil {
+		h.writeStatus(c, text, st, nil)
+		return
+	}
+
+	stream, err := h.stream(cx, service, r.URL.Path, grpcContentType(ct), md)
+	if err != nil {
+		h.writeStatus(c, text, status.Newf(codes.Unavailable, "%s: %v", r.URL.Path, err), nil)
+		return
+	}
+	defer stream.Close()
+
+	// browsers send the whole request, unary and server streaming calls only
+	for {
+		flag, data, err := readFrame(body)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cancel()
+			h.writeStatus(c, text, status.Newf(codes.InvalidArgument, "read request: %v", err), nil)
+			return
+		}
+		if flag&trailerFrame != 0 {
+			continue
+		}
+		if err = stream.Send(&bytes.Frame{Data: data}); err != nil {
+			break
+		}
+	}
+	_ = stream.CloseSend()
+
+	w := c.Writer
+	for k, vv := range header(stream) {
+		for _, v := range vv {
+			w.Header().Add(k, v)
+		}
+	}
+	w.Header().Set("Content-Type", webContentType(ct))
+	w.Header().Set("Access-Control-Expose-Headers", "grpc-status, grpc-message, grpc-status-details-bin")
+	w.WriteHeader(http.StatusOK)
+
+	st := status.New(codes.OK, "")
+	for {
+		f := &bytes.Frame{}
+		err := stream.Recv(f)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			st = status.Convert(err)
+			break
+		}
+		if err = writeFrame(w, dataFrame, f.Data, text); err != nil {
+			return
+		}
+		w.Flush()
+	}
+
+	if err := writeFrame(w, trailerFrame, trailer(st, trailers(stream)), text); err != nil {
+		logger.Errorf("[grpc-web] %s: write trailer: %v", r.URL.Path, err)
+	}
+	w.Flush()
+}
+
+// writeStatus responds to a call which failed before reaching the backend
+func (h *webHandler) writeStatus(c *gin.Context, text bool, st *status.Status, md metadata.MD) {
+	logger.Errorf("[grpc-web] %s: %s", c.Request.URL.Path, st.Message())
+
+	w := c.Writer
+	w.Header().Set("Content-Type", webContentType(c.GetHeader("Content-Type")))
+	w.Header().Set("Access-Control-Expose-Headers", "grpc-status, grpc-message, grpc-status-details-bin")
+	w.Header().Set("grpc-status", fmt.Sprintf("%d", st.Code()))
+	w.Header().Set("grpc-message", encodeMessage(st.Message()))
+	w.WriteHeader(http.StatusOK)
+	_ = writeFrame(w, trailerFrame, trailer(st, md), text)
+	w.Flush()
+}
+
+func (h *webHandler) String() string {
+	return "grpc-web"
+}
+
+// grpcContentType maps the gRPC-Web content type to the content type of the backend call
+func grpcContentType(ct string) string {
+	return "application/grpc+" + subtype(ct)
+}
+
+// webContentType returns the gRPC-Web content type of the response
+func webContentType(ct string) string {
+	if isText(ct) {
+		return "application/grpc-web-text+" + subtype(ct)
+	}
+	return "application/grpc-web+" + subtype(ct)
+}
+
+// readFrame reads a length prefixed gRPC-Web frame
+func readFrame(r io.Reader) (byte, []byte, error) {
+	prefix := make([]byte, 5)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, fmt.Errorf("truncated frame header")
+		}
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(prefix[1:])
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, fmt.Errorf("truncated frame: %v", err)
+	}
+	return prefix[0], data, nil
+}
+
+// textReader decodes the base64 body of grpc-web-text requests. Clients may
+// encode every chunk on its own, so padding can end any 4 byte quantum and
+// the body is decoded up to each padded quantum separately.
+type textReader struct {
+	r   io.Reader
+	buf [4096]byte
+	in  []byte
+	out []byte
+}
+
+func (t *textReader) Read(p []byte) (int, error) {
+	for len(t.out) == 0 {
+		n, err := t.r.Read(t.buf[:])
+		t.in = append(t.in, t.buf[:n]...)
+
+		quanta := t.in[:len(t.in)/4*4]
+		for len(quanta) > 0 {
+			end := len(quanta)
+			if i := gbytes.IndexByte(quanta, '='); i >= 0 {
+				end = (i/4 + 1) * 4
+			}
+			dst := make([]byte, base64.StdEncoding.DecodedLen(end))
+			m, derr := base64.StdEncoding.Decode(dst, quanta[:end])
+			if derr != nil {
+				return 0, derr
+			}
+			t.out = append(t.out, dst[:m]...)
+			quanta = quanta[end:]
+		}
+		t.in = append(t.in[:0], t.in[len(t.in)/4*4:]...)
+
+		if err == io.EOF && len(t.in) > 0 {
+			return 0, base64.CorruptInputError(len(t.in))
+		}
+		if err != nil && len(t.out) == 0 {
+			return 0, err
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	n := copy(p, t.out)
+	t.out = t.out[n:]
+	return n, nil
+}
+
+// writeFrame writes a length prefixed gRPC-Web frame, base64 encoded for text
+func writeFrame(w io.Writer, flag byte, data []byte, text bool) error {
+	buf := make([]byte, 5+len(data))
+	buf[0] = flag
+	binary.BigEndian.PutUint32(buf[1:5], uint32(len(data)))
+	copy(buf[5:], data)
+	if text {
+		buf = []byte(base64.StdEncoding.EncodeToString(buf))
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// trailer encodes the status and trailer metadata as the block of a trailer frame
+func trailer(st *status.Status, md metadata.MD) []byte {
+	buf := gbytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "grpc-status: %d\r\n", st.Code())
+	fmt.Fprintf(buf, "grpc-message: %s\r\n", encodeMessage(st.Message()))
+	if p := st.Proto(); p != nil && len(p.Details) > 0 {
+		if b, err := gproto.Marshal(p); err == nil {
+			fmt.Fprintf(buf, "grpc-status-details-bin: %s\r\n", base64.RawStdEncoding.EncodeToString(b))
+		}
+	}
+	for k, vv := range md {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, "grpc-status") || k == "grpc-message" {
+			continue
+		}
+		for _, v := range vv {
+			fmt.Fprintf(buf, "%s: %s\r\n", k, v)
+		}
+	}
+	return buf.Bytes()
+}
+
+// encodeMessage percent encodes the grpc-message as defined by the grpc protocol
+func encodeMessage(msg string) string {
+	var sb strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			sb.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&sb, "%%%02X", c)
+	}
+	return sb.String()
+}
+
+// NewHandler returns a gRPC-Web handler
+func NewHandler(opts ...handler.Option) handler.Handler {
+	options := handler.NewOptions(opts...)
+
+	return &webHandler{
+		proxy: newProxy(options),
+	}
+}
+
+// WithService creates a gRPC-Web handler with a service
+func WithService(s *api.Service, opts ...handler.Option) handler.Handler {
+	options := handler.NewOptions(opts...)
+
+	return &webHandler{
+		proxy: newProxy(options),
+		s:     s,
+	}
+}
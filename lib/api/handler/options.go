@@ -23,9 +23,12 @@
 package handler
 
 import (
+	"context"
+
 	"github.com/vine-io/vine/core/client"
 	"github.com/vine-io/vine/core/client/grpc"
 	"github.com/vine-io/vine/core/client/selector"
+	"github.com/vine-io/vine/lib/api"
 	"github.com/vine-io/vine/lib/api/router"
 )
 
@@ -33,6 +36,11 @@ var (
 	DefaultMaxRecvSize int64 = 1024 * 1024 * 100 // 100Mb
 )
 
+// Authorizer checks the caller may call the endpoint of the service, md is
+// the metadata of the request. The handlers which call the services without
+// going through the http routing e.g. graphql and grpc call it before each call.
+type Authorizer func(ctx context.Context, service string, ep *api.Endpoint, md map[string]string) error
+
 type Options struct {
 	MaxRecvSize int64
 	Namespace   string
@@ -40,6 +48,11 @@ type Options struct {
 	Client      client.Client
 	Strategy    selector.Strategy
 	Metadata    map[string]string
+	// Validation checks requests against the schema documented by
+	// the service before they are sent
+	Validation bool
+	// Authorize checks the calls, nil allows all of them
+	Authorize Authorizer
 }
 
 type Option func(o *Options)
@@ -112,3 +125,20 @@ func WithSelectStrategy(strategy selector.Strategy) Option {
 		o.Strategy = strategy
 	}
 }
+
+// WithValidation rejects the requests which break the schema documented by the
+// service before they reach it. The openapi schema only approximates the tags of
+// Validate(), e.g. gte=0 and all but one of pattern, prefix, suffix and contains
+// are not documented, so the service must still validate its requests.
+func WithValidation() Option {
+	return func(o *Options) {
+		o.Validation = true
+	}
+}
+
+// WithAuthorizer checks the calls of the handler with a
+func WithAuthorizer(a Authorizer) Option {
+	return func(o *Options) {
+		o.Authorize = a
+	}
+}
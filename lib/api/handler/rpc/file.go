@@ -0,0 +1,430 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vine-io/vine/core/client"
+	"github.com/vine-io/vine/core/client/selector"
+	"github.com/vine-io/vine/lib/api"
+	"github.com/vine-io/vine/lib/errors"
+	"github.com/vine-io/vine/lib/logger"
+	"github.com/vine-io/vine/util/context/metadata"
+)
+
+var (
+	// DefaultChunkSize is the size of the FileHeader chunks streamed to the backend
+	DefaultChunkSize = 64 * 1024
+
+	// DefaultMaxFieldSize limits the size of a form field of a multipart upload
+	DefaultMaxFieldSize int64 = 1024 * 1024
+)
+
+func isMultipart(c *gin.Context) bool {
+	return strings.Contains(c.ContentType(), "multipart/form-data")
+}
+
+// isUpload returns true for a binary body sent to a client streaming endpoint
+func isUpload(c *gin.Context, svc *api.Service) bool {
+	return svc.Endpoint.Stream == string(api.Client) && c.ContentType() == "application/octet-stream"
+}
+
+// uploadHandler streams the files of a multipart form or a binary body to a client
+// streaming rpc as api.FileHeader chunks without buffering them. The query parameters
+// and the form fields are merged into every message, a form without files is sent as
+// a single message of its fields. As the files aren't buffered the fields are merged
+// into the messages of the files which follow them, the fields following the last
+// file are sent in a message of their own. The query parameters and the fields
+// preceding the first file are also passed on as X-Api-Field-* metadata.
+func uploadHandler(ctx *gin.Context, service *api.Service, c client.Client, so selector.SelectOption) {
+	if service.Endpoint.Stream != string(api.Client) {
+		writeError(ctx, errors.BadRequest("go.vine.api", "server endpoint must be gRPC client stream"))
+		return
+	}
+
+	cx := ctx.Request.Context()
+	fields := make(map[string]interface{})
+	// pending is set when fields were read after the last message
+	pending := false
+	addField := func(key, value string) {
+		if v, ok := fields[key]; ok {
+			value = v.(string) + "," + value
+		}
+		fields[key] = value
+		pending = true
+	}
+	for key, values := range ctx.Request.URL.Query() {
+		addField(key, strings.Join(values, ","))
+		cx = metadata.Set(cx, "X-Api-Field-"+key, strings.Join(values, ","))
+	}
+
+	// the request and response are json encoded
+	ct := "application/json"
+
+	var stream client.Stream
+	open := func() error {
+		if stream != nil {
+			return nil
+		}
+		req := c.NewRequest(
+			service.Name,
+			service.Endpoint.Name,
+			&api.FileHeader{},
+			client.WithContentType(ct),
+			client.StreamingRequest(),
+		)
+
+		var err error
+		stream, err = c.Stream(cx, req, client.WithSelectOption(so))
+		return err
+	}
+	send := func(name string, size int64, r io.Reader) error {
+		if err := open(); err != nil {
+			return err
+		}
+		pending = false
+		return sendChunks(stream, fields, name, size, r)
+	}
+
+	var err error
+	if mr, e := ctx.Request.MultipartReader(); e == nil {
+		for {
+			part, e := mr.NextPart()
+			if e == io.EOF {
+				break
+			}
+			if e != nil {
+				err = errors.BadRequest("go.vine.api", "read multipart: %v", e)
+				break
+			}
+
+			// a form field
+			if part.FileName() == "" {
+				b, e := io.ReadAll(io.LimitReader(part, DefaultMaxFieldSize))
+				_ = part.Close()
+				if e != nil {
+					err = errors.BadRequest("go.vine.api", "read field %s: %v", part.FormName(), e)
+					break
+				}
+				addField(part.FormName(), string(b))
+				if stream == nil {
+					cx = metadata.Set(cx, "X-Api-Field-"+part.FormName(), string(b))
+				}
+				continue
+			}
+
+			size, _ := strconv.ParseInt(part.Header.Get("Content-Length"), 10, 64)
+			err = send(part.FileName(), size, part)
+			_ = part.Close()
+			if err != nil {
+				break
+			}
+		}
+
+		// the fields of a form without files, or following the last file
+		if err == nil && (stream == nil || pending) {
+			if err = open(); err == nil {
+				if err = stream.Send(fields); err == io.EOF {
+					err = nil
+				}
+			}
+		}
+	} else {
+		name := ctx.Query("name")
+		if _, params, e := mime.ParseMediaType(ctx.GetHeader("Content-Disposition")); e == nil && params["filename"] != "" {
+			name = params["filename"]
+		}
+		err = send(name, ctx.Request.ContentLength, ctx.Request.Body)
+	}
+
+	ctx.Request.Header.Set("Content-Type", ct)
+	if stream != nil {
+		defer stream.Close()
+	}
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	if err = stream.CloseSend(); err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	result, err := stream.Response().Read()
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	writeResponse(ctx, result)
+}
+
+// sendChunks streams the content of r as api.FileHeader chunks merged with the fields
+func sendChunks(stream client.Stream, fields map[string]interface{}, name string, size int64, r io.Reader) error {
+	buf := make([]byte, DefaultChunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			data := make(map[string]interface{}, len(fields)+4)
+			for k, v := range fields {
+				data[k] = v
+			}
+			data["name"] = name
+			data["size"] = size
+			data["length"] = n
+			data["chunk"] = buf[:n]
+			if e := stream.Send(data); e != nil {
+				// the backend closed the stream, the error is returned with the response
+				if e == io.EOF {
+					return nil
+				}
+				return e
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return errors.BadRequest("go.vine.api", "read %s: %v", name, err)
+		}
+	}
+}
+
+func isDownLoadLink(s *api.Service) bool {
+	return s.Endpoint.Stream == string(api.Server) && strings.HasSuffix(strings.ToLower(s.Endpoint.Name), "download")
+}
+
+// byteRange is the first range of a Range header, end is -1 when open ended
+type byteRange struct {
+	start, end int64
+}
+
+// parseRange parses a single range of the form bytes=start-end, bytes=start- or bytes=-suffix
+func parseRange(s string) (*byteRange, bool) {
+	if !strings.HasPrefix(s, "bytes=") {
+		return nil, false
+	}
+	spec := strings.TrimSpace(strings.Split(strings.TrimPrefix(s, "bytes="), ",")[0])
+	idx := strings.IndexRune(spec, '-')
+	if idx < 0 {
+		return nil, false
+	}
+
+	start, end := strings.TrimSpace(spec[:idx]), strings.TrimSpace(spec[idx+1:])
+	r := &byteRange{end: -1}
+	var err error
+	switch {
+	case start == "" && end == "":
+		return nil, false
+	case start == "":
+		// suffix range, resolved once the size is known
+		if r.end, err = strconv.ParseInt(end, 10, 64); err != nil || r.end <= 0 {
+			return nil, false
+		}
+		r.start = -1
+	default:
+		if r.start, err = strconv.ParseInt(start, 10, 64); err != nil || r.start < 0 {
+			return nil, false
+		}
+		if end != "" {
+			if r.end, err = strconv.ParseInt(end, 10, 64); err != nil || r.end < r.start {
+				return nil, false
+			}
+		}
+	}
+	return r, true
+}
+
+// downLoadHandler streams the api.FileHeader chunks of a server streaming rpc back as a
+// file download. The size of the first chunk sets the Content-Length, a Range request
+// is passed on to the backend as the offset of the api.FileDesc and can't be satisfied
+// when the first chunk has no size.
+func downLoadHandler(ctx *gin.Context, service *api.Service, c client.Client, so selector.SelectOption) {
+	request := &api.FileDesc{}
+
+	_ = ctx.ShouldBindJSON(request)
+	if request.Name == "" && request.Offset == 0 {
+		request.Name = ctx.Query("name")
+		offset := ctx.Query("offset")
+		request.Offset, _ = strconv.ParseInt(offset, 10, 64)
+	}
+
+	if request.Name == "" && request.Offset == 0 {
+		writeError(ctx, errors.BadRequest("go.vine.api", "read request values: request is empty"))
+		return
+	}
+
+	rng, partial := parseRange(ctx.GetHeader("Range"))
+	if partial && rng.start >= 0 {
+		request.Offset = rng.start
+	}
+
+	ct := "application/proto"
+	req := c.NewRequest(
+		service.Name,
+		service.Endpoint.Name,
+		request,
+		client.WithContentType(ct),
+		client.StreamingRequest(),
+	)
+
+	// the stream is cancelled once the requested range is sent
+	cx, cancel := context.WithCancel(ctx.Request.Context())
+	defer cancel()
+
+	stream, err := c.Stream(cx, req, client.WithSelectOption(so))
+	if err != nil {
+		logger.Error(err)
+		writeError(ctx, fmt.Errorf("create stream: %v", err))
+		return
+	}
+	defer stream.Close()
+
+	if err = stream.Send(request); err != nil {
+		logger.Error(err)
+		writeError(ctx, err)
+		return
+	}
+
+	// the size of the file is told by the first chunk
+	reader := &chunkReader{rsp: stream.Response()}
+	first, err := reader.next()
+	if err != nil && err != io.EOF {
+		writeError(ctx, err)
+		return
+	}
+
+	size := int64(-1)
+	if first != nil && first.Size > 0 {
+		size = first.Size
+	}
+
+	// a range of a file of unknown size, or starting past the end can't be satisfied
+	if partial && (size < 0 || rng.start >= size) {
+		if size >= 0 {
+			ctx.Header("Content-Range", fmt.Sprintf("bytes */%d", size))
+		}
+		writeError(ctx, errors.New("go.vine.api", "range not satisfiable", http.StatusRequestedRangeNotSatisfiable))
+		return
+	}
+
+	if partial && rng.start < 0 {
+		// the backend streams from the start, skip what isn't requested
+		rng.start = size - rng.end
+		if rng.start < 0 {
+			rng.start = 0
+		}
+		rng.end = -1
+		reader.skip = rng.start
+	}
+
+	code := http.StatusOK
+	length := int64(-1)
+	if size >= 0 {
+		length = size - request.Offset
+	}
+	if partial {
+		code = http.StatusPartialContent
+		if rng.end < 0 || (size >= 0 && rng.end >= size) {
+			rng.end = size - 1
+		}
+		length = rng.end - rng.start + 1
+		ctx.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rng.start, rng.end, size))
+	}
+
+	ctx.Header("Accept-Ranges", "bytes")
+	ctx.Header("Content-Type", "application/octet-stream")
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, request.Name))
+	if length >= 0 {
+		ctx.Header("Content-Length", strconv.FormatInt(length, 10))
+	}
+	ctx.Writer.WriteHeader(code)
+
+	var r io.Reader = reader
+	if length >= 0 {
+		r = io.LimitReader(reader, length)
+	}
+	if _, err = io.Copy(ctx.Writer, r); err != nil {
+		// the headers are gone, all we can do is to drop the connection
+		logger.Errorf("download %s: %v", request.Name, err)
+		return
+	}
+	logger.Infof("code=%d [%s] %s", code, ctx.Request.Method, ctx.Request.URL.Path)
+}
+
+// chunkReader reads the chunks of a stream of api.FileHeader
+type chunkReader struct {
+	rsp  client.Response
+	buf  []byte
+	skip int64
+}
+
+// next reads the next api.FileHeader and buffers its chunk
+func (cr *chunkReader) next() (*api.FileHeader, error) {
+	b, err := cr.rsp.Read()
+	if err != nil {
+		return nil, err
+	}
+	frame := &api.FileHeader{}
+	if err = frame.Unmarshal(b); err != nil {
+		return nil, err
+	}
+	if frame.Length > 0 && frame.Length <= int64(len(frame.Chunk)) {
+		frame.Chunk = frame.Chunk[:frame.Length]
+	}
+	cr.buf = frame.Chunk
+	return frame, nil
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if cr.skip > 0 && len(cr.buf) > 0 {
+			n := int64(len(cr.buf))
+			if n > cr.skip {
+				n = cr.skip
+			}
+			cr.buf = cr.buf[n:]
+			cr.skip -= n
+			continue
+		}
+		if len(cr.buf) > 0 {
+			break
+		}
+		if _, err := cr.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, cr.buf)
+	cr.buf = cr.buf[n:]
+	return n, nil
+}
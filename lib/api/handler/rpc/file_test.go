@@ -0,0 +1,251 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package rpc
+
+import (
+	gbytes "bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	membroker "github.com/vine-io/vine/core/broker/memory"
+	cmemory "github.com/vine-io/vine/core/client/memory"
+	regMemory "github.com/vine-io/vine/core/registry/memory"
+	"github.com/vine-io/vine/core/server"
+	smemory "github.com/vine-io/vine/core/server/memory"
+	"github.com/vine-io/vine/lib/api"
+	"github.com/vine-io/vine/lib/api/handler"
+	"github.com/vine-io/vine/util/context/metadata"
+)
+
+var content = strings.Repeat("0123456789", 1000)
+
+type Files struct{}
+
+func (f *Files) Upload(ctx context.Context, stream server.Stream) error {
+	var names []string
+	size := 0
+	for {
+		header := &api.FileHeader{}
+		if err := stream.Recv(header); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		if len(names) == 0 || names[len(names)-1] != header.Name {
+			names = append(names, header.Name)
+		}
+		size += len(header.Chunk[:header.Length])
+	}
+	field, _ := metadata.Get(ctx, "X-Api-Field-Dir")
+	return stream.Send(&api.Pair{Key: field, Values: append(names, fmt.Sprintf("%d", size))})
+}
+
+// Form returns the fields, the file name and the length of each message
+func (f *Files) Form(ctx context.Context, stream server.Stream) error {
+	var messages []string
+	for {
+		m := map[string]interface{}{}
+		if err := stream.Recv(&m); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		messages = append(messages, fmt.Sprintf("%v %v %v %v", m["dir"], m["tag"], m["name"], m["length"]))
+	}
+	return stream.Send(&api.Pair{Values: messages})
+}
+
+// UnsizedDownload streams a file without telling its size
+func (f *Files) UnsizedDownload(ctx context.Context, stream server.Stream) error {
+	desc := &api.FileDesc{}
+	if err := stream.Recv(desc); err != nil {
+		return err
+	}
+	data := []byte(content)[desc.Offset:]
+	return stream.Send(&api.FileHeader{Name: desc.Name, Length: int64(len(data)), Chunk: data})
+}
+
+func (f *Files) Download(ctx context.Context, stream server.Stream) error {
+	desc := &api.FileDesc{}
+	if err := stream.Recv(desc); err != nil {
+		return err
+	}
+	if desc.Offset >= int64(len(content)) {
+		return stream.Send(&api.FileHeader{Name: desc.Name, Size: int64(len(content))})
+	}
+	data := []byte(content)[desc.Offset:]
+	for len(data) > 0 {
+		n := 3000
+		if n > len(data) {
+			n = len(data)
+		}
+		header := &api.FileHeader{Name: desc.Name, Size: int64(len(content)), Length: int64(n), Chunk: data[:n]}
+		if err := stream.Send(header); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func TestFiles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := regMemory.NewRegistry()
+	s := smemory.NewServer(server.Name("files"), server.Registry(r), server.Broker(membroker.NewBroker()))
+	if err := s.Handle(s.NewHandler(&Files{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	services, err := r.GetService(context.TODO(), "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := handler.WithClient(cmemory.NewClient())
+
+	serve := func(endpoint string, stream api.StreamType, req *http.Request) *httptest.ResponseRecorder {
+		h := WithService(&api.Service{
+			Name:     "files",
+			Endpoint: &api.Endpoint{Name: endpoint, Stream: string(stream)},
+			Services: services,
+		}, opt)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+		h.Handle(c)
+		return w
+	}
+
+	t.Run("multipart upload", func(t *testing.T) {
+		body := gbytes.NewBuffer(nil)
+		mw := multipart.NewWriter(body)
+		_ = mw.WriteField("dir", "/tmp")
+		fw, _ := mw.CreateFormFile("file", "a.txt")
+		_, _ = fw.Write([]byte(content))
+		fw, _ = mw.CreateFormFile("file", "b.txt")
+		_, _ = fw.Write([]byte(content[:10]))
+		_ = mw.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/files/upload", body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := serve("Files.Upload", api.Client, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"key":"/tmp","values":["a.txt","b.txt","10010"]}`, w.Body.String())
+	})
+
+	t.Run("multipart fields", func(t *testing.T) {
+		body := gbytes.NewBuffer(nil)
+		mw := multipart.NewWriter(body)
+		_ = mw.WriteField("dir", "/tmp")
+		fw, _ := mw.CreateFormFile("file", "a.txt")
+		_, _ = fw.Write([]byte(content[:10]))
+		_ = mw.WriteField("tag", "x")
+		fw, _ = mw.CreateFormFile("file", "b.txt")
+		_, _ = fw.Write([]byte(content[:10]))
+		_ = mw.WriteField("tag", "y")
+		_ = mw.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/files/form?dir=/var", body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := serve("Files.Form", api.Client, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		// the fields following the last file are sent on their own
+		assert.JSONEq(t, `{"values":["/var,/tmp <nil> a.txt 10","/var,/tmp x b.txt 10","/var,/tmp x,y <nil> <nil>"]}`, w.Body.String())
+
+		// a form without files is a single message
+		body = gbytes.NewBuffer(nil)
+		mw = multipart.NewWriter(body)
+		_ = mw.WriteField("tag", "x")
+		_ = mw.Close()
+		req = httptest.NewRequest(http.MethodPost, "/files/form", body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w = serve("Files.Form", api.Client, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"values":["<nil> x <nil> <nil>"]}`, w.Body.String())
+	})
+
+	t.Run("binary upload", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/files/upload?name=c.bin", strings.NewReader(content))
+		req.Header.Set("Content-Type", "application/octet-stream")
+		w := serve("Files.Upload", api.Client, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"values":["c.bin","10000"]}`, w.Body.String())
+	})
+
+	t.Run("download", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/files/download?name=a.txt", nil)
+		w := serve("Files.Download", api.Server, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "10000", w.Header().Get("Content-Length"))
+		assert.Equal(t, content, w.Body.String())
+	})
+
+	t.Run("download range", func(t *testing.T) {
+		for rng, expect := range map[string]string{
+			"bytes=100-199":   content[100:200],
+			"bytes=9990-":     content[9990:],
+			"bytes=-5":        content[9995:],
+			"bytes=2990-3009": content[2990:3010],
+		} {
+			req := httptest.NewRequest(http.MethodGet, "/files/download?name=a.txt", nil)
+			req.Header.Set("Range", rng)
+			w := serve("Files.Download", api.Server, req)
+			assert.Equal(t, http.StatusPartialContent, w.Code, rng)
+			assert.Equal(t, fmt.Sprintf("%d", len(expect)), w.Header().Get("Content-Length"), rng)
+			assert.Equal(t, expect, w.Body.String(), rng)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/files/download?name=a.txt", nil)
+		req.Header.Set("Range", "bytes=20000-")
+		w := serve("Files.Download", api.Server, req)
+		assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+
+		// the range of a file of unknown size
+		req = httptest.NewRequest(http.MethodGet, "/files/download?name=a.txt", nil)
+		req.Header.Set("Range", "bytes=100-")
+		w = serve("Files.UnsizedDownload", api.Server, req)
+		assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Range"))
+
+		req = httptest.NewRequest(http.MethodGet, "/files/download?name=a.txt", nil)
+		w = serve("Files.UnsizedDownload", api.Server, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Length"))
+		assert.Equal(t, content, w.Body.String())
+	})
+}
@@ -0,0 +1,76 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/vine-io/vine/core/client"
+	"github.com/vine-io/vine/core/client/selector"
+	"github.com/vine-io/vine/lib/api/router/traffic"
+	"github.com/vine-io/vine/lib/logger"
+	"github.com/vine-io/vine/util/context/metadata"
+)
+
+var (
+	// DefaultMirrorTimeout bounds the calls shadowed to a mirrored version
+	DefaultMirrorTimeout = time.Second * 30
+	// DefaultMirrorConcurrency bounds the calls a handler shadows at once, the
+	// requests are not mirrored while it is reached
+	DefaultMirrorConcurrency = 64
+)
+
+// mirror shadows the request to the version the router picked for mirroring,
+// the response is discarded and the caller never waits for it.
+func (h *rpcHandler) mirror(ctx context.Context, cc client.Client, req client.Request, rsp interface{}) {
+	svc, ok := traffic.MirrorFromContext(ctx)
+	if !ok || len(svc.Services) == 0 {
+		return
+	}
+
+	select {
+	case h.mirrors <- struct{}{}:
+	default:
+		logger.Debugf("mirror %s to %s: too many mirrored calls", req.Endpoint(), svc.Name)
+		return
+	}
+
+	// the request context ends with the request, keep the metadata only
+	mctx := context.Background()
+	if md, ok := metadata.FromContext(ctx); ok {
+		mctx = metadata.NewContext(mctx, metadata.Copy(md))
+	}
+
+	so := selector.WithStrategy(strategy(svc.Services))
+	go func() {
+		defer func() { <-h.mirrors }()
+
+		mctx, cancel := context.WithTimeout(mctx, DefaultMirrorTimeout)
+		defer cancel()
+
+		if err := cc.Call(mctx, req, rsp, client.WithSelectOption(so)); err != nil {
+			logger.Debugf("mirror %s to %s: %v", req.Endpoint(), svc.Name, err)
+		}
+	}()
+}
@@ -42,6 +42,7 @@ import (
 	"github.com/vine-io/vine/core/registry"
 	"github.com/vine-io/vine/lib/api"
 	"github.com/vine-io/vine/lib/api/handler"
+	"github.com/vine-io/vine/lib/api/router/traffic"
 	"github.com/vine-io/vine/lib/errors"
 	"github.com/vine-io/vine/lib/logger"
 	ctx "github.com/vine-io/vine/util/context"
@@ -101,6 +102,9 @@ func (m *RawMessage) UnmarshalJSON(data []byte) error {
 type rpcHandler struct {
 	opts handler.Options
 	s    *api.Service
+
+	// mirrors bounds the mirrored calls in flight
+	mirrors chan struct{}
 }
 
 type buffer struct {
@@ -139,8 +143,17 @@ func (h *rpcHandler) Handle(c *gin.Context) {
 		cx = metadata.Set(cx, k, v)
 	}
 
+	// honour the deadline sent by the caller, the context is
+	// also cancelled when the client disconnects
+	cx, cancel := ctx.WithMetadataDeadline(cx)
+	defer cancel()
+
+	// the router records the version the request is mirrored to
+	cx = traffic.NewMirrorContext(cx)
+
 	// set merged context to request
 	r := c.Request.Clone(cx)
+	c.Request = r
 	var service *api.Service
 	if h.s != nil {
 		// we were given the service
@@ -178,8 +191,8 @@ func (h *rpcHandler) Handle(c *gin.Context) {
 	}
 	so := selector.WithStrategy(gy)
 
-	if isMultipart(c) {
-		multipartHandler(c, service, cc, so)
+	if isMultipart(c) || isUpload(c, service) {
+		uploadHandler(c, service, cc, so)
 		return
 	}
 
@@ -188,6 +201,12 @@ func (h *rpcHandler) Handle(c *gin.Context) {
 		return
 	}
 
+	// server streams may be consumed as Server-Sent Events
+	if isEventStream(c, service) {
+		serveEventStream(c, service, cc, so)
+		return
+	}
+
 	// if stream we currently only support json
 	if isStream(c, service) {
 		// drop older context as it can have timeouts and create new
@@ -198,8 +217,24 @@ func (h *rpcHandler) Handle(c *gin.Context) {
 	}
 
 	// walk the standard call path
+	// the field of the response sent as the body
+	rspField := responseField(r)
+
+	// json requests are transcoded with the schema of the endpoint when the
+	// service documents one
+	var tc *transcoder
+	if ct == "application/json" || ct == "application/grpc+json" {
+		tc = newTranscoder(cx, cc, service, client.WithSelectOption(so))
+	}
+
 	// get payload
-	br, err := requestPayload(r)
+	var br []byte
+	var err error
+	if tc != nil {
+		br, err = tc.payload(r)
+	} else {
+		br, err = requestPayload(r)
+	}
 	if err != nil {
 		writeError(c, err)
 		return
@@ -226,6 +261,9 @@ func (h *rpcHandler) Handle(c *gin.Context) {
 			client.WithContentType(ct),
 		)
 
+		// shadow the call to a mirrored version
+		h.mirror(r.Context(), cc, req, &Message{})
+
 		// make the call
 		if err := cc.Call(cx, req, response, client.WithSelectOption(so)); err != nil {
 			writeError(c, err)
@@ -252,6 +290,14 @@ func (h *rpcHandler) Handle(c *gin.Context) {
 			request = br
 		}
 
+		// reject requests which break the documented constraints before they reach the service
+		if h.opts.Validation {
+			if err = validateRequest(cx, cc, service, br, client.WithSelectOption(so)); err != nil {
+				writeError(c, err)
+				return
+			}
+		}
+
 		// create request/response
 		var response RawMessage
 
@@ -261,6 +307,9 @@ func (h *rpcHandler) Handle(c *gin.Context) {
 			&request,
 			client.WithContentType(ct),
 		)
+		// shadow the call to a mirrored version
+		h.mirror(r.Context(), cc, req, &RawMessage{})
+
 		// make the call
 		if err = cc.Call(cx, req, &response, client.WithSelectOption(so)); err != nil {
 			writeError(c, err)
@@ -273,6 +322,14 @@ func (h *rpcHandler) Handle(c *gin.Context) {
 			writeError(c, err)
 			return
 		}
+
+		if rspField != "" {
+			rsp, err = tc.responseBody(rsp, rspField)
+			if err != nil {
+				writeError(c, err)
+				return
+			}
+		}
 	}
 
 	// write the response
@@ -564,14 +621,16 @@ func writeResponse(c *gin.Context, rsp []byte) {
 func NewHandler(opts ...handler.Option) handler.Handler {
 	options := handler.NewOptions(opts...)
 	return &rpcHandler{
-		opts: options,
+		opts:    options,
+		mirrors: make(chan struct{}, DefaultMirrorConcurrency),
 	}
 }
 
 func WithService(s *api.Service, opts ...handler.Option) handler.Handler {
 	options := handler.NewOptions(opts...)
 	return &rpcHandler{
-		opts: options,
-		s:    s,
+		opts:    options,
+		s:       s,
+		mirrors: make(chan struct{}, DefaultMirrorConcurrency),
 	}
 }
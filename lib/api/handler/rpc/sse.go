@@ -0,0 +1,266 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package rpc
+
+import (
+	b "bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	json "github.com/json-iterator/go"
+	"github.com/vine-io/vine/core/client"
+	"github.com/vine-io/vine/core/client/selector"
+	"github.com/vine-io/vine/core/codec/bytes"
+	"github.com/vine-io/vine/lib/api"
+	"github.com/vine-io/vine/lib/errors"
+	"github.com/vine-io/vine/lib/logger"
+)
+
+var (
+	// DefaultHeartbeat is the interval on which an idle event stream is kept alive
+	DefaultHeartbeat = time.Second * 15
+)
+
+// isEventStream returns true when a server streaming endpoint should be served as
+// Server-Sent Events, either because the client accepts text/event-stream or the
+// endpoint has been flagged with api.WithSSE.
+func isEventStream(c *gin.Context, svc *api.Service) bool {
+	if svc.Endpoint == nil || svc.Endpoint.Stream != string(api.Server) {
+		return false
+	}
+
+	for _, v := range strings.Split(c.GetHeader("Accept"), ",") {
+		if idx := strings.IndexRune(v, ';'); idx >= 0 {
+			v = v[:idx]
+		}
+		if strings.TrimSpace(strings.ToLower(v)) == "text/event-stream" {
+			return true
+		}
+	}
+
+	v, _ := strconv.ParseBool(endpointMetadata(svc, api.SSE))
+	return v
+}
+
+// endpointMetadata returns the value of the key in the registry metadata of the routed endpoint
+func endpointMetadata(svc *api.Service, key string) string {
+	for _, service := range svc.Services {
+		for _, ep := range service.Endpoints {
+			if ep.Name == svc.Endpoint.Name {
+				if v, ok := ep.Metadata[key]; ok {
+					return v
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// eventWriter writes Server-Sent Events
+type eventWriter struct {
+	w     io.Writer
+	flush func()
+}
+
+// event writes an event, the data is split into multiple data lines where needed
+func (e *eventWriter) event(id, name string, data []byte) error {
+	buf := b.NewBuffer(nil)
+	if len(id) > 0 {
+		fmt.Fprintf(buf, "id: %s\n", id)
+	}
+	if len(name) > 0 {
+		fmt.Fprintf(buf, "event: %s\n", name)
+	}
+	for _, line := range b.Split(data, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(b.TrimSuffix(line, []byte("\r")))
+		buf.WriteString("\n")
+	}
+	buf.WriteString("\n")
+	return e.write(buf.Bytes())
+}
+
+// heartbeat writes a comment which is ignored by clients but keeps proxies from closing the connection
+func (e *eventWriter) heartbeat() error {
+	return e.write([]byte(": heartbeat\n\n"))
+}
+
+func (e *eventWriter) write(p []byte) error {
+	if _, err := e.w.Write(p); err != nil {
+		return err
+	}
+	if e.flush != nil {
+		e.flush()
+	}
+	return nil
+}
+
+// streamError converts the error returned by a stream to a *errors.Error
+func streamError(err error) *errors.Error {
+	ce := errors.FromErr(err)
+	// the status message may carry the encoded error of the backend
+	if pe := errors.Parse(ce.Detail); pe.Code != 0 {
+		ce = pe
+	}
+	if ce.Code == 0 {
+		ce.Code = http.StatusInternalServerError
+		ce.Status = http.StatusText(http.StatusInternalServerError)
+	}
+	if len(ce.Id) == 0 {
+		ce.Id = "go.vine.api"
+	}
+	return ce
+}
+
+// serveEventStream streams the responses of a server streaming rpc back as Server-Sent Events.
+// Events are numbered, a client reconnecting with the Last-Event-ID header continues from that
+// number. The header is passed on to the backend as metadata so that it can resume the stream.
+func serveEventStream(ctx *gin.Context, service *api.Service, c client.Client, so selector.SelectOption) {
+	ct := ctx.GetHeader("Content-Type")
+	// Strip charset from Content-Type (like `application/json; charset=UTF-8`)
+	if idx := strings.IndexRune(ct, ';'); idx >= 0 {
+		ct = ct[:idx]
+	}
+
+	payload, err := requestPayload(ctx.Request)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+
+	var request interface{}
+	switch {
+	case hasCodec(ct, protoCodecs):
+		request = &bytes.Frame{Data: payload}
+	default:
+		ct = "application/json"
+		if len(payload) == 0 {
+			payload = []byte(`{}`)
+		}
+		m := json.RawMessage(payload)
+		request = &m
+	}
+
+	var seq uint64
+	if v := ctx.GetHeader("Last-Event-ID"); len(v) > 0 {
+		seq, _ = strconv.ParseUint(v, 10, 64)
+	}
+
+	req := c.NewRequest(
+		service.Name,
+		service.Endpoint.Name,
+		request,
+		client.WithContentType(ct),
+		client.StreamingRequest(),
+	)
+
+	stream, err := c.Stream(ctx.Request.Context(), req, client.WithSelectOption(so))
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	defer stream.Close()
+
+	if err = stream.Send(request); err != nil {
+		writeError(ctx, err)
+		return
+	}
+	_ = stream.CloseSend()
+
+	header := ctx.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// disable response buffering of nginx
+	header.Set("X-Accel-Buffering", "no")
+	ctx.Writer.WriteHeader(http.StatusOK)
+
+	ew := &eventWriter{w: ctx.Writer, flush: ctx.Writer.Flush}
+	// send the headers straight away
+	ew.flush()
+
+	type result struct {
+		buf []byte
+		err error
+	}
+
+	results := make(chan result)
+	go func() {
+		rsp := stream.Response()
+		for {
+			buf, err := rsp.Read()
+			select {
+			case results <- result{buf, err}:
+			case <-stream.Context().Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := DefaultHeartbeat
+	if heartbeat <= 0 {
+		heartbeat = time.Second * 15
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case <-ticker.C:
+			if err := ew.heartbeat(); err != nil {
+				return
+			}
+		case r := <-results:
+			if r.err == io.EOF {
+				return
+			}
+			if r.err != nil {
+				ce := streamError(r.err)
+				logger.Errorf("code=%d [%s] %s | %s", ce.Code, ctx.Request.Method, ctx.Request.URL.Path, ce.Detail)
+				data, _ := json.Marshal(ce)
+				_ = ew.event("", "error", data)
+				return
+			}
+
+			data := r.buf
+			if ct != "application/json" {
+				data = []byte(base64.StdEncoding.EncodeToString(data))
+			}
+			seq++
+			if err := ew.event(strconv.FormatUint(seq, 10), "", data); err != nil {
+				return
+			}
+		}
+	}
+}
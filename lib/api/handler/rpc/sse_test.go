@@ -0,0 +1,143 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	membroker "github.com/vine-io/vine/core/broker/memory"
+	cmemory "github.com/vine-io/vine/core/client/memory"
+	"github.com/vine-io/vine/core/registry"
+	regMemory "github.com/vine-io/vine/core/registry/memory"
+	"github.com/vine-io/vine/core/server"
+	smemory "github.com/vine-io/vine/core/server/memory"
+	"github.com/vine-io/vine/lib/api"
+	"github.com/vine-io/vine/lib/api/handler"
+	"github.com/vine-io/vine/lib/errors"
+	"github.com/vine-io/vine/util/context/metadata"
+)
+
+type watchRequest struct {
+	Name string `json:"name"`
+}
+
+type watchResponse struct {
+	Msg string `json:"msg"`
+}
+
+type Watcher struct{}
+
+func (w *Watcher) Watch(ctx context.Context, stream server.Stream) error {
+	req := &watchRequest{}
+	if err := stream.Recv(req); err != nil {
+		return err
+	}
+	if req.Name == "" {
+		return errors.BadRequest("watcher", "name is empty")
+	}
+	last, _ := metadata.Get(ctx, "Last-Event-Id")
+	for i := 0; i < 3; i++ {
+		if err := stream.Send(&watchResponse{Msg: fmt.Sprintf("%s%s-%d", req.Name, last, i)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestServeEventStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := regMemory.NewRegistry()
+	s := smemory.NewServer(server.Name("watcher"), server.Registry(r), server.Broker(membroker.NewBroker()))
+	if err := s.Handle(s.NewHandler(&Watcher{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	services, err := r.GetService(context.TODO(), "watcher")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := &api.Service{
+		Name:     "watcher",
+		Endpoint: &api.Endpoint{Name: "Watcher.Watch", Stream: string(api.Server)},
+		Services: services,
+	}
+	h := WithService(svc, handler.WithClient(cmemory.NewClient()))
+
+	serve := func(body string, header map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/watcher/watch", strings.NewReader(body))
+		c.Request.Header.Set("Accept", "text/event-stream")
+		for k, v := range header {
+			c.Request.Header.Set(k, v)
+		}
+		h.Handle(c)
+		return w
+	}
+
+	w := serve(`{"name":"vine"}`, nil)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "id: 1\ndata: {\"msg\":\"vine-0\"}\n\n"+
+		"id: 2\ndata: {\"msg\":\"vine-1\"}\n\n"+
+		"id: 3\ndata: {\"msg\":\"vine-2\"}\n\n", w.Body.String())
+
+	// resume from the last event seen
+	w = serve(`{"name":"vine"}`, map[string]string{"Last-Event-ID": "5"})
+	assert.True(t, strings.HasPrefix(w.Body.String(), "id: 6\ndata: {\"msg\":\"vine5-0\"}\n\n"))
+
+	// errors are sent as error events
+	w = serve(`{}`, nil)
+	body := w.Body.String()
+	assert.True(t, strings.HasPrefix(body, "event: error\ndata: "))
+	ce := errors.Parse(strings.TrimSpace(strings.TrimPrefix(body, "event: error\ndata: ")))
+	assert.Equal(t, errors.StatusBadRequest, ce.Code)
+
+	// without the Accept header the endpoint must be flagged
+	w = httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/watcher/watch", nil)
+	assert.False(t, isEventStream(c, svc))
+
+	svc.Services = []*registry.Service{{
+		Name: "watcher",
+		Endpoints: []*registry.Endpoint{{
+			Name:     "Watcher.Watch",
+			Metadata: map[string]string{api.SSE: "true"},
+		}},
+	}}
+	assert.True(t, isEventStream(c, svc))
+}
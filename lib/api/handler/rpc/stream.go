@@ -25,16 +25,11 @@ package rpc
 import (
 	b "bytes"
 	"errors"
-	"fmt"
-	"io"
-	"mime/multipart"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/gogo/protobuf/proto"
 	"github.com/gorilla/websocket"
 	json "github.com/json-iterator/go"
 	"github.com/vine-io/vine/core/client"
@@ -143,7 +138,7 @@ func serveWebSocket(ctx *gin.Context, service *api.Service, c client.Client, so
 	)
 
 	// create a new stream
-	stream, err := c.Stream(ctx, req, client.WithSelectOption(so))
+	stream, err := c.Stream(ctx.Request.Context(), req, client.WithSelectOption(so))
 	if err != nil {
 		logger.Error(err)
 		return
@@ -163,7 +158,7 @@ func serveWebSocket(ctx *gin.Context, service *api.Service, c client.Client, so
 	// receive from stream and send to client
 	for {
 		select {
-		case <-ctx.Done():
+		case <-ctx.Request.Context().Done():
 			return
 		case <-stream.Context().Done():
 			return
@@ -253,234 +248,3 @@ func isStream(c *gin.Context, svc *api.Service) bool {
 	}
 	return false
 }
-
-func isMultipart(c *gin.Context) bool {
-	return strings.Contains(c.ContentType(), "multipart/form-data")
-}
-
-func multipartHandler(ctx *gin.Context, service *api.Service, c client.Client, so selector.SelectOption) {
-	if service.Endpoint.Stream != string(api.Client) {
-		writeError(ctx, fmt.Errorf("server endpoint must be gRPC client stream"))
-		return
-	}
-
-	form, err := ctx.MultipartForm()
-	if err != nil {
-		writeError(ctx, err)
-		return
-	}
-
-	vals := make(map[string]string)
-	for key, values := range ctx.Request.URL.Query() {
-		vv, ok := vals[key]
-		if !ok {
-			vals[key] = strings.Join(values, ",")
-		} else {
-			vals[key] = vv + "," + strings.Join(values, ",")
-		}
-	}
-
-	for key, values := range form.Value {
-		vv, ok := vals[key]
-		if !ok {
-			vals[key] = strings.Join(values, ",")
-		} else {
-			vals[key] = vv + "," + strings.Join(values, ",")
-		}
-	}
-
-	br, err := json.Marshal(vals)
-	if err != nil {
-		writeError(ctx, err)
-		return
-	}
-
-	var request interface{}
-	m := json.RawMessage(br)
-	request = &m
-
-	ct := "application/json"
-	ctx.Request.Header.Set("Content-Type", ct)
-	req := c.NewRequest(
-		service.Name,
-		service.Endpoint.Name,
-		request,
-		client.WithContentType(ct),
-		client.StreamingRequest(),
-	)
-
-	// create a new stream
-	stream, err := c.Stream(ctx, req, client.WithSelectOption(so))
-	if err != nil {
-		logger.Error(err)
-		return
-	}
-	defer stream.Close()
-
-	done := make(chan struct{})
-	ech := make(chan error, 1)
-
-	process := func(service *api.Service, s client.Stream, files map[string][]*multipart.FileHeader, vals map[string]string) {
-
-		data := map[string]interface{}{}
-		for key, values := range vals {
-			data[key] = values
-		}
-
-		var e error
-		if form.File == nil || len(form.File) == 0 {
-			if e = stream.Send(data); e != nil {
-				ech <- e
-			}
-		} else {
-			var fd multipart.File
-			for _, headers := range form.File {
-				for _, header := range headers {
-					data["name"] = header.Filename
-					data["size"] = header.Size
-
-					fd, e = header.Open()
-					if e != nil {
-						ech <- e
-						return
-					}
-
-					buf := make([]byte, 4096)
-					for {
-						n, e1 := fd.Read(buf)
-						if e1 != nil && e1 != io.EOF {
-							ech <- e1
-							_ = fd.Close()
-							return
-						}
-
-						if n > 0 {
-							data["length"] = n
-							data["chunk"] = buf[:n]
-							if ee := stream.Send(data); ee != nil {
-								ech <- ee
-								_ = fd.Close()
-								return
-							}
-						}
-
-						if e1 == io.EOF {
-							_ = fd.Close()
-							break
-						}
-					}
-				}
-			}
-		}
-
-		if e = stream.CloseSend(); e != nil {
-			ech <- e
-			return
-		}
-
-		close(done)
-	}
-
-	go process(service, stream, form.File, vals)
-
-	select {
-	case err = <-ech:
-		writeError(ctx, err)
-		return
-	case <-done:
-	}
-
-	rsp := stream.Response()
-	result, err := rsp.Read()
-	if err != nil {
-		writeError(ctx, err)
-		return
-	}
-
-	writeResponse(ctx, result)
-	return
-}
-
-func isDownLoadLink(s *api.Service) bool {
-	return s.Endpoint.Stream == string(api.Server) && strings.HasSuffix(strings.ToLower(s.Endpoint.Name), "download")
-}
-
-func downLoadHandler(ctx *gin.Context, service *api.Service, c client.Client, so selector.SelectOption) {
-	request := &api.FileDesc{}
-
-	_ = ctx.ShouldBindJSON(request)
-	if request.Name == "" && request.Offset == 0 {
-		request.Name = ctx.Query("name")
-		offset := ctx.Query("offset")
-		request.Offset, _ = strconv.ParseInt(offset, 10, 64)
-	}
-
-	if request.Name == "" && request.Offset == 0 {
-		writeError(ctx, fmt.Errorf("read request values: request is empty"))
-		return
-	}
-
-	ct := "application/proto"
-	req := c.NewRequest(
-		service.Name,
-		service.Endpoint.Name,
-		request,
-		client.WithContentType(ct),
-		client.StreamingRequest(),
-	)
-
-	// create a new stream
-	stream, err := c.Stream(ctx, req, client.WithSelectOption(so))
-	if err != nil {
-		logger.Error(err)
-		writeError(ctx, fmt.Errorf("create stream: %v", err))
-		return
-	}
-	defer stream.Close()
-
-	if err = stream.Send(request); err != nil {
-		logger.Error(err)
-		writeError(ctx, err)
-		return
-	}
-
-	ctx.Writer.Header().Set("Accept-Ranges", "bytes")
-	ctx.Writer.Header().Set("Content-Type", "application/octet-stream")
-	disposition := fmt.Sprintf(`attachment; filename="%s"`, request.Name)
-	ctx.Writer.Header().Set("Content-Disposition", disposition)
-
-	rsp := stream.Response()
-	reader := &responseReader{conn: rsp}
-
-	ctx.Writer.WriteHeader(200)
-	_, err = io.Copy(ctx.Writer, reader)
-	if err != nil {
-		writeError(ctx, err)
-		return
-	}
-	return
-}
-
-type responseReader struct {
-	conn client.Response
-}
-
-func (rr *responseReader) Read(b []byte) (n int, err error) {
-	frame := &api.FileHeader{}
-	buf, e := rr.conn.Read()
-	if e != nil && e != io.EOF {
-		return 0, e
-	}
-	err = proto.Unmarshal(buf, frame)
-	if err != nil {
-		return 0, err
-	}
-	if frame.Length > 0 {
-		n = copy(b, frame.Chunk[:frame.Length])
-		return n, nil
-	}
-	if e == io.EOF {
-		return 0, e
-	}
-	return
-}
@@ -0,0 +1,216 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/vine-io/vine/core/client"
+	"github.com/vine-io/vine/lib/api"
+	pb "github.com/vine-io/vine/lib/api/handler/openapi/proto"
+	"github.com/vine-io/vine/lib/api/router/httprule"
+	"github.com/vine-io/vine/lib/errors"
+	"github.com/vine-io/vine/util/context/metadata"
+)
+
+// transcoder maps http requests to the request message of an endpoint and
+// its response message back, with the schemas of the OpenAPI document.
+type transcoder struct {
+	request  *httprule.Message
+	response *httprule.Message
+}
+
+// newTranscoder returns nil when the service has no document for the endpoint
+func newTranscoder(ctx context.Context, cc client.Client, service *api.Service, opts ...client.CallOption) *transcoder {
+	if service.Endpoint == nil {
+		return nil
+	}
+
+	doc := schemas.get(ctx, cc, service.Name, opts...)
+	if doc == nil {
+		return nil
+	}
+
+	model := requestModel(doc, service.Endpoint.Name)
+	if model == nil {
+		return nil
+	}
+
+	seen := map[string]*httprule.Message{}
+	t := &transcoder{request: describe(doc, model, seen)}
+	if model = responseModel(doc, service.Endpoint.Name); model != nil {
+		t.response = describe(doc, model, seen)
+	}
+	return t
+}
+
+// payload builds the json request from the path variables, the query and the
+// body of the request following google.api.http
+func (t *transcoder) payload(r *http.Request) ([]byte, error) {
+	rctx := r.Context()
+	md, ok := metadata.FromContext(rctx)
+	if !ok {
+		md = make(map[string]string)
+	}
+
+	req := &httprule.Request{Vars: map[string]string{}, Query: r.URL.Query()}
+
+	// get fields from url path
+	for k, v := range md {
+		k = strings.ToLower(k)
+		// filter own keys
+		if strings.HasPrefix(k, "x-api-field-") {
+			req.Vars[strings.TrimPrefix(k, "x-api-field-")] = v
+			delete(md, k)
+		} else if k == "x-api-body" {
+			req.BodyField = v
+			delete(md, k)
+		}
+	}
+
+	// restore context without fields
+	*r = *r.Clone(metadata.NewContext(rctx, md))
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		buf := bufferPool.Get()
+		defer bufferPool.Put(buf)
+		if _, err := buf.ReadFrom(r.Body); err != nil {
+			return nil, err
+		}
+		req.Body = append([]byte{}, buf.Bytes()...)
+	}
+
+	out, err := httprule.Transcode(t.request, req)
+	if err != nil {
+		return nil, errors.BadRequest("go.vine.api", err.Error())
+	}
+	return out, nil
+}
+
+// responseBody takes the field of the binding out of the response
+func (t *transcoder) responseBody(rsp []byte, field string) ([]byte, error) {
+	var msg *httprule.Message
+	if t != nil {
+		msg = t.response
+	}
+	return httprule.ResponseBody(msg, rsp, field)
+}
+
+// responseField returns the response_body of the binding which matched the
+// request, it isn't sent to the service
+func responseField(r *http.Request) string {
+	rctx := r.Context()
+	md, ok := metadata.FromContext(rctx)
+	if !ok {
+		return ""
+	}
+
+	var field string
+	for k, v := range md {
+		if strings.ToLower(k) == "x-api-response-body" {
+			field = v
+			delete(md, k)
+		}
+	}
+
+	*r = *r.Clone(metadata.NewContext(rctx, md))
+	return field
+}
+
+// describe converts the model to the description of a message, messages are
+// registered by name before their fields so recursive messages terminate
+func describe(doc *pb.OpenAPI, model *pb.Model, seen map[string]*httprule.Message) *httprule.Message {
+	msg := &httprule.Message{Fields: map[string]*httprule.Field{}}
+	for name, schema := range model.Properties {
+		if schema == nil {
+			continue
+		}
+		msg.Fields[name] = describeField(doc, name, schema, seen)
+	}
+	return msg
+}
+
+func describeField(doc *pb.OpenAPI, name string, schema *pb.Schema, seen map[string]*httprule.Message) *httprule.Field {
+	f := &httprule.Field{Name: name}
+	switch {
+	case schema.AdditionalProperties != nil:
+		f.Map = true
+		schema = schema.AdditionalProperties
+	case schema.Type == "array" && schema.Items != nil:
+		f.Repeated = true
+		schema = schema.Items
+	case schema.Type == "array":
+		// google.protobuf.ListValue
+		f.Kind = httprule.KindJSON
+		return f
+	}
+
+	if schema.Ref != "" {
+		ref := refName(schema.Ref)
+		if msg, ok := seen[ref]; ok {
+			f.Kind, f.Message = httprule.KindMessage, msg
+			return f
+		}
+		if doc.Components == nil || doc.Components.Schemas[ref] == nil {
+			f.Kind = httprule.KindJSON
+			return f
+		}
+		msg := &httprule.Message{}
+		seen[ref] = msg
+		*msg = *describe(doc, doc.Components.Schemas[ref], seen)
+		f.Kind, f.Message = httprule.KindMessage, msg
+		return f
+	}
+
+	switch schema.Type {
+	case "boolean":
+		f.Kind = httprule.KindBool
+	case "integer":
+		f.Kind = httprule.KindInt
+		if len(schema.Enum) > 0 {
+			// enums are documented by their numbers
+			f.Kind = httprule.KindEnum
+		}
+	case "number":
+		f.Kind = httprule.KindFloat
+	case "string":
+		switch schema.Format {
+		case "byte":
+			f.Kind = httprule.KindBytes
+		case "date-time":
+			f.Kind = httprule.KindTimestamp
+		case "duration":
+			f.Kind = httprule.KindDuration
+		case "field-mask":
+			f.Kind = httprule.KindFieldMask
+		default:
+			f.Kind = httprule.KindString
+		}
+	default:
+		// google.protobuf.Struct and Value
+		f.Kind = httprule.KindJSON
+	}
+	return f
+}
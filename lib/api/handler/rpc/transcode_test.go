@@ -0,0 +1,181 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	membroker "github.com/vine-io/vine/core/broker/memory"
+	cmemory "github.com/vine-io/vine/core/client/memory"
+	regMemory "github.com/vine-io/vine/core/registry/memory"
+	"github.com/vine-io/vine/core/server"
+	smemory "github.com/vine-io/vine/core/server/memory"
+	"github.com/vine-io/vine/lib/api"
+	"github.com/vine-io/vine/lib/api/handler"
+	pb "github.com/vine-io/vine/lib/api/handler/openapi/proto"
+	"github.com/vine-io/vine/lib/errors"
+	"github.com/vine-io/vine/util/context/metadata"
+)
+
+type listFilter struct {
+	Since  string            `json:"since"`
+	Labels map[string]string `json:"labels"`
+}
+
+type listRequest struct {
+	Parent string      `json:"parent"`
+	Tags   []string    `json:"tags"`
+	Limit  int64       `json:"limit"`
+	Filter *listFilter `json:"filter"`
+}
+
+type listResponse struct {
+	Request *listRequest `json:"request"`
+	Total   int64        `json:"total"`
+}
+
+type Messages struct{}
+
+func (m *Messages) List(ctx context.Context, req *listRequest, rsp *listResponse) error {
+	rsp.Request = req
+	return nil
+}
+
+type messagesDoc struct{}
+
+func (d *messagesDoc) GetOpenAPIDoc(ctx context.Context, req *pb.GetOpenAPIDocRequest, rsp *pb.GetOpenAPIDocResponse) error {
+	rsp.Apis = []*pb.OpenAPI{{
+		Paths: map[string]*pb.OpenAPIPath{
+			"/v1/{parent}/messages": {Get: &pb.OpenAPIPathDocs{
+				OperationId: "MessagesList",
+				Parameters: []*pb.PathParameters{
+					{Name: "parent", In: "path", Schema: &pb.Schema{Type: "string"}},
+					{Name: "tags", In: "query", Schema: &pb.Schema{Type: "array", Items: &pb.Schema{Type: "string"}}},
+					{Name: "limit", In: "query", Schema: &pb.Schema{Type: "integer", Format: "int64"}},
+					{Name: "filter", In: "query", Schema: &pb.Schema{Ref: "#/components/schemas/messages.Filter"}},
+				},
+				Responses: map[string]*pb.PathResponse{
+					"200": {Content: &pb.PathRequestBodyContent{ApplicationJson: &pb.ApplicationContent{
+						Schema: &pb.Schema{Ref: "#/components/schemas/messages.ListResponse"},
+					}}},
+				},
+			}},
+		},
+		Components: &pb.OpenAPIComponents{Schemas: map[string]*pb.Model{
+			"messages.Filter": {
+				Type: "object",
+				Properties: map[string]*pb.Schema{
+					"since":  {Type: "string", Format: "date-time"},
+					"labels": {AdditionalProperties: &pb.Schema{Type: "string"}},
+				},
+			},
+			"messages.ListResponse": {
+				Type: "object",
+				Properties: map[string]*pb.Schema{
+					"request": {Ref: "#/components/schemas/messages.ListRequest"},
+					"total":   {Type: "integer", Format: "int64"},
+				},
+			},
+		}},
+	}}
+	return nil
+}
+
+func (d *messagesDoc) GetEndpoint(ctx context.Context, req *pb.GetEndpointRequest, rsp *pb.GetEndpointResponse) error {
+	return nil
+}
+
+func TestTranscode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := regMemory.NewRegistry()
+	s := smemory.NewServer(
+		server.Name("messages"),
+		server.Registry(r),
+		server.Broker(membroker.NewBroker()),
+	)
+	if err := s.Handle(s.NewHandler(&Messages{})); err != nil {
+		t.Fatal(err)
+	}
+	if err := pb.RegisterOpenAPIServiceHandler(s, &messagesDoc{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	services, err := r.GetService(context.TODO(), "messages")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := &api.Service{
+		Name:     "messages",
+		Endpoint: &api.Endpoint{Name: "Messages.List"},
+		Services: services,
+	}
+	h := WithService(svc, handler.WithClient(cmemory.NewClient()))
+
+	// serve sets the metadata the router sets for the matched binding
+	serve := func(target, responseBody string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+		c.Request.Header.Set("Content-Type", "application/json")
+		ctx := metadata.NewContext(c.Request.Context(), metadata.Metadata{
+			"x-api-field-parent":  "shelves/1",
+			"x-api-body":          "*",
+			"x-api-response-body": responseBody,
+		})
+		c.Request = c.Request.WithContext(ctx)
+		h.Handle(c)
+		return w
+	}
+
+	w := serve("/v1/shelves/1/messages?tags=a&tags=b&limit=5&filter.since=2020-01-01T00:00:00Z&filter.labels[app]=vine&api_key=secret", "request")
+	if assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		assert.JSONEq(t, `{
+			"parent": "shelves/1",
+			"tags": ["a", "b"],
+			"limit": 5,
+			"filter": {"since": "2020-01-01T00:00:00Z", "labels": {"app": "vine"}}
+		}`, w.Body.String())
+	}
+
+	// unset fields of the response are written as their zero value
+	w = serve("/v1/shelves/1/messages", "total")
+	if assert.Equal(t, http.StatusOK, w.Code, w.Body.String()) {
+		assert.Equal(t, `0`, w.Body.String())
+	}
+
+	w = serve("/v1/shelves/1/messages?limit=ten", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, errors.Parse(w.Body.String()).Detail, "limit")
+}
@@ -0,0 +1,371 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	json "github.com/json-iterator/go"
+	"github.com/vine-io/vine/core/client"
+	"github.com/vine-io/vine/lib/api"
+	pb "github.com/vine-io/vine/lib/api/handler/openapi/proto"
+	"github.com/vine-io/vine/lib/errors"
+	"github.com/vine-io/vine/util/is"
+)
+
+var (
+	// DefaultSchemaTTL is how long the OpenAPI document of a service is cached
+	// before the gateway asks for it again
+	DefaultSchemaTTL = time.Minute
+
+	schemas = &schemaCache{docs: map[string]*schemaEntry{}}
+)
+
+type schemaEntry struct {
+	doc     *pb.OpenAPI
+	expires time.Time
+}
+
+// schemaCache keeps the OpenAPI documents served by the OpenAPIService of each
+// service, services without documents are cached as well so they are not asked
+// on every request.
+type schemaCache struct {
+	sync.RWMutex
+
+	docs map[string]*schemaEntry
+}
+
+func (s *schemaCache) get(ctx context.Context, cc client.Client, name string, opts ...client.CallOption) *pb.OpenAPI {
+	s.RLock()
+	entry, ok := s.docs[name]
+	s.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.doc
+	}
+
+	var doc *pb.OpenAPI
+	rsp, err := pb.NewOpenAPIService(name, cc).GetOpenAPIDoc(ctx, &pb.GetOpenAPIDocRequest{}, opts...)
+	if err == nil && len(rsp.Apis) > 0 {
+		doc = &pb.OpenAPI{
+			Paths:      map[string]*pb.OpenAPIPath{},
+			Components: &pb.OpenAPIComponents{Schemas: map[string]*pb.Model{}},
+		}
+		for _, item := range rsp.Apis {
+			if item == nil {
+				continue
+			}
+			for k, v := range item.Paths {
+				doc.Paths[k] = v
+			}
+			if item.Components != nil {
+				for k, v := range item.Components.Schemas {
+					doc.Components.Schemas[k] = v
+				}
+			}
+		}
+	}
+
+	s.Lock()
+	s.docs[name] = &schemaEntry{doc: doc, expires: time.Now().Add(DefaultSchemaTTL)}
+	s.Unlock()
+
+	return doc
+}
+
+// validateRequest checks the json payload against the request schema which
+// protoc-gen-vine generated from the same tags as Validate(). It returns a
+// BadRequest listing every violation, or nil when the request is valid or the
+// service has no document.
+func validateRequest(ctx context.Context, cc client.Client, service *api.Service, payload []byte, opts ...client.CallOption) error {
+	if service.Endpoint == nil {
+		return nil
+	}
+
+	doc := schemas.get(ctx, cc, service.Name, opts...)
+	if doc == nil {
+		return nil
+	}
+
+	model := requestModel(doc, service.Endpoint.Name)
+	if model == nil {
+		return nil
+	}
+
+	data := map[string]interface{}{}
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &data); err != nil {
+			// leave malformed payloads to the codec of the service
+			return nil
+		}
+	}
+
+	v := &schemaValidator{doc: doc, violations: make([]*errors.Violation, 0)}
+	v.model("", model, data, 0)
+	if len(v.violations) == 0 {
+		return nil
+	}
+
+	details := make([]string, 0, len(v.violations))
+	for _, item := range v.violations {
+		details = append(details, fmt.Sprintf("field '%s' violates '%s'", item.Field, item.Rule))
+	}
+	e := errors.BadRequest(service.Name, strings.Join(details, "; "))
+	e.Violations = v.violations
+	return e
+}
+
+// operation finds the operation of the endpoint, the operation id is generated
+// as the service name followed by the method name.
+func operation(doc *pb.OpenAPI, endpoint string) *pb.OpenAPIPathDocs {
+	id := strings.Replace(endpoint, ".", "", 1)
+	for _, path := range doc.Paths {
+		for _, op := range []*pb.OpenAPIPathDocs{path.Get, path.Post, path.Put, path.Patch, path.Delete} {
+			if op != nil && op.OperationId == id {
+				return op
+			}
+		}
+	}
+	return nil
+}
+
+// requestModel finds the request schema of the endpoint
+func requestModel(doc *pb.OpenAPI, endpoint string) *pb.Model {
+	op := operation(doc, endpoint)
+	if op == nil {
+		return nil
+	}
+
+	if body := op.RequestBody; body != nil && body.Content != nil &&
+		body.Content.ApplicationJson != nil && body.Content.ApplicationJson.Schema != nil {
+		return doc.Components.Schemas[refName(body.Content.ApplicationJson.Schema.Ref)]
+	}
+
+	// requests without body are documented as parameters
+	model := &pb.Model{Type: "object", Properties: map[string]*pb.Schema{}}
+	for _, p := range op.Parameters {
+		if p.Schema == nil {
+			continue
+		}
+		model.Properties[p.Name] = p.Schema
+		if p.Required {
+			model.Required = append(model.Required, p.Name)
+		}
+	}
+	return model
+}
+
+// responseModel finds the schema of the successful response of the endpoint
+func responseModel(doc *pb.OpenAPI, endpoint string) *pb.Model {
+	op := operation(doc, endpoint)
+	if op == nil || doc.Components == nil {
+		return nil
+	}
+
+	rsp, ok := op.Responses["200"]
+	if !ok || rsp == nil || rsp.Content == nil || rsp.Content.ApplicationJson == nil || rsp.Content.ApplicationJson.Schema == nil {
+		return nil
+	}
+	return doc.Components.Schemas[refName(rsp.Content.ApplicationJson.Schema.Ref)]
+}
+
+func refName(ref string) string {
+	return strings.TrimPrefix(ref, "#/components/schemas/")
+}
+
+type schemaValidator struct {
+	doc        *pb.OpenAPI
+	violations []*errors.Violation
+}
+
+func (v *schemaValidator) violate(field, rule string, value interface{}) {
+	s := ""
+	if value != nil {
+		s = fmt.Sprintf("%v", value)
+	}
+	v.violations = append(v.violations, &errors.Violation{Field: field, Rule: rule, Value: s})
+}
+
+func (v *schemaValidator) model(prefix string, model *pb.Model, data map[string]interface{}, depth int) {
+	// guard against recursive messages
+	if model == nil || depth > 32 {
+		return
+	}
+
+	required := map[string]bool{}
+	for _, name := range model.Required {
+		required[name] = true
+	}
+
+	names := make([]string, 0, len(model.Properties))
+	for name := range model.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		schema := model.Properties[name]
+		value := data[name]
+		if isZero(value) {
+			if required[name] || schema.Required {
+				v.violate(prefix+name, "required", nil)
+			}
+			continue
+		}
+		v.schema(prefix+name, schema, value, depth)
+	}
+}
+
+func (v *schemaValidator) schema(field string, schema *pb.Schema, value interface{}, depth int) {
+	if schema.Ref != "" {
+		if m, ok := value.(map[string]interface{}); ok && v.doc.Components != nil {
+			v.model(field+".", v.doc.Components.Schemas[refName(schema.Ref)], m, depth+1)
+		}
+		return
+	}
+
+	switch schema.Type {
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok || schema.Items == nil {
+			return
+		}
+		for i, item := range items {
+			if !isZero(item) {
+				v.schema(fmt.Sprintf("%s[%d]", field, i), schema.Items, item, depth)
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return
+		}
+		v.str(field, schema, s)
+	case "integer", "number":
+		n, ok := number(value)
+		if !ok {
+			return
+		}
+		v.num(field, schema, value, n)
+	}
+}
+
+func (v *schemaValidator) str(field string, schema *pb.Schema, s string) {
+	if len(schema.Enum) > 0 && !is.In(schema.Enum, s) {
+		v.violate(field, "in", s)
+	}
+	if schema.MinLength > 0 && len(s) < int(schema.MinLength) {
+		v.violate(field, "min_len", s)
+	}
+	if schema.MaxLength > 0 && len(s) > int(schema.MaxLength) {
+		v.violate(field, "max_len", s)
+	}
+	if schema.Pattern != "" {
+		pattern := schema.Pattern
+		if len(pattern) > 1 && strings.HasPrefix(pattern, "'") && strings.HasSuffix(pattern, "'") {
+			pattern = pattern[1 : len(pattern)-1]
+		}
+		if !is.Re(pattern, s) {
+			v.violate(field, "pattern", s)
+		}
+	}
+
+	var ok = true
+	rule := schema.Format
+	switch schema.Format {
+	case "email":
+		ok = is.Email(s)
+	case "uuid":
+		ok = is.Uuid(s)
+	case "uri":
+		ok = is.URL(s)
+	case "hostname":
+		rule = "domain"
+		ok = is.Domain(s)
+	case "ipv4":
+		ok = is.IPv4(s)
+	case "ipv6":
+		ok = is.IPv6(s)
+	}
+	if !ok {
+		v.violate(field, rule, s)
+	}
+}
+
+func (v *schemaValidator) num(field string, schema *pb.Schema, value interface{}, n float64) {
+	if len(schema.Enum) > 0 && !is.In(schema.Enum, strconv.FormatFloat(n, 'f', -1, 64)) {
+		v.violate(field, "in", value)
+	}
+	if schema.Minimum != 0 || schema.ExclusiveMinimum {
+		min := float64(schema.Minimum)
+		if schema.ExclusiveMinimum && n <= min {
+			v.violate(field, "gt", value)
+		} else if n < min {
+			v.violate(field, "gte", value)
+		}
+	}
+	if schema.Maximum != 0 || schema.ExclusiveMaximum {
+		max := float64(schema.Maximum)
+		if schema.ExclusiveMaximum && n >= max {
+			v.violate(field, "lt", value)
+		} else if n > max {
+			v.violate(field, "lte", value)
+		}
+	}
+}
+
+// number accepts json numbers and the strings decoded from query parameters
+func number(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// isZero mirrors the generated validators which only check fields that are set
+func isZero(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case float64:
+		return v == 0
+	case bool:
+		return !v
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
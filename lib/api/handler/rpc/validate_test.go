@@ -0,0 +1,191 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	membroker "github.com/vine-io/vine/core/broker/memory"
+	cmemory "github.com/vine-io/vine/core/client/memory"
+	regMemory "github.com/vine-io/vine/core/registry/memory"
+	"github.com/vine-io/vine/core/server"
+	smemory "github.com/vine-io/vine/core/server/memory"
+	"github.com/vine-io/vine/lib/api"
+	"github.com/vine-io/vine/lib/api/handler"
+	pb "github.com/vine-io/vine/lib/api/handler/openapi/proto"
+	"github.com/vine-io/vine/lib/errors"
+	"github.com/vine-io/vine/util/is"
+	"github.com/vine-io/vine/util/wrapper"
+)
+
+type signupRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Age   int32  `json:"age"`
+}
+
+// Validate is written the way protoc-gen-validator generates it
+func (m *signupRequest) Validate() error {
+	errs := make([]error, 0)
+	if len(m.Name) == 0 {
+		errs = append(errs, is.Invalid("name", "required", "", "is required"))
+	}
+	if len(m.Email) != 0 {
+		if !is.Email(m.Email) {
+			errs = append(errs, is.Invalid("email", "email", m.Email, "is not a valid email"))
+		}
+	}
+	if int64(m.Age) != 0 {
+		if !(m.Age >= 18) {
+			errs = append(errs, is.Invalid("age", "gte", m.Age, "must great than or equal to '18'"))
+		}
+	}
+	return is.MargeErr(errs...)
+}
+
+type signupResponse struct {
+	Name string `json:"name"`
+}
+
+type Account struct {
+	calls int32
+}
+
+func (a *Account) Signup(ctx context.Context, req *signupRequest, rsp *signupResponse) error {
+	atomic.AddInt32(&a.calls, 1)
+	rsp.Name = req.Name
+	return nil
+}
+
+type accountDoc struct{}
+
+func (d *accountDoc) GetOpenAPIDoc(ctx context.Context, req *pb.GetOpenAPIDocRequest, rsp *pb.GetOpenAPIDocResponse) error {
+	rsp.Apis = []*pb.OpenAPI{{
+		Paths: map[string]*pb.OpenAPIPath{
+			"/api/signup": {Post: &pb.OpenAPIPathDocs{
+				OperationId: "AccountSignup",
+				RequestBody: &pb.PathRequestBody{Content: &pb.PathRequestBodyContent{
+					ApplicationJson: &pb.ApplicationContent{Schema: &pb.Schema{Ref: "#/components/schemas/account.SignupRequest"}},
+				}},
+			}},
+		},
+		Components: &pb.OpenAPIComponents{Schemas: map[string]*pb.Model{
+			"account.SignupRequest": {
+				Type: "object",
+				Properties: map[string]*pb.Schema{
+					"name":  {Type: "string"},
+					"email": {Type: "string", Format: "email"},
+					"age":   {Type: "integer", Format: "int32", Minimum: 18},
+				},
+				Required: []string{"name"},
+			},
+		}},
+	}}
+	return nil
+}
+
+func (d *accountDoc) GetEndpoint(ctx context.Context, req *pb.GetEndpointRequest, rsp *pb.GetEndpointResponse) error {
+	return nil
+}
+
+func TestValidateRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	account := &Account{}
+	r := regMemory.NewRegistry()
+	s := smemory.NewServer(
+		server.Name("account"),
+		server.Registry(r),
+		server.Broker(membroker.NewBroker()),
+		server.WrapHandler(wrapper.ValidateHandler()),
+	)
+	if err := s.Handle(s.NewHandler(account)); err != nil {
+		t.Fatal(err)
+	}
+	if err := pb.RegisterOpenAPIServiceHandler(s, &accountDoc{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	services, err := r.GetService(context.TODO(), "account")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := &api.Service{
+		Name:     "account",
+		Endpoint: &api.Endpoint{Name: "Account.Signup"},
+		Services: services,
+	}
+
+	serve := func(h handler.Handler, body string) *errors.Error {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/signup", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		h.Handle(c)
+		if w.Code == http.StatusOK {
+			return nil
+		}
+		return errors.Parse(w.Body.String())
+	}
+
+	h := WithService(svc, handler.WithClient(cmemory.NewClient()), handler.WithValidation())
+	assert.Nil(t, serve(h, `{"name":"vine","age":20}`))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&account.calls))
+
+	// the gateway rejects the request without calling the service
+	e := serve(h, `{"email":"vine","age":3}`)
+	if assert.NotNil(t, e) {
+		assert.Equal(t, errors.StatusBadRequest, e.Code)
+		assert.Equal(t, []*errors.Violation{
+			{Field: "age", Rule: "gte", Value: "3"},
+			{Field: "email", Rule: "email", Value: "vine"},
+			{Field: "name", Rule: "required"},
+		}, e.Violations)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&account.calls))
+
+	// the server wrapper catches what the gateway lets through
+	h = WithService(svc, handler.WithClient(cmemory.NewClient()))
+	e = serve(h, `{"email":"vine"}`)
+	if assert.NotNil(t, e) {
+		assert.Equal(t, errors.StatusBadRequest, e.Code)
+		assert.Equal(t, []*errors.Violation{
+			{Field: "name", Rule: "required"},
+			{Field: "email", Rule: "email", Value: "vine"},
+		}, e.Violations)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&account.calls))
+}
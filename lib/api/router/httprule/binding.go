@@ -0,0 +1,131 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package httprule
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Binding binds an http method and path to an rpc method, like a
+// google.api.HttpRule or one of its additional bindings.
+type Binding struct {
+	Methods []string
+	// Body is the request field the body maps to, "*" or "" for the whole request
+	Body string
+	// ResponseBody is the response field sent as the body, "" for the whole response
+	ResponseBody string
+
+	patterns []Pattern
+	regexps  []*regexp.Regexp
+}
+
+// NewBinding compiles the paths, they are path templates or POSIX
+// regexps anchored with ^ and $
+func NewBinding(methods, paths []string, body, responseBody string) (*Binding, error) {
+	b := &Binding{Methods: methods, Body: body, ResponseBody: responseBody}
+
+	for _, p := range paths {
+		var pcreok bool
+
+		// pcre only when we have start and end markers
+		if len(p) > 0 && p[0] == '^' && p[len(p)-1] == '$' {
+			pcrereg, err := regexp.CompilePOSIX(p)
+			if err == nil {
+				b.regexps = append(b.regexps, pcrereg)
+				pcreok = true
+			}
+		}
+
+		rule, err := Parse(p)
+		if err != nil && !pcreok {
+			return nil, err
+		} else if err != nil && pcreok {
+			continue
+		}
+
+		tpl := rule.Compile()
+		pattern, err := NewPattern(tpl.Version, tpl.OpCodes, tpl.Pool, tpl.Verb)
+		if err != nil {
+			return nil, err
+		}
+		b.patterns = append(b.patterns, pattern)
+	}
+
+	return b, nil
+}
+
+// Split splits the path of a request into its components and verb
+func Split(path string) ([]string, string) {
+	var idx int
+	if len(path) > 0 && path != "/" {
+		idx = 1
+	}
+	components := strings.Split(path[idx:], "/")
+
+	var verb string
+	last := components[len(components)-1]
+	if i := strings.LastIndex(last, ":"); i > 0 {
+		components[len(components)-1], verb = last[:i], last[i+1:]
+	}
+	return components, verb
+}
+
+// Match returns the path variables when the binding matches the request
+func (b *Binding) Match(method, path string) (map[string]string, bool) {
+	var ok bool
+	for _, m := range b.Methods {
+		if m == method {
+			ok = true
+			break
+		}
+	}
+	if !ok {
+		return nil, false
+	}
+
+	components, verb := Split(path)
+	for _, p := range b.patterns {
+		if vars, err := p.Match(components, verb); err == nil {
+			return vars, true
+		}
+		// the colon may belong to the last component rather than a verb
+		if verb != "" {
+			last := components[len(components)-1]
+			components[len(components)-1] = last + ":" + verb
+			vars, err := p.Match(components, "")
+			components[len(components)-1] = last
+			if err == nil {
+				return vars, true
+			}
+		}
+	}
+
+	for _, r := range b.regexps {
+		if r.MatchString(path) {
+			return map[string]string{}, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,61 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package httprule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBinding(t *testing.T) {
+	b, err := NewBinding([]string{"GET"}, []string{"/v1/{name=messages/*}", "/v1/users/{id}:watch", "^/legacy/.*$"}, "", "")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, spec := range []struct {
+		method string
+		path   string
+		vars   map[string]string
+		ok     bool
+	}{
+		{method: "GET", path: "/v1/messages/1", vars: map[string]string{"name": "messages/1"}, ok: true},
+		{method: "POST", path: "/v1/messages/1"},
+		{method: "GET", path: "/v1/users/1:watch", vars: map[string]string{"id": "1"}, ok: true},
+		{method: "GET", path: "/v1/users/1"},
+		{method: "GET", path: "/v1/users/1:list"},
+		// the colon belongs to the variable when there is no verb
+		{method: "GET", path: "/v1/messages/a:b", vars: map[string]string{"name": "messages/a:b"}, ok: true},
+		{method: "GET", path: "/legacy/a/b", vars: map[string]string{}, ok: true},
+	} {
+		vars, ok := b.Match(spec.method, spec.path)
+		assert.Equal(t, spec.ok, ok, spec.path)
+		if spec.ok {
+			assert.Equal(t, spec.vars, vars, spec.path)
+		}
+	}
+
+	_, err = NewBinding([]string{"GET"}, []string{"/v1/{name"}, "", "")
+	assert.Error(t, err)
+}
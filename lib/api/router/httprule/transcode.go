@@ -0,0 +1,568 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package httprule
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+// Kind is the json mapping of a field, as defined by the proto3 json encoding
+type Kind int
+
+const (
+	KindString Kind = iota
+	KindBool
+	KindInt
+	KindFloat
+	KindBytes
+	// KindEnum values are encoded by name or by number
+	KindEnum
+	// KindTimestamp is a google.protobuf.Timestamp encoded as RFC 3339
+	KindTimestamp
+	// KindDuration is a google.protobuf.Duration encoded as seconds with an "s" suffix
+	KindDuration
+	// KindFieldMask is a google.protobuf.FieldMask encoded as comma separated paths
+	KindFieldMask
+	// KindJSON is a google.protobuf.Struct, Value or ListValue, any json value
+	KindJSON
+	KindMessage
+)
+
+// Field describes a field of a message, wrapper types are described by the
+// kind of the value they wrap
+type Field struct {
+	// Name is the json name of the field
+	Name     string
+	Kind     Kind
+	Repeated bool
+	// Map fields have string keys and values described by Kind and Message
+	Map bool
+	// Message describes the fields of Message kinds
+	Message *Message
+}
+
+// Message describes the fields of a message by json name
+type Message struct {
+	Fields map[string]*Field
+}
+
+// Lookup finds a field by its json name or by its proto name
+func (m *Message) Lookup(name string) *Field {
+	if m == nil {
+		return nil
+	}
+	if f, ok := m.Fields[name]; ok {
+		return f
+	}
+	return m.Fields[jsonName(name)]
+}
+
+// jsonName converts a proto field name to its json name the way protoc does
+func jsonName(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper && r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Request is the http request transcoded to a message
+type Request struct {
+	// Vars are the path variables matched by the binding
+	Vars map[string]string
+	// Query holds the query parameters
+	Query url.Values
+	// Body is nil when the request has no body, like GET requests
+	Body []byte
+	// BodyField is the body of the binding, "*" or "" for the whole message
+	BodyField string
+}
+
+// Transcode builds the json encoding of the message from the request following
+// google.api.http: the body is decoded first, then the path variables, then the
+// query parameters for the fields which are neither bound by the path nor by the
+// body. When the body maps to the whole message the query parameters are ignored.
+// Unknown query parameters are ignored.
+func Transcode(msg *Message, req *Request) ([]byte, error) {
+	out := map[string]interface{}{}
+
+	bodyField := req.BodyField
+	if bodyField == "" {
+		bodyField = "*"
+	}
+
+	var bodyPath []string
+	if req.Body != nil {
+		if bodyField == "*" {
+			if len(bytes.TrimSpace(req.Body)) > 0 {
+				v, err := decode(req.Body)
+				if err != nil {
+					return nil, err
+				}
+				if v != nil {
+					m, ok := v.(map[string]interface{})
+					if !ok {
+						return nil, fmt.Errorf("invalid body, expected a json object")
+					}
+					out = m
+				}
+			}
+		} else {
+			var err error
+			bodyPath, err = setBody(msg, out, bodyField, req.Body)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	names := make([]string, 0, len(req.Vars))
+	for name := range req.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bound := make([][]string, 0, len(names)+1)
+	for _, name := range names {
+		segs, err := parseKey(name)
+		if err != nil {
+			return nil, err
+		}
+		p, err := set(msg, out, segs, []string{req.Vars[name]}, true)
+		if err != nil {
+			return nil, err
+		}
+		bound = append(bound, p)
+	}
+
+	if req.Body == nil || bodyField != "*" {
+		if bodyPath != nil {
+			bound = append(bound, bodyPath)
+		}
+
+		keys := make([]string, 0, len(req.Query))
+		for key := range req.Query {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if !known(msg, key) {
+				continue
+			}
+			segs, err := parseKey(key)
+			if err != nil {
+				return nil, err
+			}
+			if isBound(msg, segs, bound) {
+				continue
+			}
+			if _, err = set(msg, out, segs, req.Query[key], false); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return json.Marshal(out)
+}
+
+// ResponseBody selects the field of the response sent as the http body, unset
+// fields are written as their zero value
+func ResponseBody(msg *Message, rsp []byte, field string) ([]byte, error) {
+	if field == "" || field == "*" {
+		return rsp, nil
+	}
+
+	var v interface{}
+	if len(bytes.TrimSpace(rsp)) > 0 {
+		var err error
+		if v, err = decode(rsp); err != nil {
+			return nil, err
+		}
+	}
+
+	var f *Field
+	for _, name := range strings.Split(field, ".") {
+		f = msg.Lookup(name)
+		if f != nil {
+			name = f.Name
+			msg = f.Message
+		} else {
+			msg = nil
+		}
+
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			v = nil
+			continue
+		}
+		if v, ok = m[name]; !ok {
+			// proto names are accepted by json decoders
+			v = m[jsonName(name)]
+		}
+	}
+
+	if v == nil {
+		v = zero(f)
+	}
+	return json.Marshal(v)
+}
+
+// zero is the json value of an unset field
+func zero(f *Field) interface{} {
+	if f == nil {
+		return nil
+	}
+	switch {
+	case f.Repeated:
+		return []interface{}{}
+	case f.Map:
+		return map[string]interface{}{}
+	}
+	switch f.Kind {
+	case KindString, KindBytes:
+		return ""
+	case KindBool:
+		return false
+	case KindInt, KindFloat, KindEnum:
+		return 0
+	}
+	return nil
+}
+
+func decode(b []byte) (interface{}, error) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid body: %v", err)
+	}
+	return v, nil
+}
+
+// fieldSegment is a component of a field path, map entries are written as name[key]
+type fieldSegment struct {
+	name   string
+	key    string
+	hasKey bool
+}
+
+func parseKey(key string) ([]fieldSegment, error) {
+	var segs []fieldSegment
+	for _, part := range strings.Split(key, ".") {
+		s := fieldSegment{name: part}
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			if !strings.HasSuffix(part, "]") || i == 0 {
+				return nil, fmt.Errorf("invalid field path %q", key)
+			}
+			s = fieldSegment{name: part[:i], key: part[i+1 : len(part)-1], hasKey: true}
+		}
+		if s.name == "" {
+			return nil, fmt.Errorf("invalid field path %q", key)
+		}
+		segs = append(segs, s)
+	}
+	return segs, nil
+}
+
+// known reports whether the query key names a field of the message, the key
+// is read leniently so that malformed unknown parameters are ignored as well
+func known(msg *Message, key string) bool {
+	for _, part := range strings.Split(key, ".") {
+		if msg == nil {
+			return true
+		}
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			part = part[:i]
+		}
+		f := msg.Lookup(part)
+		if f == nil {
+			return false
+		}
+		msg = f.Message
+	}
+	return true
+}
+
+// isBound reports whether the field path is bound by a path variable or by the body
+func isBound(msg *Message, segs []fieldSegment, bound [][]string) bool {
+	names := make([]string, 0, len(segs))
+	for _, s := range segs {
+		f := msg.Lookup(s.name)
+		if f == nil {
+			names = append(names, s.name)
+			msg = nil
+			continue
+		}
+		names = append(names, f.Name)
+		msg = f.Message
+	}
+
+	for _, b := range bound {
+		if len(b) > len(names) {
+			continue
+		}
+		match := true
+		for i := range b {
+			if b[i] != names[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// child returns the object stored under name, replacing other values
+func child(out map[string]interface{}, name string) map[string]interface{} {
+	if m, ok := out[name].(map[string]interface{}); ok {
+		return m
+	}
+	m := map[string]interface{}{}
+	out[name] = m
+	return m
+}
+
+func setBody(msg *Message, out map[string]interface{}, field string, body []byte) ([]string, error) {
+	segs, err := parseKey(field)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if v, err = decode(body); err != nil {
+			return nil, err
+		}
+	}
+
+	path := make([]string, 0, len(segs))
+	for i, s := range segs {
+		name := s.name
+		f := msg.Lookup(name)
+		if f != nil {
+			name = f.Name
+			msg = f.Message
+		} else {
+			msg = nil
+		}
+		path = append(path, name)
+
+		if i < len(segs)-1 {
+			out = child(out, name)
+			continue
+		}
+		if v != nil {
+			out[name] = v
+		}
+	}
+	return path, nil
+}
+
+// set writes the values to the field path, it returns the json names of the path
+func set(msg *Message, out map[string]interface{}, segs []fieldSegment, values []string, fromPath bool) ([]string, error) {
+	path := make([]string, 0, len(segs))
+	for i, s := range segs {
+		last := i == len(segs)-1
+
+		f := msg.Lookup(s.name)
+		if f == nil {
+			// without a description the value is kept as a string
+			path = append(path, s.name)
+			if !last {
+				out = child(out, s.name)
+				continue
+			}
+			if len(values) == 1 {
+				out[s.name] = values[0]
+			} else {
+				out[s.name] = values
+			}
+			return path, nil
+		}
+		path = append(path, f.Name)
+		msg = f.Message
+
+		if s.hasKey {
+			if !f.Map {
+				return nil, fmt.Errorf("field %q is not a map", s.name)
+			}
+			entries := child(out, f.Name)
+			if !last {
+				if f.Kind != KindMessage {
+					return nil, fmt.Errorf("field %q is not a message map", s.name)
+				}
+				out = child(entries, s.key)
+				continue
+			}
+			if len(values) > 1 {
+				return nil, fmt.Errorf("too many values for field %q", s.name)
+			}
+			v, err := scalar(f, values[0])
+			if err != nil {
+				return nil, err
+			}
+			entries[s.key] = v
+			return path, nil
+		}
+
+		if !last {
+			if f.Kind != KindMessage || f.Repeated || f.Map {
+				return nil, fmt.Errorf("field %q is not a message", s.name)
+			}
+			out = child(out, f.Name)
+			continue
+		}
+
+		if f.Map {
+			return nil, fmt.Errorf("map field %q needs a key, like %s[key]", s.name, s.name)
+		}
+		if f.Kind == KindMessage {
+			return nil, fmt.Errorf("field %q is a message", s.name)
+		}
+
+		if f.Repeated {
+			if fromPath {
+				values = strings.Split(values[0], ",")
+			}
+			list := make([]interface{}, 0, len(values))
+			for _, value := range values {
+				v, err := scalar(f, value)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			out[f.Name] = list
+			return path, nil
+		}
+
+		if len(values) > 1 {
+			if f.Kind != KindFieldMask {
+				return nil, fmt.Errorf("too many values for field %q", s.name)
+			}
+			values = []string{strings.Join(values, ",")}
+		}
+		v, err := scalar(f, values[0])
+		if err != nil {
+			return nil, err
+		}
+		out[f.Name] = v
+	}
+	return path, nil
+}
+
+// scalar converts the string to the json value of the field
+func scalar(f *Field, s string) (interface{}, error) {
+	invalid := func() error {
+		return fmt.Errorf("invalid value %q for field %q", s, f.Name)
+	}
+
+	switch f.Kind {
+	case KindBool:
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, invalid()
+		}
+		return v, nil
+	case KindInt:
+		if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return json.Number(strconv.FormatInt(v, 10)), nil
+		}
+		if v, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return json.Number(strconv.FormatUint(v, 10)), nil
+		}
+		return nil, invalid()
+	case KindFloat:
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, invalid()
+		}
+		switch {
+		case math.IsNaN(v):
+			return "NaN", nil
+		case math.IsInf(v, 1):
+			return "Infinity", nil
+		case math.IsInf(v, -1):
+			return "-Infinity", nil
+		}
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64)), nil
+	case KindEnum:
+		if v, err := strconv.ParseInt(s, 10, 32); err == nil {
+			return json.Number(strconv.FormatInt(v, 10)), nil
+		}
+		if s == "" {
+			return nil, invalid()
+		}
+		return s, nil
+	case KindBytes:
+		for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+			if b, err := enc.DecodeString(s); err == nil {
+				return base64.StdEncoding.EncodeToString(b), nil
+			}
+		}
+		return nil, invalid()
+	case KindTimestamp:
+		if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+			return nil, invalid()
+		}
+		return s, nil
+	case KindDuration:
+		if !strings.HasSuffix(s, "s") {
+			return nil, invalid()
+		}
+		if _, err := strconv.ParseFloat(strings.TrimSuffix(s, "s"), 64); err != nil {
+			return nil, invalid()
+		}
+		return s, nil
+	case KindJSON:
+		if json.Valid([]byte(s)) {
+			return json.RawMessage(s), nil
+		}
+		// a google.protobuf.Value holding a string
+		return s, nil
+	}
+	return s, nil
+}
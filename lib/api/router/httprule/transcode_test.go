@@ -0,0 +1,249 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package httprule
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testMessage mirrors the message grpc-gateway uses for its query parameter tests
+func testMessage() *Message {
+	nested := &Message{Fields: map[string]*Field{
+		"name":   {Name: "name", Kind: KindString},
+		"amount": {Name: "amount", Kind: KindInt},
+	}}
+	nested.Fields["nested"] = &Field{Name: "nested", Kind: KindMessage, Message: nested}
+
+	return &Message{Fields: map[string]*Field{
+		"name":              {Name: "name", Kind: KindString},
+		"floatValue":        {Name: "floatValue", Kind: KindFloat},
+		"int64Value":        {Name: "int64Value", Kind: KindInt},
+		"boolValue":         {Name: "boolValue", Kind: KindBool},
+		"bytesValue":        {Name: "bytesValue", Kind: KindBytes},
+		"enumValue":         {Name: "enumValue", Kind: KindEnum},
+		"repeatedValue":     {Name: "repeatedValue", Kind: KindString, Repeated: true},
+		"repeatedEnum":      {Name: "repeatedEnum", Kind: KindEnum, Repeated: true},
+		"timestampValue":    {Name: "timestampValue", Kind: KindTimestamp},
+		"durationValue":     {Name: "durationValue", Kind: KindDuration},
+		"updateMask":        {Name: "updateMask", Kind: KindFieldMask},
+		"structValue":       {Name: "structValue", Kind: KindJSON},
+		"valueValue":        {Name: "valueValue", Kind: KindJSON},
+		"wrapperInt32Value": {Name: "wrapperInt32Value", Kind: KindInt},
+		"mapValue":          {Name: "mapValue", Kind: KindString, Map: true},
+		"mapMessage":        {Name: "mapMessage", Kind: KindMessage, Map: true, Message: nested},
+		"nested":            {Name: "nested", Kind: KindMessage, Message: nested},
+		"nestedList":        {Name: "nestedList", Kind: KindMessage, Repeated: true, Message: nested},
+	}}
+}
+
+func TestTranscodeQuery(t *testing.T) {
+	for _, spec := range []struct {
+		query string
+		want  string
+		err   bool
+	}{
+		{query: "", want: `{}`},
+		{query: "name=foo", want: `{"name":"foo"}`},
+		// proto names are accepted
+		{query: "float_value=1.5&int64_value=-9007199254740993", want: `{"floatValue":1.5,"int64Value":-9007199254740993}`},
+		{query: "floatValue=NaN", want: `{"floatValue":"NaN"}`},
+		{query: "floatValue=-Inf", want: `{"floatValue":"-Infinity"}`},
+		{query: "boolValue=true", want: `{"boolValue":true}`},
+		{query: "boolValue=1", want: `{"boolValue":true}`},
+		{query: "boolValue=yes", err: true},
+		{query: "int64Value=1.5", err: true},
+		{query: "bytesValue=YWJjMTIzIT8kKiYoKSctPUB-", want: `{"bytesValue":"YWJjMTIzIT8kKiYoKSctPUB+"}`},
+		{query: "bytesValue=YWJjMTIzIT8kKiYoKSctPUB%2B", want: `{"bytesValue":"YWJjMTIzIT8kKiYoKSctPUB+"}`},
+		// enums by number or by name
+		{query: "enumValue=1", want: `{"enumValue":1}`},
+		{query: "enumValue=Z", want: `{"enumValue":"Z"}`},
+		{query: "repeatedEnum=1&repeatedEnum=Z", want: `{"repeatedEnum":[1,"Z"]}`},
+		// repeated fields take every value, singular fields only one
+		{query: "repeatedValue=a&repeatedValue=b&repeatedValue=c", want: `{"repeatedValue":["a","b","c"]}`},
+		{query: "name=a&name=b", err: true},
+		// well known types
+		{query: "timestampValue=2016-12-15T05:35:32.000000009Z", want: `{"timestampValue":"2016-12-15T05:35:32.000000009Z"}`},
+		{query: "timestampValue=2016-12-15", err: true},
+		{query: "durationValue=13600.5s", want: `{"durationValue":"13600.5s"}`},
+		{query: "durationValue=1h", err: true},
+		{query: "updateMask=name,nested.amount", want: `{"updateMask":"name,nested.amount"}`},
+		{query: "updateMask=name&updateMask=nested.amount", want: `{"updateMask":"name,nested.amount"}`},
+		{query: `structValue={"a":{"b":1}}`, want: `{"structValue":{"a":{"b":1}}}`},
+		{query: "valueValue=bar", want: `{"valueValue":"bar"}`},
+		{query: "valueValue=[1,2]", want: `{"valueValue":[1,2]}`},
+		{query: "wrapperInt32Value=12", want: `{"wrapperInt32Value":12}`},
+		// nested messages and maps
+		{query: "nested.name=a&nested.nested.amount=2", want: `{"nested":{"name":"a","nested":{"amount":2}}}`},
+		{query: "mapValue[a]=1&mapValue[b]=2", want: `{"mapValue":{"a":"1","b":"2"}}`},
+		{query: "mapMessage[a].amount=3", want: `{"mapMessage":{"a":{"amount":3}}}`},
+		{query: "mapValue=a", err: true},
+		{query: "nested=a", err: true},
+		{query: "name.foo=a", err: true},
+		{query: "nestedList.name=a", err: true},
+		// unknown parameters are ignored
+		{query: "api_key=secret&nested.unknown=1", want: `{}`},
+		{query: "x[=1&a..b=1&nested..name=1&name=foo", want: `{"name":"foo"}`},
+		{query: "mapValue[=1", err: true},
+	} {
+		query, err := url.ParseQuery(spec.query)
+		if !assert.NoError(t, err, spec.query) {
+			continue
+		}
+		out, err := Transcode(testMessage(), &Request{Query: query})
+		if spec.err {
+			assert.Error(t, err, spec.query)
+			continue
+		}
+		if assert.NoError(t, err, spec.query) {
+			assert.JSONEq(t, spec.want, string(out), spec.query)
+		}
+	}
+}
+
+func TestTranscodeBinding(t *testing.T) {
+	for _, spec := range []struct {
+		name string
+		req  *Request
+		want string
+		err  bool
+	}{
+		{
+			name: "path variables win over the body",
+			req: &Request{
+				Vars:      map[string]string{"name": "foo", "nested.amount": "2"},
+				Body:      []byte(`{"name":"bar","nested":{"name":"a","amount":1},"boolValue":true}`),
+				BodyField: "*",
+			},
+			want: `{"name":"foo","nested":{"name":"a","amount":2},"boolValue":true}`,
+		},
+		{
+			name: "query is ignored when the body is the whole message",
+			req: &Request{
+				Query:     url.Values{"boolValue": {"true"}},
+				Body:      []byte(`{"name":"bar"}`),
+				BodyField: "*",
+			},
+			want: `{"name":"bar"}`,
+		},
+		{
+			name: "body field",
+			req: &Request{
+				Vars:      map[string]string{"name": "foo"},
+				Query:     url.Values{"boolValue": {"true"}, "nested.name": {"b"}, "name": {"c"}},
+				Body:      []byte(`{"name":"a","amount":1}`),
+				BodyField: "nested",
+			},
+			want: `{"name":"foo","nested":{"name":"a","amount":1},"boolValue":true}`,
+		},
+		{
+			name: "body field by proto name",
+			req: &Request{
+				Body:      []byte(`[1,2]`),
+				BodyField: "value_value",
+			},
+			want: `{"valueValue":[1,2]}`,
+		},
+		{
+			name: "repeated path variable",
+			req: &Request{
+				Vars: map[string]string{"repeatedValue": "a,b"},
+			},
+			want: `{"repeatedValue":["a","b"]}`,
+		},
+		{
+			name: "typed path variable",
+			req: &Request{
+				Vars: map[string]string{"int64Value": "a"},
+			},
+			err: true,
+		},
+		{
+			name: "unknown path variable",
+			req: &Request{
+				Vars: map[string]string{"id": "1"},
+			},
+			want: `{"id":"1"}`,
+		},
+		{
+			name: "empty body",
+			req: &Request{
+				Vars:      map[string]string{"name": "foo"},
+				Body:      []byte{},
+				BodyField: "*",
+			},
+			want: `{"name":"foo"}`,
+		},
+		{
+			name: "invalid body",
+			req: &Request{
+				Body:      []byte(`[1]`),
+				BodyField: "*",
+			},
+			err: true,
+		},
+	} {
+		out, err := Transcode(testMessage(), spec.req)
+		if spec.err {
+			assert.Error(t, err, spec.name)
+			continue
+		}
+		if assert.NoError(t, err, spec.name) {
+			assert.JSONEq(t, spec.want, string(out), spec.name)
+		}
+	}
+}
+
+func TestResponseBody(t *testing.T) {
+	rsp := []byte(`{"name":"foo","nested":{"amount":"9007199254740993"},"repeatedValue":["a"]}`)
+	for _, spec := range []struct {
+		field string
+		want  string
+	}{
+		{field: "", want: string(rsp)},
+		{field: "*", want: string(rsp)},
+		{field: "name", want: `"foo"`},
+		{field: "repeated_value", want: `["a"]`},
+		{field: "nested", want: `{"amount":"9007199254740993"}`},
+		{field: "nested.amount", want: `"9007199254740993"`},
+		// unset fields are written as zero values
+		{field: "boolValue", want: `false`},
+		{field: "nestedList", want: `[]`},
+		{field: "mapValue", want: `{}`},
+		{field: "timestampValue", want: `null`},
+		{field: "nested.name", want: `""`},
+		{field: "nestedList.amount", want: `0`},
+	} {
+		out, err := ResponseBody(testMessage(), rsp, spec.field)
+		if assert.NoError(t, err, spec.field) {
+			assert.JSONEq(t, spec.want, string(out), spec.field)
+		}
+	}
+
+	out, err := ResponseBody(testMessage(), nil, "repeatedValue")
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `[]`, string(out))
+	}
+}
@@ -33,6 +33,7 @@ type Options struct {
 	Handler  string
 	Registry registry.Registry
 	Resolver resolver.Resolver
+	Splitter Splitter
 }
 
 type Option func(o *Options)
@@ -77,3 +78,10 @@ func WithResolver(r resolver.Resolver) Option {
 		o.Resolver = r
 	}
 }
+
+// WithSplitter splits the traffic of routed services between versions
+func WithSplitter(s Splitter) Option {
+	return func(o *Options) {
+		o.Splitter = s
+	}
+}
@@ -29,7 +29,6 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
-	"strings"
 	"sync"
 	"time"
 
@@ -45,8 +44,8 @@ import (
 // endpoint struct, that holds compiled pcre
 type endpoint struct {
 	hostregs []*regexp.Regexp
-	pathregs []httprule.Pattern
-	pcreregs []*regexp.Regexp
+	// the http rule of the endpoint followed by its additional bindings
+	bindings []*httprule.Binding
 }
 
 // router is the default router
@@ -199,32 +198,14 @@ func (r *registryRouter) store(services []*registry.Service) {
 			cep.hostregs = append(cep.hostregs, hostreg)
 		}
 
-		for _, p := range ep.Endpoint.Path {
-			var pcreok bool
-
-			if p[0] == '^' && p[len(p)-1] == '$' {
-				pcrereg, err := regexp.CompilePOSIX(p)
-				if err == nil {
-					cep.pcreregs = append(cep.pcreregs, pcrereg)
-					pcreok = true
-				}
-			}
-
-			rule, err := httprule.Parse(p)
-			if err != nil && !pcreok {
-				logger.Tracef("endpoint have invalid path pattern: %+v", err)
-				continue
-			} else if err != nil && pcreok {
-				continue
-			}
-
-			tpl := rule.Compile()
-			pathreg, err := httprule.NewPattern(tpl.Version, tpl.OpCodes, tpl.Pool, "")
+		rules := append([]*api.Endpoint{ep.Endpoint}, ep.Endpoint.AdditionalBindings...)
+		for _, rule := range rules {
+			b, err := httprule.NewBinding(rule.Method, rule.Path, rule.Body, rule.ResponseBody)
 			if err != nil {
 				logger.Tracef("endpoint have invalid path pattern: %+v", err)
 				continue
 			}
-			cep.pathregs = append(cep.pathregs, pathreg)
+			cep.bindings = append(cep.bindings, b)
 		}
 
 		r.ceps[name] = cep
@@ -307,13 +288,6 @@ func (r *registryRouter) Endpoint(req *http.Request) (*api.Service, error) {
 	r.RLock()
 	defer r.RUnlock()
 
-	var idx int
-	path := req.URL.Path
-	if len(path) > 0 && path != "/" {
-		idx = 1
-	}
-	paths := strings.Split(path[idx:], "/")
-
 	// use the first match
 	// TODO: weighted matching
 	for n, e := range r.eps {
@@ -322,20 +296,8 @@ func (r *registryRouter) Endpoint(req *http.Request) (*api.Service, error) {
 			continue
 		}
 		ep := e.Endpoint
-		var mMatch, hMatch, pMatch bool
-		// 1. try method
-		for _, m := range ep.Method {
-			if m == req.Method {
-				mMatch = true
-				break
-			}
-		}
-		if !mMatch {
-			continue
-		}
-		logger.Debugf("api method match %s", req.Method)
-
-		// 2. try host
+		var hMatch bool
+		// 1. try host
 		if len(ep.Host) == 0 {
 			hMatch = true
 		} else {
@@ -356,15 +318,15 @@ func (r *registryRouter) Endpoint(req *http.Request) (*api.Service, error) {
 		}
 		logger.Debugf("api host match %s", req.Host)
 
-		// 3. try path via google.api path matching
-		for _, pathreg := range cep.pathregs {
-			matches, err := pathreg.Match(paths, "")
-			if err != nil {
-				logger.Debugf("api gpath not match %s != %v", path, pathreg)
+		// 2. try the method and path of each binding via google.api path
+		// matching, then pcre path matching
+		for _, b := range cep.bindings {
+			matches, ok := b.Match(req.Method, req.URL.Path)
+			if !ok {
 				continue
 			}
-			logger.Debugf("api gpath match %s = %v", path, pathreg)
-			pMatch = true
+			logger.Debugf("api path match %s %s", req.Method, req.URL.Path)
+
 			ctx := req.Context()
 			md, ok := metadata.FromContext(ctx)
 			if !ok {
@@ -373,31 +335,13 @@ func (r *registryRouter) Endpoint(req *http.Request) (*api.Service, error) {
 			for k, v := range matches {
 				md.Set("x-api-field-"+k, v)
 			}
-			md.Set("x-api-body", ep.Body)
+			md.Set("x-api-body", b.Body)
+			md.Set("x-api-response-body", b.ResponseBody)
 			*req = *req.Clone(metadata.NewContext(ctx, md))
-			break
-		}
-
-		if !pMatch {
-			// 4. try path via pcre path matching
-			for _, pathreg := range cep.pcreregs {
-				if !pathreg.MatchString(req.URL.Path) {
-					logger.Debugf("api pcre path not match %s != %v", path, pathreg)
-					continue
-				}
-				logger.Debugf("api pcre path match %s != %v", path, pathreg)
-				pMatch = true
-				break
-			}
-		}
 
-		if !pMatch {
-			continue
+			// we got here, so its a match
+			return e, nil
 		}
-
-		// TODO: Percentage traffic
-		// we got here, so its a match
-		return e, nil
 	}
 
 	// no match
@@ -409,6 +353,19 @@ func (r *registryRouter) Route(req *http.Request) (*api.Service, error) {
 		return nil, errors.New("router closed")
 	}
 
+	svc, err := r.route(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// split the traffic between versions
+	if r.opts.Splitter != nil {
+		svc = r.opts.Splitter.Split(req, svc)
+	}
+	return svc, nil
+}
+
+func (r *registryRouter) route(req *http.Request) (*api.Service, error) {
 	// try to get an endpoint
 	ep, err := r.Endpoint(req)
 	if err == nil {
@@ -465,6 +422,19 @@ func (r *registryRouter) Route(req *http.Request) (*api.Service, error) {
 			},
 			Services: services,
 		}, nil
+	// grpc proxy handler
+	case "grpc":
+		// construct api service
+		return &api.Service{
+			Name: name,
+			Endpoint: &api.Endpoint{
+				Name:    rp.Path,
+				Handler: r.opts.Handler,
+				Host:    []string{req.Host},
+				Path:    []string{rp.Path},
+			},
+			Services: services,
+		}, nil
 	}
 
 	return nil, errors.New("unknown handler")
@@ -23,11 +23,13 @@
 package registry
 
 import (
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/vine-io/vine/core/registry"
+	"github.com/vine-io/vine/util/context/metadata"
 )
 
 func TestStoreRegex(t *testing.T) {
@@ -53,5 +55,53 @@ func TestStoreRegex(t *testing.T) {
 	},
 	)
 
-	assert.Len(t, router.ceps["Foobar.foo"].pcreregs, 1)
+	assert.Len(t, router.ceps["Foobar.foo"].bindings, 1)
+	_, ok := router.ceps["Foobar.foo"].bindings[0].Match("POST", "/foo/")
+	assert.True(t, ok)
+}
+
+func TestAdditionalBindings(t *testing.T) {
+	router := newRouter()
+	router.store([]*registry.Service{
+		{
+			Name:    "Foobar",
+			Version: "latest",
+			Endpoints: []*registry.Endpoint{
+				{
+					Name: "foo",
+					Metadata: map[string]string{
+						"endpoint":            "Foobar.Get",
+						"method":              "GET",
+						"path":                "/v1/foo/{id}",
+						"response_body":       "foo",
+						"handler":             "rpc",
+						"additional_bindings": `[{"Method":["POST"],"Path":["/v1/foo:get"],"Body":"*"}]`,
+					},
+				},
+			},
+			Metadata: map[string]string{},
+		},
+	},
+	)
+
+	r, _ := http.NewRequest("GET", "/v1/foo/1", nil)
+	s, err := router.Endpoint(r)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Foobar.Get", s.Endpoint.Name)
+		md, _ := metadata.FromContext(r.Context())
+		assert.Equal(t, "1", md["x-api-field-id"])
+		assert.Equal(t, "foo", md["x-api-response-body"])
+	}
+
+	r, _ = http.NewRequest("POST", "/v1/foo:get", nil)
+	_, err = router.Endpoint(r)
+	if assert.NoError(t, err) {
+		md, _ := metadata.FromContext(r.Context())
+		assert.Equal(t, "*", md["x-api-body"])
+		assert.Equal(t, "", md["x-api-response-body"])
+	}
+
+	r, _ = http.NewRequest("POST", "/v1/foo/1", nil)
+	_, err = router.Endpoint(r)
+	assert.Error(t, err)
 }
@@ -44,3 +44,9 @@ type Router interface {
 	// Route returns an api.Service route
 	Route(r *http.Request) (*api.Service, error)
 }
+
+// Splitter divides the traffic of a routed service between its versions
+type Splitter interface {
+	// Split returns the service narrowed to the versions chosen for the request
+	Split(r *http.Request, svc *api.Service) *api.Service
+}
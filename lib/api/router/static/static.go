@@ -41,8 +41,8 @@ import (
 type endpoint struct {
 	apiep    *api.Endpoint
 	hostregs []*regexp.Regexp
-	pathregs []httprule.Pattern
-	pcreregs []*regexp.Regexp
+	// the http rule of the endpoint followed by its additional bindings
+	bindings []*httprule.Binding
 }
 
 // router is the default router
@@ -114,9 +114,8 @@ func (r *staticRouter) Register(ep *api.Endpoint) error {
 		return err
 	}
 
-	var pathregs []httprule.Pattern
 	var hostregs []*regexp.Regexp
-	var pcreregs []*regexp.Regexp
+	var bindings []*httprule.Binding
 
 	for _, h := range ep.Host {
 		if h == "" || h == "*" {
@@ -129,38 +128,19 @@ func (r *staticRouter) Register(ep *api.Endpoint) error {
 		hostregs = append(hostregs, hostreg)
 	}
 
-	for _, p := range ep.Path {
-		var pcreok bool
-
-		// pcre only when we have start and end markers
-		if p[0] == '^' && p[len(p)-1] == '$' {
-			pcrereg, err := regexp.CompilePOSIX(p)
-			if err == nil {
-				pcreregs = append(pcreregs, pcrereg)
-				pcreok = true
-			}
-		}
-
-		rule, err := httprule.Parse(p)
-		if err != nil && !pcreok {
-			return err
-		} else if err != nil && pcreok {
-			continue
-		}
-
-		tpl := rule.Compile()
-		pathreg, err := httprule.NewPattern(tpl.Version, tpl.OpCodes, tpl.Pool, "")
+	rules := append([]*api.Endpoint{ep}, ep.AdditionalBindings...)
+	for _, rule := range rules {
+		b, err := httprule.NewBinding(rule.Method, rule.Path, rule.Body, rule.ResponseBody)
 		if err != nil {
 			return err
 		}
-		pathregs = append(pathregs, pathreg)
+		bindings = append(bindings, b)
 	}
 
 	r.Lock()
 	r.eps[ep.Name] = &endpoint{
 		apiep:    ep,
-		pcreregs: pcreregs,
-		pathregs: pathregs,
+		bindings: bindings,
 		hostregs: hostregs,
 	}
 	r.Unlock()
@@ -231,6 +211,9 @@ func (r *staticRouter) Endpoint(req *http.Request) (*api.Service, error) {
 			Path:    ep.apiep.Path,
 			Body:    ep.apiep.Body,
 			Stream:  ep.apiep.Stream,
+
+			ResponseBody:       ep.apiep.ResponseBody,
+			AdditionalBindings: ep.apiep.AdditionalBindings,
 		},
 		Services: services,
 	}
@@ -246,31 +229,13 @@ func (r *staticRouter) endpoint(req *http.Request) (*endpoint, error) {
 	r.RLock()
 	defer r.RUnlock()
 
-	var idx int
-	if len(req.URL.Path) > 0 && req.URL.Path != "/" {
-		idx = 1
-	}
-	path := strings.Split(req.URL.Path[idx:], "/")
 	// use the first match
 	// TODO: weighted matching
 
 	for _, ep := range r.eps {
-		var mMatch, hMatch, pMatch bool
-
-		// 1. try method
-		for _, m := range ep.apiep.Method {
-			if m == req.Method {
-				mMatch = true
-				break
-			}
-		}
-		if !mMatch {
-			continue
-		}
+		var hMatch bool
 
-		logger.Debugf("api method match %s", req.Method)
-
-		// 2. try host
+		// 1. try host
 		if len(ep.apiep.Host) == 0 {
 			hMatch = true
 		} else {
@@ -291,17 +256,15 @@ func (r *staticRouter) endpoint(req *http.Request) (*endpoint, error) {
 		}
 		logger.Debugf("api host match %s", req.URL.Host)
 
-		// 3. try google.api path
-		for _, pathreg := range ep.pathregs {
-			matches, err := pathreg.Match(path, "")
-			if err != nil {
-
-				logger.Debugf("api gpath not match %s != %v", path, pathreg)
-
+		// 2. try the method and path of each binding via google.api path
+		// matching, then pcre path matching
+		for _, b := range ep.bindings {
+			matches, ok := b.Match(req.Method, req.URL.Path)
+			if !ok {
 				continue
 			}
-			logger.Debugf("api gpath match %s = %v", path, pathreg)
-			pMatch = true
+			logger.Debugf("api path match %s %s", req.Method, req.URL.Path)
+
 			ctx := req.Context()
 			md, ok := metadata.FromContext(ctx)
 			if !ok {
@@ -310,30 +273,15 @@ func (r *staticRouter) endpoint(req *http.Request) (*endpoint, error) {
 			for k, v := range matches {
 				md.Set("x-api-field-"+k, v)
 			}
-			md["x-api-body"] = ep.apiep.Body
+			md["x-api-body"] = b.Body
+			md["x-api-response-body"] = b.ResponseBody
 			*req = *req.Clone(metadata.NewContext(ctx, md))
-			break
-		}
 
-		if !pMatch {
-			// 4. try path via pcre path matching
-			for _, pathreg := range ep.pcreregs {
-				if !pathreg.MatchString(req.URL.Path) {
-					logger.Debugf("api pcre path not match %s != %v", req.URL.Path, pathreg)
-					continue
-				}
-				pMatch = true
-				break
-			}
-		}
+			// TODO: Percentage traffic
 
-		if !pMatch {
-			continue
+			// we got here, so its a match
+			return ep, nil
 		}
-		// TODO: Percentage traffic
-
-		// we got here, so its a match
-		return ep, nil
 	}
 
 	// no match
@@ -351,6 +299,11 @@ func (r *staticRouter) Route(req *http.Request) (*api.Service, error) {
 		return nil, err
 	}
 
+	// split the traffic between versions
+	if r.opts.Splitter != nil {
+		ep = r.opts.Splitter.Split(req, ep)
+	}
+
 	return ep, nil
 }
 
@@ -0,0 +1,286 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package traffic splits the traffic of a routed service between its versions
+package traffic
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/vine-io/vine/core/registry"
+	"github.com/vine-io/vine/lib/api"
+	"github.com/vine-io/vine/lib/config"
+	"github.com/vine-io/vine/lib/config/reader"
+	"github.com/vine-io/vine/lib/logger"
+)
+
+// Rule describes how the traffic of a service is split, e.g.
+//
+//	{"service": "go.vine.helloworld", "weights": {"v1": 95, "v2": 5}, "cookie": "canary",
+//	 "mirror": {"version": "v3", "percent": 10}}
+type Rule struct {
+	// Service is the name of the routed service
+	Service string `json:"service"`
+	// Weights divides the traffic between versions
+	Weights map[string]int `json:"weights"`
+	// Header is the request header which pins a version, defaults to DefaultHeader
+	Header string `json:"header"`
+	// Cookie is the cookie which pins a version
+	Cookie string `json:"cookie"`
+	// Mirror shadows a share of the traffic to a version
+	Mirror *Mirror `json:"mirror"`
+}
+
+// Mirror copies requests to a version, its responses are discarded
+type Mirror struct {
+	Version string `json:"version"`
+	// Percent of the requests which are copied, 0-100
+	Percent float64 `json:"percent"`
+	// Methods which are copied besides the safe GET, HEAD and OPTIONS e.g.
+	// PUT, the mirrored version then writes the same data as the other one
+	Methods []string `json:"methods"`
+}
+
+// mirrors reports whether the requests of the method are copied, the safe
+// methods always are and the others only when the rule lists them
+func (m *Mirror) mirrors(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	for _, v := range m.Methods {
+		if strings.EqualFold(v, method) {
+			return true
+		}
+	}
+	return false
+}
+
+type mirrorKey struct{}
+type versionKey struct{}
+
+// NewVersionContext pins the version of the services routed for a request,
+// it takes precedence over the header, the cookie and the weights of a rule
+func NewVersionContext(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, versionKey{}, version)
+}
+
+// VersionFromContext returns the version pinned by NewVersionContext
+func VersionFromContext(ctx context.Context) (string, bool) {
+	version, ok := ctx.Value(versionKey{}).(string)
+	return version, ok
+}
+
+// mirrored holds the service Split picked for mirroring
+type mirrored struct {
+	sync.Mutex
+	svc *api.Service
+}
+
+// NewMirrorContext returns a context in which Split records the service the
+// request is mirrored to, requests routed without one are never mirrored
+func NewMirrorContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, mirrorKey{}, &mirrored{})
+}
+
+// MirrorFromContext returns the service a request should be mirrored to
+func MirrorFromContext(ctx context.Context) (*api.Service, bool) {
+	m, ok := ctx.Value(mirrorKey{}).(*mirrored)
+	if !ok {
+		return nil, false
+	}
+	m.Lock()
+	defer m.Unlock()
+	return m.svc, m.svc != nil
+}
+
+// Splitter applies the rules to routed services
+type Splitter struct {
+	opts Options
+
+	sync.RWMutex
+	rules map[string]*Rule
+
+	sub *config.Subscription
+}
+
+// Split narrows the services of svc to the version chosen for the request. When
+// the request is mirrored the mirror service is recorded in the context created
+// by NewMirrorContext, the request itself is left untouched.
+func (s *Splitter) Split(req *http.Request, svc *api.Service) *api.Service {
+	if svc == nil {
+		return svc
+	}
+
+	s.RLock()
+	rule, ok := s.rules[svc.Name]
+	s.RUnlock()
+	if !ok {
+		return svc
+	}
+
+	out := svc
+	if version := s.version(req, rule); version != "" {
+		if services := filter(svc.Services, version); len(services) > 0 {
+			out = &api.Service{Name: svc.Name, Endpoint: svc.Endpoint, Services: services}
+		}
+	}
+
+	m, ok := req.Context().Value(mirrorKey{}).(*mirrored)
+	if !ok || rule.Mirror == nil || rule.Mirror.Version == "" || !rule.Mirror.mirrors(req.Method) {
+		return out
+	}
+	if rand.Float64()*100 < rule.Mirror.Percent {
+		if services := filter(svc.Services, rule.Mirror.Version); len(services) > 0 {
+			m.Lock()
+			m.svc = &api.Service{Name: svc.Name, Endpoint: svc.Endpoint, Services: services}
+			m.Unlock()
+		}
+	}
+
+	return out
+}
+
+// version returns the version pinned by the request or picks one by weight
+func (s *Splitter) version(req *http.Request, rule *Rule) string {
+	if v, ok := VersionFromContext(req.Context()); ok && v != "" {
+		return v
+	}
+	header := rule.Header
+	if header == "" {
+		header = DefaultHeader
+	}
+	if v := req.Header.Get(header); v != "" {
+		return v
+	}
+	if rule.Cookie != "" {
+		if c, err := req.Cookie(rule.Cookie); err == nil && c.Value != "" {
+			return c.Value
+		}
+	}
+
+	total := 0
+	for _, w := range rule.Weights {
+		if w > 0 {
+			total += w
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+
+	n := rand.Intn(total)
+	for version, w := range rule.Weights {
+		if w <= 0 {
+			continue
+		}
+		if n < w {
+			return version
+		}
+		n -= w
+	}
+	return ""
+}
+
+func filter(services []*registry.Service, version string) []*registry.Service {
+	out := make([]*registry.Service, 0, len(services))
+	for _, service := range services {
+		if service.Version == version {
+			out = append(out, service)
+		}
+	}
+	return out
+}
+
+// Update replaces the rules
+func (s *Splitter) Update(rules ...*Rule) {
+	m := make(map[string]*Rule, len(rules))
+	for _, rule := range rules {
+		if rule == nil || rule.Service == "" {
+			continue
+		}
+		m[rule.Service] = rule
+	}
+
+	s.Lock()
+	s.rules = m
+	s.Unlock()
+}
+
+// Rules returns the rules in use
+func (s *Splitter) Rules() []*Rule {
+	s.RLock()
+	defer s.RUnlock()
+
+	rules := make([]*Rule, 0, len(s.rules))
+	for _, rule := range s.rules {
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func (s *Splitter) load(v reader.Value) {
+	// fall back to the static rules when the path is not in the config
+	if b := strings.TrimSpace(string(v.Bytes())); b == "" || b == "null" {
+		s.Update(s.opts.Rules...)
+		return
+	}
+
+	rules := make([]*Rule, 0)
+	if err := v.Scan(&rules); err != nil {
+		logger.Errorf("unable to load traffic rules: %v", err)
+		return
+	}
+	s.Update(rules...)
+}
+
+// Close stops watching the config
+func (s *Splitter) Close() error {
+	if s.sub != nil {
+		return s.sub.Stop()
+	}
+	return nil
+}
+
+// NewSplitter returns a Splitter, the rules are loaded from the config when one is given
+func NewSplitter(opts ...Option) *Splitter {
+	options := NewOptions(opts...)
+	s := &Splitter{
+		opts:  options,
+		rules: map[string]*Rule{},
+	}
+	s.Update(options.Rules...)
+
+	if options.Config != nil {
+		sub, err := config.Follow(options.Config, s.load, options.Path...)
+		if err != nil {
+			logger.Errorf("unable to watch traffic rules: %v", err)
+		}
+		s.sub = sub
+	}
+
+	return s
+}
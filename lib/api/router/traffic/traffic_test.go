@@ -0,0 +1,176 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package traffic
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vine-io/vine/core/registry"
+	"github.com/vine-io/vine/lib/api"
+	cmemory "github.com/vine-io/vine/lib/config/memory"
+	"github.com/vine-io/vine/lib/config/source"
+	smemory "github.com/vine-io/vine/lib/config/source/memory"
+)
+
+func testService() *api.Service {
+	return &api.Service{
+		Name:     "helloworld",
+		Endpoint: &api.Endpoint{Name: "Helloworld.Call"},
+		Services: []*registry.Service{
+			{Name: "helloworld", Version: "v1"},
+			{Name: "helloworld", Version: "v2"},
+		},
+	}
+}
+
+func versionOf(svc *api.Service) string {
+	if len(svc.Services) != 1 {
+		return ""
+	}
+	return svc.Services[0].Version
+}
+
+func TestSplit(t *testing.T) {
+	s := NewSplitter(WithRules(&Rule{
+		Service: "helloworld",
+		Weights: map[string]int{"v1": 80, "v2": 20},
+		Cookie:  "canary",
+	}))
+	defer s.Close()
+
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/helloworld/call", nil)
+		counts[versionOf(s.Split(req, testService()))]++
+	}
+	assert.Equal(t, 10000, counts["v1"]+counts["v2"])
+	assert.InDelta(t, 2000, counts["v2"], 300)
+
+	// pinned by header
+	req := httptest.NewRequest(http.MethodGet, "/helloworld/call", nil)
+	req.Header.Set(DefaultHeader, "v2")
+	assert.Equal(t, "v2", versionOf(s.Split(req, testService())))
+
+	// pinned by cookie
+	req = httptest.NewRequest(http.MethodGet, "/helloworld/call", nil)
+	req.AddCookie(&http.Cookie{Name: "canary", Value: "v1"})
+	assert.Equal(t, "v1", versionOf(s.Split(req, testService())))
+
+	// an unknown version falls back to every version
+	req = httptest.NewRequest(http.MethodGet, "/helloworld/call", nil)
+	req.Header.Set(DefaultHeader, "v3")
+	assert.Len(t, s.Split(req, testService()).Services, 2)
+
+	// services without rules are untouched
+	svc := testService()
+	svc.Name = "other"
+	assert.Equal(t, svc, s.Split(req, svc))
+}
+
+func TestMirror(t *testing.T) {
+	s := NewSplitter(WithRules(&Rule{
+		Service: "helloworld",
+		Weights: map[string]int{"v1": 100},
+		Mirror:  &Mirror{Version: "v2", Percent: 100},
+	}))
+	defer s.Close()
+
+	req := httptest.NewRequest(http.MethodGet, "/helloworld/call", nil)
+	req = req.WithContext(NewMirrorContext(req.Context()))
+	ctx := req.Context()
+	assert.Equal(t, "v1", versionOf(s.Split(req, testService())))
+	// the request is left untouched
+	assert.Equal(t, ctx, req.Context())
+
+	mirror, ok := MirrorFromContext(req.Context())
+	if assert.True(t, ok) {
+		assert.Equal(t, "v2", versionOf(mirror))
+	}
+
+	// only the safe methods are mirrored unless the rule lists the others
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		req = httptest.NewRequest(method, "/helloworld/call", nil)
+		req = req.WithContext(NewMirrorContext(req.Context()))
+		s.Split(req, testService())
+		_, ok = MirrorFromContext(req.Context())
+		assert.False(t, ok, method)
+	}
+
+	s.Update(&Rule{
+		Service: "helloworld",
+		Weights: map[string]int{"v1": 100},
+		Mirror:  &Mirror{Version: "v2", Percent: 100, Methods: []string{"put"}},
+	})
+	req = httptest.NewRequest(http.MethodPut, "/helloworld/call", nil)
+	req = req.WithContext(NewMirrorContext(req.Context()))
+	s.Split(req, testService())
+	_, ok = MirrorFromContext(req.Context())
+	assert.True(t, ok)
+	req = httptest.NewRequest(http.MethodDelete, "/helloworld/call", nil)
+	req = req.WithContext(NewMirrorContext(req.Context()))
+	s.Split(req, testService())
+	_, ok = MirrorFromContext(req.Context())
+	assert.False(t, ok)
+
+	// nor are those routed without a mirror context
+	req = httptest.NewRequest(http.MethodGet, "/helloworld/call", nil)
+	s.Split(req, testService())
+	_, ok = MirrorFromContext(req.Context())
+	assert.False(t, ok)
+}
+
+func TestReload(t *testing.T) {
+	src := smemory.NewSource(smemory.WithJSON([]byte(`{"api":{"traffic":[{"service":"helloworld","weights":{"v1":100}}]}}`)))
+	c := cmemory.NewConfig()
+	if err := c.Load(src); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s := NewSplitter(WithConfig(c))
+	defer s.Close()
+
+	// the static rules are used when the config has none
+	static := NewSplitter(WithConfig(c), WithPath("api", "none"), WithRules(&Rule{Service: "helloworld", Weights: map[string]int{"v2": 100}}))
+	defer static.Close()
+	assert.Equal(t, "v2", versionOf(static.Split(httptest.NewRequest(http.MethodGet, "/", nil), testService())))
+
+	split := func() string {
+		return versionOf(s.Split(httptest.NewRequest(http.MethodGet, "/", nil), testService()))
+	}
+	assert.Equal(t, "v1", split())
+
+	// the source starts watching in the background, keep pushing the change until it lands
+	assert.Eventually(t, func() bool {
+		src.(interface{ Update(*source.ChangeSet) }).Update(&source.ChangeSet{
+			Data:   []byte(`{"api":{"traffic":[{"service":"helloworld","weights":{"v2":100}}]}}`),
+			Format: "json",
+		})
+		return split() == "v2"
+	}, time.Second*5, time.Millisecond*50)
+}
@@ -0,0 +1,467 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package cache caches the GET responses of the api gateway following the
+// Cache-Control, ETag and Vary semantics of a shared http cache
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vine-io/vine/lib/api/router/traffic"
+	"github.com/vine-io/vine/lib/api/server"
+	"github.com/vine-io/vine/lib/cache"
+	log "github.com/vine-io/vine/lib/logger"
+)
+
+// entry is a cached response
+type entry struct {
+	Status  int         `json:"status"`
+	Header  http.Header `json:"header"`
+	Body    []byte      `json:"body"`
+	Created time.Time   `json:"created"`
+}
+
+// Cache serves the responses of the gateway from a cache.Cache. The ttl of
+// an endpoint is set by its Cache field and may be shortened by the max-age
+// of the response.
+type Cache struct {
+	opts Options
+}
+
+func New(opts ...Option) *Cache {
+	return &Cache{opts: NewOptions(opts...)}
+}
+
+func (c *Cache) Options() Options {
+	return c.opts
+}
+
+// Wrapper returns the cache as a server.Wrapper
+func (c *Cache) Wrapper() server.Wrapper {
+	return c.Handler
+}
+
+// Handler caches the responses of h and serves the purge requests when an admin token is set
+func (c *Cache) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(c.opts.AdminPath) > 0 && len(c.opts.AdminToken) > 0 && r.URL.Path == c.opts.AdminPath {
+			c.purge(w, r)
+			return
+		}
+
+		c.serve(h, w, r)
+	})
+}
+
+// Purge removes the responses whose path starts with prefix and returns how many were removed
+func (c *Cache) Purge(ctx context.Context, prefix string) (int, error) {
+	keys, err := c.opts.Cache.List(ctx, cache.ListPrefix(c.opts.Prefix+prefix))
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, key := range keys {
+		if err := c.opts.Cache.Del(ctx, key); err != nil {
+			return n, err
+		}
+		// the vary index is not a response
+		if strings.Contains(key, "#") {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (c *Cache) serve(h http.Handler, w http.ResponseWriter, r *http.Request) {
+	if bypass(r) {
+		h.ServeHTTP(w, r)
+		return
+	}
+
+	cc := parseCacheControl(r.Header)
+	if cc.has("no-store") {
+		h.ServeHTTP(w, r)
+		return
+	}
+
+	ttl, version := c.route(r)
+	if ttl <= 0 {
+		h.ServeHTTP(w, r)
+		return
+	}
+
+	// the version chosen for the key is the one the request is routed to
+	if len(version) > 0 {
+		r = r.WithContext(traffic.NewVersionContext(r.Context(), version))
+	}
+
+	ctx := r.Context()
+	key := c.key(r, version)
+
+	// no-cache asks for a response from the backend, which is then stored
+	if !cc.has("no-cache") {
+		if e, err := c.lookup(ctx, key, r); err == nil && fresh(e, cc) {
+			c.write(w, r, e, "HIT")
+			return
+		}
+	}
+
+	if cc.has("only-if-cached") {
+		w.WriteHeader(http.StatusGatewayTimeout)
+		return
+	}
+
+	rec := newRecorder()
+	h.ServeHTTP(rec, r)
+
+	e := &entry{
+		Status:  rec.status,
+		Header:  rec.header,
+		Body:    rec.body.Bytes(),
+		Created: time.Now(),
+	}
+
+	if d, ok := storable(r, e, ttl); ok {
+		if len(e.Header.Get("ETag")) == 0 {
+			e.Header.Set("ETag", etag(e.Body))
+		}
+		if err := c.store(ctx, key, r, e, d); err != nil {
+			log.Warnf("cache response of %s: %v", r.URL.Path, err)
+		}
+	}
+
+	c.write(w, r, e, "MISS")
+}
+
+// route returns how long the response of the request endpoint is cached and
+// the version of the routed services when they share one, e.g. the version
+// chosen by the traffic splitter
+func (c *Cache) route(r *http.Request) (time.Duration, string) {
+	if c.opts.Router == nil {
+		return c.opts.TTL, ""
+	}
+
+	// the router may change the request, so route a copy
+	svc, err := c.opts.Router.Route(r.Clone(r.Context()))
+	if err != nil || svc == nil || svc.Endpoint == nil {
+		return 0, ""
+	}
+
+	var version string
+	for i, s := range svc.Services {
+		if i > 0 && s.Version != version {
+			version = ""
+			break
+		}
+		version = s.Version
+	}
+
+	if len(svc.Endpoint.Cache) == 0 {
+		return c.opts.TTL, version
+	}
+
+	d, err := time.ParseDuration(svc.Endpoint.Cache)
+	if err != nil {
+		log.Warnf("invalid cache of endpoint %s: %v", svc.Endpoint.Name, err)
+		return 0, ""
+	}
+
+	return d, version
+}
+
+// key returns the key of the vary index of the request, the responses are
+// stored under it suffixed with the hash of the varying headers. The key is
+// specific to the version of the service and to the caller.
+func (c *Cache) key(r *http.Request, version string) string {
+	key := c.opts.Prefix + r.URL.Path + "?" + r.URL.RawQuery + "@" + r.Host
+	if len(version) > 0 {
+		key += "~" + version
+	}
+
+	h := sha1.New()
+	identified := false
+	for _, name := range c.opts.Identity {
+		if values := r.Header.Values(name); len(values) > 0 {
+			identified = true
+			fmt.Fprintf(h, "%s:%s\n", strings.ToLower(name), strings.Join(values, ","))
+		}
+	}
+	if identified {
+		key += fmt.Sprintf("!%x", h.Sum(nil))
+	}
+	return key
+}
+
+func (c *Cache) lookup(ctx context.Context, key string, r *http.Request) (*entry, error) {
+	recs, err := c.opts.Cache.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, cache.ErrNotFound
+	}
+
+	recs, err = c.opts.Cache.Get(ctx, variant(key, r, string(recs[0].Value)))
+	if err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, cache.ErrNotFound
+	}
+
+	e := &entry{}
+	if err := json.Unmarshal(recs[0].Value, e); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (c *Cache) store(ctx context.Context, key string, r *http.Request, e *entry, ttl time.Duration) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	vary := strings.Join(e.Header.Values("Vary"), ",")
+	if err := c.opts.Cache.Put(ctx, &cache.Record{Key: key, Value: []byte(vary)}, cache.PutTTL(ttl)); err != nil {
+		return err
+	}
+
+	return c.opts.Cache.Put(ctx, &cache.Record{Key: variant(key, r, vary), Value: b}, cache.PutTTL(ttl))
+}
+
+func (c *Cache) write(w http.ResponseWriter, r *http.Request, e *entry, state string) {
+	header := w.Header()
+	for k, v := range e.Header {
+		header[k] = v
+	}
+	header.Set("X-Cache", state)
+	if state == "HIT" {
+		header.Set("Age", strconv.Itoa(int(time.Since(e.Created).Seconds())))
+	}
+
+	if e.Status == http.StatusOK && match(r.Header.Get("If-None-Match"), e.Header.Get("ETag")) {
+		header.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(e.Status)
+	if r.Method != http.MethodHead {
+		w.Write(e.Body)
+	}
+}
+
+func (c *Cache) purge(w http.ResponseWriter, r *http.Request) {
+	v := r.Header.Get("Authorization")
+	if !strings.HasPrefix(v, "Bearer ") ||
+		subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(v, "Bearer ")), []byte(c.opts.AdminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "invalid admin token", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodDelete && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "DELETE, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	if len(prefix) == 0 {
+		prefix = "/"
+	}
+
+	n, err := c.Purge(r.Context(), prefix)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"purged": n})
+}
+
+// bypass reports whether the request can't be answered from the cache
+func bypass(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return true
+	}
+	// websockets and event streams are never complete responses
+	if len(r.Header.Get("Upgrade")) > 0 {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// fresh reports whether the entry satisfies the max-age of the request
+func fresh(e *entry, cc cacheControl) bool {
+	v, ok := cc["max-age"]
+	if !ok {
+		return true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return false
+	}
+	return time.Since(e.Created) <= time.Duration(n)*time.Second
+}
+
+// storable returns the ttl of the response or false if it may not be stored by a shared cache
+func storable(r *http.Request, e *entry, ttl time.Duration) (time.Duration, bool) {
+	if r.Method != http.MethodGet || e.Status != http.StatusOK {
+		return 0, false
+	}
+
+	cc := parseCacheControl(e.Header)
+	// no-cache requires a revalidation with the backend, which is not supported
+	if cc.has("no-store") || cc.has("no-cache") || cc.has("private") {
+		return 0, false
+	}
+	if len(e.Header.Get("Set-Cookie")) > 0 || strings.TrimSpace(e.Header.Get("Vary")) == "*" {
+		return 0, false
+	}
+	if len(r.Header.Get("Authorization")) > 0 && !cc.has("public") && !cc.has("s-maxage") {
+		return 0, false
+	}
+
+	v, ok := cc["s-maxage"]
+	if !ok {
+		v, ok = cc["max-age"]
+	}
+	if ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, false
+		}
+		ttl = time.Duration(n) * time.Second
+	}
+
+	return ttl, ttl > 0
+}
+
+// variant returns the key of the response for the values of the vary headers
+func variant(key string, r *http.Request, vary string) string {
+	h := sha1.New()
+	for _, name := range strings.Split(vary, ",") {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			continue
+		}
+		fmt.Fprintf(h, "%s:%s\n", strings.ToLower(name), strings.Join(r.Header.Values(name), ","))
+	}
+	return fmt.Sprintf("%s#%x", key, h.Sum(nil))
+}
+
+func etag(b []byte) string {
+	return fmt.Sprintf(`"%x"`, sha1.Sum(b))
+}
+
+// match reports whether the If-None-Match header matches the etag, comparing weakly
+func match(header, tag string) bool {
+	if len(header) == 0 || len(tag) == 0 {
+		return false
+	}
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheControl are the directives of the Cache-Control header
+type cacheControl map[string]string
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, value := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(value, ",") {
+			d = strings.TrimSpace(d)
+			if len(d) == 0 {
+				continue
+			}
+			parts := strings.SplitN(d, "=", 2)
+			name := strings.ToLower(strings.TrimSpace(parts[0]))
+			if len(parts) == 2 {
+				cc[name] = strings.Trim(strings.TrimSpace(parts[1]), `"`)
+			} else {
+				cc[name] = ""
+			}
+		}
+	}
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// recorder holds the response of the handler until it is complete
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	wrote  bool
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: http.Header{}, status: http.StatusOK}
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if r.wrote {
+		return
+	}
+	r.status = code
+	r.wrote = true
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if !r.wrote {
+		r.WriteHeader(http.StatusOK)
+	}
+	return r.body.Write(b)
+}
+
+// Flush is a noop, the response is written once complete
+func (r *recorder) Flush() {}
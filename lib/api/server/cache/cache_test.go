@@ -0,0 +1,259 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vine-io/vine/core/registry"
+	"github.com/vine-io/vine/lib/api"
+	"github.com/vine-io/vine/lib/api/router"
+	"github.com/vine-io/vine/lib/api/router/traffic"
+	"github.com/vine-io/vine/lib/cache/memory"
+)
+
+// testRouter routes every request to the endpoint
+type testRouter struct {
+	ep *api.Endpoint
+}
+
+func (t *testRouter) Options() router.Options                        { return router.Options{} }
+func (t *testRouter) Close() error                                   { return nil }
+func (t *testRouter) Register(ep *api.Endpoint) error                { return nil }
+func (t *testRouter) Deregister(ep *api.Endpoint) error              { return nil }
+func (t *testRouter) Endpoint(r *http.Request) (*api.Service, error) { return t.Route(r) }
+
+func (t *testRouter) Route(r *http.Request) (*api.Service, error) {
+	return &api.Service{Name: "go.vine.api.test", Endpoint: t.ep}, nil
+}
+
+func testServer(ep *api.Endpoint, header http.Header, opts ...Option) (http.Handler, *int) {
+	calls := 0
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.Write([]byte("hello " + r.Header.Get("Accept-Language")))
+	})
+
+	c := New(append([]Option{WithCache(memory.NewCache()), WithRouter(&testRouter{ep: ep})}, opts...)...)
+	return c.Handler(h), &calls
+}
+
+func do(h http.Handler, method, target string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestCache(t *testing.T) {
+	h, calls := testServer(&api.Endpoint{Name: "Test.Call", Cache: "1m"}, nil)
+
+	w := do(h, http.MethodGet, "/test/call?a=1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	tag := w.Header().Get("ETag")
+	assert.NotEmpty(t, tag)
+
+	w = do(h, http.MethodGet, "/test/call?a=1", nil)
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Equal(t, "hello ", w.Body.String())
+	assert.Equal(t, 1, *calls)
+
+	// another query is another response
+	do(h, http.MethodGet, "/test/call?a=2", nil)
+	assert.Equal(t, 2, *calls)
+
+	w = do(h, http.MethodGet, "/test/call?a=1", http.Header{"If-None-Match": {tag}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	w = do(h, http.MethodHead, "/test/call?a=1", nil)
+	assert.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	assert.Empty(t, w.Body.String())
+
+	// no-cache refetches, no-store bypasses the cache
+	do(h, http.MethodGet, "/test/call?a=1", http.Header{"Cache-Control": {"no-cache"}})
+	assert.Equal(t, 3, *calls)
+	w = do(h, http.MethodGet, "/test/call?a=1", http.Header{"Cache-Control": {"no-store"}})
+	assert.Empty(t, w.Header().Get("X-Cache"))
+	assert.Equal(t, 4, *calls)
+
+	do(h, http.MethodPost, "/test/call?a=1", nil)
+	assert.Equal(t, 5, *calls)
+
+	// endpoints without a ttl are not cached
+	h, calls = testServer(&api.Endpoint{Name: "Test.Call"}, nil)
+	do(h, http.MethodGet, "/test/call", nil)
+	do(h, http.MethodGet, "/test/call", nil)
+	assert.Equal(t, 2, *calls)
+}
+
+func TestCacheControl(t *testing.T) {
+	ep := &api.Endpoint{Name: "Test.Call", Cache: "1m"}
+
+	for _, v := range []string{"no-store", "private", "no-cache", "max-age=0"} {
+		h, calls := testServer(ep, http.Header{"Cache-Control": {v}})
+		do(h, http.MethodGet, "/test/call", nil)
+		do(h, http.MethodGet, "/test/call", nil)
+		assert.Equal(t, 2, *calls, v)
+	}
+
+	h, calls := testServer(ep, http.Header{"Cache-Control": {"max-age=60"}})
+	do(h, http.MethodGet, "/test/call", nil)
+	do(h, http.MethodGet, "/test/call", nil)
+	assert.Equal(t, 1, *calls)
+
+	// authorized responses are private unless public
+	h, calls = testServer(ep, nil)
+	auth := http.Header{"Authorization": {"Bearer token"}}
+	do(h, http.MethodGet, "/test/call", auth)
+	do(h, http.MethodGet, "/test/call", auth)
+	assert.Equal(t, 2, *calls)
+
+	h, calls = testServer(ep, http.Header{"Cache-Control": {"public"}})
+	do(h, http.MethodGet, "/test/call", auth)
+	do(h, http.MethodGet, "/test/call", auth)
+	assert.Equal(t, 1, *calls)
+}
+
+func TestVary(t *testing.T) {
+	h, calls := testServer(&api.Endpoint{Name: "Test.Call", Cache: "1m"}, http.Header{"Vary": {"Accept-Language"}})
+
+	en := http.Header{"Accept-Language": {"en"}}
+	zh := http.Header{"Accept-Language": {"zh"}}
+
+	assert.Equal(t, "hello en", do(h, http.MethodGet, "/test/call", en).Body.String())
+	assert.Equal(t, "hello zh", do(h, http.MethodGet, "/test/call", zh).Body.String())
+	assert.Equal(t, "hello en", do(h, http.MethodGet, "/test/call", en).Body.String())
+	assert.Equal(t, "hello zh", do(h, http.MethodGet, "/test/call", zh).Body.String())
+	assert.Equal(t, 2, *calls)
+}
+
+func TestPurge(t *testing.T) {
+	// the purge endpoint is not served without an admin token
+	h, calls := testServer(&api.Endpoint{Name: "Test.Call", Cache: "1m"}, nil)
+	do(h, http.MethodDelete, DefaultAdminPath, nil)
+	assert.Equal(t, 1, *calls)
+
+	h, calls = testServer(&api.Endpoint{Name: "Test.Call", Cache: "1m"}, nil, WithAdminToken("secret"))
+	admin := http.Header{"Authorization": {"Bearer secret"}}
+
+	do(h, http.MethodGet, "/test/call", nil)
+	do(h, http.MethodGet, "/other/call", nil)
+
+	w := do(h, http.MethodDelete, DefaultAdminPath+"?prefix=/test", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = do(h, http.MethodDelete, DefaultAdminPath+"?prefix=/test", http.Header{"Authorization": {"Bearer other"}})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = do(h, http.MethodGet, DefaultAdminPath+"?prefix=/test", admin)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	w = do(h, http.MethodDelete, DefaultAdminPath+"?prefix=/test", admin)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"purged":1}`, strings.TrimSpace(w.Body.String()))
+
+	do(h, http.MethodGet, "/test/call", nil)
+	do(h, http.MethodGet, "/other/call", nil)
+	assert.Equal(t, 3, *calls)
+}
+
+func TestStorable(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	d, ok := storable(r, &entry{Status: http.StatusOK, Header: http.Header{"Cache-Control": {"max-age=10, s-maxage=30"}}}, time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, d)
+
+	_, ok = storable(r, &entry{Status: http.StatusNotFound, Header: http.Header{}}, time.Minute)
+	assert.False(t, ok)
+
+	_, ok = storable(r, &entry{Status: http.StatusOK, Header: http.Header{"Vary": {"*"}}}, time.Minute)
+	assert.False(t, ok)
+}
+
+// splitRouter routes the requests to the versions of the splitter
+type splitRouter struct {
+	testRouter
+	sp *traffic.Splitter
+}
+
+func (t *splitRouter) Route(r *http.Request) (*api.Service, error) {
+	svc := &api.Service{
+		Name:     "go.vine.api.test",
+		Endpoint: t.ep,
+		Services: []*registry.Service{{Version: "v1"}, {Version: "v2"}},
+	}
+	return t.sp.Split(r, svc), nil
+}
+
+func TestVersion(t *testing.T) {
+	rt := &splitRouter{
+		testRouter: testRouter{ep: &api.Endpoint{Name: "Test.Call", Cache: "1m"}},
+		sp:         traffic.NewSplitter(traffic.WithRules(&traffic.Rule{Service: "go.vine.api.test", Weights: map[string]int{"v1": 50, "v2": 50}})),
+	}
+	calls := 0
+	h := New(WithCache(memory.NewCache()), WithRouter(rt)).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		svc, _ := rt.Route(r)
+		w.Write([]byte(svc.Services[0].Version))
+	}))
+
+	// a version is cached once and only served to the requests routed to it
+	seen := map[string]int{}
+	for i := 0; i < 50; i++ {
+		w := do(h, http.MethodGet, "/test/call", nil)
+		seen[w.Body.String()]++
+	}
+	assert.Len(t, seen, 2)
+	assert.Equal(t, 2, calls)
+
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, "v2", do(h, http.MethodGet, "/test/call", http.Header{traffic.DefaultHeader: {"v2"}}).Body.String())
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	h, calls := testServer(&api.Endpoint{Name: "Test.Call", Cache: "1m"}, http.Header{"Cache-Control": {"public"}})
+
+	alice := http.Header{"Authorization": {"Bearer alice"}}
+	bob := http.Header{"X-Api-Key": {"bob.secret"}}
+	for i := 0; i < 2; i++ {
+		do(h, http.MethodGet, "/test/call", nil)
+		do(h, http.MethodGet, "/test/call", alice)
+		do(h, http.MethodGet, "/test/call", bob)
+		do(h, http.MethodGet, "/test/call", http.Header{"Cookie": {"session=1"}})
+	}
+	assert.Equal(t, 4, *calls)
+}
@@ -0,0 +1,124 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package cache
+
+import (
+	"time"
+
+	"github.com/vine-io/vine/lib/api/router"
+	"github.com/vine-io/vine/lib/cache"
+)
+
+var (
+	// DefaultPrefix is prepended to the keys of the cached responses
+	DefaultPrefix = "api:"
+	// DefaultAdminPath is where the purge requests are served
+	DefaultAdminPath = "/admin/cache"
+	// DefaultIdentity are the request headers which identify a caller, the
+	// responses are cached per caller when they are set
+	DefaultIdentity = []string{"Authorization", "X-API-Key", "Cookie"}
+)
+
+type Options struct {
+	// Cache the responses are stored in
+	Cache cache.Cache
+	// Router resolves the endpoint of a request to read its cache ttl
+	Router router.Router
+	// TTL of the responses whose endpoint sets none, zero only caches tagged endpoints
+	TTL time.Duration
+	// Prefix of the cache keys
+	Prefix string
+	// Identity are the request headers which identify a caller
+	Identity []string
+	// AdminPath serves the purge requests
+	AdminPath string
+	// AdminToken is the bearer token of the purge requests, empty disables them
+	AdminToken string
+}
+
+type Option func(o *Options)
+
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Cache:     cache.DefaultCache,
+		Prefix:    DefaultPrefix,
+		Identity:  DefaultIdentity,
+		AdminPath: DefaultAdminPath,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithCache sets the cache the responses are stored in
+func WithCache(c cache.Cache) Option {
+	return func(o *Options) {
+		o.Cache = c
+	}
+}
+
+// WithRouter sets the router used to find the endpoint ttl
+func WithRouter(r router.Router) Option {
+	return func(o *Options) {
+		o.Router = r
+	}
+}
+
+// WithTTL sets the ttl of the endpoints without one
+func WithTTL(d time.Duration) Option {
+	return func(o *Options) {
+		o.TTL = d
+	}
+}
+
+// WithPrefix sets the prefix of the cache keys
+func WithPrefix(p string) Option {
+	return func(o *Options) {
+		o.Prefix = p
+	}
+}
+
+// WithIdentity sets the request headers which identify a caller
+func WithIdentity(headers ...string) Option {
+	return func(o *Options) {
+		o.Identity = headers
+	}
+}
+
+// WithAdminPath sets the path of the purge endpoint
+func WithAdminPath(p string) Option {
+	return func(o *Options) {
+		o.AdminPath = p
+	}
+}
+
+// WithAdminToken sets the bearer token required by the purge endpoint, which
+// is only served with one
+func WithAdminToken(token string) Option {
+	return func(o *Options) {
+		o.AdminToken = token
+	}
+}
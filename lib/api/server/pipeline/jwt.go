@@ -0,0 +1,318 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package pipeline
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	json "github.com/json-iterator/go"
+	"github.com/vine-io/vine/lib/api/server"
+	"github.com/vine-io/vine/lib/errors"
+)
+
+var hashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+// verifier checks the signature of a json web token
+type verifier struct {
+	secret []byte
+	key    interface{}
+}
+
+func (v *verifier) verify(alg string, signed, sig []byte) error {
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	hash, ok := hashes[alg[2:]]
+	if !ok {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "HS":
+		if v.secret == nil {
+			return fmt.Errorf("unexpected algorithm %q", alg)
+		}
+		mac := hmac.New(hash.New, v.secret)
+		mac.Write(signed)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	case "RS":
+		key, ok := v.key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("unexpected algorithm %q", alg)
+		}
+		return rsa.VerifyPKCS1v15(key, hash, digest, sig)
+	case "ES":
+		key, ok := v.key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("unexpected algorithm %q", alg)
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return fmt.Errorf("invalid signature")
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported algorithm %q", alg)
+}
+
+func parsePublicKey(data string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, fmt.Errorf("invalid public_key")
+	}
+	if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+		return cert.PublicKey, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public_key: %v", err)
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported public_key %T", key)
+}
+
+// audience is the aud claim, either a string or a list of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+type claims struct {
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+}
+
+type jwtOptions struct {
+	Secret    string        `json:"secret"`
+	PublicKey string        `json:"public_key"`
+	Issuer    string        `json:"issuer"`
+	Audience  string        `json:"audience"`
+	Header    string        `json:"header"`
+	Query     string        `json:"query"`
+	Leeway    time.Duration `json:"-"`
+	// Claims maps claims to the request headers they are passed in
+	Claims map[string]string `json:"claims"`
+}
+
+// parse verifies the token and returns its claims
+func (o *jwtOptions) parse(v *verifier, token string, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token")
+	}
+	if err := json.Unmarshal(b, &header); err != nil {
+		return nil, fmt.Errorf("malformed token")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token")
+	}
+	if err := v.verify(header.Alg, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token")
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, fmt.Errorf("malformed claims")
+	}
+
+	unix := float64(now.Unix())
+	leeway := o.Leeway.Seconds()
+	if c.ExpiresAt != nil && unix > *c.ExpiresAt+leeway {
+		return nil, fmt.Errorf("token expired")
+	}
+	if c.NotBefore != nil && unix < *c.NotBefore-leeway {
+		return nil, fmt.Errorf("token not valid yet")
+	}
+	if o.Issuer != "" && c.Issuer != o.Issuer {
+		return nil, fmt.Errorf("invalid issuer")
+	}
+	if o.Audience != "" {
+		ok := false
+		for _, a := range c.Audience {
+			ok = ok || a == o.Audience
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid audience")
+		}
+	}
+
+	all := map[string]interface{}{}
+	if err := json.Unmarshal(payload, &all); err != nil {
+		return nil, fmt.Errorf("malformed claims")
+	}
+	return all, nil
+}
+
+// newJWT accepts the requests carrying a valid json web token, signed with the
+// secret (HS256, HS384, HS512) or the public key (RS256, RS384, RS512, ES256,
+// ES384, ES512). The claims can be passed to the services in headers.
+//
+//	type: jwt
+//	secret: secret
+//	public_key: "-----BEGIN PUBLIC KEY-----..."
+//	issuer: vine
+//	audience: api
+//	header: Authorization
+//	query: access_token
+//	leeway: 30s
+//	claims: {sub: X-User-Id}
+func newJWT(raw []byte) (server.Wrapper, error) {
+	o := &jwtOptions{Header: "Authorization"}
+	if err := decode(raw, o); err != nil {
+		return nil, err
+	}
+	var extra struct {
+		Leeway string `json:"leeway"`
+	}
+	if err := decode(raw, &extra); err != nil {
+		return nil, err
+	}
+	if extra.Leeway != "" {
+		d, err := time.ParseDuration(extra.Leeway)
+		if err != nil {
+			return nil, fmt.Errorf("invalid leeway: %v", err)
+		}
+		o.Leeway = d
+	}
+
+	v := &verifier{}
+	switch {
+	case o.Secret != "" && o.PublicKey != "":
+		return nil, fmt.Errorf("secret and public_key are exclusive")
+	case o.Secret != "":
+		v.secret = []byte(o.Secret)
+	case o.PublicKey != "":
+		key, err := parsePublicKey(o.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		v.key = key
+	default:
+		return nil, fmt.Errorf("secret or public_key is required")
+	}
+
+	unauthorized := func(w http.ResponseWriter, detail string) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeError(w, errors.Unauthorized(errorId, detail))
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := r.Header.Get(o.Header)
+			if strings.EqualFold(o.Header, "Authorization") {
+				if len(token) > 7 && strings.EqualFold(token[:7], "Bearer ") {
+					token = token[7:]
+				} else {
+					token = ""
+				}
+			}
+			if token == "" && o.Query != "" {
+				token = r.URL.Query().Get(o.Query)
+			}
+			if token == "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeError(w, errors.Unauthorized(errorId, "missing token"))
+				return
+			}
+
+			c, err := o.parse(v, token, time.Now())
+			if err != nil {
+				unauthorized(w, err.Error())
+				return
+			}
+
+			// never trust the claim headers sent by the client
+			for name, header := range o.Claims {
+				r.Header.Del(header)
+				switch cv := c[name].(type) {
+				case nil:
+				case string:
+					r.Header.Set(header, cv)
+				default:
+					b, _ := json.Marshal(cv)
+					r.Header.Set(header, string(b))
+				}
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}, nil
+}
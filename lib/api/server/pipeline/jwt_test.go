@@ -0,0 +1,121 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package pipeline
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hs256(secret, header, payload string) string {
+	signed := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(payload))
+	mac := hmac.New(crypto.SHA256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestJWT(t *testing.T) {
+	w, err := newJWT([]byte(`{"secret":"secret","issuer":"vine","audience":"api","leeway":"1s","claims":{"sub":"X-User-Id","roles":"X-Roles"}}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var user, roles string
+	h := w(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, roles = r.Header.Get("X-User-Id"), r.Header.Get("X-Roles")
+	}))
+
+	exp := time.Now().Add(time.Hour).Unix()
+	bearer := func(token string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + token, "X-Roles": "forged"}
+	}
+
+	token := hs256("secret", `{"alg":"HS256","typ":"JWT"}`, fmt.Sprintf(`{"sub":"joe","iss":"vine","aud":["api"],"exp":%d}`, exp))
+	assert.Equal(t, http.StatusOK, serve(h, http.MethodGet, "/", bearer(token)).Code)
+	assert.Equal(t, "joe", user)
+	assert.Empty(t, roles)
+
+	rsp := serve(h, http.MethodGet, "/", nil)
+	assert.Equal(t, http.StatusUnauthorized, rsp.Code)
+	assert.Equal(t, "Bearer", rsp.Header().Get("WWW-Authenticate"))
+
+	for name, token := range map[string]string{
+		"signature": hs256("other", `{"alg":"HS256"}`, `{"iss":"vine","aud":"api"}`),
+		"expired":   hs256("secret", `{"alg":"HS256"}`, fmt.Sprintf(`{"iss":"vine","aud":"api","exp":%d}`, time.Now().Add(-time.Minute).Unix())),
+		"issuer":    hs256("secret", `{"alg":"HS256"}`, `{"iss":"other","aud":"api"}`),
+		"audience":  hs256("secret", `{"alg":"HS256"}`, `{"iss":"vine","aud":"other"}`),
+		"none":      base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"vine","aud":"api"}`)) + ".",
+		"malformed": "abc",
+	} {
+		assert.Equal(t, http.StatusUnauthorized, serve(h, http.MethodGet, "/", bearer(token)).Code, name)
+	}
+}
+
+func TestJWTPublicKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	w, err := newJWT([]byte(fmt.Sprintf(`{"public_key":%q,"query":"access_token"}`, pub)))
+	if !assert.NoError(t, err) {
+		return
+	}
+	h := w(ok)
+
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256"}`)) + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"joe"}`))
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	token := signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+
+	assert.Equal(t, http.StatusOK, serve(h, http.MethodGet, "/?access_token="+token, nil).Code)
+	// a token signed with the public key as hmac secret is rejected
+	assert.Equal(t, http.StatusUnauthorized, serve(h, http.MethodGet, "/?access_token="+hs256(pub, `{"alg":"HS256"}`, `{}`), nil).Code)
+
+	_, err = newJWT([]byte(`{}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,76 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package pipeline
+
+import (
+	"github.com/vine-io/vine/lib/config"
+)
+
+var (
+	// DefaultPath is where the pipeline is read from the config
+	DefaultPath = []string{"api", "middleware"}
+)
+
+type Options struct {
+	// Config the pipeline is loaded from and watched in
+	Config config.Config
+	// Path of the pipeline in the config
+	Path []string
+	// Spec used when the path is not in the config
+	Spec *Spec
+}
+
+type Option func(o *Options)
+
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Path: DefaultPath,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithConfig loads the pipeline from the config and reloads it on change
+func WithConfig(c config.Config) Option {
+	return func(o *Options) {
+		o.Config = c
+	}
+}
+
+// WithPath sets the path of the pipeline in the config
+func WithPath(path ...string) Option {
+	return func(o *Options) {
+		o.Path = path
+	}
+}
+
+// WithSpec sets a static pipeline
+func WithSpec(s *Spec) Option {
+	return func(o *Options) {
+		o.Spec = s
+	}
+}
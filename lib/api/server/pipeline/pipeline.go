@@ -0,0 +1,328 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package pipeline runs the requests of the api gateway through the policies
+// declared in the config, e.g.
+//
+//	api:
+//	  middleware:
+//	    global:
+//	      - type: request_id
+//	      - type: access_log
+//	    routes:
+//	      - path: /greeter
+//	        methods: [POST]
+//	        policies:
+//	          - type: rate_limit
+//	            rate: 10
+//	            burst: 20
+//
+// Global policies apply to every request and run before the policies of the
+// route with the longest matching path prefix.
+package pipeline
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	json "github.com/json-iterator/go"
+	"github.com/vine-io/vine/lib/api/server"
+	"github.com/vine-io/vine/lib/config"
+	"github.com/vine-io/vine/lib/config/reader"
+	"github.com/vine-io/vine/lib/logger"
+)
+
+// Spec declares the pipeline
+type Spec struct {
+	// Global policies apply to every request
+	Global []*Policy `json:"global"`
+	// Routes add policies to the requests under a path
+	Routes []*Route `json:"routes"`
+}
+
+// Route applies policies to the requests whose path starts with Path
+type Route struct {
+	Path string `json:"path"`
+	// Methods restricts the route to the methods, all when empty
+	Methods  []string  `json:"methods"`
+	Policies []*Policy `json:"policies"`
+}
+
+// Policy is a middleware of the pipeline. The options of the policy are the
+// other fields of its object and are decoded by the factory of its type.
+type Policy struct {
+	Type string
+	Raw  json.RawMessage
+}
+
+func (p *Policy) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	p.Type = v.Type
+	p.Raw = append(p.Raw[:0], b...)
+	return nil
+}
+
+func (p *Policy) MarshalJSON() ([]byte, error) {
+	if len(p.Raw) > 0 {
+		return p.Raw, nil
+	}
+	return json.Marshal(map[string]string{"type": p.Type})
+}
+
+// Factory builds a policy from its json options
+type Factory func(raw []byte) (server.Wrapper, error)
+
+var (
+	mu sync.RWMutex
+	// DefaultPolicies are the policies which can be declared by their type
+	DefaultPolicies = map[string]Factory{
+		"headers":    newHeaders,
+		"ip_filter":  newIPFilter,
+		"body_limit": newBodyLimit,
+		"jwt":        newJWT,
+		"api_key":    newAPIKey,
+		"rate_limit": newRateLimit,
+		"request_id": newRequestID,
+		"access_log": newAccessLog,
+	}
+)
+
+// Register makes a policy available to the pipeline under the type name
+func Register(name string, f Factory) {
+	mu.Lock()
+	DefaultPolicies[name] = f
+	mu.Unlock()
+}
+
+func build(policies []*Policy) ([]server.Wrapper, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	wrappers := make([]server.Wrapper, 0, len(policies))
+	for _, p := range policies {
+		if p == nil {
+			continue
+		}
+		f, ok := DefaultPolicies[p.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown policy %q", p.Type)
+		}
+		raw := p.Raw
+		if len(raw) == 0 {
+			raw = []byte(`{}`)
+		}
+		w, err := f(raw)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %v", p.Type, err)
+		}
+		wrappers = append(wrappers, w)
+	}
+	return wrappers, nil
+}
+
+type route struct {
+	prefix   string
+	methods  map[string]bool
+	wrappers []server.Wrapper
+}
+
+func (r *route) match(req *http.Request) bool {
+	if len(r.methods) > 0 && !r.methods[req.Method] {
+		return false
+	}
+	path := req.URL.Path
+	prefix := strings.TrimSuffix(r.prefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// compiled is a spec with its policies built
+type compiled struct {
+	global []server.Wrapper
+	// routes ordered by the length of their prefix, longest first
+	routes []*route
+}
+
+func compile(spec *Spec) (*compiled, error) {
+	c := &compiled{}
+	if spec == nil {
+		return c, nil
+	}
+
+	var err error
+	if c.global, err = build(spec.Global); err != nil {
+		return nil, err
+	}
+
+	for _, r := range spec.Routes {
+		if r == nil {
+			continue
+		}
+		if !strings.HasPrefix(r.Path, "/") {
+			return nil, fmt.Errorf("route path %q must start with /", r.Path)
+		}
+		rt := &route{prefix: r.Path}
+		if len(r.Methods) > 0 {
+			rt.methods = map[string]bool{}
+			for _, m := range r.Methods {
+				rt.methods[strings.ToUpper(m)] = true
+			}
+		}
+		if rt.wrappers, err = build(r.Policies); err != nil {
+			return nil, fmt.Errorf("route %s: %v", r.Path, err)
+		}
+		c.routes = append(c.routes, rt)
+	}
+
+	sort.SliceStable(c.routes, func(i, j int) bool {
+		return len(c.routes[i].prefix) > len(c.routes[j].prefix)
+	})
+
+	return c, nil
+}
+
+// wrappers returns the policies which apply to the request in the order they run
+func (c *compiled) wrappers(req *http.Request) []server.Wrapper {
+	for _, r := range c.routes {
+		if r.match(req) {
+			if len(c.global) == 0 {
+				return r.wrappers
+			}
+			out := make([]server.Wrapper, 0, len(c.global)+len(r.wrappers))
+			out = append(out, c.global...)
+			return append(out, r.wrappers...)
+		}
+	}
+	return c.global
+}
+
+// Pipeline runs the requests through the policies of its spec
+type Pipeline struct {
+	opts Options
+
+	sync.RWMutex
+	spec     *Spec
+	compiled *compiled
+
+	sub *config.Subscription
+}
+
+// Handler runs the policies before h
+func (p *Pipeline) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.RLock()
+		c := p.compiled
+		p.RUnlock()
+
+		wrappers := c.wrappers(r)
+		next := h
+		for i := len(wrappers) - 1; i >= 0; i-- {
+			next = wrappers[i](next)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Wrapper returns the pipeline as a server.Wrapper
+func (p *Pipeline) Wrapper() server.Wrapper {
+	return p.Handler
+}
+
+// Update replaces the spec, the pipeline in use is kept when the spec is invalid
+func (p *Pipeline) Update(spec *Spec) error {
+	c, err := compile(spec)
+	if err != nil {
+		return err
+	}
+
+	p.Lock()
+	p.spec = spec
+	p.compiled = c
+	p.Unlock()
+	return nil
+}
+
+// Spec returns the spec in use
+func (p *Pipeline) Spec() *Spec {
+	p.RLock()
+	defer p.RUnlock()
+	return p.spec
+}
+
+func (p *Pipeline) load(v reader.Value) {
+	// fall back to the static spec when the path is not in the config
+	if b := strings.TrimSpace(string(v.Bytes())); b == "" || b == "null" {
+		if err := p.Update(p.opts.Spec); err != nil {
+			logger.Errorf("invalid middleware pipeline: %v", err)
+		}
+		return
+	}
+
+	spec := &Spec{}
+	if err := v.Scan(spec); err != nil {
+		logger.Errorf("unable to load middleware pipeline: %v", err)
+		return
+	}
+	if err := p.Update(spec); err != nil {
+		logger.Errorf("invalid middleware pipeline, keeping the current one: %v", err)
+	}
+}
+
+// Close stops watching the config
+func (p *Pipeline) Close() error {
+	if p.sub != nil {
+		return p.sub.Stop()
+	}
+	return nil
+}
+
+// NewPipeline returns a Pipeline, the spec is loaded from the config when one is given
+func NewPipeline(opts ...Option) *Pipeline {
+	options := NewOptions(opts...)
+	p := &Pipeline{
+		opts:     options,
+		compiled: &compiled{},
+	}
+
+	if options.Spec != nil {
+		if err := p.Update(options.Spec); err != nil {
+			logger.Errorf("invalid middleware pipeline: %v", err)
+		}
+	}
+
+	if options.Config != nil {
+		sub, err := config.Follow(options.Config, p.load, options.Path...)
+		if err != nil {
+			logger.Errorf("unable to watch middleware pipeline: %v", err)
+		}
+		p.sub = sub
+	}
+
+	return p
+}
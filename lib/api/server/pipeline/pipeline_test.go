@@ -0,0 +1,230 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package pipeline
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vine-io/vine/lib/api/server"
+	cmemory "github.com/vine-io/vine/lib/config/memory"
+	"github.com/vine-io/vine/lib/config/source"
+	smemory "github.com/vine-io/vine/lib/config/source/memory"
+)
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Seen", r.Header.Get("X-Tag"))
+	w.Write([]byte("ok"))
+})
+
+func spec(t *testing.T, s string) *Spec {
+	sp := &Spec{}
+	if err := json.Unmarshal([]byte(s), sp); err != nil {
+		t.Fatal(err)
+	}
+	return sp
+}
+
+func serve(h http.Handler, method, path string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rsp := httptest.NewRecorder()
+	h.ServeHTTP(rsp, req)
+	return rsp
+}
+
+func TestPipeline(t *testing.T) {
+	p := NewPipeline(WithSpec(spec(t, `{
+		"global": [{"type": "headers", "request": {"set": {"X-Tag": "global"}}}],
+		"routes": [
+			{"path": "/greeter", "policies": [{"type": "api_key", "keys": ["secret"]}]},
+			{"path": "/greeter/public", "policies": []},
+			{"path": "/admin", "methods": ["post"], "policies": [{"type": "ip_filter", "deny": ["192.0.2.0/24"]}]}
+		]
+	}`)))
+	defer p.Close()
+	h := p.Handler(ok)
+
+	rsp := serve(h, http.MethodGet, "/other", nil)
+	assert.Equal(t, http.StatusOK, rsp.Code)
+	assert.Equal(t, "global", rsp.Header().Get("X-Seen"))
+
+	assert.Equal(t, http.StatusUnauthorized, serve(h, http.MethodGet, "/greeter/call", nil).Code)
+	assert.Equal(t, http.StatusOK, serve(h, http.MethodGet, "/greeter/call", map[string]string{"X-API-Key": "secret"}).Code)
+	// the longest prefix wins and prefixes match whole segments
+	assert.Equal(t, http.StatusOK, serve(h, http.MethodGet, "/greeter/public/call", nil).Code)
+	assert.Equal(t, http.StatusOK, serve(h, http.MethodGet, "/greeterx", nil).Code)
+
+	// httptest requests come from 192.0.2.1
+	assert.Equal(t, http.StatusForbidden, serve(h, http.MethodPost, "/admin", nil).Code)
+	assert.Equal(t, http.StatusOK, serve(h, http.MethodGet, "/admin", nil).Code)
+
+	// an invalid spec keeps the pipeline in use
+	assert.Error(t, p.Update(spec(t, `{"global": [{"type": "unknown"}]}`)))
+	assert.Error(t, p.Update(spec(t, `{"routes": [{"path": "/a", "policies": [{"type": "rate_limit"}]}]}`)))
+	assert.Equal(t, http.StatusUnauthorized, serve(h, http.MethodGet, "/greeter/call", nil).Code)
+}
+
+func TestRegister(t *testing.T) {
+	Register("test_deny", func(raw []byte) (server.Wrapper, error) {
+		return func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			})
+		}, nil
+	})
+
+	p := NewPipeline(WithSpec(spec(t, `{"global": [{"type": "test_deny"}]}`)))
+	defer p.Close()
+	assert.Equal(t, http.StatusTeapot, serve(p.Handler(ok), http.MethodGet, "/", nil).Code)
+}
+
+func TestReload(t *testing.T) {
+	src := smemory.NewSource(smemory.WithJSON([]byte(`{"api":{"middleware":{"global":[{"type":"api_key","keys":["one"]}]}}}`)))
+	c := cmemory.NewConfig()
+	if err := c.Load(src); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	p := NewPipeline(WithConfig(c))
+	defer p.Close()
+	h := p.Handler(ok)
+
+	assert.Equal(t, http.StatusOK, serve(h, http.MethodGet, "/", map[string]string{"X-API-Key": "one"}).Code)
+
+	// the source starts watching in the background, keep pushing the change until it lands
+	assert.Eventually(t, func() bool {
+		src.(interface{ Update(*source.ChangeSet) }).Update(&source.ChangeSet{
+			Data:   []byte(`{"api":{"middleware":{"global":[{"type":"api_key","keys":["two"]}]}}}`),
+			Format: "json",
+		})
+		return serve(h, http.MethodGet, "/", map[string]string{"X-API-Key": "two"}).Code == http.StatusOK
+	}, time.Second*5, time.Millisecond*50)
+	assert.Equal(t, http.StatusUnauthorized, serve(h, http.MethodGet, "/", map[string]string{"X-API-Key": "one"}).Code)
+}
+
+func TestHeaders(t *testing.T) {
+	w, err := newHeaders([]byte(`{"request":{"remove":["X-Tag"]},"response":{"set":{"X-Frame-Options":"DENY"},"remove":["X-Seen"]}}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	rsp := serve(w(ok), http.MethodGet, "/", map[string]string{"X-Tag": "a"})
+	assert.Equal(t, "DENY", rsp.Header().Get("X-Frame-Options"))
+	assert.Empty(t, rsp.Header().Values("X-Seen"))
+}
+
+func TestIPFilter(t *testing.T) {
+	w, err := newIPFilter([]byte(`{"allow":["10.0.0.0/8"],"deny":["10.0.0.1"],"trust_forwarded":true}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	h := w(ok)
+	assert.Equal(t, http.StatusOK, serve(h, http.MethodGet, "/", map[string]string{"X-Forwarded-For": "10.1.2.3, 192.0.2.1"}).Code)
+	assert.Equal(t, http.StatusForbidden, serve(h, http.MethodGet, "/", map[string]string{"X-Forwarded-For": "10.0.0.1"}).Code)
+	assert.Equal(t, http.StatusForbidden, serve(h, http.MethodGet, "/", nil).Code)
+
+	_, err = newIPFilter([]byte(`{"allow":["nope"]}`))
+	assert.Error(t, err)
+}
+
+func TestBodyLimit(t *testing.T) {
+	w, err := newBodyLimit([]byte(`{"max_bytes":4}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	h := w(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		}
+	}))
+
+	rsp := httptest.NewRecorder()
+	h.ServeHTTP(rsp, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("1234")))
+	assert.Equal(t, http.StatusOK, rsp.Code)
+
+	rsp = httptest.NewRecorder()
+	h.ServeHTTP(rsp, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("12345")))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rsp.Code)
+
+	// without a content length the body is cut while it is read
+	req := httptest.NewRequest(http.MethodPost, "/", ioutil.NopCloser(strings.NewReader("12345")))
+	req.ContentLength = -1
+	rsp = httptest.NewRecorder()
+	h.ServeHTTP(rsp, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rsp.Code)
+}
+
+func TestRateLimit(t *testing.T) {
+	w, err := newRateLimit([]byte(`{"rate":1,"burst":2,"key":"header:X-User"}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	h := w(ok)
+	a := map[string]string{"X-User": "a"}
+	assert.Equal(t, http.StatusOK, serve(h, http.MethodGet, "/", a).Code)
+	assert.Equal(t, http.StatusOK, serve(h, http.MethodGet, "/", a).Code)
+	rsp := serve(h, http.MethodGet, "/", a)
+	assert.Equal(t, http.StatusTooManyRequests, rsp.Code)
+	assert.Equal(t, "1", rsp.Header().Get("Retry-After"))
+	// other clients have their own bucket
+	assert.Equal(t, http.StatusOK, serve(h, http.MethodGet, "/", map[string]string{"X-User": "b"}).Code)
+
+	l := &limiter{rate: 1, burst: 1, buckets: map[string]*bucket{}}
+	now := time.Now()
+	allowed, _ := l.allow("a", now)
+	assert.True(t, allowed)
+	allowed, wait := l.allow("a", now)
+	assert.False(t, allowed)
+	assert.Equal(t, time.Second, wait)
+	allowed, _ = l.allow("a", now.Add(time.Second))
+	assert.True(t, allowed)
+}
+
+func TestRequestID(t *testing.T) {
+	w, err := newRequestID([]byte(`{}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	var seen string
+	h := w(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Get("X-Request-Id")
+	}))
+
+	rsp := serve(h, http.MethodGet, "/", nil)
+	assert.NotEmpty(t, seen)
+	assert.Equal(t, seen, rsp.Header().Get("X-Request-Id"))
+
+	rsp = serve(h, http.MethodGet, "/", map[string]string{"X-Request-Id": "abc"})
+	assert.Equal(t, "abc", seen)
+	assert.Equal(t, "abc", rsp.Header().Get("X-Request-Id"))
+}
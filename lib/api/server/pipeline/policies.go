@@ -0,0 +1,469 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package pipeline
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	json "github.com/json-iterator/go"
+	"github.com/vine-io/vine/lib/api/server"
+	"github.com/vine-io/vine/lib/errors"
+	"github.com/vine-io/vine/lib/logger"
+)
+
+const errorId = "go.vine.api"
+
+func writeError(w http.ResponseWriter, err *errors.Error) {
+	b, _ := json.Marshal(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(int(err.Code))
+	w.Write(b)
+}
+
+func decode(raw []byte, v interface{}) error {
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("invalid options: %v", err)
+	}
+	return nil
+}
+
+// responseWriter records the status and the size of the response
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response does not implement http.Hijacker")
+	}
+	return h.Hijack()
+}
+
+type headerRules struct {
+	Set    map[string]string `json:"set"`
+	Add    map[string]string `json:"add"`
+	Remove []string          `json:"remove"`
+}
+
+func (r *headerRules) apply(h http.Header) {
+	for _, k := range r.Remove {
+		h.Del(k)
+	}
+	for k, v := range r.Set {
+		h.Set(k, v)
+	}
+	for k, v := range r.Add {
+		h.Add(k, v)
+	}
+}
+
+// headerWriter rewrites the response headers before they are sent
+type headerWriter struct {
+	*responseWriter
+	rules *headerRules
+	done  bool
+}
+
+func (w *headerWriter) WriteHeader(code int) {
+	if !w.done {
+		w.done = true
+		w.rules.apply(w.Header())
+	}
+	w.responseWriter.WriteHeader(code)
+}
+
+func (w *headerWriter) Write(b []byte) (int, error) {
+	if !w.done {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.responseWriter.Write(b)
+}
+
+// newHeaders rewrites the headers of the request and of the response
+//
+//	type: headers
+//	request: {set: {}, add: {}, remove: []}
+//	response: {set: {}, add: {}, remove: []}
+func newHeaders(raw []byte) (server.Wrapper, error) {
+	var o struct {
+		Request  headerRules `json:"request"`
+		Response headerRules `json:"response"`
+	}
+	if err := decode(raw, &o); err != nil {
+		return nil, err
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			o.Request.apply(r.Header)
+			h.ServeHTTP(&headerWriter{responseWriter: &responseWriter{ResponseWriter: w}, rules: &o.Response}, r)
+		})
+	}, nil
+}
+
+func parseNets(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip %q", s)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client, the first address of
+// X-Forwarded-For or X-Real-Ip is used when forwarded is true
+func clientIP(r *http.Request, forwarded bool) string {
+	if forwarded {
+		if v := r.Header.Get("X-Forwarded-For"); v != "" {
+			return strings.TrimSpace(strings.Split(v, ",")[0])
+		}
+		if v := r.Header.Get("X-Real-Ip"); v != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// newIPFilter rejects the clients which are denied or not allowed, deny wins
+//
+//	type: ip_filter
+//	allow: [10.0.0.0/8]
+//	deny: [10.0.0.1]
+//	trust_forwarded: false
+func newIPFilter(raw []byte) (server.Wrapper, error) {
+	var o struct {
+		Allow          []string `json:"allow"`
+		Deny           []string `json:"deny"`
+		TrustForwarded bool     `json:"trust_forwarded"`
+	}
+	if err := decode(raw, &o); err != nil {
+		return nil, err
+	}
+	allow, err := parseNets(o.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := parseNets(o.Deny)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := net.ParseIP(clientIP(r, o.TrustForwarded))
+			if ip == nil || contains(deny, ip) || (len(allow) > 0 && !contains(allow, ip)) {
+				writeError(w, errors.Forbidden(errorId, "access denied"))
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// newBodyLimit rejects the requests whose body is larger than max_bytes
+//
+//	type: body_limit
+//	max_bytes: 1048576
+func newBodyLimit(raw []byte) (server.Wrapper, error) {
+	var o struct {
+		MaxBytes int64 `json:"max_bytes"`
+	}
+	if err := decode(raw, &o); err != nil {
+		return nil, err
+	}
+	if o.MaxBytes <= 0 {
+		return nil, fmt.Errorf("max_bytes must be positive")
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > o.MaxBytes {
+				writeError(w, errors.New(errorId, "request body too large", http.StatusRequestEntityTooLarge))
+				return
+			}
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, o.MaxBytes)
+			}
+			h.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// newAPIKey accepts the requests carrying one of the keys
+//
+//	type: api_key
+//	keys: [secret]
+//	header: X-API-Key
+//	query: api_key
+func newAPIKey(raw []byte) (server.Wrapper, error) {
+	o := struct {
+		Keys   []string `json:"keys"`
+		Header string   `json:"header"`
+		Query  string   `json:"query"`
+	}{Header: "X-API-Key"}
+	if err := decode(raw, &o); err != nil {
+		return nil, err
+	}
+	if len(o.Keys) == 0 {
+		return nil, fmt.Errorf("no keys")
+	}
+
+	valid := func(key string) bool {
+		ok := 0
+		for _, k := range o.Keys {
+			ok |= subtle.ConstantTimeCompare([]byte(k), []byte(key))
+		}
+		return ok == 1
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(o.Header)
+			if key == "" && o.Query != "" {
+				key = r.URL.Query().Get(o.Query)
+			}
+			if key == "" || !valid(key) {
+				writeError(w, errors.Unauthorized(errorId, "invalid api key"))
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter is a token bucket per key
+type limiter struct {
+	sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+	pruned  time.Time
+}
+
+// allow takes a token, the wait until the next token is returned when there is none
+func (l *limiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	// drop the buckets which are full again
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.pruned) > full {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > full {
+				delete(l.buckets, k)
+			}
+		}
+		l.pruned = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// newRateLimit limits the requests per second of each client
+//
+//	type: rate_limit
+//	rate: 10
+//	burst: 20
+//	key: ip | global | header:X-User
+//	trust_forwarded: false
+func newRateLimit(raw []byte) (server.Wrapper, error) {
+	o := struct {
+		Rate           float64 `json:"rate"`
+		Burst          int     `json:"burst"`
+		Key            string  `json:"key"`
+		TrustForwarded bool    `json:"trust_forwarded"`
+	}{Key: "ip"}
+	if err := decode(raw, &o); err != nil {
+		return nil, err
+	}
+	if o.Rate <= 0 {
+		return nil, fmt.Errorf("rate must be positive")
+	}
+	if o.Burst <= 0 {
+		o.Burst = int(math.Ceil(o.Rate))
+	}
+
+	var key func(r *http.Request) string
+	switch {
+	case o.Key == "ip":
+		key = func(r *http.Request) string { return clientIP(r, o.TrustForwarded) }
+	case o.Key == "global":
+		key = func(r *http.Request) string { return "" }
+	case strings.HasPrefix(o.Key, "header:"):
+		name := strings.TrimPrefix(o.Key, "header:")
+		key = func(r *http.Request) string { return r.Header.Get(name) }
+	default:
+		return nil, fmt.Errorf("unknown key %q", o.Key)
+	}
+
+	l := &limiter{rate: o.Rate, burst: float64(o.Burst), buckets: map[string]*bucket{}}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ok, wait := l.allow(key(r), time.Now()); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				writeError(w, errors.TooManyRequests(errorId, "rate limit exceeded"))
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// newRequestID sets a request id on the requests which have none and
+// returns it in the response
+//
+//	type: request_id
+//	header: X-Request-Id
+func newRequestID(raw []byte) (server.Wrapper, error) {
+	o := struct {
+		Header string `json:"header"`
+	}{Header: "X-Request-Id"}
+	if err := decode(raw, &o); err != nil {
+		return nil, err
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(o.Header)
+			if id == "" {
+				id = uuid.New().String()
+				r.Header.Set(o.Header, id)
+			}
+			w.Header().Set(o.Header, id)
+			h.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// newAccessLog logs every request once it is served
+//
+//	type: access_log
+//	level: info
+//	trust_forwarded: false
+func newAccessLog(raw []byte) (server.Wrapper, error) {
+	o := struct {
+		Level          string `json:"level"`
+		TrustForwarded bool   `json:"trust_forwarded"`
+	}{Level: "info"}
+	if err := decode(raw, &o); err != nil {
+		return nil, err
+	}
+	level, err := logger.GetLevel(o.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := &responseWriter{ResponseWriter: w}
+			h.ServeHTTP(rw, r)
+			if rw.status == 0 {
+				rw.status = http.StatusOK
+			}
+			logger.Logf(level, "%s %s %s %d %d %s %q",
+				clientIP(r, o.TrustForwarded), r.Method, r.URL.RequestURI(), rw.status, rw.size, time.Since(start), r.UserAgent())
+		})
+	}, nil
+}
@@ -29,3 +29,8 @@ const (
 	Client        StreamType = "client"
 	Bidirectional StreamType = "bidirectional"
 )
+
+const (
+	// SSE is the endpoint metadata key which serves a server stream as Server-Sent Events
+	SSE = "sse"
+)
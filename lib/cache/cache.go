@@ -34,6 +34,8 @@ import (
 var (
 	// ErrNotFound is returned when a key doesn't exist
 	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when the record does not match the condition of a put
+	ErrConflict = errors.New("conflict")
 	// DefaultCache is the memory cache.
 	DefaultCache Cache
 
@@ -42,6 +44,8 @@ var (
 
 func init() {
 	Flag.String("cache.default", "", "Cache used for key-value storage")
+	Flag.Int("cache.max-entries", 0, "Bound the number of records of a table of the cache, zero is unbounded")
+	Flag.Int64("cache.max-bytes", 0, "Bound the size of the records of a table of the cache, zero is unbounded")
 }
 
 // Cache is a data cache interface
@@ -0,0 +1,337 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package file is a persistent cache storing every table in an append only log
+package file
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vine-io/vine/lib/cache"
+	log "github.com/vine-io/vine/lib/logger"
+)
+
+// NewCache returns a file cache
+func NewCache(opts ...cache.Option) cache.Cache {
+	f := &fileCache{
+		options: cache.Options{
+			Database: "vine",
+			Table:    "vine",
+		},
+		tables: map[string]*table{},
+	}
+	for _, o := range opts {
+		o(&f.options)
+	}
+	f.configure()
+	return f
+}
+
+type fileCache struct {
+	options cache.Options
+
+	sync.RWMutex
+	dir      string
+	interval time.Duration
+	// the open tables keyed by their path
+	tables map[string]*table
+	// exit stops the compaction of the expired records
+	exit chan bool
+}
+
+// escape makes the name safe to use as a file name
+func escape(name string) string {
+	return strings.ReplaceAll(url.PathEscape(name), ".", "%2E")
+}
+
+// configure reads the options of the context, the open tables are closed
+func (f *fileCache) configure() {
+	f.Lock()
+	defer f.Unlock()
+
+	f.dir = DefaultDir
+	f.interval = DefaultCompactInterval
+	if ctx := f.options.Context; ctx != nil {
+		if dir, ok := ctx.Value(dirKey{}).(string); ok {
+			f.dir = dir
+		}
+		if d, ok := ctx.Value(compactIntervalKey{}).(time.Duration); ok {
+			f.interval = d
+		}
+	}
+	f.closeTables()
+}
+
+// closeTables closes the open tables and stops the compaction
+func (f *fileCache) closeTables() error {
+	if f.exit != nil {
+		close(f.exit)
+		f.exit = nil
+	}
+	var gerr error
+	for path, t := range f.tables {
+		if err := t.close(); err != nil && gerr == nil {
+			gerr = err
+		}
+		delete(f.tables, path)
+	}
+	return gerr
+}
+
+// table returns the open table, the log is created when create is set
+func (f *fileCache) table(database, tbl string, create bool) (*table, error) {
+	if len(database) == 0 {
+		database = f.options.Database
+	}
+	if len(tbl) == 0 {
+		tbl = f.options.Table
+	}
+
+	f.RLock()
+	path := filepath.Join(f.dir, escape(database), escape(tbl)+".log")
+	t, ok := f.tables[path]
+	f.RUnlock()
+	if ok {
+		return t, nil
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	if t, ok = f.tables[path]; ok {
+		return t, nil
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) && !create {
+		return nil, nil
+	}
+	t, err := openTable(path)
+	if err != nil {
+		return nil, err
+	}
+	f.tables[path] = t
+
+	if f.exit == nil && f.interval > 0 {
+		f.exit = make(chan bool)
+		go f.run(f.interval, f.exit)
+	}
+	return t, nil
+}
+
+// run compacts the tables holding expired records every interval
+func (f *fileCache) run(interval time.Duration, exit chan bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-exit:
+			return
+		case <-ticker.C:
+			f.RLock()
+			tables := make([]*table, 0, len(f.tables))
+			for _, t := range f.tables {
+				tables = append(tables, t)
+			}
+			f.RUnlock()
+
+			for _, t := range tables {
+				if err := t.compactExpired(); err != nil {
+					log.Errorf("cache: compacting %s: %v", t.path, err)
+				}
+			}
+		}
+	}
+}
+
+func (f *fileCache) list(t *table, limit, offset uint) []string {
+	if t == nil {
+		return []string{}
+	}
+
+	allKeys := t.keys()
+
+	if limit != 0 || offset != 0 {
+		sort.Strings(allKeys)
+		if offset > uint(len(allKeys)) {
+			offset = uint(len(allKeys))
+		}
+		end := uint(len(allKeys))
+		if limit != 0 && offset+limit < end {
+			end = offset + limit
+		}
+		return allKeys[offset:end]
+	}
+
+	return allKeys
+}
+
+func (f *fileCache) Init(opts ...cache.Option) error {
+	for _, o := range opts {
+		o(&f.options)
+	}
+	f.configure()
+	return nil
+}
+
+func (f *fileCache) Options() cache.Options {
+	return f.options
+}
+
+func (f *fileCache) Get(ctx context.Context, key string, opts ...cache.GetOption) ([]*cache.Record, error) {
+	readOpts := cache.GetOptions{}
+	for _, o := range opts {
+		o(&readOpts)
+	}
+
+	t, err := f.table(readOpts.Database, readOpts.Table, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+
+	// Handle Prefix / suffix
+	if readOpts.Prefix || readOpts.Suffix {
+		k := f.list(t, readOpts.Limit, readOpts.Offset)
+
+		for _, kk := range k {
+			if readOpts.Prefix && !strings.HasPrefix(kk, key) {
+				continue
+			}
+
+			if readOpts.Suffix && !strings.HasSuffix(kk, key) {
+				continue
+			}
+
+			keys = append(keys, kk)
+		}
+	} else {
+		keys = []string{key}
+	}
+
+	var results []*cache.Record
+
+	for _, k := range keys {
+		if t == nil {
+			return results, cache.ErrNotFound
+		}
+		r, err := t.get(k)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, r)
+	}
+
+	return results, nil
+}
+
+func (f *fileCache) Put(ctx context.Context, r *cache.Record, opts ...cache.PutOption) error {
+	writeOpts := cache.PutOptions{}
+	for _, o := range opts {
+		o(&writeOpts)
+	}
+
+	t, err := f.table(writeOpts.Database, writeOpts.Table, true)
+	if err != nil {
+		return err
+	}
+
+	if len(opts) > 0 {
+		// Copy the record before applying options, or the incoming record will be mutated
+		newRecord := *r
+		if !writeOpts.Expiry.IsZero() {
+			newRecord.Expiry = time.Until(writeOpts.Expiry)
+		}
+		if writeOpts.TTL != 0 {
+			newRecord.Expiry = writeOpts.TTL
+		}
+		return t.put(&newRecord, writeOpts)
+	}
+
+	return t.put(r, writeOpts)
+}
+
+func (f *fileCache) Del(ctx context.Context, key string, opts ...cache.DelOption) error {
+	deleteOptions := cache.DelOptions{}
+	for _, o := range opts {
+		o(&deleteOptions)
+	}
+
+	t, err := f.table(deleteOptions.Database, deleteOptions.Table, false)
+	if err != nil || t == nil {
+		return err
+	}
+	return t.del(key)
+}
+
+func (f *fileCache) List(ctx context.Context, opts ...cache.ListOption) ([]string, error) {
+	listOptions := cache.ListOptions{}
+
+	for _, o := range opts {
+		o(&listOptions)
+	}
+
+	t, err := f.table(listOptions.Database, listOptions.Table, false)
+	if err != nil {
+		return nil, err
+	}
+	keys := f.list(t, listOptions.Limit, listOptions.Offset)
+
+	if len(listOptions.Prefix) > 0 {
+		var prefixKeys []string
+		for _, k := range keys {
+			if strings.HasPrefix(k, listOptions.Prefix) {
+				prefixKeys = append(prefixKeys, k)
+			}
+		}
+		keys = prefixKeys
+	}
+
+	if len(listOptions.Suffix) > 0 {
+		var suffixKeys []string
+		for _, k := range keys {
+			if strings.HasSuffix(k, listOptions.Suffix) {
+				suffixKeys = append(suffixKeys, k)
+			}
+		}
+		keys = suffixKeys
+	}
+
+	return keys, nil
+}
+
+// Close closes the logs, they are opened again on use
+func (f *fileCache) Close() error {
+	f.Lock()
+	defer f.Unlock()
+	return f.closeTables()
+}
+
+func (f *fileCache) String() string {
+	return "file"
+}
@@ -0,0 +1,249 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package file
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vine-io/vine/lib/cache"
+)
+
+func newCache(t *testing.T, opts ...cache.Option) (cache.Cache, string) {
+	dir, err := ioutil.TempDir("", "vine-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return NewCache(append([]cache.Option{WithDir(dir)}, opts...)...), dir
+}
+
+func TestFileBasic(t *testing.T) {
+	s, _ := newCache(t)
+	defer s.Close()
+	ctx := context.TODO()
+
+	if _, err := s.Get(ctx, "foo"); err != cache.ErrNotFound {
+		t.Errorf("expected %v, got %v", cache.ErrNotFound, err)
+	}
+	for i := 0; i < 10; i++ {
+		k := fmt.Sprintf("foo%d", i)
+		if err := s.Put(ctx, &cache.Record{Key: k, Value: []byte(k), Metadata: map[string]interface{}{"i": "v"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Put(ctx, &cache.Record{Key: "barfoo", Value: []byte("bar")}, cache.PutTTL(time.Millisecond*50))
+
+	results, err := s.Get(ctx, "foo1")
+	if err != nil || len(results) != 1 || string(results[0].Value) != "foo1" || results[0].Metadata["i"] != "v" {
+		t.Errorf("unexpected %v %v", results, err)
+	}
+	// the keys are sorted before the offset, barfoo is the first
+	if results, _ := s.Get(ctx, "foo", cache.GetPrefix(), cache.GetLimit(3), cache.GetOffset(2)); len(results) != 3 || results[0].Key != "foo1" {
+		t.Errorf("expected foo1 to foo3, got %v", results)
+	}
+	if results, _ := s.Get(ctx, "foo", cache.GetSuffix()); len(results) != 1 || results[0].Expiry <= 0 {
+		t.Errorf("expected barfoo with an expiry, got %v", results)
+	}
+	if keys, _ := s.List(ctx, cache.ListLimit(2), cache.ListOffset(9)); fmt.Sprint(keys) != "[foo8 foo9]" {
+		t.Errorf("expected [foo8 foo9], got %v", keys)
+	}
+
+	time.Sleep(time.Millisecond * 60)
+	if results, _ := s.Get(ctx, "foo", cache.GetSuffix()); len(results) != 0 {
+		t.Errorf("expected barfoo to expire, got %v", results)
+	}
+
+	if err := s.Del(ctx, "foo1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "foo1"); err != cache.ErrNotFound {
+		t.Errorf("expected %v, got %v", cache.ErrNotFound, err)
+	}
+
+	// tables are namespaced
+	s.Put(ctx, &cache.Record{Key: "foo1", Value: []byte("other")}, cache.PutTo("..", "other"))
+	if results, _ := s.Get(ctx, "foo1", cache.GetFrom("..", "other")); len(results) != 1 || string(results[0].Value) != "other" {
+		t.Errorf("unexpected %v", results)
+	}
+	if keys, _ := s.List(ctx, cache.ListFrom("..", "other")); len(keys) != 1 {
+		t.Errorf("expected 1 key, got %v", keys)
+	}
+}
+
+func TestFilePersistence(t *testing.T) {
+	s, dir := newCache(t)
+	ctx := context.TODO()
+	s.Put(ctx, &cache.Record{Key: "a", Value: []byte("1")})
+	s.Put(ctx, &cache.Record{Key: "b", Value: []byte("2")})
+	s.Put(ctx, &cache.Record{Key: "a", Value: []byte("3")})
+	s.Put(ctx, &cache.Record{Key: "c", Value: []byte("4"), Expiry: time.Millisecond})
+	s.Del(ctx, "b")
+	s.Close()
+
+	// a torn write at the end of the log is dropped
+	path := filepath.Join(dir, "vine", "vine.log")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 42, 1, 2})
+	f.Close()
+
+	time.Sleep(time.Millisecond * 2)
+	s = NewCache(WithDir(dir))
+	defer s.Close()
+	if keys, _ := s.List(ctx); fmt.Sprint(keys) != "[a]" {
+		t.Errorf("expected [a], got %v", keys)
+	}
+	if results, _ := s.Get(ctx, "a"); len(results) != 1 || string(results[0].Value) != "3" {
+		t.Errorf("unexpected %v", results)
+	}
+	if err := s.Put(ctx, &cache.Record{Key: "d", Value: []byte("5")}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if keys, _ := s.List(ctx, cache.ListLimit(10)); fmt.Sprint(keys) != "[a d]" {
+		t.Errorf("expected [a d], got %v", keys)
+	}
+}
+
+func TestFileCompaction(t *testing.T) {
+	threshold := DefaultCompactThreshold
+	DefaultCompactThreshold = 10
+	defer func() { DefaultCompactThreshold = threshold }()
+
+	s, dir := newCache(t, WithCompactInterval(time.Millisecond*10))
+	defer s.Close()
+	ctx := context.TODO()
+	path := filepath.Join(dir, "vine", "vine.log")
+
+	for i := 0; i < 11; i++ {
+		s.Put(ctx, &cache.Record{Key: "a", Value: []byte(fmt.Sprint(i))})
+	}
+	s.Put(ctx, &cache.Record{Key: "b", Value: []byte("b")}, cache.PutTTL(time.Millisecond*20))
+
+	// the stale records of a are dropped
+	tbl, _ := s.(*fileCache).table("", "", false)
+	tbl.RLock()
+	size := tbl.size
+	tbl.RUnlock()
+	if info, err := os.Stat(path); err != nil || info.Size() != size {
+		t.Fatalf("expected the log of %d bytes, got %v %v", size, info, err)
+	}
+	if results, _ := s.Get(ctx, "a"); len(results) != 1 || string(results[0].Value) != "10" {
+		t.Errorf("unexpected %v", results)
+	}
+
+	// the expired b is removed from the log
+	deadline := time.Now().Add(time.Second)
+	for {
+		info, _ := os.Stat(path)
+		if info.Size() < size {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the expired record was not compacted")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if _, err := os.Stat(path + ".compact"); !os.IsNotExist(err) {
+		t.Errorf("expected no leftover of the compaction, got %v", err)
+	}
+}
+
+func TestFileLock(t *testing.T) {
+	s, dir := newCache(t)
+	ctx := context.TODO()
+	if err := s.Put(ctx, &cache.Record{Key: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a log has a single writer
+	other := NewCache(WithDir(dir))
+	if err := other.Put(ctx, &cache.Record{Key: "b"}); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Fatalf("expected the log to be locked, got %v", err)
+	}
+
+	s.Close()
+	if err := other.Put(ctx, &cache.Record{Key: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	other.Close()
+}
+
+func TestFileCorrupt(t *testing.T) {
+	s, dir := newCache(t)
+	ctx := context.TODO()
+	s.Put(ctx, &cache.Record{Key: "a", Value: []byte("1")})
+	s.Put(ctx, &cache.Record{Key: "b", Value: []byte("2")})
+	s.Close()
+
+	// a corrupt frame before the tail is not truncated
+	path := filepath.Join(dir, "vine", "vine.log")
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[headerSize+2] ^= 0xff
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s = NewCache(WithDir(dir))
+	defer s.Close()
+	if _, err := s.Get(ctx, "b"); err == nil || !strings.Contains(err.Error(), "corrupt frame at offset 0") {
+		t.Fatalf("expected the log to be corrupt, got %v", err)
+	}
+	if info, _ := os.Stat(path); info.Size() != int64(len(b)) {
+		t.Fatalf("expected the log to be kept, got %d bytes", info.Size())
+	}
+}
+
+func TestFilePutIfMatch(t *testing.T) {
+	s, _ := newCache(t)
+	defer s.Close()
+	ctx := context.TODO()
+
+	if err := s.Put(ctx, &cache.Record{Key: "a", Value: []byte("1")}, cache.PutIfMatch(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, &cache.Record{Key: "a", Value: []byte("2")}, cache.PutIfMatch(nil)); err != cache.ErrConflict {
+		t.Errorf("expected %v, got %v", cache.ErrConflict, err)
+	}
+	if err := s.Put(ctx, &cache.Record{Key: "a", Value: []byte("2")}, cache.PutIfMatch([]byte("3"))); err != cache.ErrConflict {
+		t.Errorf("expected %v, got %v", cache.ErrConflict, err)
+	}
+	if err := s.Put(ctx, &cache.Record{Key: "a", Value: []byte("2")}, cache.PutIfMatch([]byte("1"))); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := s.Get(ctx, "a"); err != nil || string(r[0].Value) != "2" {
+		t.Errorf("expected 2, got %v %v", r, err)
+	}
+}
@@ -0,0 +1,79 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vine-io/vine/lib/cache"
+)
+
+var (
+	// DefaultDir is the directory of the logs of the tables, it is specific to
+	// the executable as a log can only be opened by a single process
+	DefaultDir = defaultDir()
+	// DefaultCompactInterval is the interval of the removal of the expired records
+	DefaultCompactInterval = time.Minute
+	// DefaultCompactThreshold is the number of stale records of a table over
+	// which it is compacted when they outnumber the live ones
+	DefaultCompactThreshold = 1000
+)
+
+func defaultDir() string {
+	if dir := os.Getenv("VINE_CACHE_DIR"); len(dir) > 0 {
+		return dir
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "vine", "cache", filepath.Base(os.Args[0]))
+}
+
+type dirKey struct{}
+type compactIntervalKey struct{}
+
+func withValue(o *cache.Options, k, v interface{}) {
+	if o.Context == nil {
+		o.Context = context.Background()
+	}
+	o.Context = context.WithValue(o.Context, k, v)
+}
+
+// WithDir sets the directory of the logs, DefaultDir by default
+func WithDir(dir string) cache.Option {
+	return func(o *cache.Options) {
+		withValue(o, dirKey{}, dir)
+	}
+}
+
+// WithCompactInterval sets the interval of the removal of the expired records,
+// zero disables it
+func WithCompactInterval(d time.Duration) cache.Option {
+	return func(o *cache.Options) {
+		withValue(o, compactIntervalKey{}, d)
+	}
+}
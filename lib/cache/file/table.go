@@ -0,0 +1,426 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package file
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	json "github.com/json-iterator/go"
+
+	"github.com/vine-io/vine/lib/cache"
+	log "github.com/vine-io/vine/lib/logger"
+)
+
+// headerSize is the size of the header of a frame, the length and the crc32
+// of the payload which follows
+const headerSize = 8
+
+var (
+	errCorrupt = errors.New("corrupt frame")
+	errLocked  = errors.New("locked by another process")
+)
+
+// frame is a record appended to the log of a table
+type frame struct {
+	Key      string                 `json:"k"`
+	Value    []byte                 `json:"v,omitempty"`
+	Metadata map[string]interface{} `json:"m,omitempty"`
+	// Expires is the expiry in unix nanoseconds, zero never expires
+	Expires int64 `json:"e,omitempty"`
+	// Deleted marks the deletion of the key
+	Deleted bool `json:"d,omitempty"`
+}
+
+func (f *frame) record() *cache.Record {
+	r := &cache.Record{
+		Key:      f.Key,
+		Value:    f.Value,
+		Metadata: f.Metadata,
+	}
+	if r.Value == nil {
+		r.Value = []byte{}
+	}
+	if r.Metadata == nil {
+		r.Metadata = map[string]interface{}{}
+	}
+	if f.Expires != 0 {
+		r.Expiry = time.Until(time.Unix(0, f.Expires))
+	}
+	return r
+}
+
+// location is the position of the last frame of a key in the log
+type location struct {
+	offset  int64
+	size    int64
+	expires int64
+}
+
+func (l *location) expired(now int64) bool {
+	return l.expires != 0 && now > l.expires
+}
+
+// table is the append only log of a table and the index of its keys
+type table struct {
+	sync.RWMutex
+
+	path  string
+	f     *os.File
+	size  int64
+	index map[string]*location
+	// stale is the number of frames of the log which are overwritten or deleted
+	stale int
+}
+
+func openTable(path string) (*table, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	// a log has a single writer, the one which locks it
+	if err := lock(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("cache: open %s: %v", path, err)
+	}
+	// the log is replaced by the compacted one atomically, a leftover is from an interrupted compaction
+	os.Remove(path + ".compact")
+
+	t := &table{
+		path:  path,
+		f:     f,
+		index: map[string]*location{},
+	}
+	if err := t.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// load replays the log. The torn tail of an interrupted write is truncated,
+// a corrupt frame before it fails as the records which follow would be lost.
+func (t *table) load() error {
+	info, err := t.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(t.f)
+	header := make([]byte, headerSize)
+	var offset int64
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return err
+		}
+		n := int64(binary.BigEndian.Uint32(header))
+		end := offset + headerSize + n
+		if end > info.Size() {
+			break
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return err
+		}
+		fr, err := decode(header, payload)
+		if err != nil {
+			if end == info.Size() {
+				break
+			}
+			return fmt.Errorf("cache: %s has a %v at offset %d", t.path, err, offset)
+		}
+		t.apply(fr, offset, headerSize+n)
+		offset += headerSize + n
+	}
+
+	if info.Size() > offset {
+		log.Warnf("cache: truncating %s at %d, the %d bytes after it are a torn write", t.path, offset, info.Size()-offset)
+		if err := t.f.Truncate(offset); err != nil {
+			return err
+		}
+		if err := t.f.Sync(); err != nil {
+			return err
+		}
+	}
+	t.size = offset
+	return nil
+}
+
+func encode(fr *frame) ([]byte, error) {
+	payload, err := json.Marshal(fr)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, headerSize+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(payload))
+	copy(buf[headerSize:], payload)
+	return buf, nil
+}
+
+func decode(header, payload []byte) (*frame, error) {
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, errCorrupt
+	}
+	fr := &frame{}
+	if err := json.Unmarshal(payload, fr); err != nil {
+		return nil, errCorrupt
+	}
+	return fr, nil
+}
+
+// apply indexes the frame written at offset
+func (t *table) apply(fr *frame, offset, size int64) {
+	if _, ok := t.index[fr.Key]; ok {
+		delete(t.index, fr.Key)
+		t.stale++
+	}
+	if fr.Deleted {
+		t.stale++
+		return
+	}
+	t.index[fr.Key] = &location{offset: offset, size: size, expires: fr.Expires}
+}
+
+// write appends the frame and syncs the log before indexing it
+func (t *table) write(fr *frame) error {
+	buf, err := encode(fr)
+	if err != nil {
+		return err
+	}
+	if _, err := t.f.WriteAt(buf, t.size); err != nil {
+		// drop what was written of the frame
+		t.f.Truncate(t.size)
+		return err
+	}
+	if err := t.f.Sync(); err != nil {
+		return err
+	}
+	t.apply(fr, t.size, int64(len(buf)))
+	t.size += int64(len(buf))
+	return nil
+}
+
+func (t *table) read(l *location) (*frame, error) {
+	buf := make([]byte, l.size)
+	if _, err := t.f.ReadAt(buf, l.offset); err != nil {
+		return nil, err
+	}
+	return decode(buf[:headerSize], buf[headerSize:])
+}
+
+func (t *table) get(key string) (*cache.Record, error) {
+	t.RLock()
+	defer t.RUnlock()
+
+	l, ok := t.index[key]
+	if !ok || l.expired(time.Now().UnixNano()) {
+		return nil, cache.ErrNotFound
+	}
+	fr, err := t.read(l)
+	if err != nil {
+		return nil, err
+	}
+	return fr.record(), nil
+}
+
+func (t *table) keys() []string {
+	t.RLock()
+	defer t.RUnlock()
+
+	now := time.Now().UnixNano()
+	keys := make([]string, 0, len(t.index))
+	for k, l := range t.index {
+		if !l.expired(now) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func (t *table) put(r *cache.Record, o cache.PutOptions) error {
+	fr := &frame{
+		Key:      r.Key,
+		Value:    r.Value,
+		Metadata: r.Metadata,
+	}
+	if r.Expiry != 0 {
+		fr.Expires = time.Now().Add(r.Expiry).UnixNano()
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	if o.Conditional {
+		var v []byte
+		l, ok := t.index[fr.Key]
+		if ok && l.expired(time.Now().UnixNano()) {
+			ok = false
+		}
+		if ok {
+			cur, err := t.read(l)
+			if err != nil {
+				return err
+			}
+			v = cur.Value
+		}
+		if o.Conflicts(v, ok) {
+			return cache.ErrConflict
+		}
+	}
+	if err := t.write(fr); err != nil {
+		return err
+	}
+	return t.maybeCompact()
+}
+
+func (t *table) del(key string) error {
+	t.Lock()
+	defer t.Unlock()
+	if _, ok := t.index[key]; !ok {
+		return nil
+	}
+	if err := t.write(&frame{Key: key, Deleted: true}); err != nil {
+		return err
+	}
+	return t.maybeCompact()
+}
+
+// maybeCompact compacts the log when the stale frames outnumber the live ones
+func (t *table) maybeCompact() error {
+	if t.stale < DefaultCompactThreshold || t.stale <= len(t.index) {
+		return nil
+	}
+	return t.compact()
+}
+
+// compactExpired compacts the log when it holds expired records
+func (t *table) compactExpired() error {
+	t.Lock()
+	defer t.Unlock()
+
+	now := time.Now().UnixNano()
+	for _, l := range t.index {
+		if l.expired(now) {
+			return t.compact()
+		}
+	}
+	return nil
+}
+
+// compact rewrites the live records to a new log which then replaces the
+// current one, a crash leaves either of them intact
+func (t *table) compact() error {
+	tmp := t.path + ".compact"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	// the new log is locked before it replaces the current one
+	if err := lock(f); err != nil {
+		return fail(err)
+	}
+
+	// keep the order of the log
+	now := time.Now().UnixNano()
+	keys := make([]string, 0, len(t.index))
+	for k, l := range t.index {
+		if !l.expired(now) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return t.index[keys[i]].offset < t.index[keys[j]].offset
+	})
+
+	w := bufio.NewWriter(f)
+	index := make(map[string]*location, len(keys))
+	var size int64
+	for _, k := range keys {
+		l := t.index[k]
+		buf := make([]byte, l.size)
+		if _, err := t.f.ReadAt(buf, l.offset); err != nil {
+			return fail(err)
+		}
+		if _, err := w.Write(buf); err != nil {
+			return fail(err)
+		}
+		index[k] = &location{offset: size, size: l.size, expires: l.expires}
+		size += l.size
+	}
+	if err := w.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := f.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		return fail(err)
+	}
+	if err := syncDir(filepath.Dir(t.path)); err != nil {
+		log.Warnf("cache: syncing the directory of %s: %v", t.path, err)
+	}
+
+	t.f.Close()
+	t.f = f
+	t.size = size
+	t.index = index
+	t.stale = 0
+	return nil
+}
+
+func (t *table) close() error {
+	t.Lock()
+	defer t.Unlock()
+	return t.f.Close()
+}
+
+// syncDir persists the rename of a file of the directory
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
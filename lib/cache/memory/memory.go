@@ -24,15 +24,14 @@
 package memory
 
 import (
+	"container/list"
 	"context"
-	"errors"
-	"path/filepath"
+	"fmt"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
-	gcache "github.com/patrickmn/go-cache"
-
 	"github.com/vine-io/vine/lib/cache"
 )
 
@@ -43,130 +42,448 @@ func NewCache(opts ...cache.Option) cache.Cache {
 			Database: "vine",
 			Table:    "vine",
 		},
-		cache: gcache.New(gcache.NoExpiration, 5*time.Minute),
+		partitions: map[string]*partition{},
 	}
 	for _, o := range opts {
 		o(&s.options)
 	}
+	s.configure()
 	return s
 }
 
 type memoryCache struct {
 	options cache.Options
 
-	cache *gcache.Cache
+	sync.RWMutex
+	// the tables keyed by database/table
+	partitions map[string]*partition
+	// the options read from the context
+	policy   Policy
+	limits   map[string]Limits
+	evict    EvictFunc
+	interval time.Duration
+	// exit stops the purge of the expired records
+	exit chan bool
 }
 
-type cacheRecord struct {
+type entry struct {
 	key       string
 	value     []byte
 	metadata  map[string]interface{}
 	expiresAt time.Time
+	size      int64
+
+	// the bookkeeping of the policy
+	elem    *list.Element
+	segment int
+	freq    uint64
+	tick    uint64
+	index   int
 }
 
-func (m *memoryCache) key(prefix, key string) string {
-	return filepath.Join(prefix, key)
+func (e *entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && now.After(e.expiresAt)
 }
 
-func (m *memoryCache) prefix(database, table string) string {
-	if len(database) == 0 {
-		database = m.options.Database
+// record returns a copy of the entry
+func (e *entry) record() *cache.Record {
+	r := &cache.Record{
+		Key:      e.key,
+		Value:    make([]byte, len(e.value)),
+		Metadata: make(map[string]interface{}, len(e.metadata)),
 	}
-	if len(table) == 0 {
-		table = m.options.Table
+	copy(r.Value, e.value)
+	if !e.expiresAt.IsZero() {
+		r.Expiry = time.Until(e.expiresAt)
+	}
+	for k, v := range e.metadata {
+		r.Metadata[k] = v
 	}
-	return filepath.Join(database, table)
+	return r
+}
+
+type eviction struct {
+	e      *entry
+	reason Reason
 }
 
-func (m *memoryCache) get(prefix, key string) (*cache.Record, error) {
-	key = m.key(prefix, key)
+// partition is a table with its own limits and policy
+type partition struct {
+	sync.Mutex
 
-	var cachedRecord *cacheRecord
-	r, found := m.cache.Get(key)
-	if !found {
-		return nil, cache.ErrNotFound
+	database, table string
+	limits          Limits
+	items           map[string]*entry
+	policy          policy
+	bytes           int64
+
+	hits, misses, evictions, expirations uint64
+}
+
+// purge removes the expired records
+func (p *partition) purge(now time.Time) []eviction {
+	var evicted []eviction
+	for _, e := range p.items {
+		if e.expired(now) {
+			p.remove(e)
+			p.expirations++
+			evicted = append(evicted, eviction{e, ReasonExpired})
+		}
 	}
+	return evicted
+}
 
-	cachedRecord, ok := r.(*cacheRecord)
+// PartitionStats describes a table of the cache
+type PartitionStats struct {
+	Database   string `json:"database"`
+	Table      string `json:"table"`
+	Policy     Policy `json:"policy"`
+	Entries    int    `json:"entries"`
+	Bytes      int64  `json:"bytes"`
+	MaxEntries int    `json:"max_entries"`
+	MaxBytes   int64  `json:"max_bytes"`
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	// Evictions counts the records evicted by the policy
+	Evictions uint64 `json:"evictions"`
+	// Expirations counts the expired records removed
+	Expirations uint64 `json:"expirations"`
+}
+
+// Stats returns the stats of the tables of a memory cache sorted by name,
+// nil for the other caches
+func Stats(c cache.Cache) []*PartitionStats {
+	m, ok := c.(*memoryCache)
 	if !ok {
-		return nil, errors.New("retrieved a non *cacheRecord from the cache")
+		return nil
 	}
+	return m.Stats()
+}
 
-	// Copy the record on the way out
-	newRecord := &cache.Record{}
-	newRecord.Key = strings.TrimPrefix(cachedRecord.key, prefix+"/")
-	newRecord.Value = make([]byte, len(cachedRecord.value))
-	newRecord.Metadata = make(map[string]interface{})
+func partitionName(database, table string) string {
+	return database + "/" + table
+}
 
-	// copy the value into the new record
-	copy(newRecord.Value, cachedRecord.value)
+func newPartition(database, table string, l Limits) *partition {
+	return &partition{
+		database: database,
+		table:    table,
+		limits:   l,
+		items:    map[string]*entry{},
+		policy:   newPolicy(l.Policy, l.MaxEntries),
+	}
+}
 
-	// check if we need to set the expiry
-	if !cachedRecord.expiresAt.IsZero() {
-		newRecord.Expiry = time.Until(cachedRecord.expiresAt)
+// setLimits applies new limits, the records are ordered again when the policy changes
+func (p *partition) setLimits(l Limits) []eviction {
+	if l.Policy != p.limits.Policy {
+		p.policy = newPolicy(l.Policy, l.MaxEntries)
+		for _, e := range p.items {
+			p.policy.add(e)
+		}
 	}
+	p.limits = l
+	return p.shrink(time.Now())
+}
+
+func (p *partition) over() bool {
+	return (p.limits.MaxEntries > 0 && len(p.items) > p.limits.MaxEntries) ||
+		(p.limits.MaxBytes > 0 && p.bytes > p.limits.MaxBytes)
+}
 
-	// copy in the metadata
-	for k, v := range cachedRecord.metadata {
-		newRecord.Metadata[k] = v
+// shrink evicts the records over the limits
+func (p *partition) shrink(now time.Time) []eviction {
+	var evicted []eviction
+	for p.over() {
+		e := p.policy.victim()
+		if e == nil {
+			break
+		}
+		p.remove(e)
+		reason := ReasonCapacity
+		if e.expired(now) {
+			reason = ReasonExpired
+			p.expirations++
+		} else {
+			p.evictions++
+		}
+		evicted = append(evicted, eviction{e, reason})
 	}
+	return evicted
+}
 
-	return newRecord, nil
+func (p *partition) remove(e *entry) {
+	delete(p.items, e.key)
+	p.bytes -= e.size
+	p.policy.remove(e)
 }
 
-func (m *memoryCache) set(prefix string, r *cache.Record) {
-	key := m.key(prefix, r.Key)
+func (p *partition) get(key string, now time.Time) (*cache.Record, []eviction) {
+	e, ok := p.items[key]
+	if !ok {
+		p.misses++
+		return nil, nil
+	}
+	if e.expired(now) {
+		p.remove(e)
+		p.misses++
+		p.expirations++
+		return nil, []eviction{{e, ReasonExpired}}
+	}
+	p.hits++
+	p.policy.hit(e)
+	return e.record(), nil
+}
 
-	// copy the incoming record and then
-	// convert the expiry in to a hard timestamp
-	i := &cacheRecord{}
-	i.key = r.Key
-	i.value = make([]byte, len(r.Value))
-	i.metadata = make(map[string]interface{})
+func (p *partition) set(e *entry) ([]eviction, error) {
+	if p.limits.MaxBytes > 0 && e.size > p.limits.MaxBytes {
+		return nil, fmt.Errorf("record %s of %d bytes is over the limit of %d bytes", e.key, e.size, p.limits.MaxBytes)
+	}
+	if old, ok := p.items[e.key]; ok {
+		p.remove(old)
+	}
+	p.items[e.key] = e
+	p.bytes += e.size
+	p.policy.add(e)
+	return p.shrink(time.Now()), nil
+}
 
-	// copy the the value
-	copy(i.value, r.Value)
+func (p *partition) stats() *PartitionStats {
+	p.Lock()
+	defer p.Unlock()
+	return &PartitionStats{
+		Database:    p.database,
+		Table:       p.table,
+		Policy:      p.limits.Policy,
+		Entries:     len(p.items),
+		Bytes:       p.bytes,
+		MaxEntries:  p.limits.MaxEntries,
+		MaxBytes:    p.limits.MaxBytes,
+		Hits:        p.hits,
+		Misses:      p.misses,
+		Evictions:   p.evictions,
+		Expirations: p.expirations,
+	}
+}
 
-	// set the expiry
-	if r.Expiry != 0 {
-		i.expiresAt = time.Now().Add(r.Expiry)
+// configure reads the options of the context and applies the limits to the tables
+func (m *memoryCache) configure() {
+	m.Lock()
+	m.policy = DefaultPolicy
+	m.limits = nil
+	m.evict = nil
+	m.interval = DefaultPurgeInterval
+	if ctx := m.options.Context; ctx != nil {
+		if p, ok := ctx.Value(policyKey{}).(Policy); ok {
+			m.policy = p
+		}
+		if l, ok := ctx.Value(partitionsKey{}).(map[string]Limits); ok {
+			m.limits = l
+		}
+		if fn, ok := ctx.Value(evictKey{}).(EvictFunc); ok {
+			m.evict = fn
+		}
+		if d, ok := ctx.Value(purgeIntervalKey{}).(time.Duration); ok {
+			m.interval = d
+		}
+	}
+	// the purge is started again with the new interval
+	m.stop()
+
+	var evicted []eviction
+	var from []*partition
+	for _, p := range m.partitions {
+		p.Lock()
+		list := p.setLimits(m.limitsOf(p.database, p.table))
+		p.Unlock()
+		for range list {
+			from = append(from, p)
+		}
+		evicted = append(evicted, list...)
 	}
+	evict := m.evict
+	m.Unlock()
 
-	// set the metadata
-	for k, v := range r.Metadata {
-		i.metadata[k] = v
+	for i, ev := range evicted {
+		if evict != nil {
+			evict(from[i].database, from[i].table, ev.e.record(), ev.reason)
+		}
 	}
+}
 
-	m.cache.Set(key, i, r.Expiry)
+// limitsOf returns the limits of the table, the ones of the cache by default
+func (m *memoryCache) limitsOf(database, table string) Limits {
+	l := m.limits[partitionName(database, table)]
+	if l.MaxEntries == 0 {
+		l.MaxEntries = m.options.MaxEntries
+	}
+	if l.MaxBytes == 0 {
+		l.MaxBytes = m.options.MaxBytes
+	}
+	if l.Policy == "" {
+		l.Policy = m.policy
+	}
+	return l
 }
 
-func (m *memoryCache) delete(prefix, key string) {
-	key = m.key(prefix, key)
-	m.cache.Delete(key)
+// partition returns the table, it is created when create is set
+func (m *memoryCache) partition(database, table string, create bool) *partition {
+	if len(database) == 0 {
+		database = m.options.Database
+	}
+	if len(table) == 0 {
+		table = m.options.Table
+	}
+	name := partitionName(database, table)
+
+	m.RLock()
+	p, ok := m.partitions[name]
+	m.RUnlock()
+	if ok || !create {
+		return p
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	if p, ok = m.partitions[name]; !ok {
+		p = newPartition(database, table, m.limitsOf(database, table))
+		m.partitions[name] = p
+	}
+	if m.exit == nil && m.interval > 0 {
+		m.exit = make(chan bool)
+		go m.run(m.interval, m.exit)
+	}
+	return p
+}
+
+// run purges the expired records of the tables every interval
+func (m *memoryCache) run(interval time.Duration, exit chan bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-exit:
+			return
+		case <-ticker.C:
+			m.RLock()
+			partitions := make([]*partition, 0, len(m.partitions))
+			for _, p := range m.partitions {
+				partitions = append(partitions, p)
+			}
+			m.RUnlock()
+
+			now := time.Now()
+			for _, p := range partitions {
+				p.Lock()
+				evicted := p.purge(now)
+				p.Unlock()
+				m.evicted(p, evicted)
+			}
+		}
+	}
+}
+
+// stop stops the purge, it is started again with the next table
+func (m *memoryCache) stop() {
+	if m.exit != nil {
+		close(m.exit)
+		m.exit = nil
+	}
+}
+
+// evicted calls the evict func with the evicted records
+func (m *memoryCache) evicted(p *partition, evicted []eviction) {
+	if len(evicted) == 0 {
+		return
+	}
+	m.RLock()
+	evict := m.evict
+	m.RUnlock()
+	if evict == nil {
+		return
+	}
+	for _, ev := range evicted {
+		evict(p.database, p.table, ev.e.record(), ev.reason)
+	}
 }
 
-func (m *memoryCache) list(prefix string, limit, offset uint) []string {
-	allItems := m.cache.Items()
-	allKeys := make([]string, len(allItems))
-	i := 0
+func (m *memoryCache) get(p *partition, key string) (*cache.Record, error) {
+	if p == nil {
+		return nil, cache.ErrNotFound
+	}
+	p.Lock()
+	r, evicted := p.get(key, time.Now())
+	p.Unlock()
+	m.evicted(p, evicted)
+	if r == nil {
+		return nil, cache.ErrNotFound
+	}
+	return r, nil
+}
 
-	for k := range allItems {
-		if !strings.HasPrefix(k, prefix+"/") {
-			continue
+func (m *memoryCache) set(p *partition, r *cache.Record, o cache.PutOptions) error {
+	// copy the incoming record and then
+	// convert the expiry in to a hard timestamp
+	e := &entry{
+		key:      r.Key,
+		value:    make([]byte, len(r.Value)),
+		metadata: make(map[string]interface{}, len(r.Metadata)),
+		size:     int64(len(r.Key) + len(r.Value)),
+	}
+	copy(e.value, r.Value)
+	if r.Expiry != 0 {
+		e.expiresAt = time.Now().Add(r.Expiry)
+	}
+	for k, v := range r.Metadata {
+		e.metadata[k] = v
+	}
+
+	p.Lock()
+	if old, ok := p.items[e.key]; o.Conditional {
+		if ok && old.expired(time.Now()) {
+			ok = false
+		}
+		var v []byte
+		if ok {
+			v = old.value
+		}
+		if o.Conflicts(v, ok) {
+			p.Unlock()
+			return cache.ErrConflict
+		}
+	}
+	evicted, err := p.set(e)
+	p.Unlock()
+	m.evicted(p, evicted)
+	return err
+}
+
+func (m *memoryCache) list(p *partition, limit, offset uint) []string {
+	if p == nil {
+		return []string{}
+	}
+
+	now := time.Now()
+	p.Lock()
+	allKeys := make([]string, 0, len(p.items))
+	for k, e := range p.items {
+		if !e.expired(now) {
+			allKeys = append(allKeys, k)
 		}
-		allKeys[i] = strings.TrimPrefix(k, prefix+"/")
-		i++
 	}
+	p.Unlock()
 
 	if limit != 0 || offset != 0 {
-		sort.Slice(allKeys, func(i, j int) bool { return allKeys[i] < allKeys[j] })
-		min := func(i, j uint) uint {
-			if i < j {
-				return i
-			}
-			return j
+		sort.Strings(allKeys)
+		if offset > uint(len(allKeys)) {
+			offset = uint(len(allKeys))
+		}
+		end := uint(len(allKeys))
+		if limit != 0 && offset+limit < end {
+			end = offset + limit
 		}
-		return allKeys[offset:min(limit, uint(len(allKeys)))]
+		return allKeys[offset:end]
 	}
 
 	return allKeys
@@ -176,6 +493,7 @@ func (m *memoryCache) Init(opts ...cache.Option) error {
 	for _, o := range opts {
 		o(&m.options)
 	}
+	m.configure()
 	return nil
 }
 
@@ -183,19 +501,38 @@ func (m *memoryCache) Options() cache.Options {
 	return m.options
 }
 
+// Stats returns the stats of the tables sorted by name
+func (m *memoryCache) Stats() []*PartitionStats {
+	m.RLock()
+	partitions := make([]*partition, 0, len(m.partitions))
+	for _, p := range m.partitions {
+		partitions = append(partitions, p)
+	}
+	m.RUnlock()
+
+	stats := make([]*PartitionStats, 0, len(partitions))
+	for _, p := range partitions {
+		stats = append(stats, p.stats())
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return partitionName(stats[i].Database, stats[i].Table) < partitionName(stats[j].Database, stats[j].Table)
+	})
+	return stats
+}
+
 func (m *memoryCache) Get(ctx context.Context, key string, opts ...cache.GetOption) ([]*cache.Record, error) {
 	readOpts := cache.GetOptions{}
 	for _, o := range opts {
 		o(&readOpts)
 	}
 
-	prefix := m.prefix(readOpts.Database, readOpts.Table)
+	p := m.partition(readOpts.Database, readOpts.Table, false)
 
 	var keys []string
 
 	// Handle Prefix / suffix
 	if readOpts.Prefix || readOpts.Suffix {
-		k := m.list(prefix, readOpts.Limit, readOpts.Offset)
+		k := m.list(p, readOpts.Limit, readOpts.Offset)
 
 		for _, kk := range k {
 			if readOpts.Prefix && !strings.HasPrefix(kk, key) {
@@ -215,7 +552,7 @@ func (m *memoryCache) Get(ctx context.Context, key string, opts ...cache.GetOpti
 	var results []*cache.Record
 
 	for _, k := range keys {
-		r, err := m.get(prefix, k)
+		r, err := m.get(p, k)
 		if err != nil {
 			return results, err
 		}
@@ -231,7 +568,7 @@ func (m *memoryCache) Put(ctx context.Context, r *cache.Record, opts ...cache.Pu
 		o(&writeOpts)
 	}
 
-	prefix := m.prefix(writeOpts.Database, writeOpts.Table)
+	p := m.partition(writeOpts.Database, writeOpts.Table, true)
 
 	if len(opts) > 0 {
 		// Copy the record before applying options, or the incoming record will be mutated
@@ -253,14 +590,11 @@ func (m *memoryCache) Put(ctx context.Context, r *cache.Record, opts ...cache.Pu
 			newRecord.Metadata[k] = v
 		}
 
-		m.set(prefix, &newRecord)
-		return nil
+		return m.set(p, &newRecord, writeOpts)
 	}
 
 	// set
-	m.set(prefix, r)
-
-	return nil
+	return m.set(p, r, writeOpts)
 }
 
 func (m *memoryCache) Del(ctx context.Context, key string, opts ...cache.DelOption) error {
@@ -269,8 +603,15 @@ func (m *memoryCache) Del(ctx context.Context, key string, opts ...cache.DelOpti
 		o(&deleteOptions)
 	}
 
-	prefix := m.prefix(deleteOptions.Database, deleteOptions.Table)
-	m.delete(prefix, key)
+	p := m.partition(deleteOptions.Database, deleteOptions.Table, false)
+	if p == nil {
+		return nil
+	}
+	p.Lock()
+	if e, ok := p.items[key]; ok {
+		p.remove(e)
+	}
+	p.Unlock()
 	return nil
 }
 
@@ -281,8 +622,8 @@ func (m *memoryCache) List(ctx context.Context, opts ...cache.ListOption) ([]str
 		o(&listOptions)
 	}
 
-	prefix := m.prefix(listOptions.Database, listOptions.Table)
-	keys := m.list(prefix, listOptions.Limit, listOptions.Offset)
+	p := m.partition(listOptions.Database, listOptions.Table, false)
+	keys := m.list(p, listOptions.Limit, listOptions.Offset)
 
 	if len(listOptions.Prefix) > 0 {
 		var prefixKeys []string
@@ -308,7 +649,10 @@ func (m *memoryCache) List(ctx context.Context, opts ...cache.ListOption) ([]str
 }
 
 func (m *memoryCache) Close() error {
-	m.cache.Flush()
+	m.Lock()
+	m.stop()
+	m.partitions = map[string]*partition{}
+	m.Unlock()
 	return nil
 }
 
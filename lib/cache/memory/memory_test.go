@@ -300,3 +300,159 @@ func basictest(s cache.Cache, t *testing.T) {
 		}
 	}
 }
+
+func put(t *testing.T, s cache.Cache, keys ...string) {
+	for _, k := range keys {
+		if err := s.Put(context.TODO(), &cache.Record{Key: k, Value: []byte(k)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func keys(t *testing.T, s cache.Cache) string {
+	k, err := s.List(context.TODO(), cache.ListLimit(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprint(k)
+}
+
+func TestMemoryEviction(t *testing.T) {
+	cases := []struct {
+		policy Policy
+		want   string
+	}{
+		// a is used, b is the least recently used
+		{LRU, "[a c d]"},
+		// c is the least frequently used
+		{LFU, "[a b d]"},
+		// d is kept in the window, c is used less than a and b
+		{TinyLFU, "[a b d]"},
+	}
+
+	for _, c := range cases {
+		t.Run(string(c.policy), func(t *testing.T) {
+			s := NewCache(cache.MaxEntries(3), WithPolicy(c.policy))
+			put(t, s, "a", "b")
+			for i := 0; i < 3; i++ {
+				s.Get(context.TODO(), "b")
+			}
+			put(t, s, "c")
+			for i := 0; i < 3; i++ {
+				s.Get(context.TODO(), "a")
+			}
+			put(t, s, "d")
+			if got := keys(t, s); got != c.want {
+				t.Errorf("expected %s, got %s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestMemoryMaxBytes(t *testing.T) {
+	s := NewCache(cache.MaxBytes(6))
+	put(t, s, "a", "b", "c", "d")
+	if got := keys(t, s); got != "[b c d]" {
+		t.Errorf("expected [b c d], got %s", got)
+	}
+	if err := s.Put(context.TODO(), &cache.Record{Key: "e", Value: []byte("too large")}); err == nil {
+		t.Error("expected an error for a record over the limit")
+	}
+}
+
+func TestMemoryPartitions(t *testing.T) {
+	var evicted []string
+	s := NewCache(
+		cache.MaxEntries(2),
+		WithPartition("vine", "small", Limits{MaxEntries: 1}),
+		WithEvictFunc(func(database, table string, r *cache.Record, reason Reason) {
+			evicted = append(evicted, fmt.Sprintf("%s/%s/%s:%s", database, table, r.Key, reason))
+		}),
+	)
+	ctx := context.TODO()
+	put(t, s, "a", "b", "c")
+	for _, k := range []string{"a", "b"} {
+		s.Put(ctx, &cache.Record{Key: k}, cache.PutTo("vine", "small"))
+	}
+	s.Put(ctx, &cache.Record{Key: "e"}, cache.PutTo("vine", "expiring"), cache.PutTTL(time.Millisecond))
+	time.Sleep(time.Millisecond * 5)
+	if _, err := s.Get(ctx, "e", cache.GetFrom("vine", "expiring")); err != cache.ErrNotFound {
+		t.Errorf("expected %v, got %v", cache.ErrNotFound, err)
+	}
+	s.Get(ctx, "b")
+
+	want := "[vine/vine/a:capacity vine/small/a:capacity vine/expiring/e:expired]"
+	if got := fmt.Sprint(evicted); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	stats := Stats(s)
+	if len(stats) != 3 {
+		t.Fatalf("expected 3 partitions, got %d", len(stats))
+	}
+	small, expiring, def := stats[1], stats[0], stats[2]
+	if small.Table != "small" || small.Entries != 1 || small.MaxEntries != 1 || small.Evictions != 1 {
+		t.Errorf("unexpected stats %# v", pretty.Formatter(small))
+	}
+	if expiring.Entries != 0 || expiring.Misses != 1 || expiring.Expirations != 1 {
+		t.Errorf("unexpected stats %# v", pretty.Formatter(expiring))
+	}
+	if def.Entries != 2 || def.MaxEntries != 2 || def.Hits != 1 || def.Evictions != 1 || def.Policy != LRU {
+		t.Errorf("unexpected stats %# v", pretty.Formatter(def))
+	}
+
+	// shrinking the cache evicts the records over the new limit
+	s.Init(cache.MaxEntries(1))
+	if got := keys(t, s); got != "[b]" {
+		t.Errorf("expected [b], got %s", got)
+	}
+}
+
+func TestMemoryPurge(t *testing.T) {
+	s := NewCache(WithPurgeInterval(time.Millisecond * 10))
+	defer s.Close()
+	for i := 0; i < 1000; i++ {
+		s.Put(context.TODO(), &cache.Record{Key: fmt.Sprint(i)}, cache.PutTTL(time.Millisecond))
+	}
+	s.Put(context.TODO(), &cache.Record{Key: "kept"})
+
+	// the expired records are removed without being read
+	deadline := time.Now().Add(time.Second)
+	for {
+		stats := Stats(s)[0]
+		if stats.Entries == 1 && stats.Expirations == 1000 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the expired records were not purged %# v", pretty.Formatter(stats))
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestMemoryPutIfMatch(t *testing.T) {
+	s := NewCache()
+	ctx := context.TODO()
+	if err := s.Put(ctx, &cache.Record{Key: "a", Value: []byte("1")}, cache.PutIfMatch(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, &cache.Record{Key: "a", Value: []byte("2")}, cache.PutIfMatch(nil)); err != cache.ErrConflict {
+		t.Errorf("expected %v, got %v", cache.ErrConflict, err)
+	}
+	if err := s.Put(ctx, &cache.Record{Key: "a", Value: []byte("2")}, cache.PutIfMatch([]byte("3"))); err != cache.ErrConflict {
+		t.Errorf("expected %v, got %v", cache.ErrConflict, err)
+	}
+	if err := s.Put(ctx, &cache.Record{Key: "a", Value: []byte("2")}, cache.PutIfMatch([]byte("1"))); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := s.Get(ctx, "a"); err != nil || string(r[0].Value) != "2" {
+		t.Errorf("expected 2, got %v %v", r, err)
+	}
+
+	// an expired record is missing
+	s.Put(ctx, &cache.Record{Key: "b", Value: []byte("1"), Expiry: time.Millisecond})
+	time.Sleep(time.Millisecond * 5)
+	if err := s.Put(ctx, &cache.Record{Key: "b", Value: []byte("2")}, cache.PutIfMatch(nil)); err != nil {
+		t.Error(err)
+	}
+}
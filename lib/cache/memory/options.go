@@ -0,0 +1,122 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/vine-io/vine/lib/cache"
+)
+
+// Policy chooses the records evicted from a full table
+type Policy string
+
+const (
+	// LRU evicts the least recently used record
+	LRU Policy = "lru"
+	// LFU evicts the least frequently used record
+	LFU Policy = "lfu"
+	// TinyLFU is W-TinyLFU, the new records enter a small LRU window and
+	// only replace the records of the main LRU which are used less often
+	TinyLFU Policy = "tinylfu"
+)
+
+var (
+	// DefaultPolicy is the policy of the tables without one
+	DefaultPolicy = LRU
+	// DefaultPurgeInterval is the interval of the removal of the expired records
+	DefaultPurgeInterval = time.Minute * 5
+)
+
+// Limits bound a table, the zero values are the ones of the cache
+type Limits struct {
+	MaxEntries int
+	MaxBytes   int64
+	Policy     Policy
+}
+
+// Reason is why a record is evicted
+type Reason string
+
+const (
+	// ReasonCapacity is the eviction of a record to respect the limits
+	ReasonCapacity Reason = "capacity"
+	// ReasonExpired is the eviction of an expired record
+	ReasonExpired Reason = "expired"
+)
+
+// EvictFunc is called with the records evicted from a table
+type EvictFunc func(database, table string, r *cache.Record, reason Reason)
+
+type policyKey struct{}
+type partitionsKey struct{}
+type evictKey struct{}
+type purgeIntervalKey struct{}
+
+func withValue(o *cache.Options, k, v interface{}) {
+	if o.Context == nil {
+		o.Context = context.Background()
+	}
+	o.Context = context.WithValue(o.Context, k, v)
+}
+
+// WithPolicy sets the eviction policy of the tables, DefaultPolicy by default
+func WithPolicy(p Policy) cache.Option {
+	return func(o *cache.Options) {
+		withValue(o, policyKey{}, p)
+	}
+}
+
+// WithPartition sets the limits of a table, the other tables are bounded by
+// cache.MaxEntries and cache.MaxBytes
+func WithPartition(database, table string, l Limits) cache.Option {
+	return func(o *cache.Options) {
+		partitions := map[string]Limits{}
+		if o.Context != nil {
+			if p, ok := o.Context.Value(partitionsKey{}).(map[string]Limits); ok {
+				for k, v := range p {
+					partitions[k] = v
+				}
+			}
+		}
+		partitions[partitionName(database, table)] = l
+		withValue(o, partitionsKey{}, partitions)
+	}
+}
+
+// WithEvictFunc sets the function called with the evicted records, it is not
+// called for the deleted ones
+func WithEvictFunc(fn EvictFunc) cache.Option {
+	return func(o *cache.Options) {
+		withValue(o, evictKey{}, fn)
+	}
+}
+
+// WithPurgeInterval sets the interval of the removal of the expired records,
+// zero disables it and they are only removed when read or evicted
+func WithPurgeInterval(d time.Duration) cache.Option {
+	return func(o *cache.Options) {
+		withValue(o, purgeIntervalKey{}, d)
+	}
+}
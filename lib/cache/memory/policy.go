@@ -0,0 +1,306 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package memory
+
+import (
+	"container/heap"
+	"container/list"
+	"hash/fnv"
+)
+
+// policy orders the records of a table for eviction, it is not safe for
+// concurrent use
+type policy interface {
+	// add a new record
+	add(e *entry)
+	// hit records an access to the record
+	hit(e *entry)
+	// remove the record
+	remove(e *entry)
+	// victim returns the record to evict, nil when there is none
+	victim() *entry
+}
+
+func newPolicy(p Policy, capacity int) policy {
+	switch p {
+	case LFU:
+		return &lfu{}
+	case TinyLFU:
+		return newTinyLFU(capacity)
+	}
+	return &lru{ll: list.New()}
+}
+
+type lru struct {
+	ll *list.List
+}
+
+func (p *lru) add(e *entry) {
+	e.elem = p.ll.PushFront(e)
+}
+
+func (p *lru) hit(e *entry) {
+	p.ll.MoveToFront(e.elem)
+}
+
+func (p *lru) remove(e *entry) {
+	p.ll.Remove(e.elem)
+}
+
+func (p *lru) victim() *entry {
+	if b := p.ll.Back(); b != nil {
+		return b.Value.(*entry)
+	}
+	return nil
+}
+
+// lfu is a min heap of the records by their count of accesses, the least
+// recently used goes first among the records used as often
+type lfu struct {
+	entries []*entry
+	tick    uint64
+}
+
+func (p *lfu) Len() int { return len(p.entries) }
+
+func (p *lfu) Less(i, j int) bool {
+	a, b := p.entries[i], p.entries[j]
+	if a.freq != b.freq {
+		return a.freq < b.freq
+	}
+	return a.tick < b.tick
+}
+
+func (p *lfu) Swap(i, j int) {
+	p.entries[i], p.entries[j] = p.entries[j], p.entries[i]
+	p.entries[i].index = i
+	p.entries[j].index = j
+}
+
+func (p *lfu) Push(x interface{}) {
+	e := x.(*entry)
+	e.index = len(p.entries)
+	p.entries = append(p.entries, e)
+}
+
+func (p *lfu) Pop() interface{} {
+	n := len(p.entries)
+	e := p.entries[n-1]
+	p.entries[n-1] = nil
+	p.entries = p.entries[:n-1]
+	return e
+}
+
+func (p *lfu) add(e *entry) {
+	p.tick++
+	e.freq, e.tick = 1, p.tick
+	heap.Push(p, e)
+}
+
+func (p *lfu) hit(e *entry) {
+	p.tick++
+	e.freq++
+	e.tick = p.tick
+	heap.Fix(p, e.index)
+}
+
+func (p *lfu) remove(e *entry) {
+	heap.Remove(p, e.index)
+}
+
+func (p *lfu) victim() *entry {
+	if len(p.entries) == 0 {
+		return nil
+	}
+	return p.entries[0]
+}
+
+const (
+	window = iota + 1
+	probation
+	protected
+)
+
+// tinyLFU is W-TinyLFU: the new records enter an LRU window of 1% of the
+// records, the records leaving it enter the main segmented LRU but are the
+// next victim unless they are used more often than its own victim. The
+// frequencies are estimated by a count-min sketch which is aged.
+type tinyLFU struct {
+	sketch *sketch
+	// the segments, protected holds 80% of the main records
+	window, probation, protected *list.List
+	// the last record which left the window
+	candidate *entry
+}
+
+func newTinyLFU(capacity int) *tinyLFU {
+	return &tinyLFU{
+		sketch:    newSketch(capacity),
+		window:    list.New(),
+		probation: list.New(),
+		protected: list.New(),
+	}
+}
+
+func (p *tinyLFU) segment(e *entry) *list.List {
+	switch e.segment {
+	case window:
+		return p.window
+	case probation:
+		return p.probation
+	}
+	return p.protected
+}
+
+func (p *tinyLFU) move(e *entry, segment int) {
+	p.segment(e).Remove(e.elem)
+	e.segment = segment
+	e.elem = p.segment(e).PushFront(e)
+}
+
+func (p *tinyLFU) add(e *entry) {
+	p.sketch.increment(e.key)
+	e.segment = window
+	e.elem = p.window.PushFront(e)
+
+	p.candidate = nil
+	share := (p.window.Len() + p.probation.Len() + p.protected.Len()) / 100
+	if share < 1 {
+		share = 1
+	}
+	if p.window.Len() > share {
+		p.candidate = back(p.window)
+		p.move(p.candidate, probation)
+	}
+}
+
+func (p *tinyLFU) hit(e *entry) {
+	p.sketch.increment(e.key)
+	switch e.segment {
+	case window, protected:
+		p.segment(e).MoveToFront(e.elem)
+	case probation:
+		// promote, demoting the oldest protected record when it is full
+		p.move(e, protected)
+		if main := p.probation.Len() + p.protected.Len(); p.protected.Len() > main*8/10 {
+			p.move(back(p.protected), probation)
+		}
+	}
+}
+
+func (p *tinyLFU) remove(e *entry) {
+	if e == p.candidate {
+		p.candidate = nil
+	}
+	p.segment(e).Remove(e.elem)
+}
+
+func back(l *list.List) *entry {
+	if b := l.Back(); b != nil {
+		return b.Value.(*entry)
+	}
+	return nil
+}
+
+func (p *tinyLFU) victim() *entry {
+	victim := back(p.probation)
+	if victim == nil || victim == p.candidate {
+		if v := back(p.protected); v != nil {
+			victim = v
+		}
+	}
+
+	// the record which left the window is admitted when it is used more
+	// often than the victim of the main segments
+	if c := p.candidate; c != nil && c != victim {
+		if victim == nil || p.sketch.estimate(c.key) <= p.sketch.estimate(victim.key) {
+			return c
+		}
+		return victim
+	}
+
+	if victim != nil {
+		return victim
+	}
+	return back(p.window)
+}
+
+// sketch is a count-min sketch of 4 rows of counters, halved every 10 times
+// its width of increments so that the old frequencies fade
+type sketch struct {
+	rows      [4][]uint8
+	mask      uint64
+	additions int
+	reset     int
+}
+
+func newSketch(capacity int) *sketch {
+	width := 64
+	for width < capacity && width < 1<<20 {
+		width <<= 1
+	}
+	s := &sketch{mask: uint64(width - 1), reset: 10 * width}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *sketch) indexes(key string) [4]uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	lo, hi := sum&0xffffffff, sum>>32
+	var idx [4]uint64
+	for i := range idx {
+		idx[i] = (lo + uint64(i)*hi) & s.mask
+	}
+	return idx
+}
+
+func (s *sketch) increment(key string) {
+	for i, j := range s.indexes(key) {
+		if s.rows[i][j] < 15 {
+			s.rows[i][j]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.reset {
+		for i := range s.rows {
+			for j := range s.rows[i] {
+				s.rows[i][j] >>= 1
+			}
+		}
+		s.additions /= 2
+	}
+}
+
+func (s *sketch) estimate(key string) uint8 {
+	min := uint8(15)
+	for i, j := range s.indexes(key) {
+		if s.rows[i][j] < min {
+			min = s.rows[i][j]
+		}
+	}
+	return min
+}
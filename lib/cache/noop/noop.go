@@ -43,6 +43,14 @@ func (n *noopCache) Get(ctx context.Context, key string, opts ...cache.GetOption
 }
 
 func (n *noopCache) Put(ctx context.Context, r *cache.Record, opts ...cache.PutOption) error {
+	// nothing is kept, the keys are always missing
+	o := cache.PutOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.Conflicts(nil, false) {
+		return cache.ErrConflict
+	}
 	return nil
 }
 
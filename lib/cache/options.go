@@ -23,6 +23,7 @@
 package cache
 
 import (
+	"bytes"
 	"context"
 	"time"
 
@@ -39,6 +40,10 @@ type Options struct {
 	Database string
 	// Table is analagous to a table in database backends or a key prefix in KV backends
 	Table string
+	// MaxEntries bounds the number of records of a table, zero is unbounded
+	MaxEntries int
+	// MaxBytes bounds the size of the keys and values of a table, zero is unbounded
+	MaxBytes int64
 	// Context should contain all implementation specific options, using context.WithValue.
 	Context context.Context
 	// Client to use for RPC
@@ -71,6 +76,22 @@ func Table(t string) Option {
 	}
 }
 
+// MaxEntries bounds the number of records of a table, the records over it
+// are evicted by the implementations which support it
+func MaxEntries(n int) Option {
+	return func(o *Options) {
+		o.MaxEntries = n
+	}
+}
+
+// MaxBytes bounds the size of the keys and values of a table, the records
+// over it are evicted by the implementations which support it
+func MaxBytes(n int64) Option {
+	return func(o *Options) {
+		o.MaxBytes = n
+	}
+}
+
 // WithContext sets the stores context, for any extra configuration
 func WithContext(c context.Context) Option {
 	return func(o *Options) {
@@ -145,6 +166,22 @@ type PutOptions struct {
 	Expiry time.Time
 	// TTL is the time until the record expires
 	TTL time.Duration
+	// Conditional puts only apply when the key holds Match, or is missing
+	// when Match is nil
+	Conditional bool
+	Match       []byte
+}
+
+// Conflicts reports whether the put does not apply to the key, v is its value
+// and ok is false when it is missing
+func (o PutOptions) Conflicts(v []byte, ok bool) bool {
+	if !o.Conditional {
+		return false
+	}
+	if o.Match == nil {
+		return ok
+	}
+	return !ok || !bytes.Equal(v, o.Match)
 }
 
 // PutOption sets values in PutOptions
@@ -172,6 +209,16 @@ func PutTTL(d time.Duration) PutOption {
 	}
 }
 
+// PutIfMatch only puts the record when its key holds the value, or when the
+// key is missing if the value is nil. The put fails with ErrConflict otherwise,
+// which lets the writers sharing a cache compare and set a record.
+func PutIfMatch(v []byte) PutOption {
+	return func(w *PutOptions) {
+		w.Conditional = true
+		w.Match = v
+	}
+}
+
 // DelOptions configures an individual Del operation
 type DelOptions struct {
 	Database, Table string
@@ -36,6 +36,7 @@ import (
 	"github.com/vine-io/vine/core/broker/memory"
 	"github.com/vine-io/vine/core/client"
 	grpcClient "github.com/vine-io/vine/core/client/grpc"
+	memClient "github.com/vine-io/vine/core/client/memory"
 	"github.com/vine-io/vine/core/client/selector"
 	"github.com/vine-io/vine/core/client/selector/dns"
 	"github.com/vine-io/vine/core/client/selector/static"
@@ -46,12 +47,15 @@ import (
 	"github.com/vine-io/vine/lib/cache"
 	"github.com/vine-io/vine/lib/config"
 	configMemory "github.com/vine-io/vine/lib/config/memory"
+	"github.com/vine-io/vine/lib/config/source/file"
 	log "github.com/vine-io/vine/lib/logger"
 	"github.com/vine-io/vine/lib/trace"
 	memTracer "github.com/vine-io/vine/lib/trace/memory"
 	"gopkg.in/yaml.v3"
 	// servers
 	grpcServer "github.com/vine-io/vine/core/server/grpc"
+	memServer "github.com/vine-io/vine/core/server/memory"
+	fileCache "github.com/vine-io/vine/lib/cache/file"
 	memCache "github.com/vine-io/vine/lib/cache/memory"
 	nopCache "github.com/vine-io/vine/lib/cache/noop"
 	// config
@@ -85,7 +89,8 @@ var (
 	}
 
 	DefaultClients = map[string]func(...client.Option) client.Client{
-		"grpc": grpcClient.NewClient,
+		"grpc":   grpcClient.NewClient,
+		"memory": memClient.NewClient,
 	}
 
 	DefaultRegistries = map[string]func(...registry.Option) registry.Registry{
@@ -99,10 +104,12 @@ var (
 	}
 
 	DefaultServers = map[string]func(...server.Option) server.Server{
-		"grpc": grpcServer.NewServer,
+		"grpc":   grpcServer.NewServer,
+		"memory": memServer.NewServer,
 	}
 
 	DefaultCaches = map[string]func(...cache.Option) cache.Cache{
+		"file":   fileCache.NewCache,
 		"memory": memCache.NewCache,
 		"noop":   nopCache.NewCache,
 	}
@@ -176,6 +183,7 @@ func newCmd(opts ...Option) Cmd {
 	flags.AddFlagSet(selector.Flag)
 	flags.AddFlagSet(server.Flag)
 	flags.AddFlagSet(cache.Flag)
+	flags.AddFlagSet(config.Flag)
 	flags.AddFlagSet(log.Flag)
 	flags.AddFlagSet(trace.Flag)
 
@@ -294,6 +302,11 @@ func (c *cmd) before(cmd *cobra.Command, args []string) error {
 	}
 	log.DefaultLogger = log.NewHelper(log.NewLogger(lopts...))
 
+	// Set the profile of the config files
+	if profile := uc.GetString("profile"); len(profile) > 0 {
+		file.DefaultProfile = profile
+	}
+
 	// Set the cache
 	if name := uc.GetString("cache.default"); len(name) > 0 {
 		s, ok := options.Caches[name]
@@ -426,6 +439,18 @@ func (c *cmd) before(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if n := uc.GetInt("cache.max-entries"); n > 0 {
+		if err := (*options.Cache).Init(cache.MaxEntries(n)); err != nil {
+			log.Fatalf("Error configuring cache: %v", err)
+		}
+	}
+
+	if n := uc.GetInt64("cache.max-bytes"); n > 0 {
+		if err := (*options.Cache).Init(cache.MaxBytes(n)); err != nil {
+			log.Fatalf("Error configuring cache: %v", err)
+		}
+	}
+
 	if name := uc.GetString("server.name"); len(name) > 0 {
 		serverOpts = append(serverOpts, server.Name(name))
 	}
@@ -0,0 +1,428 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	json "github.com/json-iterator/go"
+	"github.com/vine-io/vine/lib/config/reader"
+)
+
+type BindOptions struct {
+	// Path of the values bound to the struct, the root when empty
+	Path []string
+	// Strict reports the keys which are not bound to a field
+	Strict bool
+}
+
+type BindOption func(o *BindOptions)
+
+// BindPath binds the values under the path e.g. BindPath("hosts", "database")
+func BindPath(path ...string) BindOption {
+	return func(o *BindOptions) {
+		o.Path = path
+	}
+}
+
+// BindStrict reports the keys of the config which are not bound to a field,
+// a typo in a key is an error instead of a zero value
+func BindStrict() BindOption {
+	return func(o *BindOptions) {
+		o.Strict = true
+	}
+}
+
+var (
+	typeOfDuration = reflect.TypeOf(time.Duration(0))
+	typeOfTime     = reflect.TypeOf(time.Time{})
+
+	validate = newValidator()
+)
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("validate")
+	// report the config keys instead of the field names
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _ := fieldName(f)
+		return name
+	})
+	return v
+}
+
+// fieldName returns the dotted key of the field and whether it was tagged
+func fieldName(f reflect.StructField) (string, bool) {
+	if tag := f.Tag.Get("config"); len(tag) > 0 {
+		return tag, true
+	}
+	if tag := strings.Split(f.Tag.Get("json"), ",")[0]; len(tag) > 0 {
+		return tag, false
+	}
+	return f.Name, false
+}
+
+type binder struct {
+	strict bool
+	// the keys bound to a value and the ones of the structs
+	values  map[string]bool
+	structs map[string]bool
+	// the struct types on the path being bound
+	types map[reflect.Type]int
+	errs  []string
+}
+
+// Bind sets the fields of the struct v, which must be a pointer, from the
+// values. The config tag holds the dotted key of a field e.g.
+//
+//	Host string `config:"db.host" default:"localhost" validate:"required,hostname"`
+//
+// untagged fields use their json name or field name. The default tag is
+// used when the key is missing and the validate tag holds the rules of
+// github.com/go-playground/validator which are checked after the binding.
+// A nil pointer to a struct is only allocated when there are keys under it.
+func Bind(values reader.Values, v interface{}, opts ...BindOption) error {
+	var options BindOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind config: %T is not a pointer to a struct", v)
+	}
+
+	var root interface{} = values.Map()
+	for _, p := range options.Path {
+		m, _ := root.(map[string]interface{})
+		root = m[p]
+	}
+	data, _ := root.(map[string]interface{})
+
+	b := &binder{
+		strict:  options.Strict,
+		values:  make(map[string]bool),
+		structs: map[string]bool{"": true},
+		types:   make(map[reflect.Type]int),
+	}
+	b.bindStruct(rv.Elem(), nil, data)
+	if b.strict {
+		b.unknown(nil, data)
+	}
+	if len(b.errs) == 0 {
+		b.validate(v)
+	}
+
+	if len(b.errs) > 0 {
+		prefix := "bind config"
+		if len(options.Path) > 0 {
+			prefix += " " + strings.Join(options.Path, ".")
+		}
+		return fmt.Errorf("%s: %s", prefix, strings.Join(b.errs, "; "))
+	}
+	return nil
+}
+
+// lookup returns the value of the path and the keys it was found at, the
+// keys are matched case insensitively like json does
+func lookup(data map[string]interface{}, path []string) (interface{}, []string, bool) {
+	var v interface{} = data
+	keys := make([]string, 0, len(path))
+	for _, p := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, nil, false
+		}
+		key := p
+		if _, ok := m[p]; !ok {
+			key = ""
+			for k := range m {
+				if strings.EqualFold(k, p) {
+					key = k
+					break
+				}
+			}
+			if len(key) == 0 {
+				return nil, nil, false
+			}
+		}
+		v = m[key]
+		keys = append(keys, key)
+	}
+	return v, keys, true
+}
+
+func (b *binder) bindStruct(rv reflect.Value, prefix []string, data map[string]interface{}) {
+	rt := rv.Type()
+	b.types[rt]++
+	defer func() { b.types[rt]-- }()
+
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if len(f.PkgPath) > 0 || f.Tag.Get("config") == "-" {
+			continue
+		}
+
+		name, tagged := fieldName(f)
+		fv := rv.Field(i)
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		isStruct := ft.Kind() == reflect.Struct && ft != typeOfTime
+
+		// embedded structs share the keys of their parent, so one which
+		// embeds a struct of the path would be bound forever
+		if f.Anonymous && !tagged && isStruct {
+			if b.types[ft] == 0 {
+				b.bindStruct(b.alloc(fv), prefix, data)
+			}
+			continue
+		}
+
+		path := append(append([]string{}, prefix...), strings.Split(name, ".")...)
+		raw, keys, ok := lookup(data, path)
+		if !ok {
+			keys = path
+		}
+
+		if isStruct && (raw == nil || isMap(raw)) && len(f.Tag.Get("default")) == 0 {
+			// a nil pointer is left nil without keys under it, which ends
+			// the structs referring to themselves e.g. a linked list
+			if f.Type.Kind() == reflect.Ptr && fv.IsNil() && raw == nil {
+				continue
+			}
+			b.structs[strings.Join(keys, ".")] = true
+			b.bindStruct(b.alloc(fv), keys, data)
+			continue
+		}
+
+		key := strings.Join(keys, ".")
+		b.values[key] = true
+
+		if !ok || raw == nil {
+			if def, ok := f.Tag.Lookup("default"); ok {
+				if err := setDefault(fv, def); err != nil {
+					b.errs = append(b.errs, fmt.Sprintf("%s: invalid default %q: %v", key, def, err))
+				}
+			}
+			continue
+		}
+		if err := set(fv, raw); err != nil {
+			b.errs = append(b.errs, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+}
+
+func isMap(v interface{}) bool {
+	_, ok := v.(map[string]interface{})
+	return ok
+}
+
+// alloc returns the struct of the field, allocating it when it is a nil pointer
+func (b *binder) alloc(fv reflect.Value) reflect.Value {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return fv.Elem()
+	}
+	return fv
+}
+
+// unknown reports the keys which are neither bound nor the keys of a struct
+func (b *binder) unknown(prefix []string, data map[string]interface{}) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		path := append(append([]string{}, prefix...), k)
+		key := strings.Join(path, ".")
+		if b.values[key] {
+			continue
+		}
+		if m, ok := data[k].(map[string]interface{}); ok && b.nested(key) {
+			b.unknown(path, m)
+			continue
+		}
+		b.errs = append(b.errs, fmt.Sprintf("%s: unknown key", key))
+	}
+}
+
+// nested reports whether a bound key or a struct is under the key
+func (b *binder) nested(key string) bool {
+	if b.structs[key] {
+		return true
+	}
+	for k := range b.values {
+		if strings.HasPrefix(k, key+".") {
+			return true
+		}
+	}
+	for k := range b.structs {
+		if strings.HasPrefix(k, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *binder) validate(v interface{}) {
+	err := validate.Struct(v)
+	if err == nil {
+		return
+	}
+	verrs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		b.errs = append(b.errs, err.Error())
+		return
+	}
+	for _, fe := range verrs {
+		// the namespace starts with the name of the struct
+		key := fe.Namespace()
+		if i := strings.Index(key, "."); i >= 0 {
+			key = key[i+1:]
+		}
+		rule := fe.Tag()
+		if len(fe.Param()) > 0 {
+			rule += "=" + fe.Param()
+		}
+		b.errs = append(b.errs, fmt.Sprintf("%s: failed the %s rule", key, rule))
+	}
+}
+
+// setDefault sets the field from its default tag, the items of slices are
+// separated by commas and maps and structs are json
+func setDefault(fv reflect.Value, def string) error {
+	switch fv.Kind() {
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Uint8 {
+			return set(fv, def)
+		}
+		var items []interface{}
+		for _, item := range strings.Split(def, ",") {
+			items = append(items, strings.TrimSpace(item))
+		}
+		return set(fv, items)
+	case reflect.Map, reflect.Struct:
+		var v interface{}
+		if err := json.Unmarshal([]byte(def), &v); err != nil {
+			return err
+		}
+		return set(fv, v)
+	}
+	return set(fv, def)
+}
+
+// set converts the json decoded value to the field, the strings of sources
+// like env are parsed when the field is a number or a bool
+func set(fv reflect.Value, raw interface{}) error {
+	if fv.Kind() == reflect.Ptr {
+		v := reflect.New(fv.Type().Elem())
+		if err := set(v.Elem(), raw); err != nil {
+			return err
+		}
+		fv.Set(v)
+		return nil
+	}
+
+	s, isString := raw.(string)
+
+	if fv.Type() == typeOfDuration {
+		switch x := raw.(type) {
+		case string:
+			d, err := time.ParseDuration(x)
+			if err != nil {
+				return err
+			}
+			fv.SetInt(int64(d))
+			return nil
+		case float64:
+			fv.SetInt(int64(x))
+			return nil
+		}
+	}
+
+	if isString {
+		switch fv.Kind() {
+		case reflect.String:
+			fv.SetString(s)
+			return nil
+		case reflect.Bool:
+			v, err := strconv.ParseBool(s)
+			if err != nil {
+				return fmt.Errorf("cannot bind %q to %s", s, fv.Type())
+			}
+			fv.SetBool(v)
+			return nil
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			v, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+			if err != nil {
+				return fmt.Errorf("cannot bind %q to %s", s, fv.Type())
+			}
+			fv.SetInt(v)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			v, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+			if err != nil {
+				return fmt.Errorf("cannot bind %q to %s", s, fv.Type())
+			}
+			fv.SetUint(v)
+			return nil
+		case reflect.Float32, reflect.Float64:
+			v, err := strconv.ParseFloat(s, fv.Type().Bits())
+			if err != nil {
+				return fmt.Errorf("cannot bind %q to %s", s, fv.Type())
+			}
+			fv.SetFloat(v)
+			return nil
+		}
+	} else if fv.Kind() == reflect.String {
+		switch raw.(type) {
+		case bool, float64:
+			fv.SetString(fmt.Sprint(raw))
+			return nil
+		}
+	}
+
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	v := reflect.New(fv.Type())
+	if err := json.Unmarshal(b, v.Interface()); err != nil {
+		return fmt.Errorf("cannot bind %s to %s", b, fv.Type())
+	}
+	fv.Set(v.Elem())
+	return nil
+}
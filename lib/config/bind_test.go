@@ -0,0 +1,134 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vine-io/vine/lib/config/reader"
+	"github.com/vine-io/vine/lib/config/reader/json"
+	"github.com/vine-io/vine/lib/config/source"
+)
+
+type dbConfig struct {
+	Host    string        `config:"host" default:"localhost" validate:"required,hostname"`
+	Port    int           `config:"port" default:"3306" validate:"min=1,max=65535"`
+	Timeout time.Duration `config:"timeout" default:"5s"`
+}
+
+type appConfig struct {
+	Name    string            `config:"name" validate:"required"`
+	Debug   bool              `config:"debug"`
+	Tags    []string          `config:"tags" default:"a,b"`
+	Labels  map[string]string `config:"labels"`
+	DB      dbConfig          `config:"db"`
+	Cache   *dbConfig         `config:"cache"`
+	Replica string            `config:"db.replica.host"`
+	Ignored string            `config:"-"`
+}
+
+func values(t *testing.T, data string) reader.Values {
+	v, err := json.NewReader().Values(&source.ChangeSet{Data: []byte(data), Format: "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestBind(t *testing.T) {
+	c := &appConfig{}
+	err := Bind(values(t, `{
+		"name": "greeter",
+		"debug": "true",
+		"labels": {"team": "core"},
+		"db": {"port": "3307", "timeout": "1m", "replica": {"host": "db-2"}},
+		"cache": {"host": "cache", "port": 6379}
+	}`), c)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "greeter", c.Name)
+	assert.True(t, c.Debug)
+	assert.Equal(t, []string{"a", "b"}, c.Tags)
+	assert.Equal(t, map[string]string{"team": "core"}, c.Labels)
+	assert.Equal(t, dbConfig{Host: "localhost", Port: 3307, Timeout: time.Minute}, c.DB)
+	assert.Equal(t, &dbConfig{Host: "cache", Port: 6379, Timeout: 5 * time.Second}, c.Cache)
+	assert.Equal(t, "db-2", c.Replica)
+
+	// the values under a path
+	db := &dbConfig{}
+	assert.NoError(t, Bind(values(t, `{"hosts":{"db":{"host":"db-1"}}}`), db, BindPath("hosts", "db")))
+	assert.Equal(t, "db-1", db.Host)
+}
+
+func TestBindErrors(t *testing.T) {
+	err := Bind(values(t, `{"db": {"port": "abc"}}`), &appConfig{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `db.port: cannot bind "abc" to int`)
+	}
+
+	err = Bind(values(t, `{"db": {"host": "not a host!", "port": 70000}}`), &appConfig{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "name: failed the required rule")
+		assert.Contains(t, err.Error(), "db.host: failed the hostname rule")
+		assert.Contains(t, err.Error(), "db.port: failed the max=65535 rule")
+	}
+
+	// a typo is only reported in strict mode
+	data := `{"name": "greeter", "db": {"hots": "db-1", "replica": {"host": "db-2", "port": 1}}, "lables": {}}`
+	assert.NoError(t, Bind(values(t, data), &appConfig{}))
+	err = Bind(values(t, data), &appConfig{}, BindStrict())
+	if assert.Error(t, err) {
+		assert.Equal(t, "bind config: db.hots: unknown key; db.replica.port: unknown key; lables: unknown key", err.Error())
+	}
+
+	assert.Error(t, Bind(values(t, `{}`), appConfig{}))
+}
+
+type node struct {
+	Name string `config:"name" default:"leaf"`
+	Next *node  `config:"next"`
+}
+
+type cyclic struct {
+	*cyclic
+	Name string `config:"name"`
+}
+
+func TestBindRecursive(t *testing.T) {
+	n := &node{}
+	assert.NoError(t, Bind(values(t, `{"name": "a", "next": {"name": "b", "next": {}}}`), n, BindStrict()))
+	assert.Equal(t, &node{Name: "a", Next: &node{Name: "b", Next: &node{Name: "leaf"}}}, n)
+
+	n = &node{}
+	assert.NoError(t, Bind(values(t, `{}`), n))
+	assert.Equal(t, &node{Name: "leaf"}, n)
+
+	c := &cyclic{}
+	assert.NoError(t, Bind(values(t, `{"name": "a"}`), c))
+	assert.Equal(t, "a", c.Name)
+	assert.Nil(t, c.cyclic)
+}
@@ -23,6 +23,8 @@
 package config
 
 import (
+	"github.com/spf13/pflag"
+	"github.com/vine-io/vine/lib/config/loader"
 	"github.com/vine-io/vine/lib/config/reader"
 	"github.com/vine-io/vine/lib/config/source"
 	"github.com/vine-io/vine/lib/config/source/file"
@@ -55,8 +57,14 @@ type Watcher interface {
 var (
 	// DefaultConfig default Config Manager
 	DefaultConfig Config
+
+	Flag = pflag.NewFlagSet("config", pflag.ExitOnError)
 )
 
+func init() {
+	Flag.String("profile", "", "Profile of the config files e.g. prod reads config.prod.yaml over config.yaml, defaults to $VINE_PROFILE")
+}
+
 // Bytes Return config as raw json
 func Bytes() []byte {
 	return DefaultConfig.Bytes()
@@ -92,6 +100,15 @@ func Watch(path ...string) (Watcher, error) {
 	return DefaultConfig.Watch(path...)
 }
 
+// Audit returns the changes applied by the loader of c, the oldest first. It
+// is empty when the loader keeps no audit log.
+func Audit(c Config) []*loader.Entry {
+	if a, ok := c.Options().Loader.(loader.Auditor); ok {
+		return a.Audit()
+	}
+	return nil
+}
+
 // LoadFile is short hand for creating a file source and loading it
 func LoadFile(path string) error {
 	return Load(file.NewSource(
@@ -0,0 +1,158 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package debug serves the loaded config with the origin of its values and the
+// audit log of its changes over http. The values are served as they are loaded,
+// the encrypted values are left encrypted but the interpolated values are
+// redacted as they may hold secrets read from the environment.
+package debug
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	json "github.com/json-iterator/go"
+	"github.com/vine-io/vine/lib/config"
+	"github.com/vine-io/vine/lib/config/loader"
+	"github.com/vine-io/vine/lib/config/source"
+)
+
+var (
+	DefaultPrefix = "/debug/config"
+)
+
+// Value is a value of the config with its origin
+type Value struct {
+	Path   string         `json:"path"`
+	Value  interface{}    `json:"value"`
+	Origin *source.Origin `json:"origin,omitempty"`
+}
+
+// Config is the whole config with the origins of its values
+type Config struct {
+	Version string                    `json:"version"`
+	Config  map[string]interface{}    `json:"config"`
+	Origins map[string]*source.Origin `json:"origins"`
+}
+
+// Audit is the audit log of the config
+type Audit struct {
+	Entries []*loader.Entry `json:"entries"`
+}
+
+// authorize rejects the requests without the admin token as a bearer Authorization
+func authorize(token string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		v := ctx.GetHeader("Authorization")
+		if !strings.HasPrefix(v, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(v, "Bearer ")), []byte(token)) != 1 {
+			ctx.Header("WWW-Authenticate", "Bearer")
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, "invalid admin token")
+		}
+	}
+}
+
+// RegisterHandler serves the config at DefaultPrefix, its value at ?path=a.b
+// and the audit log at DefaultPrefix/audit. The requests carry the admin token
+// as a bearer Authorization, nothing is served without one.
+func RegisterHandler(c config.Config, router gin.IRoutes, token string) {
+	if len(token) == 0 {
+		return
+	}
+	router.GET(DefaultPrefix, authorize(token), configHandler(c))
+	router.GET(path.Join(DefaultPrefix, "audit"), authorize(token), auditHandler(c))
+}
+
+// redact replaces the interpolated values in place
+func redact(values map[string]interface{}, origins map[string]*source.Origin) {
+	for p, o := range origins {
+		if o == nil || !o.Interpolated {
+			continue
+		}
+		keys := strings.Split(p, ".")
+		m := values
+		for _, key := range keys[:len(keys)-1] {
+			if m, _ = m[key].(map[string]interface{}); m == nil {
+				break
+			}
+		}
+		if m == nil {
+			continue
+		}
+		if _, ok := m[keys[len(keys)-1]]; ok {
+			m[keys[len(keys)-1]] = loader.Redacted
+		}
+	}
+}
+
+func configHandler(c config.Config) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		snap, err := config.NewSnapshot(c)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+		values := make(map[string]interface{})
+		if err = json.Unmarshal(snap.Bytes(), &values); err != nil {
+			ctx.JSON(http.StatusInternalServerError, err.Error())
+			return
+		}
+		redact(values, snap.Origins())
+
+		p := ctx.Query("path")
+		if len(p) == 0 {
+			ctx.JSON(http.StatusOK, &Config{Version: snap.Version, Config: values, Origins: snap.Origins()})
+			return
+		}
+
+		keys := strings.Split(p, ".")
+		var v interface{} = values
+		for _, key := range keys {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				v = nil
+				break
+			}
+			if v, ok = m[key]; !ok {
+				break
+			}
+		}
+		if v == nil {
+			ctx.JSON(http.StatusNotFound, p+" not found")
+			return
+		}
+		ctx.JSON(http.StatusOK, &Value{Path: p, Value: v, Origin: snap.Get(keys...).Origin()})
+	}
+}
+
+func auditHandler(c config.Config) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		entries := config.Audit(c)
+		if entries == nil {
+			entries = []*loader.Entry{}
+		}
+		ctx.JSON(http.StatusOK, &Audit{Entries: entries})
+	}
+}
@@ -0,0 +1,144 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package debug
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vine-io/vine/lib/config"
+	"github.com/vine-io/vine/lib/config/loader"
+	cmemory "github.com/vine-io/vine/lib/config/memory"
+	"github.com/vine-io/vine/lib/config/source"
+	"github.com/vine-io/vine/lib/config/source/memory"
+)
+
+const token = "admin"
+
+func get(router http.Handler, target string, v interface{}) int {
+	rsp := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(rsp, req)
+	if rsp.Code == http.StatusOK {
+		json.Unmarshal(rsp.Body.Bytes(), v)
+	}
+	return rsp.Code
+}
+
+func TestHandler(t *testing.T) {
+	os.Setenv("VINE_TEST_DB_TOKEN", "s3cret")
+	defer os.Unsetenv("VINE_TEST_DB_TOKEN")
+
+	src := memory.NewSource(memory.WithJSON([]byte(`{"db": {"host": "db-1", "password": "ENC[secret]", "token": "${VINE_TEST_DB_TOKEN}"}}`)))
+	c := cmemory.NewConfig()
+	defer c.Close()
+	if !assert.NoError(t, c.Load(src)) {
+		return
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterHandler(c, router, token)
+
+	// the source may be updated before the loader watches it
+	up := src.(interface{ Update(*source.ChangeSet) })
+	assert.Eventually(t, func() bool {
+		up.Update(&source.ChangeSet{Data: []byte(`{"db": {"host": "db-2", "password": "ENC[secret]", "token": "${VINE_TEST_DB_TOKEN}"}, "level": "info"}`), Format: "json"})
+		return len(config.Audit(c)) == 2
+	}, 5*time.Second, 50*time.Millisecond)
+
+	var audit Audit
+	if !assert.Equal(t, http.StatusOK, get(router, DefaultPrefix+"/audit", &audit)) || !assert.Len(t, audit.Entries, 2) {
+		return
+	}
+	assert.Equal(t, "load", audit.Entries[0].Source)
+	if assert.Len(t, audit.Entries[0].Changes, 3) {
+		assert.Equal(t, "db.token", audit.Entries[0].Changes[2].Path)
+		assert.Equal(t, loader.Redacted, audit.Entries[0].Changes[2].To)
+	}
+	changes := audit.Entries[1].Changes
+	if assert.Len(t, changes, 2) {
+		assert.Equal(t, "db.host", changes[0].Path)
+		assert.Equal(t, "update", changes[0].Op)
+		assert.Equal(t, "db-1", changes[0].From)
+		assert.Equal(t, "db-2", changes[0].To)
+		assert.Equal(t, "level", changes[1].Path)
+		assert.Equal(t, "add", changes[1].Op)
+	}
+
+	var v Value
+	assert.Eventually(t, func() bool {
+		return get(router, DefaultPrefix+"?path=db.host", &v) == http.StatusOK && v.Value == "db-2"
+	}, 5*time.Second, 50*time.Millisecond)
+	if assert.NotNil(t, v.Origin) {
+		assert.Equal(t, "memory", v.Origin.Source)
+	}
+
+	var all Config
+	assert.Equal(t, http.StatusOK, get(router, DefaultPrefix, &all))
+	assert.Equal(t, "ENC[secret]", all.Config["db"].(map[string]interface{})["password"])
+	assert.Equal(t, loader.Redacted, all.Config["db"].(map[string]interface{})["token"])
+	assert.Contains(t, all.Origins, "level")
+	if assert.Contains(t, all.Origins, "db.token") {
+		assert.True(t, all.Origins["db.token"].Interpolated)
+	}
+
+	assert.Equal(t, http.StatusOK, get(router, DefaultPrefix+"?path=db.token", &v))
+	assert.Equal(t, loader.Redacted, v.Value)
+	assert.Equal(t, http.StatusOK, get(router, DefaultPrefix+"?path=db", &v))
+	assert.Equal(t, loader.Redacted, v.Value.(map[string]interface{})["token"])
+
+	assert.Equal(t, http.StatusNotFound, get(router, DefaultPrefix+"?path=db.missing", &v))
+}
+
+func TestAdminToken(t *testing.T) {
+	c := cmemory.NewConfig()
+	defer c.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterHandler(c, router, token)
+
+	for _, header := range []string{"", "Bearer wrong", token} {
+		rsp := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, DefaultPrefix, nil)
+		if len(header) > 0 {
+			req.Header.Set("Authorization", header)
+		}
+		router.ServeHTTP(rsp, req)
+		assert.Equal(t, http.StatusUnauthorized, rsp.Code, header)
+	}
+
+	// nothing is served without a token
+	router = gin.New()
+	RegisterHandler(c, router, "")
+	assert.Equal(t, http.StatusNotFound, get(router, DefaultPrefix, &Config{}))
+}
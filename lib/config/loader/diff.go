@@ -0,0 +1,62 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package loader
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/vine-io/vine/lib/config/source"
+)
+
+// Values returns the values of v which are not maps keyed by their dotted path
+func Values(v interface{}) map[string]interface{} {
+	values := make(map[string]interface{})
+	source.Walk(v, func(path string, v interface{}) {
+		values[path] = v
+	})
+	return values
+}
+
+// Diff returns the changes from the values a to b sorted by path
+func Diff(a, b map[string]interface{}) []*Change {
+	var changes []*Change
+	for path, from := range a {
+		to, ok := b[path]
+		switch {
+		case !ok:
+			changes = append(changes, &Change{Path: path, Op: "remove", From: from})
+		case !reflect.DeepEqual(from, to):
+			changes = append(changes, &Change{Path: path, Op: "update", From: from, To: to})
+		}
+	}
+	for path, to := range b {
+		if _, ok := a[path]; !ok {
+			changes = append(changes, &Change{Path: path, Op: "add", To: to})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
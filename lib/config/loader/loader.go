@@ -25,6 +25,7 @@ package loader
 
 import (
 	"context"
+	"time"
 
 	"github.com/vine-io/vine/lib/config/reader"
 	"github.com/vine-io/vine/lib/config/source"
@@ -64,9 +65,49 @@ type Snapshot struct {
 	Version string
 }
 
+// Validator checks a merged ChangeSet before it is applied
+type Validator interface {
+	Validate(*source.ChangeSet) error
+}
+
+// Auditor is implemented by the loaders which keep an audit log
+type Auditor interface {
+	// Audit returns the applied ChangeSets, the oldest first
+	Audit() []*Entry
+}
+
+// Entry is a merged ChangeSet applied by a loader
+type Entry struct {
+	// Version of the snapshot made by the ChangeSet
+	Version string `json:"version"`
+	// Source which changed, load or sync when the sources are read again
+	Source    string    `json:"source"`
+	CheckSum  string    `json:"checksum"`
+	Timestamp time.Time `json:"timestamp"`
+	// Changes against the previous snapshot
+	Changes []*Change `json:"changes"`
+}
+
+// Redacted replaces the interpolated values in the audit log, they may hold
+// secrets read from the environment
+var Redacted = "[redacted]"
+
+// Change is a value changed by a ChangeSet. The encrypted values are kept
+// encrypted and the interpolated values are Redacted.
+type Change struct {
+	// Path is the dotted path of the value
+	Path string `json:"path"`
+	// Op is add, remove or update
+	Op   string      `json:"op"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
 type Options struct {
 	Reader reader.Reader
 	Source []source.Source
+	// Validators reject the merged ChangeSets which are invalid
+	Validators []Validator
 
 	// for alternative data
 	Context context.Context
@@ -0,0 +1,108 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package memory
+
+import (
+	"strings"
+
+	json "github.com/json-iterator/go"
+	"github.com/vine-io/vine/lib/config/loader"
+	"github.com/vine-io/vine/lib/config/source"
+)
+
+var (
+	// DefaultAuditSize is the number of entries kept in the audit log
+	DefaultAuditSize = 100
+)
+
+// audit keeps the last applied ChangeSets
+type audit struct {
+	size    int
+	entries []*loader.Entry
+	// the values of the last snapshot keyed by their dotted path
+	last map[string]interface{}
+	// the origins of the last snapshot, to redact its interpolated values
+	origins map[string]*source.Origin
+}
+
+func newAudit(size int) *audit {
+	return &audit{size: size, last: map[string]interface{}{}}
+}
+
+// record adds the snapshot to the log with the changes against the last one,
+// the snapshots which change nothing are not recorded. The interpolated values
+// are redacted.
+func (a *audit) record(from string, snap *loader.Snapshot) {
+	if a.size <= 0 {
+		return
+	}
+
+	var v interface{}
+	_ = json.Unmarshal(snap.ChangeSet.Data, &v)
+	values := loader.Values(v)
+
+	changes := loader.Diff(a.last, values)
+	for _, c := range changes {
+		if c.From != nil && interpolated(a.origins, c.Path) {
+			c.From = loader.Redacted
+		}
+		if c.To != nil && interpolated(snap.ChangeSet.Origins, c.Path) {
+			c.To = loader.Redacted
+		}
+	}
+	a.last = values
+	a.origins = snap.ChangeSet.Origins
+	if len(changes) == 0 {
+		return
+	}
+
+	a.entries = append(a.entries, &loader.Entry{
+		Version:   snap.Version,
+		Source:    from,
+		CheckSum:  snap.ChangeSet.CheckSum,
+		Timestamp: snap.ChangeSet.Timestamp,
+		Changes:   changes,
+	})
+	if n := len(a.entries) - a.size; n > 0 {
+		a.entries = append(a.entries[:0:0], a.entries[n:]...)
+	}
+}
+
+// interpolated reports whether the value at the path or a value above it was
+// interpolated, the origins of lists are kept at the path of the list
+func interpolated(origins map[string]*source.Origin, path string) bool {
+	for {
+		if o, ok := origins[path]; ok && o != nil && o.Interpolated {
+			return true
+		}
+		i := strings.LastIndex(path, ".")
+		if i < 0 {
+			return false
+		}
+		path = path[:i]
+	}
+}
+
+func (a *audit) list() []*loader.Entry {
+	return append([]*loader.Entry{}, a.entries...)
+}
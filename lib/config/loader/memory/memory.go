@@ -35,6 +35,7 @@ import (
 	"github.com/vine-io/vine/lib/config/reader"
 	"github.com/vine-io/vine/lib/config/reader/json"
 	"github.com/vine-io/vine/lib/config/source"
+	log "github.com/vine-io/vine/lib/logger"
 )
 
 type memory struct {
@@ -52,11 +53,13 @@ type memory struct {
 	sources []source.Source
 
 	watchers *list.List
+	audit    *audit
 }
 
 type updateValue struct {
 	version string
 	value   reader.Value
+	origins map[string]*source.Origin
 }
 
 type watcher struct {
@@ -81,13 +84,17 @@ func (m *memory) watch(idx int, s source.Source) {
 			m.Lock()
 
 			// save
+			prev := m.sets[idx]
 			m.sets[idx] = cs
 
 			// merge sets
-			set, err := m.opts.Reader.Merge(m.sets...)
+			set, err := m.merge(m.sets...)
 			if err != nil {
+				// reject the change and keep the last good config
+				m.sets[idx] = prev
 				m.Unlock()
-				return err
+				log.Errorf("config: rejected the change of source %s: %v", cs.Source, err)
+				continue
 			}
 
 			// set values
@@ -96,6 +103,7 @@ func (m *memory) watch(idx int, s source.Source) {
 				ChangeSet: set,
 				Version:   genVer(),
 			}
+			m.audit.record(cs.Source, m.snap)
 			m.Unlock()
 
 			// send watch updates
@@ -150,12 +158,29 @@ func (m *memory) loaded() bool {
 	return loaded
 }
 
+// merge merges the sets, resolves their references and checks the result with the validators
+func (m *memory) merge(sets ...*source.ChangeSet) (*source.ChangeSet, error) {
+	set, err := m.opts.Reader.Merge(sets...)
+	if err != nil {
+		return nil, err
+	}
+	if set, err = reader.InterpolateSet(set); err != nil {
+		return nil, err
+	}
+	for _, v := range m.opts.Validators {
+		if err := v.Validate(set); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
 // reload reads the sets and creates new values
 func (m *memory) reload() error {
 	m.Lock()
 
 	// merge sets
-	set, err := m.opts.Reader.Merge(m.sets...)
+	set, err := m.merge(m.sets...)
 	if err != nil {
 		m.Unlock()
 		return err
@@ -167,6 +192,7 @@ func (m *memory) reload() error {
 		ChangeSet: set,
 		Version:   genVer(),
 	}
+	m.audit.record("load", m.snap)
 
 	m.Unlock()
 
@@ -188,16 +214,19 @@ func (m *memory) update() {
 	snap := m.snap
 	m.RUnlock()
 
+	// the watchers drop the versions they have already seen in Next
 	for _, w := range watchers {
-		if w.version >= snap.Version {
-			continue
-		}
-
 		uv := updateValue{
-			version: m.snap.Version,
+			version: snap.Version,
 			value:   vals.Get(w.path...),
+			origins: origins(snap.ChangeSet.Origins, w.path),
 		}
 
+		// replace the pending update, only the latest one matters
+		select {
+		case <-w.updates:
+		default:
+		}
 		select {
 		case w.updates <- uv:
 		default:
@@ -247,7 +276,7 @@ func (m *memory) Sync() error {
 	}
 
 	// merge sets
-	set, err := m.opts.Reader.Merge(sets...)
+	set, err := m.merge(sets...)
 	if err != nil {
 		m.Unlock()
 		return err
@@ -264,6 +293,7 @@ func (m *memory) Sync() error {
 		ChangeSet: set,
 		Version:   genVer(),
 	}
+	m.audit.record("sync", m.snap)
 
 	m.Unlock()
 
@@ -328,6 +358,8 @@ func (m *memory) Get(path ...string) (reader.Value, error) {
 func (m *memory) Load(sources ...source.Source) error {
 	var gerrors []string
 
+	var loaded []source.Source
+	var sets []*source.ChangeSet
 	for _, item := range sources {
 		set, err := item.Read()
 		if err != nil {
@@ -335,9 +367,23 @@ func (m *memory) Load(sources ...source.Source) error {
 			// continue processing
 			continue
 		}
+		loaded = append(loaded, item)
+		sets = append(sets, set)
+	}
+
+	// the sources are not added when the config they make is invalid
+	m.RLock()
+	_, err := m.merge(append(append([]*source.ChangeSet{}, m.sets...), sets...)...)
+	m.RUnlock()
+	if err != nil {
+		gerrors = append(gerrors, err.Error())
+		return errors.New(strings.Join(gerrors, "\n"))
+	}
+
+	for i, item := range loaded {
 		m.Lock()
 		m.sources = append(m.sources, item)
-		m.sets = append(m.sets, set)
+		m.sets = append(m.sets, sets[i])
 		idx := len(m.sets) - 1
 		m.Unlock()
 		go m.watch(idx, item)
@@ -385,19 +431,27 @@ func (m *memory) Watch(path ...string) (loader.Watcher, error) {
 	return w, nil
 }
 
+// Audit returns the applied changes, the oldest first
+func (m *memory) Audit() []*loader.Entry {
+	m.RLock()
+	defer m.RUnlock()
+	return m.audit.list()
+}
+
 func (m *memory) String() string {
 	return "memory"
 }
 
 func (w *watcher) Next() (*loader.Snapshot, error) {
-	update := func(v reader.Value) *loader.Snapshot {
-		w.value = v
+	update := func(uv updateValue) *loader.Snapshot {
+		w.value = uv.value
 
 		cs := &source.ChangeSet{
-			Data:      v.Bytes(),
+			Data:      uv.value.Bytes(),
 			Format:    w.reader.String(),
 			Source:    "memory",
 			Timestamp: time.Now(),
+			Origins:   uv.origins,
 		}
 		cs.CheckSum = cs.Sum()
 
@@ -425,7 +479,7 @@ func (w *watcher) Next() (*loader.Snapshot, error) {
 				continue
 			}
 
-			return update(v), nil
+			return update(uv), nil
 		}
 	}
 }
@@ -434,13 +488,37 @@ func (w *watcher) Stop() error {
 	select {
 	case <-w.exit:
 	default:
+		// updates is not closed, it may still be sent to until the
+		// watcher is removed
 		close(w.exit)
-		close(w.updates)
 	}
 
 	return nil
 }
 
+// origins returns the origins of the values below the path keyed by their
+// path relative to it
+func origins(all map[string]*source.Origin, path []string) map[string]*source.Origin {
+	if len(path) == 0 || len(all) == 0 {
+		return all
+	}
+	sub := make(map[string]*source.Origin)
+	// the value at the path is not a map
+	for i := len(path); i > 0; i-- {
+		if o, ok := all[strings.Join(path[:i], ".")]; ok {
+			sub[""] = o
+			return sub
+		}
+	}
+	prefix := strings.Join(path, ".")
+	for k, o := range all {
+		if strings.HasPrefix(k, prefix+".") {
+			sub[strings.TrimPrefix(k, prefix+".")] = o
+		}
+	}
+	return sub
+}
+
 func genVer() string {
 	return fmt.Sprintf("%d", time.Now().UnixNano())
 }
@@ -454,11 +532,19 @@ func NewLoader(opts ...loader.Option) loader.Loader {
 		o(&options)
 	}
 
+	size := DefaultAuditSize
+	if options.Context != nil {
+		if n, ok := options.Context.Value(auditSizeKey{}).(int); ok {
+			size = n
+		}
+	}
+
 	m := &memory{
 		exit:     make(chan bool),
 		opts:     options,
 		watchers: list.New(),
 		sources:  options.Source,
+		audit:    newAudit(size),
 	}
 
 	m.sets = make([]*source.ChangeSet, len(options.Source))
@@ -23,6 +23,8 @@
 package memory
 
 import (
+	"context"
+
 	"github.com/vine-io/vine/lib/config/loader"
 	"github.com/vine-io/vine/lib/config/reader"
 	"github.com/vine-io/vine/lib/config/source"
@@ -41,3 +43,24 @@ func WithReader(r reader.Reader) loader.Option {
 		o.Reader = r
 	}
 }
+
+// WithValidator appends a validator of the merged ChangeSets, a change of a
+// source which fails it is rejected and the last good config is kept
+func WithValidator(v loader.Validator) loader.Option {
+	return func(o *loader.Options) {
+		o.Validators = append(o.Validators, v)
+	}
+}
+
+type auditSizeKey struct{}
+
+// WithAuditSize sets the number of applied changes kept in the audit log,
+// DefaultAuditSize by default. Zero disables the audit log.
+func WithAuditSize(n int) loader.Option {
+	return func(o *loader.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, auditSizeKey{}, n)
+	}
+}
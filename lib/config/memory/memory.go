@@ -73,7 +73,11 @@ func (c *memory) Init(opts ...config.Option) error {
 
 	// default loader uses the configured reader
 	if c.opts.Loader == nil {
-		c.opts.Loader = m.NewLoader(m.WithReader(c.opts.Reader))
+		lopts := []loader.Option{m.WithReader(c.opts.Reader)}
+		for _, v := range c.opts.Validators {
+			lopts = append(lopts, m.WithValidator(v))
+		}
+		c.opts.Loader = m.NewLoader(lopts...)
 	}
 
 	err := c.opts.Loader.Load(c.opts.Source...)
@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/vine-io/vine/lib/config/reader"
+	"github.com/vine-io/vine/lib/config/source"
 )
 
 type value struct{}
@@ -69,3 +70,7 @@ func (v *value) Scan(val interface{}) error {
 func (v *value) Bytes() []byte {
 	return nil
 }
+
+func (v *value) Origin() *source.Origin {
+	return nil
+}
@@ -34,6 +34,9 @@ type Options struct {
 	Loader loader.Loader
 	Reader reader.Reader
 	Source []source.Source
+	// Validators reject the merged changes which are invalid, they are
+	// passed to the default loader
+	Validators []loader.Validator
 
 	// for alternative data
 	Context context.Context
@@ -61,3 +64,11 @@ func WithReader(r reader.Reader) Option {
 		o.Reader = r
 	}
 }
+
+// WithValidator appends a validator of the merged config e.g. a JSON Schema,
+// a change which fails it is rejected and the last good config is kept
+func WithValidator(v loader.Validator) Option {
+	return func(o *Options) {
+		o.Validators = append(o.Validators, v)
+	}
+}
@@ -41,6 +41,7 @@ type jsonReader struct {
 
 func (j *jsonReader) Merge(changes ...*source.ChangeSet) (*source.ChangeSet, error) {
 	var merged map[string]interface{}
+	origins := make(map[string]*source.Origin)
 
 	for _, m := range changes {
 		if m == nil {
@@ -64,6 +65,7 @@ func (j *jsonReader) Merge(changes ...*source.ChangeSet) (*source.ChangeSet, err
 		if err := mergo.Map(&merged, data, mergo.WithOverride); err != nil {
 			return nil, err
 		}
+		mergeOrigins(origins, m, data)
 	}
 
 	b, err := j.json.Encode(merged)
@@ -76,12 +78,34 @@ func (j *jsonReader) Merge(changes ...*source.ChangeSet) (*source.ChangeSet, err
 		Data:      b,
 		Source:    "json",
 		Format:    j.json.String(),
+		Origins:   origins,
 	}
 	cs.CheckSum = cs.Sum()
 
 	return cs, nil
 }
 
+// mergeOrigins sets the origins of the values of the set, they replace the
+// origins of the values they override
+func mergeOrigins(origins map[string]*source.Origin, cs *source.ChangeSet, data map[string]interface{}) {
+	source.Walk(data, func(path string, _ interface{}) {
+		o, ok := cs.Origins[path]
+		if ok {
+			c := *o
+			o = &c
+		} else {
+			o = &source.Origin{}
+		}
+		if len(o.Source) == 0 {
+			o.Source = cs.Source
+		}
+		if o.Timestamp.IsZero() {
+			o.Timestamp = cs.Timestamp
+		}
+		source.SetOrigin(origins, path, o)
+	})
+}
+
 func (j *jsonReader) Values(ch *source.ChangeSet) (reader.Values, error) {
 	if ch == nil {
 		return nil, errors.New("changeset is nil")
@@ -89,7 +113,7 @@ func (j *jsonReader) Values(ch *source.ChangeSet) (reader.Values, error) {
 	if ch.Format != "json" {
 		return nil, errors.New("unsupported format")
 	}
-	return newValues(ch)
+	return newValues(ch, j.opts.Secrets...)
 }
 
 func (j *jsonReader) String() string {
@@ -23,7 +23,9 @@
 package json
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/vine-io/vine/lib/config/source"
 )
@@ -62,4 +64,64 @@ func TestReader(t *testing.T) {
 			t.Fatalf("Expected %s got %s for path %v", test.value, v, test.path)
 		}
 	}
+
+	// the references are resolved by the loader of the consumer
+	c, err = r.Merge(&source.ChangeSet{Data: []byte(`{"a": "${HOME}", "b": "${db.host}"}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(c.Data), `"a":"${HOME}"`) || !strings.Contains(string(c.Data), `"b":"${db.host}"`) {
+		t.Fatalf("Expected the references to be kept, got %s", c.Data)
+	}
+}
+
+func TestOrigins(t *testing.T) {
+	now := time.Now()
+	file := &source.ChangeSet{
+		Data:      []byte(`{"db": {"host": "localhost", "port": 3306}, "name": "app", "tags": ["a", "b"]}`),
+		Source:    "file",
+		Timestamp: now.Add(-time.Minute),
+		Origins: map[string]*source.Origin{
+			"db.host": {Source: "file", File: "config.json", Line: 1},
+		},
+	}
+	env := &source.ChangeSet{
+		Data:      []byte(`{"db": {"port": 5432}, "name": {"first": "app"}}`),
+		Source:    "env",
+		Timestamp: now,
+	}
+
+	r := NewReader()
+	c, err := r.Merge(file, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := r.Values(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := values.Get("db", "host").Origin()
+	if o == nil || o.File != "config.json" || o.Line != 1 || !o.Timestamp.Equal(file.Timestamp) {
+		t.Fatalf("unexpected origin of db.host %+v", o)
+	}
+	if o = values.Get("db", "port").Origin(); o == nil || o.Source != "env" {
+		t.Fatalf("unexpected origin of db.port %+v", o)
+	}
+	if o = values.Get("tags").Origin(); o == nil || o.Source != "file" {
+		t.Fatalf("unexpected origin of tags %+v", o)
+	}
+	if o = values.Get("tags", "0").Origin(); o == nil || o.Source != "file" {
+		t.Fatalf("unexpected origin of tags.0 %+v", o)
+	}
+	// the latest value of the map
+	if o = values.Get("db").Origin(); o == nil || o.Source != "env" {
+		t.Fatalf("unexpected origin of db %+v", o)
+	}
+	if _, ok := c.Origins["name"]; ok {
+		t.Fatal("the origin of a value replaced by a map should be removed")
+	}
+	if o = values.Get("missing").Origin(); o != nil {
+		t.Fatalf("unexpected origin of a missing value %+v", o)
+	}
 }
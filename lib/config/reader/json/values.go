@@ -32,29 +32,60 @@ import (
 	json "github.com/json-iterator/go"
 
 	"github.com/vine-io/vine/lib/config/reader"
+	"github.com/vine-io/vine/lib/config/secrets"
 	"github.com/vine-io/vine/lib/config/source"
 )
 
 type jsonValues struct {
-	ch *source.ChangeSet
-	sj *simple.Json
+	ch      *source.ChangeSet
+	sj      *simple.Json
+	secrets []secrets.Secrets
 }
 
+// jsonValue decrypts the encrypted values when they are read
 type jsonValue struct {
 	*simple.Json
+	secrets []secrets.Secrets
+	origin  *source.Origin
 }
 
-func newValues(ch *source.ChangeSet) (reader.Values, error) {
+func newValues(ch *source.ChangeSet, ss ...secrets.Secrets) (reader.Values, error) {
 	sj := simple.New()
 	data, _ := reader.ReplaceEnvVars(ch.Data)
 	if err := sj.UnmarshalJSON(data); err != nil {
 		sj.SetPath(nil, string(ch.Data))
 	}
-	return &jsonValues{ch, sj}, nil
+	return &jsonValues{ch, sj, ss}, nil
 }
 
 func (j *jsonValues) Get(path ...string) reader.Value {
-	return &jsonValue{j.sj.GetPath(path...)}
+	return &jsonValue{j.sj.GetPath(path...), j.secrets, j.origin(path)}
+}
+
+// origin returns the origin of the value at the path, the one of the list or
+// the array which holds it or the latest one of the values of a map
+func (j *jsonValues) origin(path []string) *source.Origin {
+	if len(j.ch.Origins) == 0 {
+		return nil
+	}
+	for i := len(path); i > 0; i-- {
+		if o, ok := j.ch.Origins[strings.Join(path[:i], ".")]; ok {
+			return o
+		}
+	}
+
+	prefix := strings.Join(path, ".") + "."
+	var latest *source.Origin
+	var key string
+	for k, o := range j.ch.Origins {
+		if len(path) > 0 && !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		if latest == nil || o.Timestamp.After(latest.Timestamp) || (o.Timestamp.Equal(latest.Timestamp) && k < key) {
+			latest, key = o, k
+		}
+	}
+	return latest
 }
 
 func (j *jsonValues) Del(path ...string) {
@@ -79,6 +110,7 @@ func (j *jsonValues) Set(val interface{}, path ...string) {
 	j.sj.SetPath(path, val)
 }
 
+// Bytes returns the values as they are stored, encrypted values are not decrypted
 func (j *jsonValues) Bytes() []byte {
 	b, _ := j.sj.MarshalJSON()
 	return b
@@ -86,11 +118,21 @@ func (j *jsonValues) Bytes() []byte {
 
 func (j *jsonValues) Map() map[string]interface{} {
 	m, _ := j.sj.Map()
+	// values which can't be decrypted are left encrypted
+	if v, err := reader.Decrypt(m, j.secrets); err == nil {
+		m, _ = v.(map[string]interface{})
+	}
 	return m
 }
 
 func (j *jsonValues) Scan(v interface{}) error {
-	b, err := j.sj.MarshalJSON()
+	data, err := reader.Decrypt(j.sj.Interface(), j.secrets)
+	if err != nil {
+		return err
+	}
+	sj := simple.New()
+	sj.SetPath(nil, data)
+	b, err := sj.MarshalJSON()
 	if err != nil {
 		return err
 	}
@@ -101,14 +143,38 @@ func (j *jsonValues) String() string {
 	return "json"
 }
 
+// str returns the string value, decrypted when it is encrypted
+func (j *jsonValue) str() (string, error) {
+	s, err := j.Json.String()
+	if err != nil || len(j.secrets) == 0 || !secrets.IsEncrypted(s) {
+		return s, err
+	}
+	b, err := secrets.DecryptValue(s, j.secrets...)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// decrypted returns the value with the encrypted strings decrypted
+func (j *jsonValue) decrypted() (*simple.Json, error) {
+	data, err := reader.Decrypt(j.Interface(), j.secrets)
+	if err != nil {
+		return nil, err
+	}
+	sj := simple.New()
+	sj.SetPath(nil, data)
+	return sj, nil
+}
+
 func (j *jsonValue) Bool(def bool) bool {
 	b, err := j.Json.Bool()
 	if err == nil {
 		return b
 	}
 
-	str, ok := j.Interface().(string)
-	if !ok {
+	str, err := j.str()
+	if err != nil {
 		return def
 	}
 
@@ -126,8 +192,8 @@ func (j *jsonValue) Int(def int64) int64 {
 		return i
 	}
 
-	str, ok := j.Interface().(string)
-	if !ok {
+	str, err := j.str()
+	if err != nil {
 		return def
 	}
 
@@ -140,7 +206,11 @@ func (j *jsonValue) Int(def int64) int64 {
 }
 
 func (j *jsonValue) String(def string) string {
-	return j.Json.MustString(def)
+	s, err := j.str()
+	if err != nil {
+		return def
+	}
+	return s
 }
 
 func (j *jsonValue) Float64(def float64) float64 {
@@ -149,8 +219,8 @@ func (j *jsonValue) Float64(def float64) float64 {
 		return f
 	}
 
-	str, ok := j.Interface().(string)
-	if !ok {
+	str, err := j.str()
+	if err != nil {
 		return def
 	}
 
@@ -163,7 +233,7 @@ func (j *jsonValue) Float64(def float64) float64 {
 }
 
 func (j *jsonValue) Duration(def time.Duration) time.Duration {
-	v, err := j.Json.String()
+	v, err := j.str()
 	if err != nil {
 		return def
 	}
@@ -177,18 +247,27 @@ func (j *jsonValue) Duration(def time.Duration) time.Duration {
 }
 
 func (j *jsonValue) StringSlice(def []string) []string {
-	v, err := j.Json.String()
+	v, err := j.str()
 	if err == nil {
 		sl := strings.Split(v, ",")
 		if len(sl) > 1 {
 			return sl
 		}
 	}
-	return j.Json.MustStringArray(def)
+
+	sj, err := j.decrypted()
+	if err != nil {
+		return def
+	}
+	return sj.MustStringArray(def)
 }
 
 func (j *jsonValue) StringMap(def map[string]string) map[string]string {
-	m, err := j.Json.Map()
+	sj, err := j.decrypted()
+	if err != nil {
+		return def
+	}
+	m, err := sj.Map()
 	if err != nil {
 		return def
 	}
@@ -203,22 +282,35 @@ func (j *jsonValue) StringMap(def map[string]string) map[string]string {
 }
 
 func (j *jsonValue) Scan(v interface{}) error {
-	b, err := j.Json.MarshalJSON()
+	sj, err := j.decrypted()
+	if err != nil {
+		return err
+	}
+	b, err := sj.MarshalJSON()
 	if err != nil {
 		return err
 	}
 	return json.Unmarshal(b, v)
 }
 
+func (j *jsonValue) Origin() *source.Origin {
+	return j.origin
+}
+
 func (j *jsonValue) Bytes() []byte {
-	b, err := j.Json.Bytes()
+	s, err := j.str()
+	if err == nil {
+		return []byte(s)
+	}
+
+	// try return marshalled
+	sj, err := j.decrypted()
 	if err != nil {
-		// try return marshalled
-		b, err := j.Json.MarshalJSON()
-		if err != nil {
-			return []byte{}
-		}
-		return b
+		return []byte{}
+	}
+	b, err := sj.MarshalJSON()
+	if err != nil {
+		return []byte{}
 	}
 	return b
 }
@@ -24,8 +24,12 @@ package json
 
 import (
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/vine-io/vine/lib/config/secrets"
+	"github.com/vine-io/vine/lib/config/secrets/secretbox"
 	"github.com/vine-io/vine/lib/config/source"
 )
 
@@ -105,3 +109,75 @@ func TestStructArray(t *testing.T) {
 		}
 	}
 }
+
+func TestEncryptedValues(t *testing.T) {
+	key := make([]byte, 32)
+	s := secretbox.NewSecrets()
+	if err := s.Init(secrets.Key(key)); err != nil {
+		t.Fatal(err)
+	}
+	password, err := secrets.EncryptValue(s, []byte("s3cr3t"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeout, err := secrets.EncryptValue(s, []byte("5s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(`{"db": {"user": "vine", "password": "` + password + `", "timeout": "` + timeout + `"}}`)
+
+	values, err := newValues(&source.ChangeSet{Data: data}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := values.Get("db", "password").String(""); v != "s3cr3t" {
+		t.Fatalf("Expected s3cr3t got %s", v)
+	}
+	if v := values.Get("db", "timeout").Duration(0); v != 5*time.Second {
+		t.Fatalf("Expected 5s got %v", v)
+	}
+
+	var db struct {
+		User     string `json:"user"`
+		Password string `json:"password"`
+	}
+	if err = values.Get("db").Scan(&db); err != nil {
+		t.Fatal(err)
+	}
+	if db.User != "vine" || db.Password != "s3cr3t" {
+		t.Fatalf("Expected vine:s3cr3t got %s:%s", db.User, db.Password)
+	}
+
+	// the stored values stay encrypted
+	if !strings.Contains(string(values.Bytes()), password) {
+		t.Fatal("Expected the encrypted value in the bytes")
+	}
+
+	// without secrets the values are read as they are stored
+	values, err = newValues(&source.ChangeSet{Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := values.Get("db", "password").String(""); v != password {
+		t.Fatalf("Expected %s got %s", password, v)
+	}
+
+	// values encrypted with another key fail to decrypt
+	otherKey := make([]byte, 32)
+	otherKey[0] = 1
+	other := secretbox.NewSecrets()
+	if err := other.Init(secrets.Key(otherKey)); err != nil {
+		t.Fatal(err)
+	}
+	values, err = newValues(&source.ChangeSet{Data: data}, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := values.Get("db", "password").String("def"); v != "def" {
+		t.Fatalf("Expected def got %s", v)
+	}
+	if err = values.Get("db").Scan(&db); err == nil {
+		t.Fatal("Expected an error decrypting with another key")
+	}
+}
@@ -28,10 +28,13 @@ import (
 	"github.com/vine-io/vine/lib/config/encoder/json"
 	"github.com/vine-io/vine/lib/config/encoder/toml"
 	"github.com/vine-io/vine/lib/config/encoder/yaml"
+	"github.com/vine-io/vine/lib/config/secrets"
 )
 
 type Options struct {
 	Encoding map[string]encoder.Encoder
+	// Secrets decrypt the encrypted values
+	Secrets []secrets.Secrets
 }
 
 type Option func(o *Options)
@@ -60,3 +63,12 @@ func WithEncoder(e encoder.Encoder) Option {
 		o.Encoding[e.String()] = e
 	}
 }
+
+// WithSecrets appends the secrets which decrypt the encrypted values, e.g.
+// ENC[secretbox:...], when they are read. Pass both the old and the new
+// secrets while the key is rotated.
+func WithSecrets(s ...secrets.Secrets) Option {
+	return func(o *Options) {
+		o.Secrets = append(o.Secrets, s...)
+	}
+}
@@ -23,8 +23,15 @@
 package reader
 
 import (
+	"bytes"
+	"fmt"
 	"os"
 	"regexp"
+	"strings"
+
+	json "github.com/json-iterator/go"
+
+	"github.com/vine-io/vine/lib/config/source"
 )
 
 func ReplaceEnvVars(raw []byte) ([]byte, error) {
@@ -43,3 +50,209 @@ func replaceEnvVars(element string) string {
 	el := os.Getenv(v)
 	return el
 }
+
+var interpolateRe = regexp.MustCompile(`\$\{([A-Za-z0-9_]+(?:\.[A-Za-z0-9_\-]+)*)(?::-([^}]*))?\}`)
+
+// Interpolate replaces the references of the string values in place. A dotted
+// name refers to another key e.g. ${db.host}, any other name to an environment
+// variable e.g. ${DB_PORT}. Both take a default e.g. ${DB_PORT:-3306} which is
+// used when the key is missing or the variable is unset or empty. A value which
+// is a single reference to a key takes the type of that key. $${ is a literal ${
+// e.g. $${HOME} is left as ${HOME}.
+func Interpolate(v map[string]interface{}) error {
+	ip := &interpolator{root: v, resolving: make(map[string]bool), resolved: make(map[string]interface{})}
+	_, err := ip.walk(nil, v)
+	return err
+}
+
+// InterpolateSet returns a copy of the merged json set with its references
+// replaced. It is a step of the loader and not of the reader, the references
+// resolve against the environment of the process which consumes the config.
+// The origins of the values which held references are marked Interpolated.
+func InterpolateSet(cs *source.ChangeSet) (*source.ChangeSet, error) {
+	if cs == nil || len(cs.Data) == 0 {
+		return cs, nil
+	}
+
+	var v map[string]interface{}
+	if err := json.Unmarshal(cs.Data, &v); err != nil {
+		return nil, err
+	}
+	paths := references(v)
+	if err := Interpolate(v); err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	set := *cs
+	set.Data = b
+	set.CheckSum = set.Sum()
+	if len(paths) > 0 {
+		set.Origins = make(map[string]*source.Origin, len(cs.Origins)+len(paths))
+		for path, o := range cs.Origins {
+			set.Origins[path] = o
+		}
+		for _, path := range paths {
+			o := source.Origin{Source: cs.Source, Timestamp: cs.Timestamp}
+			if orig, ok := set.Origins[path]; ok && orig != nil {
+				o = *orig
+			}
+			o.Interpolated = true
+			set.Origins[path] = &o
+		}
+	}
+	return &set, nil
+}
+
+// references returns the dotted paths of the values of v which hold references,
+// the lists are walked as a whole
+func references(v map[string]interface{}) []string {
+	var paths []string
+	source.Walk(v, func(path string, v interface{}) {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return
+		}
+		if interpolateRe.Match(bytes.ReplaceAll(b, []byte("$${"), nil)) {
+			paths = append(paths, path)
+		}
+	})
+	return paths
+}
+
+type interpolator struct {
+	root map[string]interface{}
+	// the keys being resolved, to detect cycles
+	resolving map[string]bool
+	// the values of the keys resolved, which are not resolved again as
+	// their escaped references are now literal
+	resolved map[string]interface{}
+}
+
+func (ip *interpolator) walk(path []string, v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, item := range x {
+			nv, err := ip.walk(append(path, k), item)
+			if err != nil {
+				return nil, err
+			}
+			x[k] = nv
+		}
+	case []interface{}:
+		for i, item := range x {
+			nv, err := ip.walk(append(path, fmt.Sprint(i)), item)
+			if err != nil {
+				return nil, err
+			}
+			x[i] = nv
+		}
+	case string:
+		key := strings.Join(path, ".")
+		if nv, ok := ip.resolved[key]; ok {
+			return nv, nil
+		}
+		nv, err := ip.resolve(key, x)
+		if err != nil {
+			return nil, err
+		}
+		ip.resolved[key] = nv
+		return nv, nil
+	}
+	return v, nil
+}
+
+func (ip *interpolator) resolve(key, s string) (interface{}, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	if ip.resolving[key] {
+		return nil, fmt.Errorf("interpolate %s: reference cycle", key)
+	}
+	ip.resolving[key] = true
+	defer delete(ip.resolving, key)
+
+	// a single reference keeps the type of the key
+	parts := strings.Split(s, "$${")
+	if m := interpolateRe.FindStringSubmatchIndex(s); len(parts) == 1 && m != nil && m[0] == 0 && m[1] == len(s) {
+		return ip.lookup(key, s)
+	}
+
+	for i, part := range parts {
+		out, err := ip.replace(key, part)
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = out
+	}
+	return strings.Join(parts, "${"), nil
+}
+
+// replace replaces the references of the string with their values
+func (ip *interpolator) replace(key, s string) (string, error) {
+	var rerr error
+	out := interpolateRe.ReplaceAllStringFunc(s, func(ref string) string {
+		v, err := ip.lookup(key, ref)
+		if err != nil {
+			rerr = err
+			return ref
+		}
+		switch x := v.(type) {
+		case string:
+			return x
+		case map[string]interface{}, []interface{}:
+			b, _ := json.Marshal(x)
+			return string(b)
+		}
+		return fmt.Sprint(v)
+	})
+	if rerr != nil {
+		return "", rerr
+	}
+	return out, nil
+}
+
+func (ip *interpolator) lookup(key, ref string) (interface{}, error) {
+	m := interpolateRe.FindStringSubmatch(ref)
+	name, def := m[1], m[2]
+	hasDef := strings.Contains(ref, ":-")
+
+	if !strings.Contains(name, ".") {
+		if v := os.Getenv(name); len(v) > 0 {
+			return v, nil
+		}
+		return def, nil
+	}
+
+	var v interface{} = ip.root
+	path := strings.Split(name, ".")
+	for _, p := range path {
+		mv, ok := v.(map[string]interface{})
+		if !ok {
+			v = nil
+			break
+		}
+		v, ok = mv[p]
+		if !ok {
+			v = nil
+			break
+		}
+	}
+	if v == nil {
+		if hasDef {
+			return def, nil
+		}
+		return nil, fmt.Errorf("interpolate %s: %s is not set", key, name)
+	}
+
+	// resolve the references of the key first
+	nv, err := ip.walk(path, v)
+	if err != nil {
+		return nil, err
+	}
+	return nv, nil
+}
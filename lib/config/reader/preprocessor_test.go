@@ -26,6 +26,8 @@ import (
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/vine-io/vine/lib/config/source"
 )
 
 func TestReplaceEnvVars(t *testing.T) {
@@ -93,3 +95,114 @@ func TestReplaceEnvVars(t *testing.T) {
 		}
 	}
 }
+
+func TestInterpolate(t *testing.T) {
+	os.Setenv("VINE_TEST_HOST", "db.local")
+	os.Unsetenv("VINE_TEST_PORT")
+
+	v := map[string]interface{}{
+		"db": map[string]interface{}{
+			"host": "${VINE_TEST_HOST}",
+			"port": "${VINE_TEST_PORT:-3306}",
+			"dsn":  "${db.user}@${db.host}:${db.port}",
+			"user": "root",
+		},
+		"pool": map[string]interface{}{
+			"size": 10,
+			"max":  "${pool.size}",
+		},
+		"missing": "${no.such.key:-fallback}",
+	}
+	if err := Interpolate(v); err != nil {
+		t.Fatal(err)
+	}
+
+	db := v["db"].(map[string]interface{})
+	if db["host"] != "db.local" || db["port"] != "3306" {
+		t.Fatalf("unexpected env interpolation %v", db)
+	}
+	if db["dsn"] != "root@db.local:3306" {
+		t.Fatalf("unexpected dsn %v", db["dsn"])
+	}
+	if max := v["pool"].(map[string]interface{})["max"]; max != 10 {
+		t.Fatalf("expected the type of the key, got %#v", max)
+	}
+	if v["missing"] != "fallback" {
+		t.Fatalf("expected default, got %v", v["missing"])
+	}
+
+	if err := Interpolate(map[string]interface{}{"a": "${b.c}"}); err == nil {
+		t.Fatal("expected error for a missing key")
+	}
+	cycle := map[string]interface{}{"a": map[string]interface{}{"b": "${a.c}", "c": "${a.b}"}}
+	if err := Interpolate(cycle); err == nil {
+		t.Fatal("expected error for a reference cycle")
+	}
+}
+
+func TestInterpolateEscape(t *testing.T) {
+	os.Setenv("VINE_TEST_HOST", "db.local")
+
+	v := map[string]interface{}{
+		"x": map[string]interface{}{
+			"template": "$${VINE_TEST_HOST} is ${VINE_TEST_HOST}",
+			"literal":  "$${VINE_TEST_HOST}",
+			"invalid":  "cost: $${not a ref}",
+			// the escaped references are not resolved again through another key
+			"ref":  "${x.template}",
+			"copy": "${x.literal}",
+		},
+	}
+	if err := Interpolate(v); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"template": "${VINE_TEST_HOST} is db.local",
+		"literal":  "${VINE_TEST_HOST}",
+		"invalid":  "cost: ${not a ref}",
+		"ref":      "${VINE_TEST_HOST} is db.local",
+		"copy":     "${VINE_TEST_HOST}",
+	}
+	x := v["x"].(map[string]interface{})
+	for k, e := range expected {
+		if x[k] != e {
+			t.Fatalf("expected %s to be %q, got %q", k, e, x[k])
+		}
+	}
+}
+
+func TestInterpolateSet(t *testing.T) {
+	os.Setenv("VINE_TEST_HOST", "db.local")
+
+	cs := &source.ChangeSet{Data: []byte(`{"db": {"host": "${VINE_TEST_HOST}", "dsn": "${db.host}:3306", "port": 3306, "home": "$${HOME}"}}`), Format: "json"}
+	cs.CheckSum = cs.Sum()
+	cs.Origins = map[string]*source.Origin{"db.host": {Source: "file", File: "config.yaml", Line: 2}}
+	set, err := InterpolateSet(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(set.Data), `"dsn":"db.local:3306"`) || !strings.Contains(string(set.Data), `"host":"db.local"`) {
+		t.Fatalf("unexpected data %s", set.Data)
+	}
+	if set.CheckSum == cs.CheckSum || string(cs.Data) == string(set.Data) {
+		t.Fatal("expected a new set")
+	}
+
+	// the values which held references are marked, the origins of the set are kept
+	if o := set.Origins["db.host"]; o == nil || !o.Interpolated || o.File != "config.yaml" {
+		t.Fatalf("unexpected origin %+v", o)
+	}
+	if o := set.Origins["db.dsn"]; o == nil || !o.Interpolated {
+		t.Fatalf("unexpected origin %+v", o)
+	}
+	if _, ok := set.Origins["db.port"]; ok {
+		t.Fatal("unexpected origin of db.port")
+	}
+	if _, ok := set.Origins["db.home"]; ok {
+		t.Fatal("unexpected origin of the escaped db.home")
+	}
+	if cs.Origins["db.host"].Interpolated {
+		t.Fatal("expected the origins of the set to be left")
+	}
+}
@@ -56,4 +56,7 @@ type Value interface {
 	StringMap(def map[string]string) map[string]string
 	Scan(val interface{}) error
 	Bytes() []byte
+	// Origin returns where the value was set, nil when it is unknown. The
+	// origin of a map is the one of its latest value.
+	Origin() *source.Origin
 }
@@ -0,0 +1,76 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package reader
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vine-io/vine/lib/config/secrets"
+)
+
+// Decrypt returns a copy of the value with the encrypted strings decrypted by
+// the secrets, the value is returned as is without secrets
+func Decrypt(v interface{}, ss []secrets.Secrets) (interface{}, error) {
+	if len(ss) == 0 {
+		return v, nil
+	}
+	return decrypt(v, nil, ss)
+}
+
+func decrypt(v interface{}, path []string, ss []secrets.Secrets) (interface{}, error) {
+	switch vv := v.(type) {
+	case string:
+		if !secrets.IsEncrypted(vv) {
+			return vv, nil
+		}
+		b, err := secrets.DecryptValue(vv, ss...)
+		if err != nil && len(path) == 0 {
+			return nil, fmt.Errorf("decrypt value: %v", err)
+		} else if err != nil {
+			return nil, fmt.Errorf("decrypt %s: %v", strings.Join(path, "."), err)
+		}
+		return string(b), nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(vv))
+		for k, item := range vv {
+			value, err := decrypt(item, append(path, k), ss)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = value
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(vv))
+		for i, item := range vv {
+			value, err := decrypt(item, append(path, fmt.Sprintf("%d", i)), ss)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = value
+		}
+		return out, nil
+	}
+	return v, nil
+}
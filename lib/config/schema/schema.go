@@ -0,0 +1,486 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package schema validates the config against a JSON Schema. The keywords of
+// draft 7 which constrain values are supported, the other ones are ignored.
+package schema
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	json "github.com/json-iterator/go"
+	"github.com/vine-io/vine/lib/config/source"
+)
+
+// Schema is a compiled JSON Schema
+type Schema struct {
+	root *node
+}
+
+type node struct {
+	Ref                  string           `json:"$ref"`
+	Type                 types            `json:"type"`
+	Properties           map[string]*node `json:"properties"`
+	Required             []string         `json:"required"`
+	AdditionalProperties json.RawMessage  `json:"additionalProperties"`
+	Items                *node            `json:"items"`
+	MinItems             *int             `json:"minItems"`
+	MaxItems             *int             `json:"maxItems"`
+	UniqueItems          bool             `json:"uniqueItems"`
+	Enum                 []interface{}    `json:"enum"`
+	Const                json.RawMessage  `json:"const"`
+	Minimum              *float64         `json:"minimum"`
+	Maximum              *float64         `json:"maximum"`
+	ExclusiveMinimum     *float64         `json:"exclusiveMinimum"`
+	ExclusiveMaximum     *float64         `json:"exclusiveMaximum"`
+	MultipleOf           *float64         `json:"multipleOf"`
+	MinLength            *int             `json:"minLength"`
+	MaxLength            *int             `json:"maxLength"`
+	Pattern              string           `json:"pattern"`
+	Format               string           `json:"format"`
+	AllOf                []*node          `json:"allOf"`
+	AnyOf                []*node          `json:"anyOf"`
+	OneOf                []*node          `json:"oneOf"`
+	Not                  *node            `json:"not"`
+	Definitions          map[string]*node `json:"definitions"`
+	Defs                 map[string]*node `json:"$defs"`
+
+	// compiled keywords
+	pattern    *regexp.Regexp
+	additional *node
+	closed     bool
+	constant   interface{}
+	ref        *node
+}
+
+// types is the type keyword, a single type or a list of them
+type types []string
+
+func (t *types) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = types{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return fmt.Errorf("invalid type %s", b)
+	}
+	*t = list
+	return nil
+}
+
+// New compiles the JSON Schema
+func New(b []byte) (*Schema, error) {
+	root := &node{}
+	if err := json.Unmarshal(b, root); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+	if err := root.compile(root, "#"); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+	return &Schema{root: root}, nil
+}
+
+// MustNew compiles the JSON Schema and panics when it is invalid
+func MustNew(b []byte) *Schema {
+	s, err := New(b)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (n *node) resolve(root *node) (*node, error) {
+	switch {
+	case n.Ref == "#":
+		return root, nil
+	case strings.HasPrefix(n.Ref, "#/definitions/"):
+		if d, ok := root.Definitions[strings.TrimPrefix(n.Ref, "#/definitions/")]; ok {
+			return d, nil
+		}
+	case strings.HasPrefix(n.Ref, "#/$defs/"):
+		if d, ok := root.Defs[strings.TrimPrefix(n.Ref, "#/$defs/")]; ok {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("unresolved $ref %q", n.Ref)
+}
+
+func (n *node) compile(root *node, at string) error {
+	if n == nil {
+		return nil
+	}
+
+	if len(n.Ref) > 0 {
+		ref, err := n.resolve(root)
+		if err != nil {
+			return fmt.Errorf("%s: %v", at, err)
+		}
+		n.ref = ref
+	}
+
+	for _, t := range n.Type {
+		switch t {
+		case "object", "array", "string", "number", "integer", "boolean", "null":
+		default:
+			return fmt.Errorf("%s: unknown type %q", at, t)
+		}
+	}
+
+	if len(n.Pattern) > 0 {
+		re, err := regexp.Compile(n.Pattern)
+		if err != nil {
+			return fmt.Errorf("%s: invalid pattern: %v", at, err)
+		}
+		n.pattern = re
+	}
+
+	switch s := strings.TrimSpace(string(n.AdditionalProperties)); s {
+	case "", "true":
+	case "false":
+		n.closed = true
+	default:
+		n.additional = &node{}
+		if err := json.Unmarshal(n.AdditionalProperties, n.additional); err != nil {
+			return fmt.Errorf("%s: invalid additionalProperties: %v", at, err)
+		}
+	}
+
+	if len(n.Const) > 0 {
+		if err := json.Unmarshal(n.Const, &n.constant); err != nil {
+			return fmt.Errorf("%s: invalid const: %v", at, err)
+		}
+	}
+
+	children := map[string]*node{
+		"/items":                n.Items,
+		"/not":                  n.Not,
+		"/additionalProperties": n.additional,
+	}
+	for k, c := range n.Properties {
+		children["/properties/"+k] = c
+	}
+	for k, c := range n.Definitions {
+		children["/definitions/"+k] = c
+	}
+	for k, c := range n.Defs {
+		children["/$defs/"+k] = c
+	}
+	for name, list := range map[string][]*node{"allOf": n.AllOf, "anyOf": n.AnyOf, "oneOf": n.OneOf} {
+		for i, c := range list {
+			children[fmt.Sprintf("/%s/%d", name, i)] = c
+		}
+	}
+	for k, c := range children {
+		if err := c.compile(root, at+k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Failure is a value which does not match the schema
+type Failure struct {
+	// Path of the value e.g. db.port, empty for the root
+	Path    string
+	Message string
+}
+
+// Error lists the values which do not match the schema
+type Error struct {
+	Failures []*Failure
+}
+
+func (e *Error) Error() string {
+	msgs := make([]string, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		if len(f.Path) == 0 {
+			msgs = append(msgs, f.Message)
+			continue
+		}
+		msgs = append(msgs, f.Path+": "+f.Message)
+	}
+	return "config does not match the schema: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks the merged ChangeSet, its data is json. An *Error is
+// returned when the values do not match the schema.
+func (s *Schema) Validate(cs *source.ChangeSet) error {
+	var v interface{}
+	if len(cs.Data) > 0 {
+		if err := json.Unmarshal(cs.Data, &v); err != nil {
+			return err
+		}
+	}
+	return s.Check(v)
+}
+
+// Check validates the json decoded value
+func (s *Schema) Check(v interface{}) error {
+	var failures []*Failure
+	s.root.validate(nil, v, &failures)
+	if len(failures) > 0 {
+		return &Error{Failures: failures}
+	}
+	return nil
+}
+
+func join(path []string, key string) []string {
+	return append(append([]string{}, path...), key)
+}
+
+func fail(failures *[]*Failure, path []string, format string, a ...interface{}) {
+	*failures = append(*failures, &Failure{Path: strings.Join(path, "."), Message: fmt.Sprintf(format, a...)})
+}
+
+func typeOf(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if x == math.Trunc(x) && !math.IsInf(x, 0) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func (n *node) validate(path []string, v interface{}, failures *[]*Failure) {
+	if n.ref != nil {
+		n.ref.validate(path, v, failures)
+	}
+
+	t := typeOf(v)
+	if len(n.Type) > 0 {
+		var ok bool
+		for _, want := range n.Type {
+			if want == t || (want == "number" && t == "integer") {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			fail(failures, path, "must be of type %s, not %s", strings.Join(n.Type, " or "), t)
+			return
+		}
+	}
+
+	if len(n.Enum) > 0 {
+		var ok bool
+		for _, e := range n.Enum {
+			if reflect.DeepEqual(e, v) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			b, _ := json.Marshal(n.Enum)
+			fail(failures, path, "must be one of %s", b)
+		}
+	}
+	if len(n.Const) > 0 && !reflect.DeepEqual(n.constant, v) {
+		fail(failures, path, "must be %s", n.Const)
+	}
+
+	switch x := v.(type) {
+	case map[string]interface{}:
+		n.object(path, x, failures)
+	case []interface{}:
+		n.array(path, x, failures)
+	case string:
+		n.string(path, x, failures)
+	case float64:
+		n.number(path, x, failures)
+	}
+
+	for _, c := range n.AllOf {
+		c.validate(path, v, failures)
+	}
+	if len(n.AnyOf) > 0 {
+		var ok bool
+		for _, c := range n.AnyOf {
+			if c.matches(path, v) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			fail(failures, path, "must match one of the anyOf schemas")
+		}
+	}
+	if len(n.OneOf) > 0 {
+		var matched int
+		for _, c := range n.OneOf {
+			if c.matches(path, v) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			fail(failures, path, "must match exactly one of the oneOf schemas, matched %d", matched)
+		}
+	}
+	if n.Not != nil && n.Not.matches(path, v) {
+		fail(failures, path, "must not match the not schema")
+	}
+}
+
+func (n *node) matches(path []string, v interface{}) bool {
+	var failures []*Failure
+	n.validate(path, v, &failures)
+	return len(failures) == 0
+}
+
+func (n *node) object(path []string, v map[string]interface{}, failures *[]*Failure) {
+	for _, name := range n.Required {
+		if _, ok := v[name]; !ok {
+			fail(failures, join(path, name), "is required")
+		}
+	}
+
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if p, ok := n.Properties[k]; ok {
+			p.validate(join(path, k), v[k], failures)
+			continue
+		}
+		switch {
+		case n.closed:
+			fail(failures, join(path, k), "is not allowed")
+		case n.additional != nil:
+			n.additional.validate(join(path, k), v[k], failures)
+		}
+	}
+}
+
+func (n *node) array(path []string, v []interface{}, failures *[]*Failure) {
+	if n.MinItems != nil && len(v) < *n.MinItems {
+		fail(failures, path, "must have at least %d items", *n.MinItems)
+	}
+	if n.MaxItems != nil && len(v) > *n.MaxItems {
+		fail(failures, path, "must have at most %d items", *n.MaxItems)
+	}
+	if n.UniqueItems {
+		for i := range v {
+			for j := i + 1; j < len(v); j++ {
+				if reflect.DeepEqual(v[i], v[j]) {
+					fail(failures, path, "must have unique items, %d and %d are equal", i, j)
+				}
+			}
+		}
+	}
+	if n.Items != nil {
+		for i, item := range v {
+			n.Items.validate(join(path, strconv.Itoa(i)), item, failures)
+		}
+	}
+}
+
+func (n *node) string(path []string, v string, failures *[]*Failure) {
+	length := utf8.RuneCountInString(v)
+	if n.MinLength != nil && length < *n.MinLength {
+		fail(failures, path, "must be at least %d characters", *n.MinLength)
+	}
+	if n.MaxLength != nil && length > *n.MaxLength {
+		fail(failures, path, "must be at most %d characters", *n.MaxLength)
+	}
+	if n.pattern != nil && !n.pattern.MatchString(v) {
+		fail(failures, path, "must match the pattern %s", n.Pattern)
+	}
+	if len(n.Format) > 0 && !format(n.Format, v) {
+		fail(failures, path, "must be a valid %s", n.Format)
+	}
+}
+
+func (n *node) number(path []string, v float64, failures *[]*Failure) {
+	if n.Minimum != nil && v < *n.Minimum {
+		fail(failures, path, "must be at least %v", *n.Minimum)
+	}
+	if n.Maximum != nil && v > *n.Maximum {
+		fail(failures, path, "must be at most %v", *n.Maximum)
+	}
+	if n.ExclusiveMinimum != nil && v <= *n.ExclusiveMinimum {
+		fail(failures, path, "must be greater than %v", *n.ExclusiveMinimum)
+	}
+	if n.ExclusiveMaximum != nil && v >= *n.ExclusiveMaximum {
+		fail(failures, path, "must be less than %v", *n.ExclusiveMaximum)
+	}
+	if n.MultipleOf != nil && *n.MultipleOf > 0 {
+		if q := v / *n.MultipleOf; q != math.Trunc(q) {
+			fail(failures, path, "must be a multiple of %v", *n.MultipleOf)
+		}
+	}
+}
+
+var hostnameRe = regexp.MustCompile(`^(?i)[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*$`)
+
+// format checks the known formats, the unknown ones are accepted. The duration
+// format is a go duration e.g. 1m30s.
+func format(name, v string) bool {
+	switch name {
+	case "hostname":
+		return len(v) <= 253 && hostnameRe.MatchString(v)
+	case "ipv4":
+		ip := net.ParseIP(v)
+		return ip != nil && ip.To4() != nil && !strings.Contains(v, ":")
+	case "ipv6":
+		return net.ParseIP(v) != nil && strings.Contains(v, ":")
+	case "email":
+		a, err := mail.ParseAddress(v)
+		return err == nil && a.Address == v
+	case "uri":
+		u, err := url.Parse(v)
+		return err == nil && len(u.Scheme) > 0
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, v)
+		return err == nil
+	case "duration":
+		_, err := time.ParseDuration(v)
+		return err == nil
+	}
+	return true
+}
@@ -0,0 +1,148 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package schema
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vine-io/vine/lib/config"
+	cmemory "github.com/vine-io/vine/lib/config/memory"
+	"github.com/vine-io/vine/lib/config/source"
+	"github.com/vine-io/vine/lib/config/source/memory"
+)
+
+var testSchema = []byte(`{
+	"type": "object",
+	"required": ["name", "db"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "pattern": "^[a-z.]+$"},
+		"level": {"enum": ["debug", "info"]},
+		"db": {"$ref": "#/definitions/db"},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2, "uniqueItems": true},
+		"timeout": {"type": "string", "format": "duration"},
+		"weight": {"type": ["number", "null"], "exclusiveMinimum": 0, "maximum": 1}
+	},
+	"definitions": {
+		"db": {
+			"type": "object",
+			"required": ["host"],
+			"properties": {
+				"host": {"anyOf": [{"format": "hostname"}, {"format": "ipv4"}]},
+				"port": {"type": "integer", "minimum": 1, "maximum": 65535}
+			}
+		}
+	}
+}`)
+
+func failures(err error) []string {
+	var msgs []string
+	if e, ok := err.(*Error); ok {
+		for _, f := range e.Failures {
+			msgs = append(msgs, f.Path+": "+f.Message)
+		}
+	}
+	return msgs
+}
+
+func TestSchema(t *testing.T) {
+	s, err := New(testSchema)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	valid := `{"name": "greeter", "level": "info", "db": {"host": "10.0.0.1", "port": 3306}, "tags": ["a"], "timeout": "5s", "weight": null}`
+	assert.NoError(t, s.Validate(&source.ChangeSet{Data: []byte(valid)}))
+
+	invalid := `{"name": "Greeter", "level": "trace", "db": {"port": 1.5}, "tags": ["a", "a", "b"], "timeout": "soon", "weight": 0, "debug": true}`
+	err = s.Validate(&source.ChangeSet{Data: []byte(invalid)})
+	assert.Equal(t, []string{
+		"db.host: is required",
+		"db.port: must be of type integer, not number",
+		"debug: is not allowed",
+		"level: must be one of [\"debug\",\"info\"]",
+		"name: must match the pattern ^[a-z.]+$",
+		"tags: must have at most 2 items",
+		"tags: must have unique items, 0 and 1 are equal",
+		"timeout: must be a valid duration",
+		"weight: must be greater than 0",
+	}, failures(err))
+
+	_, err = New([]byte(`{"properties": {"a": {"$ref": "#/definitions/missing"}}}`))
+	assert.Error(t, err)
+	_, err = New([]byte(`{"type": "map"}`))
+	assert.Error(t, err)
+}
+
+func TestRejectChange(t *testing.T) {
+	src := memory.NewSource(memory.WithJSON([]byte(`{"name": "greeter", "db": {"host": "db-1"}}`)))
+	c := cmemory.NewConfig(config.WithValidator(MustNew(testSchema)))
+	defer c.Close()
+	if !assert.NoError(t, c.Load(src)) {
+		return
+	}
+
+	// an invalid source is not loaded
+	bad := memory.NewSource(memory.WithJSON([]byte(`{"db": {"port": 0}}`)))
+	assert.Error(t, c.Load(bad))
+	assert.Equal(t, "db-1", c.Get("db", "host").String(""))
+
+	w, err := c.Watch("db", "host")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Stop()
+
+	updates := make(chan string, 2)
+	go func() {
+		for {
+			v, err := w.Next()
+			if err != nil {
+				return
+			}
+			updates <- v.String("")
+		}
+	}()
+
+	// the invalid change is rejected and the next valid one applied, the
+	// changes are sent until the source is watched by the loader
+	up := src.(interface{ Update(*source.ChangeSet) })
+	tick := time.NewTicker(20 * time.Millisecond)
+	defer tick.Stop()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case v := <-updates:
+			assert.Equal(t, "db-3", v)
+			return
+		case <-tick.C:
+			up.Update(&source.ChangeSet{Data: []byte(`{"name": "greeter", "db": {"host": "db 2"}}`), Format: "json"})
+			up.Update(&source.ChangeSet{Data: []byte(`{"name": "greeter", "db": {"host": "db-3"}}`), Format: "json"})
+		case <-timeout:
+			t.Fatal("no update")
+		}
+	}
+}
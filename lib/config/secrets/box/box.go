@@ -101,6 +101,9 @@ func (b *box) Decrypt(in []byte, opts ...secrets.DecryptOption) ([]byte, error)
 	if len(options.SenderPublicKey) != keyLength {
 		return []byte{}, errors.New("sender's public key bust be provided")
 	}
+	if len(in) < 24 {
+		return []byte{}, errors.New("incoming message is too short")
+	}
 	var nonce [24]byte
 	var senderPublicKey [32]byte
 	copy(nonce[:], in[:24])
@@ -87,6 +87,9 @@ func (s *secretBox) Encrypt(in []byte, opts ...secrets.EncryptOption) ([]byte, e
 func (s *secretBox) Decrypt(in []byte, opts ...secrets.DecryptOption) ([]byte, error) {
 	// no options are expected, so they are ignored
 
+	if len(in) < 24 {
+		return []byte{}, errors.New("decryption failed (the message is too short)")
+	}
 	var decryptNonce [24]byte
 	copy(decryptNonce[:], in[:24])
 	decrypted, ok := secretbox.Open(nil, in[24:], &decryptNonce, &s.secretKey)
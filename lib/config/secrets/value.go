@@ -0,0 +1,105 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package secrets
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// encrypted values are written as ENC[<scheme>:<base64 data>]
+var valueRe = regexp.MustCompile(`ENC\[([a-z0-9-]+):([A-Za-z0-9+/=]+)\]`)
+
+// ErrNotEncrypted is returned when decrypting a value which isn't encrypted
+var ErrNotEncrypted = errors.New("value is not encrypted")
+
+// Scheme returns the name of the secrets in encrypted values, e.g. secretbox
+func Scheme(s Secrets) string {
+	return strings.TrimPrefix(s.String(), "nacl-")
+}
+
+// IsEncrypted reports whether the value is an encrypted value
+func IsEncrypted(value string) bool {
+	m := valueRe.FindStringIndex(value)
+	return m != nil && m[0] == 0 && m[1] == len(value)
+}
+
+// EncryptValue encrypts the data to a value which can be stored in the config,
+// asymmetric secrets encrypt it for their own public key
+func EncryptValue(s Secrets, data []byte) (string, error) {
+	b, err := s.Encrypt(data, RecipientPublicKey(s.Options().PublicKey))
+	if err != nil {
+		return "", err
+	}
+	return "ENC[" + Scheme(s) + ":" + base64.StdEncoding.EncodeToString(b) + "]", nil
+}
+
+// DecryptValue decrypts the value with the first of the secrets of its scheme
+// which succeeds, so values encrypted with an old key can still be read while
+// keys are rotated
+func DecryptValue(value string, ss ...Secrets) ([]byte, error) {
+	if !IsEncrypted(value) {
+		return nil, ErrNotEncrypted
+	}
+	m := valueRe.FindStringSubmatch(value)
+	scheme := m[1]
+	data, err := base64.StdEncoding.DecodeString(m[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted value: %v", err)
+	}
+
+	err = fmt.Errorf("no secrets for scheme %s", scheme)
+	for _, s := range ss {
+		if Scheme(s) != scheme {
+			continue
+		}
+		var b []byte
+		if b, err = s.Decrypt(data, SenderPublicKey(s.Options().PublicKey)); err == nil {
+			return b, nil
+		}
+	}
+	return nil, err
+}
+
+// ReplaceValues replaces the encrypted values found in the text with the
+// result of fn, leaving the rest of the text untouched
+func ReplaceValues(text []byte, fn func(value string) (string, error)) ([]byte, error) {
+	var err error
+	out := valueRe.ReplaceAllFunc(text, func(b []byte) []byte {
+		if err != nil {
+			return b
+		}
+		var value string
+		if value, err = fn(string(b)); err != nil {
+			return b
+		}
+		return []byte(value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,114 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package secrets_test
+
+import (
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	naclbox "golang.org/x/crypto/nacl/box"
+
+	"github.com/vine-io/vine/lib/config/secrets"
+	"github.com/vine-io/vine/lib/config/secrets/box"
+	"github.com/vine-io/vine/lib/config/secrets/secretbox"
+)
+
+func newSecretbox(t *testing.T) secrets.Secrets {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	s := secretbox.NewSecrets()
+	if err := s.Init(secrets.Key(key)); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestValue(t *testing.T) {
+	public, private, err := naclbox.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := box.NewSecrets()
+	if err := b.Init(secrets.PublicKey(public[:]), secrets.PrivateKey(private[:])); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []secrets.Secrets{newSecretbox(t), b} {
+		value, err := secrets.EncryptValue(s, []byte("s3cr3t"))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.True(t, strings.HasPrefix(value, "ENC["+secrets.Scheme(s)+":"), value)
+		assert.True(t, secrets.IsEncrypted(value))
+
+		data, err := secrets.DecryptValue(value, s)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "s3cr3t", string(data))
+		}
+	}
+
+	assert.False(t, secrets.IsEncrypted("plain"))
+	assert.False(t, secrets.IsEncrypted("x ENC[secretbox:AAAA]"))
+	_, err = secrets.DecryptValue("plain", b)
+	assert.Equal(t, secrets.ErrNotEncrypted, err)
+	_, err = secrets.DecryptValue("ENC[secretbox:AAAA]", b)
+	assert.Error(t, err)
+	_, err = secrets.DecryptValue("ENC[secretbox:AAAA]", newSecretbox(t))
+	assert.Error(t, err)
+}
+
+func TestRotate(t *testing.T) {
+	old, current := newSecretbox(t), newSecretbox(t)
+
+	value, err := secrets.EncryptValue(old, []byte("s3cr3t"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := []byte("db:\n  user: vine # the user\n  password: " + value + "\n")
+
+	out, err := secrets.ReplaceValues(text, func(value string) (string, error) {
+		data, err := secrets.DecryptValue(value, old)
+		if err != nil {
+			return "", err
+		}
+		return secrets.EncryptValue(current, data)
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotContains(t, string(out), value)
+	assert.True(t, strings.HasPrefix(string(out), "db:\n  user: vine # the user\n  password: ENC[secretbox:"))
+
+	// both keys decrypt while the key is rotated
+	rotated := strings.TrimSpace(strings.SplitN(string(out), "password: ", 2)[1])
+	data, err := secrets.DecryptValue(rotated, old, current)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "s3cr3t", string(data))
+	}
+	_, err = secrets.DecryptValue(rotated, old)
+	assert.Error(t, err)
+}
@@ -0,0 +1,163 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/vine-io/vine/lib/errors"
+	"github.com/vine-io/vine/util/context/metadata"
+)
+
+type handler struct {
+	m *Manager
+}
+
+// NewHandler returns the config service of the manager, serve it with
+// RegisterConfigHandler. The updates and the rollbacks carry the admin token
+// of the manager as a bearer Authorization, they are refused without one.
+func NewHandler(m *Manager) ConfigHandler {
+	return &handler{m}
+}
+
+func (h *handler) authorize(ctx context.Context) error {
+	token := h.m.opts.AdminToken
+	v, _ := metadata.Get(ctx, "Authorization")
+	if len(token) == 0 || !strings.HasPrefix(v, "Bearer ") ||
+		subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(v, "Bearer ")), []byte(token)) != 1 {
+		return errors.Unauthorized(errorId, "invalid admin token")
+	}
+	return nil
+}
+
+func (h *handler) Read(ctx context.Context, in *ReadRequest, out *ReadResponse) error {
+	d, err := h.m.Read(ctx, in.Namespace, in.Version)
+	if err != nil {
+		return err
+	}
+	out.Document = d
+	return nil
+}
+
+func (h *handler) Update(ctx context.Context, in *UpdateRequest, out *UpdateResponse) error {
+	if err := h.authorize(ctx); err != nil {
+		return err
+	}
+	d, err := h.m.Update(ctx, in)
+	if err != nil {
+		return err
+	}
+	out.Document = d
+	return nil
+}
+
+func (h *handler) History(ctx context.Context, in *HistoryRequest, out *HistoryResponse) error {
+	docs, err := h.m.History(ctx, in.Namespace, in.Limit)
+	if err != nil {
+		return err
+	}
+	out.Documents = docs
+	return nil
+}
+
+func (h *handler) Diff(ctx context.Context, in *DiffRequest, out *DiffResponse) error {
+	changes, err := h.m.Diff(ctx, in.Namespace, in.From, in.To)
+	if err != nil {
+		return err
+	}
+	out.Changes = changes
+	return nil
+}
+
+func (h *handler) Rollback(ctx context.Context, in *RollbackRequest, out *RollbackResponse) error {
+	if err := h.authorize(ctx); err != nil {
+		return err
+	}
+	d, err := h.m.Rollback(ctx, in.Namespace, in.Version, in.Comment)
+	if err != nil {
+		return err
+	}
+	out.Document = d
+	return nil
+}
+
+// Watch streams the documents newer than the version of the request. The
+// writes of this instance are pushed right away, the ones of other instances
+// sharing the store are found when it is checked at the interval.
+func (h *handler) Watch(ctx context.Context, in *WatchRequest, stream Config_WatchStream) error {
+	if err := validate(in.Namespace); err != nil {
+		return err
+	}
+
+	ch, stop := h.m.Watch(in.Namespace)
+	defer stop()
+
+	version := in.Version
+	send := func(d *Document) error {
+		if d.Version <= version {
+			return nil
+		}
+		version = d.Version
+		return stream.Send(&WatchResponse{Document: d})
+	}
+	head := func() error {
+		d, err := h.m.Read(ctx, in.Namespace, 0)
+		if err != nil && errors.FromErr(err).Code == errors.StatusNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return send(d)
+	}
+
+	// catch up with the versions written since the one of the request
+	if err := head(); err != nil {
+		return err
+	}
+
+	var tick <-chan time.Time
+	if h.m.opts.Interval > 0 {
+		t := time.NewTicker(h.m.opts.Interval)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return nil
+		case d := <-ch:
+			err = send(d)
+		case <-tick:
+			err = head()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package service
+
+import (
+	"time"
+
+	"github.com/vine-io/vine/lib/cache"
+	"github.com/vine-io/vine/lib/config/reader"
+	"github.com/vine-io/vine/lib/config/reader/json"
+)
+
+var (
+	// DefaultInterval at which the watchers check the store for versions
+	// written by other instances of the service
+	DefaultInterval = time.Second
+)
+
+type Options struct {
+	// Store keeps the versions of the documents
+	Store Store
+	// Reader validates the documents and converts them for the diff
+	Reader reader.Reader
+	// Interval at which the watchers check the store, never when zero
+	Interval time.Duration
+	// AdminToken is required by the writes of the rpc, which are refused
+	// without one
+	AdminToken string
+}
+
+type Option func(o *Options)
+
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Interval: DefaultInterval,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.Store == nil {
+		options.Store = NewStore(cache.DefaultCache)
+	}
+	if options.Reader == nil {
+		options.Reader = json.NewReader()
+	}
+
+	return options
+}
+
+// WithStore sets the store of the documents
+func WithStore(s Store) Option {
+	return func(o *Options) {
+		o.Store = s
+	}
+}
+
+// WithReader sets the reader which validates the documents
+func WithReader(r reader.Reader) Option {
+	return func(o *Options) {
+		o.Reader = r
+	}
+}
+
+// WithInterval sets the interval at which the watchers check the store
+func WithInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.Interval = d
+	}
+}
+
+// WithAdminToken protects the writes of the rpc with the token
+func WithAdminToken(t string) Option {
+	return func(o *Options) {
+		o.AdminToken = t
+	}
+}
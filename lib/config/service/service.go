@@ -0,0 +1,309 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package service is the config service, it keeps namespaced config documents
+// with their version history and pushes the updates to the watchers
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	json "github.com/json-iterator/go"
+	"github.com/vine-io/vine/lib/cache"
+	"github.com/vine-io/vine/lib/config/loader"
+	"github.com/vine-io/vine/lib/config/source"
+	"github.com/vine-io/vine/lib/errors"
+)
+
+const (
+	errorId = "go.vine.config"
+
+	// writeAttempts bounds the retries of a write racing the other instances
+	writeAttempts = 5
+)
+
+var (
+	// DefaultName of the config service
+	DefaultName = "go.vine.config"
+
+	namespaceRe = regexp.MustCompile(`^[a-zA-Z0-9_.\-]+$`)
+)
+
+// Manager keeps the documents of the namespaces
+type Manager struct {
+	opts Options
+
+	// serialises the writes of this instance, the store orders the writes of
+	// the instances sharing it
+	mu sync.Mutex
+
+	sync.RWMutex
+	watchers map[string]map[chan *Document]bool
+}
+
+func validate(namespace string) error {
+	if !namespaceRe.MatchString(namespace) {
+		return errors.BadRequest(errorId, "invalid namespace %q", namespace)
+	}
+	return nil
+}
+
+func (m *Manager) read(ctx context.Context, namespace string, version int64) (*Document, error) {
+	if err := validate(namespace); err != nil {
+		return nil, err
+	}
+	if version < 0 {
+		return nil, errors.BadRequest(errorId, "invalid version %d", version)
+	}
+	d, err := m.opts.Store.Read(ctx, namespace, version)
+	if err == cache.ErrNotFound {
+		if version == 0 {
+			return nil, errors.NotFound(errorId, "namespace %s not found", namespace)
+		}
+		return nil, errors.NotFound(errorId, "version %d of namespace %s not found", version, namespace)
+	}
+	if err != nil {
+		return nil, errors.InternalServerError(errorId, "read namespace %s: %v", namespace, err)
+	}
+	return d, nil
+}
+
+// Read returns a version of the document of the namespace, the latest when the version is zero
+func (m *Manager) Read(ctx context.Context, namespace string, version int64) (*Document, error) {
+	return m.read(ctx, namespace, version)
+}
+
+// write adds the data as the next version of the namespace, the latest document
+// is returned when it has the same data. A write without a base version is
+// retried when another instance writes the namespace first.
+func (m *Manager) write(ctx context.Context, namespace string, data []byte, format, comment string, base int64) (*Document, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	checksum := (&source.ChangeSet{Data: data}).Sum()
+	for attempt := 1; ; attempt++ {
+		head, err := m.read(ctx, namespace, 0)
+		if err != nil && errors.FromErr(err).Code != errors.StatusNotFound {
+			return nil, err
+		}
+		var version int64
+		if head != nil {
+			version = head.Version
+		}
+		if base > 0 && base != version {
+			return nil, errors.Conflict(errorId, "namespace %s is at version %d, not %d", namespace, version, base)
+		}
+		if head != nil && head.Checksum == checksum && head.Format == format {
+			return head, nil
+		}
+
+		d := &Document{
+			Namespace: namespace,
+			Version:   version + 1,
+			Data:      data,
+			Format:    format,
+			Comment:   comment,
+			Checksum:  checksum,
+			Created:   time.Now().Unix(),
+		}
+		err = m.opts.Store.Write(ctx, d)
+		if err == cache.ErrConflict && (base > 0 || attempt == writeAttempts) {
+			return nil, errors.Conflict(errorId, "namespace %s was written by another instance, retry the write", namespace)
+		}
+		if err == cache.ErrConflict {
+			continue
+		}
+		if err != nil {
+			return nil, errors.InternalServerError(errorId, "write namespace %s: %v", namespace, err)
+		}
+		m.notify(d)
+		return d, nil
+	}
+}
+
+// Update writes the data as the next version of the namespace. The data must
+// be a valid document of the format.
+func (m *Manager) Update(ctx context.Context, req *UpdateRequest) (*Document, error) {
+	if err := validate(req.Namespace); err != nil {
+		return nil, err
+	}
+	format := req.Format
+	if len(format) == 0 {
+		format = "json"
+	}
+	if _, err := m.opts.Reader.Merge(&source.ChangeSet{Data: req.Data, Format: format}); err != nil {
+		return nil, errors.BadRequest(errorId, "invalid %s document: %v", format, err)
+	}
+	return m.write(ctx, req.Namespace, req.Data, format, req.Comment, req.Version)
+}
+
+// History returns the versions of the namespace from the newest to the oldest
+func (m *Manager) History(ctx context.Context, namespace string, limit int64) ([]*Document, error) {
+	if err := validate(namespace); err != nil {
+		return nil, err
+	}
+	docs, err := m.opts.Store.History(ctx, namespace)
+	if err != nil {
+		return nil, errors.InternalServerError(errorId, "read namespace %s: %v", namespace, err)
+	}
+	if limit > 0 && int64(len(docs)) > limit {
+		docs = docs[:limit]
+	}
+	return docs, nil
+}
+
+// Rollback writes a previous version as the next version of the namespace
+func (m *Manager) Rollback(ctx context.Context, namespace string, version int64, comment string) (*Document, error) {
+	if version == 0 {
+		return nil, errors.BadRequest(errorId, "missing version")
+	}
+	d, err := m.read(ctx, namespace, version)
+	if err != nil {
+		return nil, err
+	}
+	if len(comment) == 0 {
+		comment = fmt.Sprintf("rollback to version %d", version)
+	}
+	return m.write(ctx, namespace, d.Data, d.Format, comment, 0)
+}
+
+// Diff returns the changes from a version of the namespace to another one.
+// To is the latest version when zero, From is the version before To when zero.
+func (m *Manager) Diff(ctx context.Context, namespace string, from, to int64) ([]*Change, error) {
+	dt, err := m.read(ctx, namespace, to)
+	if err != nil {
+		return nil, err
+	}
+	if from == 0 {
+		from = dt.Version - 1
+	}
+
+	var df *Document
+	if from > 0 {
+		if df, err = m.read(ctx, namespace, from); err != nil {
+			return nil, err
+		}
+	}
+
+	a, err := m.flatten(df)
+	if err != nil {
+		return nil, err
+	}
+	b, err := m.flatten(dt)
+	if err != nil {
+		return nil, err
+	}
+	return diff(a, b), nil
+}
+
+// flatten returns the values of the document by their dotted path
+func (m *Manager) flatten(d *Document) (map[string]interface{}, error) {
+	if d == nil {
+		return map[string]interface{}{}, nil
+	}
+
+	cs, err := m.opts.Reader.Merge(&source.ChangeSet{Data: d.Data, Format: d.Format})
+	if err != nil {
+		return nil, errors.InternalServerError(errorId, "read version %d: %v", d.Version, err)
+	}
+	var v interface{}
+	if err := json.Unmarshal(cs.Data, &v); err != nil {
+		return nil, errors.InternalServerError(errorId, "read version %d: %v", d.Version, err)
+	}
+	return loader.Values(v), nil
+}
+
+func encode(v interface{}) string {
+	b, _ := json.ConfigCompatibleWithStandardLibrary.Marshal(v)
+	return string(b)
+}
+
+// diff returns the changes from a to b with their values json encoded
+func diff(a, b map[string]interface{}) []*Change {
+	var changes []*Change
+	for _, c := range loader.Diff(a, b) {
+		change := &Change{Path: c.Path, Op: c.Op}
+		if c.Op != "add" {
+			change.From = encode(c.From)
+		}
+		if c.Op != "remove" {
+			change.To = encode(c.To)
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// Watch returns a channel of the documents written to the namespace by this
+// instance, only the latest document is kept when the receiver falls behind.
+// The stop func releases the watch.
+func (m *Manager) Watch(namespace string) (<-chan *Document, func()) {
+	ch := make(chan *Document, 1)
+
+	m.Lock()
+	if m.watchers[namespace] == nil {
+		m.watchers[namespace] = make(map[chan *Document]bool)
+	}
+	m.watchers[namespace][ch] = true
+	m.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.Lock()
+			delete(m.watchers[namespace], ch)
+			if len(m.watchers[namespace]) == 0 {
+				delete(m.watchers, namespace)
+			}
+			m.Unlock()
+		})
+	}
+}
+
+func (m *Manager) notify(d *Document) {
+	m.RLock()
+	defer m.RUnlock()
+
+	for ch := range m.watchers[d.Namespace] {
+		// drop the pending document, the new one replaces it
+		select {
+		case <-ch:
+		default:
+		}
+		select {
+		case ch <- d:
+		default:
+		}
+	}
+}
+
+// NewManager returns a Manager of the documents in the store
+func NewManager(opts ...Option) *Manager {
+	return &Manager{
+		opts:     NewOptions(opts...),
+		watchers: make(map[string]map[chan *Document]bool),
+	}
+}
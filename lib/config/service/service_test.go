@@ -0,0 +1,212 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	membroker "github.com/vine-io/vine/core/broker/memory"
+	"github.com/vine-io/vine/core/client"
+	cmemory "github.com/vine-io/vine/core/client/memory"
+	regMemory "github.com/vine-io/vine/core/registry/memory"
+	"github.com/vine-io/vine/core/server"
+	smemory "github.com/vine-io/vine/core/server/memory"
+	"github.com/vine-io/vine/lib/cache"
+	"github.com/vine-io/vine/lib/cache/memory"
+	"github.com/vine-io/vine/lib/errors"
+	"github.com/vine-io/vine/util/context/metadata"
+)
+
+func code(err error) errors.StatusCode {
+	return errors.FromErr(err).Code
+}
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(WithStore(NewStore(memory.NewCache())))
+
+	_, err := m.Read(ctx, "app", 0)
+	assert.Equal(t, errors.StatusNotFound, code(err))
+	_, err = m.Update(ctx, &UpdateRequest{Namespace: "a/b", Data: []byte(`{}`)})
+	assert.Equal(t, errors.StatusBadRequest, code(err))
+	_, err = m.Update(ctx, &UpdateRequest{Namespace: "app", Data: []byte(`{`)})
+	assert.Equal(t, errors.StatusBadRequest, code(err))
+
+	d, err := m.Update(ctx, &UpdateRequest{Namespace: "app", Data: []byte(`{"db":{"host":"a","port":1},"debug":true}`)})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(1), d.Version)
+		assert.Equal(t, "json", d.Format)
+	}
+	d, err = m.Update(ctx, &UpdateRequest{Namespace: "app", Data: []byte("db:\n  host: b\n  port: 1\nlevel: info\n"), Format: "yaml", Version: 1})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), d.Version)
+	}
+
+	// stale and unchanged writes
+	_, err = m.Update(ctx, &UpdateRequest{Namespace: "app", Data: []byte(`{}`), Version: 1})
+	assert.Equal(t, errors.StatusConflict, code(err))
+	d, err = m.Update(ctx, &UpdateRequest{Namespace: "app", Data: []byte("db:\n  host: b\n  port: 1\nlevel: info\n"), Format: "yaml"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), d.Version)
+	}
+
+	changes, err := m.Diff(ctx, "app", 0, 0)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []*Change{
+			{Path: "db.host", Op: "update", From: `"a"`, To: `"b"`},
+			{Path: "debug", Op: "remove", From: "true"},
+			{Path: "level", Op: "add", To: `"info"`},
+		}, changes)
+	}
+	_, err = m.Diff(ctx, "app", 5, 0)
+	assert.Equal(t, errors.StatusNotFound, code(err))
+
+	d, err = m.Rollback(ctx, "app", 1, "")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(3), d.Version)
+		assert.Equal(t, "json", d.Format)
+		assert.Equal(t, "rollback to version 1", d.Comment)
+	}
+	changes, err = m.Diff(ctx, "app", 1, 3)
+	if assert.NoError(t, err) {
+		assert.Empty(t, changes)
+	}
+
+	docs, err := m.History(ctx, "app", 0)
+	if assert.NoError(t, err) && assert.Len(t, docs, 3) {
+		assert.Equal(t, int64(3), docs[0].Version)
+		assert.Equal(t, int64(1), docs[2].Version)
+	}
+	docs, err = m.History(ctx, "app", 1)
+	if assert.NoError(t, err) {
+		assert.Len(t, docs, 1)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	r := regMemory.NewRegistry()
+	b := membroker.NewBroker()
+	store := NewStore(memory.NewCache())
+
+	s := smemory.NewServer(server.Name(DefaultName), server.Registry(r), server.Broker(b))
+	m := NewManager(WithStore(store), WithInterval(50*time.Millisecond), WithAdminToken("admin"))
+	if !assert.NoError(t, RegisterConfigHandler(s, NewHandler(m))) || !assert.NoError(t, s.Start()) {
+		return
+	}
+	defer s.Stop()
+
+	c := NewConfigService(DefaultName, cmemory.NewClient(client.Registry(r), client.Broker(b)))
+
+	// the writes carry the admin token
+	ctx := context.Background()
+	_, err := c.Update(ctx, &UpdateRequest{Namespace: "app", Data: []byte(`{"a":1}`)})
+	assert.Equal(t, errors.StatusUnauthorized, code(err))
+	_, err = c.Update(metadata.Set(ctx, "Authorization", "Bearer wrong"), &UpdateRequest{Namespace: "app", Data: []byte(`{"a":1}`)})
+	assert.Equal(t, errors.StatusUnauthorized, code(err))
+
+	ctx = metadata.Set(ctx, "Authorization", "Bearer admin")
+	_, err = c.Update(ctx, &UpdateRequest{Namespace: "app", Data: []byte(`{"a":1}`)})
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = c.Rollback(context.Background(), &RollbackRequest{Namespace: "app", Version: 1})
+	assert.Equal(t, errors.StatusUnauthorized, code(err))
+
+	stream, err := c.Watch(ctx, &WatchRequest{Namespace: "app"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer stream.Close()
+
+	recv := func() *Document {
+		rsp, err := stream.Recv()
+		if !assert.NoError(t, err) {
+			return &Document{}
+		}
+		return rsp.Document
+	}
+
+	// the current version is sent first
+	assert.Equal(t, int64(1), recv().Version)
+
+	_, err = c.Update(ctx, &UpdateRequest{Namespace: "app", Data: []byte(`{"a":2}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":2}`, string(recv().Data))
+
+	// versions written by another instance sharing the store
+	other := NewManager(WithStore(store))
+	_, err = other.Update(ctx, &UpdateRequest{Namespace: "app", Data: []byte(`{"a":3}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), recv().Version)
+
+	rsp, err := c.Diff(ctx, &DiffRequest{Namespace: "app", From: 1})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []*Change{{Path: "a", Op: "update", From: "1", To: "3"}}, rsp.Changes)
+	}
+}
+
+func TestReplicas(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(memory.NewCache())
+
+	// the instances sharing the store race for the versions
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m := NewManager(WithStore(store))
+			for j := 0; j < 5; j++ {
+				_, err := m.Update(ctx, &UpdateRequest{Namespace: "app", Data: []byte(fmt.Sprintf(`{"i":%d,"j":%d}`, i, j))})
+				if err != nil {
+					assert.Equal(t, errors.StatusConflict, code(err))
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	m := NewManager(WithStore(store))
+	head, err := m.Read(ctx, "app", 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	docs, err := m.History(ctx, "app", 0)
+	if !assert.NoError(t, err) || !assert.Len(t, docs, int(head.Version)) {
+		return
+	}
+	for i, d := range docs {
+		assert.Equal(t, head.Version-int64(i), d.Version)
+	}
+
+	// a write based on a version which another instance has moved past
+	_, err = m.Update(ctx, &UpdateRequest{Namespace: "app", Data: []byte(`{}`), Version: head.Version - 1})
+	assert.Equal(t, errors.StatusConflict, code(err))
+	assert.Equal(t, cache.ErrConflict, store.Write(ctx, &Document{Namespace: "app", Version: head.Version}))
+}
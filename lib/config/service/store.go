@@ -0,0 +1,190 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	json "github.com/json-iterator/go"
+	"github.com/vine-io/vine/lib/cache"
+)
+
+// Store keeps the versions of the documents
+type Store interface {
+	// Read returns a version of the document, the latest when the version is zero
+	Read(ctx context.Context, namespace string, version int64) (*Document, error)
+	// Write adds the version of the document and makes it the latest. It fails
+	// with cache.ErrConflict unless the latest is the version before it, which
+	// orders the writes of the instances sharing the store.
+	Write(ctx context.Context, d *Document) error
+	// History returns the versions of the document from the newest to the oldest
+	History(ctx context.Context, namespace string) ([]*Document, error)
+}
+
+var (
+	// DefaultPrefix of the records in the cache
+	DefaultPrefix = "config:"
+)
+
+type cacheStore struct {
+	c      cache.Cache
+	prefix string
+}
+
+func (s *cacheStore) key(namespace string, version int64) string {
+	// zero padded so the versions list in order
+	return fmt.Sprintf("%sdoc/%s/%020d", s.prefix, namespace, version)
+}
+
+func (s *cacheStore) get(ctx context.Context, key string) ([]byte, error) {
+	recs, err := s.c.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, cache.ErrNotFound
+	}
+	return recs[0].Value, nil
+}
+
+func (s *cacheStore) head(namespace string) string {
+	return s.prefix + "head/" + namespace
+}
+
+func decode(b []byte) (*Document, error) {
+	d := &Document{}
+	if err := json.Unmarshal(b, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Read returns a version from the history or the head, which holds the latest
+// document before it is added to the history
+func (s *cacheStore) Read(ctx context.Context, namespace string, version int64) (*Document, error) {
+	if version > 0 {
+		b, err := s.get(ctx, s.key(namespace, version))
+		if err == nil {
+			return decode(b)
+		}
+		if err != cache.ErrNotFound {
+			return nil, err
+		}
+	}
+
+	b, err := s.get(ctx, s.head(namespace))
+	if err != nil {
+		return nil, err
+	}
+	d, err := decode(b)
+	if err != nil {
+		return nil, err
+	}
+	if version > 0 && d.Version != version {
+		return nil, cache.ErrNotFound
+	}
+	return d, nil
+}
+
+// Write compares and sets the head record of the namespace to the document,
+// then adds the document to the history
+func (s *cacheStore) Write(ctx context.Context, d *Document) error {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	head, err := s.get(ctx, s.head(d.Namespace))
+	if err != nil && err != cache.ErrNotFound {
+		return err
+	}
+	var version int64
+	if head != nil {
+		latest, err := decode(head)
+		if err != nil {
+			return err
+		}
+		version = latest.Version
+		// the latest may be missing from the history when its write was interrupted
+		key := s.key(d.Namespace, version)
+		if _, err := s.get(ctx, key); err == cache.ErrNotFound {
+			if err := s.c.Put(ctx, &cache.Record{Key: key, Value: head}); err != nil {
+				return err
+			}
+		}
+	}
+	if d.Version != version+1 {
+		return cache.ErrConflict
+	}
+
+	if err := s.c.Put(ctx, &cache.Record{Key: s.head(d.Namespace), Value: b}, cache.PutIfMatch(head)); err != nil {
+		return err
+	}
+	return s.c.Put(ctx, &cache.Record{Key: s.key(d.Namespace, d.Version), Value: b})
+}
+
+func (s *cacheStore) History(ctx context.Context, namespace string) ([]*Document, error) {
+	prefix := s.prefix + "doc/" + namespace + "/"
+	names, err := s.c.List(ctx, cache.ListPrefix(prefix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+
+	docs := make([]*Document, 0, len(names))
+	for _, name := range names {
+		version, err := strconv.ParseInt(strings.TrimPrefix(name, prefix), 10, 64)
+		if err != nil {
+			continue
+		}
+		d, err := s.Read(ctx, namespace, version)
+		if err == cache.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, d)
+	}
+
+	// the head when it is not in the history yet
+	head, err := s.Read(ctx, namespace, 0)
+	if err != nil && err != cache.ErrNotFound {
+		return nil, err
+	}
+	if head != nil && (len(docs) == 0 || docs[0].Version < head.Version) {
+		docs = append([]*Document{head}, docs...)
+	}
+	return docs, nil
+}
+
+// NewStore returns a Store keeping the documents in the cache. The history
+// lives as long as the cache does, use a persistent one such as the file cache.
+// The instances sharing the cache are ordered by its PutIfMatch.
+func NewStore(c cache.Cache) Store {
+	return &cacheStore{c: c, prefix: DefaultPrefix}
+}
@@ -0,0 +1,99 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"errors"
+
+	json "github.com/json-iterator/go"
+	"github.com/vine-io/vine/lib/config/reader"
+	"github.com/vine-io/vine/lib/config/source"
+)
+
+// Snapshot is an immutable view of the whole config at a version, the values
+// read from it are coherent even when the config is reloaded meanwhile
+type Snapshot struct {
+	// Version of the loaded config, it changes with every reload
+	Version string
+
+	cs     *source.ChangeSet
+	values reader.Values
+}
+
+// NewSnapshot returns a snapshot of the config loaded by c
+func NewSnapshot(c Config) (*Snapshot, error) {
+	opts := c.Options()
+	if opts.Loader == nil || opts.Reader == nil {
+		return nil, errors.New("config is not initialised")
+	}
+
+	snap, err := opts.Loader.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	values, err := opts.Reader.Values(snap.ChangeSet)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{Version: snap.Version, cs: snap.ChangeSet, values: values}, nil
+}
+
+// Get returns the value of the path
+func (s *Snapshot) Get(path ...string) reader.Value {
+	return s.values.Get(path...)
+}
+
+// Bytes returns the config as raw json
+func (s *Snapshot) Bytes() []byte {
+	return append([]byte{}, s.cs.Data...)
+}
+
+// Origins returns where the values were set keyed by their dotted path
+func (s *Snapshot) Origins() map[string]*source.Origin {
+	origins := make(map[string]*source.Origin, len(s.cs.Origins))
+	for k, o := range s.cs.Origins {
+		c := *o
+		origins[k] = &c
+	}
+	return origins
+}
+
+// Map returns a copy of the config as a map
+func (s *Snapshot) Map() map[string]interface{} {
+	// the maps of the values are shared, decode a copy of them
+	m := make(map[string]interface{})
+	if b, err := json.Marshal(s.values.Map()); err == nil {
+		json.Unmarshal(b, &m)
+	}
+	return m
+}
+
+// Scan decodes the config into v
+func (s *Snapshot) Scan(v interface{}) error {
+	return s.values.Scan(v)
+}
+
+// Bind sets the fields of the struct v from the config, see Bind
+func (s *Snapshot) Bind(v interface{}, opts ...BindOption) error {
+	return Bind(s.values, v, opts...)
+}
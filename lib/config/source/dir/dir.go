@@ -0,0 +1,190 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package dir is a source which reads a directory tree e.g. a mounted
+// Kubernetes ConfigMap or Secret, each file is a key
+package dir
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vine-io/vine/lib/config/encoder"
+	"github.com/vine-io/vine/lib/config/encoder/hcl"
+	"github.com/vine-io/vine/lib/config/encoder/json"
+	"github.com/vine-io/vine/lib/config/encoder/toml"
+	"github.com/vine-io/vine/lib/config/encoder/yaml"
+	"github.com/vine-io/vine/lib/config/source"
+)
+
+var (
+	DefaultPath = "config"
+	// DataDir is the symlink swapped by Kubernetes to update the files of
+	// a mounted volume at once
+	DataDir = "..data"
+
+	encoders = map[string]encoder.Encoder{
+		"json": json.NewEncoder(),
+		"yaml": yaml.NewEncoder(),
+		"yml":  yaml.NewEncoder(),
+		"toml": toml.NewEncoder(),
+		"hcl":  hcl.NewEncoder(),
+	}
+)
+
+type dir struct {
+	path string
+	opts source.Options
+}
+
+// Read maps the directory to nested keys. The files with the extension of an
+// encoder are decoded under their name without the extension e.g. db.yaml is
+// db, the other files are strings under their name without the trailing new
+// line. The entries starting with .. are the internals of a Kubernetes volume
+// and are skipped.
+func (d *dir) Read() (*source.ChangeSet, error) {
+	cs := &source.ChangeSet{
+		Format:  "json",
+		Source:  d.String(),
+		Origins: make(map[string]*source.Origin),
+	}
+	values, err := d.read(d.path, "", cs)
+	if err != nil {
+		return nil, err
+	}
+	if cs.Timestamp.IsZero() {
+		cs.Timestamp = time.Now()
+	}
+
+	b, err := json.NewEncoder().Encode(values)
+	if err != nil {
+		return nil, err
+	}
+	cs.Data = b
+	cs.CheckSum = cs.Sum()
+
+	return cs, nil
+}
+
+func (d *dir) read(path, prefix string, cs *source.ChangeSet) (map[string]interface{}, error) {
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{})
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, "..") {
+			continue
+		}
+
+		p := filepath.Join(path, name)
+		// follow the symlinks to the files of the data dir
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+
+		base, ext := name, strings.TrimPrefix(filepath.Ext(name), ".")
+		enc, ok := encoders[ext]
+		if ok && !info.IsDir() {
+			base = strings.TrimSuffix(name, "."+ext)
+		}
+		key := base
+		if len(prefix) > 0 {
+			key = prefix + "." + base
+		}
+
+		var v interface{}
+		switch {
+		case info.IsDir():
+			if v, err = d.read(p, key, cs); err != nil {
+				return nil, err
+			}
+		case ok:
+			b, err := ioutil.ReadFile(p)
+			if err != nil {
+				return nil, err
+			}
+			var m map[string]interface{}
+			if err = enc.Decode(b, &m); err != nil {
+				return nil, fmt.Errorf("file %s: %v", p, err)
+			}
+			v = m
+		default:
+			b, err := ioutil.ReadFile(p)
+			if err != nil {
+				return nil, err
+			}
+			v = string(bytes.TrimSuffix(b, []byte("\n")))
+		}
+
+		if info.ModTime().After(cs.Timestamp) {
+			cs.Timestamp = info.ModTime()
+		}
+		if !info.IsDir() {
+			o := &source.Origin{Source: d.String(), File: p, Timestamp: info.ModTime()}
+			if m, ok := v.(map[string]interface{}); ok && len(m) > 0 {
+				source.Walk(m, func(path string, _ interface{}) {
+					cs.Origins[key+"."+path] = o
+				})
+			} else {
+				cs.Origins[key] = o
+			}
+		}
+
+		values[base] = v
+	}
+
+	return values, nil
+}
+
+func (d *dir) Watch() (source.Watcher, error) {
+	if _, err := os.Stat(d.path); err != nil {
+		return nil, err
+	}
+	return newWatcher(d)
+}
+
+func (d *dir) Write(cs *source.ChangeSet) error {
+	return nil
+}
+
+func (d *dir) String() string {
+	return "dir"
+}
+
+// NewSource returns a source which reads the directory of WithPath
+func NewSource(opts ...source.Option) source.Source {
+	options := source.NewOptions(opts...)
+	path := DefaultPath
+	if p, ok := options.Context.Value(dirPathKey{}).(string); ok {
+		path = p
+	}
+	return &dir{opts: options, path: path}
+}
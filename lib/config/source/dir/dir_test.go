@@ -0,0 +1,165 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package dir
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func write(t *testing.T, root string, files map[string]string) {
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// mount lays the files out like a Kubernetes volume: the files are in a
+// timestamped dir pointed by ..data and the keys are symlinks into ..data
+func mount(t *testing.T, root string, files map[string]string) {
+	data := filepath.Join(root, "..ts_"+time.Now().Format("150405.000000000"))
+	write(t, data, files)
+
+	tmp := filepath.Join(root, "..data_tmp")
+	if err := os.Symlink(filepath.Base(data), tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(root, DataDir)); err != nil {
+		t.Fatal(err)
+	}
+	for name := range files {
+		link := filepath.Join(root, name)
+		if _, err := os.Lstat(link); err == nil {
+			continue
+		}
+		if err := os.Symlink(filepath.Join(DataDir, name), link); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func read(t *testing.T, data []byte) map[string]interface{} {
+	var v map[string]interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestDir(t *testing.T) {
+	root, err := ioutil.TempDir("", "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	write(t, root, map[string]string{
+		"db.yaml":         "host: localhost\nport: 3306\n",
+		"password":        "secret\n",
+		"cache/addr.json": `{"host": "redis"}`,
+		"cache/ttl":       "10s",
+	})
+
+	cs, err := NewSource(WithPath(root)).Read()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "json", cs.Format)
+	assert.Equal(t, map[string]interface{}{
+		"db":       map[string]interface{}{"host": "localhost", "port": float64(3306)},
+		"password": "secret",
+		"cache": map[string]interface{}{
+			"addr": map[string]interface{}{"host": "redis"},
+			"ttl":  "10s",
+		},
+	}, read(t, cs.Data))
+
+	if o := cs.Origins["db.port"]; assert.NotNil(t, o) {
+		assert.Equal(t, filepath.Join(root, "db.yaml"), o.File)
+	}
+	if o := cs.Origins["cache.ttl"]; assert.NotNil(t, o) {
+		assert.Equal(t, filepath.Join(root, "cache", "ttl"), o.File)
+	}
+
+	write(t, root, map[string]string{"bad.json": "{"})
+	_, err = NewSource(WithPath(root)).Read()
+	assert.Error(t, err)
+}
+
+func TestVolume(t *testing.T) {
+	root, err := ioutil.TempDir("", "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	mount(t, root, map[string]string{"level": "info", "db.yaml": "host: db-1\n"})
+
+	src := NewSource(WithPath(root))
+	cs, err := src.Read()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]interface{}{
+		"level": "info",
+		"db":    map[string]interface{}{"host": "db-1"},
+	}, read(t, cs.Data))
+
+	w, err := src.Watch()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Stop()
+
+	// the files are swapped at once
+	mount(t, root, map[string]string{"level": "debug", "db.yaml": "host: db-2\n"})
+
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		cs, err := w.Next()
+		if assert.NoError(t, err) {
+			assert.Equal(t, map[string]interface{}{
+				"level": "debug",
+				"db":    map[string]interface{}{"host": "db-2"},
+			}, read(t, cs.Data))
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the swap of the volume was not seen")
+	}
+}
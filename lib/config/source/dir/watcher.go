@@ -0,0 +1,127 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package dir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/vine-io/vine/lib/config/source"
+)
+
+type watcher struct {
+	d    *dir
+	fw   *fsnotify.Watcher
+	exit chan bool
+	// the checksum of the last read
+	sum string
+}
+
+func newWatcher(d *dir) (source.Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &watcher{
+		d:    d,
+		fw:   fw,
+		exit: make(chan bool),
+	}
+	w.add(d.path)
+	if cs, err := d.Read(); err == nil {
+		w.sum = cs.CheckSum
+	}
+
+	return w, nil
+}
+
+// add watches the directory and its sub directories. The data dir of a
+// Kubernetes volume is not watched, it is swapped by renaming a new symlink
+// over it which is seen as an event of the directory.
+func (w *watcher) add(path string) {
+	w.fw.Add(path)
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "..") {
+			continue
+		}
+		p := filepath.Join(path, entry.Name())
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			w.add(p)
+		}
+	}
+}
+
+func (w *watcher) Next() (*source.ChangeSet, error) {
+	for {
+		// is it closed?
+		select {
+		case <-w.exit:
+			return nil, source.ErrWatcherStopped
+		default:
+		}
+
+		select {
+		case _, ok := <-w.fw.Events:
+			if !ok {
+				return nil, source.ErrWatcherStopped
+			}
+
+			cs, err := w.d.Read()
+			if err != nil {
+				return nil, err
+			}
+			// a swap of the data dir is a few events, only the one
+			// which changes the files matters
+			if cs.CheckSum == w.sum {
+				continue
+			}
+			w.sum = cs.CheckSum
+
+			// the directories may have been swapped or created
+			w.add(w.d.path)
+
+			return cs, nil
+		case err := <-w.fw.Errors:
+			return nil, err
+		case <-w.exit:
+			return nil, source.ErrWatcherStopped
+		}
+	}
+}
+
+func (w *watcher) Stop() error {
+	select {
+	case <-w.exit:
+	default:
+		close(w.exit)
+	}
+	return w.fw.Close()
+}
@@ -24,19 +24,50 @@
 package file
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"dario.cat/mergo"
+	"github.com/vine-io/vine/lib/config/encoder"
+	"github.com/vine-io/vine/lib/config/encoder/hcl"
+	"github.com/vine-io/vine/lib/config/encoder/json"
+	"github.com/vine-io/vine/lib/config/encoder/toml"
+	"github.com/vine-io/vine/lib/config/encoder/yaml"
 	"github.com/vine-io/vine/lib/config/source"
 )
 
 type file struct {
-	path string
-	opts source.Options
+	path    string
+	profile string
+	opts    source.Options
+
+	sync.RWMutex
+	// the files of the last read, they are watched
+	files []string
 }
 
 var (
 	DefaultPath = "config.json"
+	// DefaultProfile selects the overlay of the files e.g. prod reads
+	// config.prod.yaml over config.yaml, it is set by --profile
+	DefaultProfile = os.Getenv("VINE_PROFILE")
+	// IncludeKey holds the files included by a file, their paths are
+	// relative to the file and may be globs
+	IncludeKey = "include"
+
+	encoders = map[string]encoder.Encoder{
+		"json": json.NewEncoder(),
+		"yaml": yaml.NewEncoder(),
+		"yml":  yaml.NewEncoder(),
+		"toml": toml.NewEncoder(),
+		"hcl":  hcl.NewEncoder(),
+	}
 )
 
 func (f *file) Read() (*source.ChangeSet, error) {
@@ -60,11 +91,228 @@ func (f *file) Read() (*source.ChangeSet, error) {
 		Timestamp: info.ModTime(),
 		Data:      b,
 	}
+
+	// the files with includes or a profile are merged into json
+	if f.layered(b, cs.Format) {
+		layers, err := f.layers()
+		if err != nil {
+			return nil, err
+		}
+		merged := make(map[string]interface{})
+		files := make([]string, 0, len(layers))
+		cs.Origins = make(map[string]*source.Origin)
+		for _, l := range layers {
+			if err := mergo.Map(&merged, l.values, mergo.WithOverride); err != nil {
+				return nil, err
+			}
+			for key, o := range l.origins {
+				source.SetOrigin(cs.Origins, key, o)
+			}
+			if l.modified.After(cs.Timestamp) {
+				cs.Timestamp = l.modified
+			}
+			files = append(files, l.path)
+		}
+		if cs.Data, err = json.NewEncoder().Encode(merged); err != nil {
+			return nil, err
+		}
+		cs.Format = "json"
+		f.setFiles(files)
+	} else {
+		if enc, ok := encoders[cs.Format]; ok {
+			var values map[string]interface{}
+			if err := enc.Decode(b, &values); err == nil {
+				cs.Origins = origins(f.path, cs.Format, b, values, cs.Timestamp)
+			}
+		}
+		f.setFiles([]string{f.path})
+	}
+
 	cs.CheckSum = cs.Sum()
 
 	return cs, nil
 }
 
+func (f *file) setFiles(files []string) {
+	f.Lock()
+	f.files = files
+	f.Unlock()
+}
+
+// watched returns the files to watch
+func (f *file) watched() []string {
+	f.RLock()
+	defer f.RUnlock()
+	if len(f.files) == 0 {
+		return []string{f.path}
+	}
+	return append([]string{}, f.files...)
+}
+
+// overlay returns the path of the file of the profile e.g. config.prod.yaml
+func (f *file) overlay() string {
+	if len(f.profile) == 0 {
+		return ""
+	}
+	ext := filepath.Ext(f.path)
+	return strings.TrimSuffix(f.path, ext) + "." + f.profile + ext
+}
+
+// layered reports whether the file includes other files or has an overlay
+func (f *file) layered(b []byte, format string) bool {
+	if p := f.overlay(); len(p) > 0 {
+		if _, err := os.Stat(p); err == nil {
+			return true
+		}
+	}
+	enc, ok := encoders[format]
+	if !ok {
+		return false
+	}
+	var v map[string]interface{}
+	if err := enc.Decode(b, &v); err != nil {
+		return false
+	}
+	_, ok = v[IncludeKey]
+	return ok
+}
+
+type layer struct {
+	path     string
+	values   map[string]interface{}
+	origins  map[string]*source.Origin
+	modified time.Time
+}
+
+// layers returns the files in the order they are merged: the includes of a
+// file come before it and the overlay of the profile comes last
+func (f *file) layers() ([]*layer, error) {
+	loaded := make(map[string]bool)
+	layers, err := load(f.path, make(map[string]bool), loaded)
+	if err != nil {
+		return nil, err
+	}
+	if p := f.overlay(); len(p) > 0 {
+		if _, err := os.Stat(p); err == nil {
+			overlay, err := load(p, make(map[string]bool), loaded)
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, overlay...)
+		}
+	}
+	return layers, nil
+}
+
+// load returns the layers of the file and of its includes. stack holds the
+// files including it, which it must not include again, and a file already
+// loaded through another include is merged once, where it was first loaded.
+func load(path string, stack, loaded map[string]bool) ([]*layer, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if stack[abs] {
+		return nil, fmt.Errorf("file %s includes itself", path)
+	}
+	if loaded[abs] {
+		return nil, nil
+	}
+	stack[abs] = true
+	defer delete(stack, abs)
+	loaded[abs] = true
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	f := format(path, json.NewEncoder())
+	enc, ok := encoders[f]
+	if !ok {
+		return nil, fmt.Errorf("file %s: unsupported format %s", path, f)
+	}
+	values := make(map[string]interface{})
+	if err := enc.Decode(b, &values); err != nil {
+		return nil, fmt.Errorf("file %s: %v", path, err)
+	}
+
+	var includes []string
+	switch v := values[IncludeKey].(type) {
+	case nil:
+	case string:
+		includes = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			includes = append(includes, fmt.Sprint(item))
+		}
+	default:
+		return nil, fmt.Errorf("file %s: %s must be a path or a list of paths", path, IncludeKey)
+	}
+	delete(values, IncludeKey)
+
+	var layers []*layer
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+		matches, err := filepath.Glob(include)
+		if err != nil {
+			return nil, fmt.Errorf("file %s: %v", path, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(include, "*?[") {
+			return nil, fmt.Errorf("file %s: include %s not found", path, include)
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			l, err := load(m, stack, loaded)
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, l...)
+		}
+	}
+
+	l := &layer{
+		path:     path,
+		values:   values,
+		origins:  origins(path, f, b, values, info.ModTime()),
+		modified: info.ModTime(),
+	}
+	return append(layers, l), nil
+}
+
+// Layers returns the files read by the file source in the order they are
+// merged, each one as a json ChangeSet whose Source is the path of the file
+func Layers(opts ...source.Option) ([]*source.ChangeSet, error) {
+	f := NewSource(opts...).(*file)
+	layers, err := f.layers()
+	if err != nil {
+		return nil, err
+	}
+
+	sets := make([]*source.ChangeSet, 0, len(layers))
+	for _, l := range layers {
+		b, err := json.NewEncoder().Encode(l.values)
+		if err != nil {
+			return nil, err
+		}
+		cs := &source.ChangeSet{
+			Data:      b,
+			Format:    "json",
+			Source:    l.path,
+			Timestamp: l.modified,
+			Origins:   l.origins,
+		}
+		cs.CheckSum = cs.Sum()
+		sets = append(sets, cs)
+	}
+	return sets, nil
+}
+
 func (f *file) String() string {
 	return "file"
 }
@@ -87,5 +335,9 @@ func NewSource(opts ...source.Option) source.Source {
 	if ok {
 		path = f
 	}
-	return &file{opts: options, path: path}
+	profile := DefaultProfile
+	if p, ok := options.Context.Value(profileKey{}).(string); ok {
+		profile = p
+	}
+	return &file{opts: options, path: path, profile: profile}
 }
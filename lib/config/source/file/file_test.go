@@ -23,9 +23,12 @@
 package file
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -57,3 +60,156 @@ func TestFile(t *testing.T) {
 		t.Error("data from file does not match")
 	}
 }
+
+func TestFileLayers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"config.yaml":      "include: [conf.d/*.yaml]\nname: app\ndb:\n  host: localhost\n",
+		"conf.d/db.yaml":   "db:\n  host: db\n  port: 3306\n",
+		"config.prod.yaml": "db:\n  host: prod\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(dir, "config.yaml")
+	read := func(profile string) map[string]interface{} {
+		c, err := NewSource(WithPath(path), WithProfile(profile)).Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Format != "json" {
+			t.Fatalf("expected json, got %s", c.Format)
+		}
+		var v map[string]interface{}
+		if err := json.Unmarshal(c.Data, &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	v := read("")
+	if _, ok := v[IncludeKey]; ok {
+		t.Fatal("include should be removed")
+	}
+	db := v["db"].(map[string]interface{})
+	if v["name"] != "app" || db["host"] != "localhost" || db["port"] != float64(3306) {
+		t.Fatalf("unexpected config %v", v)
+	}
+
+	db = read("prod")["db"].(map[string]interface{})
+	if db["host"] != "prod" || db["port"] != float64(3306) {
+		t.Fatalf("unexpected profile config %v", db)
+	}
+
+	sets, err := Layers(WithPath(path), WithProfile("prod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sets) != 3 || sets[0].Source != filepath.Join(dir, "conf.d/db.yaml") || sets[2].Source != filepath.Join(dir, "config.prod.yaml") {
+		t.Fatalf("unexpected layers %v", sets)
+	}
+
+	c, err := NewSource(WithPath(path), WithProfile("prod")).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := c.Origins["db.host"]; o == nil || o.File != filepath.Join(dir, "config.prod.yaml") || o.Line != 2 {
+		t.Fatalf("unexpected origin of db.host %+v", o)
+	}
+	if o := c.Origins["db.port"]; o == nil || o.File != filepath.Join(dir, "conf.d/db.yaml") || o.Line != 3 {
+		t.Fatalf("unexpected origin of db.port %+v", o)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "conf.d/loop.yaml"), []byte("include: ../config.yaml\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSource(WithPath(path), WithProfile("")).Read(); err == nil {
+		t.Fatal("expected error for an include cycle")
+	}
+}
+
+func TestFileDiamond(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a and b both include common, which is merged once before them
+	files := map[string]string{
+		"config.yaml": "include: [a.yaml, b.yaml]\n",
+		"a.yaml":      "include: common.yaml\ndb:\n  host: a\n",
+		"b.yaml":      "include: common.yaml\ncache:\n  host: b\n",
+		"common.yaml": "db:\n  host: common\n  port: 3306\ncache:\n  host: common\n",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(dir, "config.yaml")
+	sets, err := Layers(WithPath(path), WithProfile(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, cs := range sets {
+		order = append(order, filepath.Base(cs.Source))
+	}
+	if strings.Join(order, ",") != "common.yaml,a.yaml,b.yaml,config.yaml" {
+		t.Fatalf("unexpected layers %v", order)
+	}
+
+	c, err := NewSource(WithPath(path), WithProfile("")).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]map[string]interface{}
+	if err := json.Unmarshal(c.Data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v["db"]["host"] != "a" || v["db"]["port"] != float64(3306) || v["cache"]["host"] != "b" {
+		t.Fatalf("unexpected config %v", v)
+	}
+}
+
+func TestFileOrigins(t *testing.T) {
+	data := []byte("{\n  \"name\": \"app\",\n  \"db\": {\n    \"hosts\": [\n      \"a\",\n      \"b\"\n    ],\n    \"port\": 3306\n  }\n}\n")
+	path := filepath.Join(os.TempDir(), fmt.Sprintf("file.%d.json", time.Now().UnixNano()))
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+
+	c, err := NewSource(WithPath(path), WithProfile("")).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(c.Data) != string(data) {
+		t.Fatal("data from file does not match")
+	}
+
+	lines := map[string]int{"name": 2, "db.hosts": 4, "db.port": 8}
+	if len(c.Origins) != len(lines) {
+		t.Fatalf("unexpected origins %v", c.Origins)
+	}
+	for key, line := range lines {
+		o := c.Origins[key]
+		if o == nil || o.Source != "file" || o.File != path || o.Line != line {
+			t.Fatalf("unexpected origin of %s %+v", key, o)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package file
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"time"
+
+	"github.com/vine-io/vine/lib/config/source"
+	"gopkg.in/yaml.v3"
+)
+
+// origins returns the origins of the values of a file, the lines are known
+// for json and yaml files
+func origins(path, format string, data []byte, values map[string]interface{}, modified time.Time) map[string]*source.Origin {
+	var lines map[string]int
+	switch format {
+	case "json":
+		lines = jsonLines(data)
+	case "yaml", "yml":
+		lines = yamlLines(data)
+	}
+
+	out := make(map[string]*source.Origin)
+	source.Walk(values, func(key string, _ interface{}) {
+		out[key] = &source.Origin{Source: "file", File: path, Line: lines[key], Timestamp: modified}
+	})
+	return out
+}
+
+func join(prefix, key string) string {
+	if len(prefix) == 0 {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// yamlLines returns the lines of the keys keyed by their dotted path
+func yamlLines(data []byte) map[string]int {
+	var n yaml.Node
+	if err := yaml.Unmarshal(data, &n); err != nil {
+		return nil
+	}
+
+	lines := make(map[string]int)
+	var walk func(prefix string, n *yaml.Node)
+	walk = func(prefix string, n *yaml.Node) {
+		switch n.Kind {
+		case yaml.DocumentNode:
+			for _, c := range n.Content {
+				walk(prefix, c)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				path := join(prefix, n.Content[i].Value)
+				lines[path] = n.Content[i].Line
+				walk(path, n.Content[i+1])
+			}
+		}
+	}
+	walk("", &n)
+	return lines
+}
+
+// jsonLines returns the lines of the keys keyed by their dotted path
+func jsonLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+	dec := stdjson.NewDecoder(bytes.NewReader(data))
+
+	var value func(path string) error
+	value = func(path string) error {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case stdjson.Delim('{'):
+			for dec.More() {
+				t, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key, _ := t.(string)
+				sub := join(path, key)
+				lines[sub] = 1 + bytes.Count(data[:dec.InputOffset()], []byte("\n"))
+				if err := value(sub); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		case stdjson.Delim('['):
+			// the values of lists have the line of the list
+			for dec.More() {
+				var skip stdjson.RawMessage
+				if err := dec.Decode(&skip); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		}
+		return err
+	}
+	if err := value(""); err != nil {
+		return nil
+	}
+	return lines
+}
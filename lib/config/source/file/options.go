@@ -29,6 +29,7 @@ import (
 )
 
 type filePathKey struct{}
+type profileKey struct{}
 
 // WithPath sets the path to file
 func WithPath(p string) source.Option {
@@ -39,3 +40,14 @@ func WithPath(p string) source.Option {
 		o.Context = context.WithValue(o.Context, filePathKey{}, p)
 	}
 }
+
+// WithProfile sets the profile of the file, its overlay e.g. config.prod.yaml
+// is merged over config.yaml. Empty disables the overlay of DefaultProfile.
+func WithProfile(p string) source.Option {
+	return func(o *source.Options) {
+		if o.Context == nil {
+			o.Context = context.Background()
+		}
+		o.Context = context.WithValue(o.Context, profileKey{}, p)
+	}
+}
@@ -46,7 +46,9 @@ func newWatcher(f *file) (source.Watcher, error) {
 		return nil, err
 	}
 
-	fw.Add(f.path)
+	for _, path := range f.watched() {
+		fw.Add(path)
+	}
 
 	return &watcher{
 		f:    f,
@@ -78,6 +80,10 @@ func (w *watcher) Next() (*source.ChangeSet, error) {
 		if err != nil {
 			return nil, err
 		}
+		// watch the files included since the last read
+		for _, path := range w.f.watched() {
+			w.fw.Add(path)
+		}
 		return c, nil
 	case err := <-w.fw.Errors:
 		return nil, err
@@ -46,7 +46,9 @@ func newWatcher(f *file) (source.Watcher, error) {
 		return nil, err
 	}
 
-	fw.Add(f.path)
+	for _, path := range f.watched() {
+		fw.Add(path)
+	}
 
 	return &watcher{
 		f:    f,
@@ -79,8 +81,11 @@ func (w *watcher) Next() (*source.ChangeSet, error) {
 			return nil, err
 		}
 
-		// add path again for the event bug of fsnotify
-		w.fw.Add(w.f.path)
+		// add paths again for the event bug of fsnotify, the files may
+		// have changed with the includes
+		for _, path := range w.f.watched() {
+			w.fw.Add(path)
+		}
 
 		return c, nil
 	case err := <-w.fw.Errors:
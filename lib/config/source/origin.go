@@ -0,0 +1,81 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package source
+
+import (
+	"strings"
+	"time"
+)
+
+// Origin is where a value of a ChangeSet was set
+type Origin struct {
+	// Source is the name of the source e.g. file, env or flag
+	Source string `json:"source"`
+	// File and Line of the value when it is read from a file
+	File      string    `json:"file,omitempty"`
+	Line      int       `json:"line,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// Interpolated is set when the value references other values or the
+	// environment, it may hold a secret once resolved
+	Interpolated bool `json:"interpolated,omitempty"`
+}
+
+// Walk calls fn with the dotted path of the values of v which are not maps,
+// lists are not walked
+func Walk(v interface{}, fn func(path string, v interface{})) {
+	walk("", v, fn)
+}
+
+func walk(prefix string, v interface{}, fn func(path string, v interface{})) {
+	m, ok := v.(map[string]interface{})
+	if !ok || (len(m) == 0 && len(prefix) > 0) {
+		if len(prefix) > 0 {
+			fn(prefix, v)
+		}
+		return
+	}
+	for k, item := range m {
+		path := k
+		if len(prefix) > 0 {
+			path = prefix + "." + k
+		}
+		walk(path, item, fn)
+	}
+}
+
+// SetOrigin sets the origin of the value at the path of the merged values, it
+// replaces the origins of the values which the value overrides
+func SetOrigin(origins map[string]*Origin, path string, o *Origin) {
+	// the values below the path
+	for key := range origins {
+		if strings.HasPrefix(key, path+".") {
+			delete(origins, key)
+		}
+	}
+	// a value replaced by a map
+	parts := strings.Split(path, ".")
+	for i := 1; i < len(parts); i++ {
+		delete(origins, strings.Join(parts[:i], "."))
+	}
+	origins[path] = o
+}
@@ -0,0 +1,133 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package service is a source reading the config from the config service
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cs "github.com/vine-io/vine/lib/config/service"
+	"github.com/vine-io/vine/lib/config/source"
+	"github.com/vine-io/vine/util/context/metadata"
+)
+
+var (
+	// DefaultNamespace of the config
+	DefaultNamespace = "default"
+)
+
+type service struct {
+	opts      source.Options
+	client    cs.ConfigService
+	namespace string
+
+	sync.RWMutex
+	// version of the last document read
+	version int64
+}
+
+func toChangeSet(d *cs.Document) *source.ChangeSet {
+	return &source.ChangeSet{
+		Data:      d.Data,
+		Format:    d.Format,
+		CheckSum:  d.Checksum,
+		Source:    "service",
+		Timestamp: time.Unix(d.Created, 0),
+	}
+}
+
+func (s *service) seen(version int64) {
+	s.Lock()
+	if version > s.version {
+		s.version = version
+	}
+	s.Unlock()
+}
+
+func (s *service) Read() (*source.ChangeSet, error) {
+	rsp, err := s.client.Read(s.opts.Context, &cs.ReadRequest{Namespace: s.namespace})
+	if err != nil {
+		return nil, err
+	}
+	s.seen(rsp.Document.Version)
+	return toChangeSet(rsp.Document), nil
+}
+
+func (s *service) Write(c *source.ChangeSet) error {
+	ctx := s.opts.Context
+	if token, ok := ctx.Value(adminTokenKey{}).(string); ok && len(token) > 0 {
+		ctx = metadata.Set(ctx, "Authorization", "Bearer "+token)
+	}
+	rsp, err := s.client.Update(ctx, &cs.UpdateRequest{
+		Namespace: s.namespace,
+		Data:      c.Data,
+		Format:    c.Format,
+	})
+	if err != nil {
+		return err
+	}
+	s.seen(rsp.Document.Version)
+	return nil
+}
+
+// Watch streams the documents newer than the last one read
+func (s *service) Watch() (source.Watcher, error) {
+	s.RLock()
+	version := s.version
+	s.RUnlock()
+
+	ctx, cancel := context.WithCancel(s.opts.Context)
+	stream, err := s.client.Watch(ctx, &cs.WatchRequest{Namespace: s.namespace, Version: version})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return newWatcher(s, stream, cancel), nil
+}
+
+func (s *service) String() string {
+	return "service"
+}
+
+// NewSource returns a source reading the namespace from the config service,
+// the updates of the namespace are pushed through a stream
+func NewSource(opts ...source.Option) source.Source {
+	options := source.NewOptions(opts...)
+
+	name := cs.DefaultName
+	if v, ok := options.Context.Value(serviceNameKey{}).(string); ok && len(v) > 0 {
+		name = v
+	}
+	namespace := DefaultNamespace
+	if v, ok := options.Context.Value(namespaceKey{}).(string); ok && len(v) > 0 {
+		namespace = v
+	}
+
+	return &service{
+		opts:      options,
+		client:    cs.NewConfigService(name, options.Client),
+		namespace: namespace,
+	}
+}
@@ -0,0 +1,109 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	membroker "github.com/vine-io/vine/core/broker/memory"
+	"github.com/vine-io/vine/core/client"
+	cmemory "github.com/vine-io/vine/core/client/memory"
+	regMemory "github.com/vine-io/vine/core/registry/memory"
+	"github.com/vine-io/vine/core/server"
+	smemory "github.com/vine-io/vine/core/server/memory"
+	"github.com/vine-io/vine/lib/cache/memory"
+	cfg "github.com/vine-io/vine/lib/config/memory"
+	cs "github.com/vine-io/vine/lib/config/service"
+	"github.com/vine-io/vine/lib/config/source"
+)
+
+func TestService(t *testing.T) {
+	r := regMemory.NewRegistry()
+	b := membroker.NewBroker()
+
+	s := smemory.NewServer(server.Name(cs.DefaultName), server.Registry(r), server.Broker(b))
+	m := cs.NewManager(cs.WithStore(cs.NewStore(memory.NewCache())), cs.WithAdminToken("admin"))
+	if !assert.NoError(t, cs.RegisterConfigHandler(s, cs.NewHandler(m))) || !assert.NoError(t, s.Start()) {
+		return
+	}
+	defer s.Stop()
+
+	ctx := context.Background()
+	_, err := m.Update(ctx, &cs.UpdateRequest{Namespace: "greeter", Data: []byte(`{"greeting":"hello"}`)})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	c := cmemory.NewClient(client.Registry(r), client.Broker(b))
+	src := NewSource(WithNamespace("greeter"), source.WithClient(c), WithAdminToken("admin"))
+
+	conf := cfg.NewConfig()
+	defer conf.Close()
+	if !assert.NoError(t, conf.Load(src)) {
+		return
+	}
+	assert.Equal(t, "hello", conf.Get("greeting").String(""))
+
+	_, err = NewSource(WithNamespace("missing"), source.WithClient(c)).Read()
+	assert.Error(t, err)
+
+	w, err := conf.Watch("greeting")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Stop()
+
+	next := func() string {
+		done := make(chan string, 1)
+		go func() {
+			v, err := w.Next()
+			if err == nil {
+				done <- v.String("")
+			}
+		}()
+		select {
+		case v := <-done:
+			return v
+		case <-time.After(time.Second):
+			t.Fatal("update not pushed within a second")
+		}
+		return ""
+	}
+
+	_, err = m.Update(ctx, &cs.UpdateRequest{Namespace: "greeter", Data: []byte(`{"greeting":"hi"}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", next())
+
+	// writes go through the service with the admin token
+	assert.Error(t, NewSource(WithNamespace("greeter"), source.WithClient(c)).Write(&source.ChangeSet{Data: []byte(`{}`), Format: "json"}))
+	assert.NoError(t, src.Write(&source.ChangeSet{Data: []byte(`{"greeting":"hey"}`), Format: "json"}))
+	assert.Equal(t, "hey", next())
+	d, err := m.Read(ctx, "greeter", 0)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(3), d.Version)
+	}
+}
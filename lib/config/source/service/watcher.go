@@ -0,0 +1,72 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package service
+
+import (
+	"context"
+	"sync"
+
+	cs "github.com/vine-io/vine/lib/config/service"
+	"github.com/vine-io/vine/lib/config/source"
+)
+
+type watcher struct {
+	s      *service
+	stream cs.Config_WatchService
+	cancel context.CancelFunc
+
+	once sync.Once
+	exit chan bool
+}
+
+func newWatcher(s *service, stream cs.Config_WatchService, cancel context.CancelFunc) *watcher {
+	return &watcher{
+		s:      s,
+		stream: stream,
+		cancel: cancel,
+		exit:   make(chan bool),
+	}
+}
+
+func (w *watcher) Next() (*source.ChangeSet, error) {
+	rsp, err := w.stream.Recv()
+	if err != nil {
+		select {
+		case <-w.exit:
+			return nil, source.ErrWatcherStopped
+		default:
+		}
+		return nil, err
+	}
+	w.s.seen(rsp.Document.Version)
+	return toChangeSet(rsp.Document), nil
+}
+
+func (w *watcher) Stop() error {
+	w.once.Do(func() {
+		close(w.exit)
+		w.stream.Close()
+		w.cancel()
+	})
+	return nil
+}
@@ -47,6 +47,9 @@ type ChangeSet struct {
 	Format    string
 	Source    string
 	Timestamp time.Time
+	// Origins are where the values were set keyed by their dotted path,
+	// the values without one come from Source
+	Origins map[string]*Origin
 }
 
 // Watcher watches a source for changes
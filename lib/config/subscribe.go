@@ -0,0 +1,233 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vine-io/vine/lib/config/reader"
+	"github.com/vine-io/vine/lib/config/source"
+	log "github.com/vine-io/vine/lib/logger"
+)
+
+type SubscribeOptions struct {
+	// Debounce waits for the changes to settle, the callback gets the value
+	// before the first change and the one after the last change
+	Debounce time.Duration
+}
+
+type SubscribeOption func(o *SubscribeOptions)
+
+// Debounce waits the duration after a change for more changes before the
+// callback is called
+func Debounce(d time.Duration) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.Debounce = d
+	}
+}
+
+// Subscription is a subscription to the changes of a key
+type Subscription struct {
+	w    Watcher
+	once sync.Once
+	exit chan bool
+}
+
+// Stop ends the subscription
+func (s *Subscription) Stop() error {
+	var err error
+	s.once.Do(func() {
+		close(s.exit)
+		err = s.w.Stop()
+	})
+	return err
+}
+
+// OnChange calls fn when the value of the dotted path changes, the root when
+// empty, with the old and the new value e.g.
+//
+//	config.OnChange(c, "db", func(old, new DBConfig) { ... })
+//
+// Structs are bound like Bind does, other types are decoded from the json of
+// the value. Values which fail to decode or bind are logged and skipped.
+func OnChange[T any](c Config, path string, fn func(old, new T), opts ...SubscribeOption) (*Subscription, error) {
+	var options SubscribeOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	var keys []string
+	if len(path) > 0 {
+		keys = strings.Split(path, ".")
+	}
+
+	old, err := decode[T](c, c.Get(keys...))
+	if err != nil {
+		return nil, fmt.Errorf("subscribe config %s: %v", path, err)
+	}
+
+	w, err := c.Watch(keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Subscription{w: w, exit: make(chan bool)}
+	go run(s, c, path, fn, old, options)
+	return s, nil
+}
+
+// Follow calls fn with the value of the path, then again with every change of
+// it until the subscription is stopped. The path is watched before it is read
+// so no change is missed. When the path can't be watched fn still gets the
+// current value and the error is returned.
+func Follow(c Config, fn func(v reader.Value), path ...string) (*Subscription, error) {
+	w, err := c.Watch(path...)
+	if err != nil {
+		fn(c.Get(path...))
+		return nil, err
+	}
+
+	s := &Subscription{w: w, exit: make(chan bool)}
+	fn(c.Get(path...))
+	go s.follow(strings.Join(path, "."), fn)
+	return s, nil
+}
+
+func (s *Subscription) follow(path string, fn func(v reader.Value)) {
+	for {
+		v, err := s.w.Next()
+		if err != nil {
+			select {
+			case <-s.exit:
+			default:
+				log.Errorf("config: stopped following the changes of %s: %v", path, err)
+			}
+			return
+		}
+		fn(v)
+	}
+}
+
+func run[T any](s *Subscription, c Config, path string, fn func(old, new T), old T, options SubscribeOptions) {
+	updates := make(chan reader.Value)
+	go func() {
+		defer close(updates)
+		for {
+			v, err := s.w.Next()
+			if err != nil {
+				return
+			}
+			select {
+			case updates <- v:
+			case <-s.exit:
+				return
+			}
+		}
+	}()
+
+	fire := func(v reader.Value) {
+		nv, err := decode[T](c, v)
+		if err != nil {
+			log.Errorf("config: skipped the change of %s: %v", path, err)
+			return
+		}
+		if reflect.DeepEqual(old, nv) {
+			return
+		}
+		fn(old, nv)
+		old = nv
+	}
+
+	var pending reader.Value
+	var timer *time.Timer
+	var fired <-chan time.Time
+	for {
+		select {
+		case v, ok := <-updates:
+			if !ok {
+				return
+			}
+			if options.Debounce <= 0 {
+				fire(v)
+				continue
+			}
+			pending = v
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.NewTimer(options.Debounce)
+			fired = timer.C
+		case <-fired:
+			fire(pending)
+			pending, fired = nil, nil
+		case <-s.exit:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+	}
+}
+
+// decode returns the value as a T
+func decode[T any](c Config, v reader.Value) (T, error) {
+	var out T
+	typ := reflect.TypeOf(&out).Elem()
+
+	st := typ
+	if st.Kind() == reflect.Ptr {
+		st = st.Elem()
+	}
+	if st.Kind() == reflect.Struct && st != typeOfTime {
+		rd := c.Options().Reader
+		if rd == nil {
+			return out, fmt.Errorf("config is not initialised")
+		}
+		data := v.Bytes()
+		if string(data) == "null" {
+			data = []byte("{}")
+		}
+		values, err := rd.Values(&source.ChangeSet{Data: data, Format: "json"})
+		if err != nil {
+			return out, err
+		}
+		target := reflect.ValueOf(&out).Elem()
+		if typ.Kind() == reflect.Ptr {
+			target.Set(reflect.New(st))
+			target = target.Elem()
+		}
+		if err := Bind(values, target.Addr().Interface()); err != nil {
+			return out, err
+		}
+		return out, nil
+	}
+
+	if err := v.Scan(&out); err != nil {
+		return out, err
+	}
+	return out, nil
+}
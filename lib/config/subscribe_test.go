@@ -0,0 +1,147 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vine-io/vine/lib/config"
+	cmemory "github.com/vine-io/vine/lib/config/memory"
+	"github.com/vine-io/vine/lib/config/source"
+	"github.com/vine-io/vine/lib/config/source/memory"
+)
+
+type db struct {
+	Host string `config:"host"`
+	Port int    `config:"port" default:"3306"`
+}
+
+type change struct {
+	old, new interface{}
+}
+
+func TestOnChange(t *testing.T) {
+	src := memory.NewSource(memory.WithJSON([]byte(`{"db": {"host": "db-1"}, "level": "info"}`)))
+	c := cmemory.NewConfig()
+	defer c.Close()
+	if !assert.NoError(t, c.Load(src)) {
+		return
+	}
+	up := src.(interface{ Update(*source.ChangeSet) })
+	update := func(data string) {
+		up.Update(&source.ChangeSet{Data: []byte(data), Format: "json"})
+	}
+
+	// the current value must decode
+	_, err := config.OnChange(c, "db.host", func(old, new int) {})
+	assert.Error(t, err)
+
+	var mu sync.Mutex
+	var changes, debounced []change
+	record := func(list *[]change) func(old, new interface{}) {
+		return func(old, new interface{}) {
+			mu.Lock()
+			*list = append(*list, change{old, new})
+			mu.Unlock()
+		}
+	}
+	count := func(list *[]change) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(*list)
+	}
+
+	r := record(&changes)
+	s, err := config.OnChange(c, "db", func(old, new *db) { r(*old, *new) })
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Stop()
+
+	rd := record(&debounced)
+	sd, err := config.OnChange(c, "db.host", func(old, new string) { rd(old, new) }, config.Debounce(100*time.Millisecond))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer sd.Stop()
+
+	// the change is sent until the source is watched by the loader
+	assert.Eventually(t, func() bool {
+		update(`{"db": {"host": "db-2"}, "level": "info"}`)
+		return count(&changes) > 0
+	}, time.Second, 20*time.Millisecond)
+	assert.Equal(t, change{db{Host: "db-1", Port: 3306}, db{Host: "db-2", Port: 3306}}, changes[0])
+	assert.Eventually(t, func() bool { return count(&debounced) > 0 }, time.Second, 10*time.Millisecond)
+
+	// other keys do not call the subscriptions of db
+	update(`{"db": {"host": "db-2"}, "level": "debug"}`)
+
+	// a burst of changes is a single debounced call
+	for _, host := range []string{"db-3", "db-4", "db-5"} {
+		update(`{"db": {"host": "` + host + `"}, "level": "debug"}`)
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Eventually(t, func() bool { return count(&debounced) > 1 }, time.Second, 10*time.Millisecond)
+	time.Sleep(150 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []change{{"db-1", "db-2"}, {"db-2", "db-5"}}, debounced)
+	assert.Equal(t, db{Host: "db-5", Port: 3306}, changes[len(changes)-1].new)
+}
+
+func TestSnapshot(t *testing.T) {
+	src := memory.NewSource(memory.WithJSON([]byte(`{"db": {"host": "db-1", "port": 3306}}`)))
+	c := cmemory.NewConfig()
+	defer c.Close()
+	if !assert.NoError(t, c.Load(src)) {
+		return
+	}
+
+	snap, err := config.NewSnapshot(c)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotEmpty(t, snap.Version)
+
+	// the snapshot is not changed by reloads or by its readers
+	src.(interface{ Update(*source.ChangeSet) }).Update(&source.ChangeSet{Data: []byte(`{"db": {"host": "db-2"}}`), Format: "json"})
+	assert.NoError(t, c.Sync())
+	snap.Map()["db"].(map[string]interface{})["host"] = "changed"
+
+	assert.Equal(t, "db-1", snap.Get("db", "host").String(""))
+	assert.Equal(t, int64(3306), snap.Get("db", "port").Int(0))
+	d := &db{}
+	assert.NoError(t, snap.Bind(d, config.BindPath("db")))
+	assert.Equal(t, db{Host: "db-1", Port: 3306}, *d)
+
+	next, err := config.NewSnapshot(c)
+	if assert.NoError(t, err) {
+		assert.NotEqual(t, snap.Version, next.Version)
+		assert.Equal(t, "db-2", next.Get("db", "host").String(""))
+	}
+}
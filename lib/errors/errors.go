@@ -74,6 +74,8 @@ type Error struct {
 	Position string     `protobuf:"bytes,5,opt,name=position,proto3" json:"position,omitempty"`
 	Child    *Child     `protobuf:"bytes,6,opt,name=child,proto3" json:"child,omitempty"`
 	Stacks   []*Stack   `protobuf:"bytes,7,rep,name=stacks,proto3" json:"stacks,omitempty"`
+	// Violations lists the fields which failed validation
+	Violations []*Violation `protobuf:"bytes,8,rep,name=violations,proto3" json:"violations,omitempty"`
 }
 
 func (e *Error) Reset()         { *e = Error{} }
@@ -99,6 +101,17 @@ func (m *Stack) Reset()         { *m = Stack{} }
 func (m *Stack) String() string { return proto.CompactTextString(m) }
 func (*Stack) ProtoMessage()    {}
 
+// Violation describes a field which failed validation
+type Violation struct {
+	Field string `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	Rule  string `protobuf:"bytes,2,opt,name=rule,proto3" json:"rule,omitempty"`
+	Value string `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *Violation) Reset()         { *m = Violation{} }
+func (m *Violation) String() string { return proto.CompactTextString(m) }
+func (*Violation) ProtoMessage()    {}
+
 // New generates a custom error.
 func New(id, detail string, code StatusCode) *Error {
 	e := &Error{
@@ -130,6 +143,16 @@ func (e *Error) WithChild(code int32, format string, a ...interface{}) *Error {
 	return e
 }
 
+// WithViolation appends a field violation to Error.Violations
+func (e *Error) WithViolation(field, rule, value string) *Error {
+	e.Violations = append(e.Violations, &Violation{
+		Field: field,
+		Rule:  rule,
+		Value: value,
+	})
+	return e
+}
+
 // WithPos fills Error.Position
 func (e *Error) WithPos() *Error {
 	_, file, line, _ := runtime.Caller(1)
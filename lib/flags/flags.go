@@ -0,0 +1,499 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+// Package flags evaluates feature flags read from the config
+package flags
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/vine-io/vine/lib/config"
+	"github.com/vine-io/vine/lib/config/reader"
+	"github.com/vine-io/vine/lib/logger"
+	"github.com/vine-io/vine/lib/trace"
+	"github.com/vine-io/vine/util/context/metadata"
+)
+
+// Flag is a boolean or a multivariate feature flag, keyed by its name in the
+// config e.g.
+//
+//	flags:
+//	  new-checkout:
+//	    enabled: true
+//	    rules:
+//	      - {attribute: Vine-Region, operator: in, values: [eu-west], variant: "on"}
+//	    rollout: {"on": 10}
+//	  checkout-theme:
+//	    enabled: true
+//	    variants: {blue: "#00f", red: "#f00"}
+//	    default: blue
+//	    rollout: {red: 50}
+type Flag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Enabled flags are evaluated, the disabled ones serve the default variant
+	Enabled bool `json:"enabled"`
+	// Variants are the values of a multivariate flag keyed by their name, a
+	// flag without variants is boolean, its variants are on and off
+	Variants map[string]interface{} `json:"variants,omitempty"`
+	// Default is the variant served when no rule or rollout applies, off by default
+	Default string `json:"default,omitempty"`
+	// Rules target the requests by their metadata, the first matching applies
+	Rules []*Rule `json:"rules,omitempty"`
+	// Rollout serves the variants to a percent of the requests matching no
+	// rule, the requests are bucketed by their stable id
+	Rollout map[string]float64 `json:"rollout,omitempty"`
+	// Key is the metadata key of the stable id, the key of the Manager by default
+	Key string `json:"key,omitempty"`
+}
+
+// Rule serves a variant to the requests whose metadata attribute matches
+type Rule struct {
+	// Attribute is the metadata key e.g. Vine-Region
+	Attribute string `json:"attribute"`
+	// Operator is one of the Op constants, OpIn by default
+	Operator string   `json:"operator,omitempty"`
+	Values   []string `json:"values,omitempty"`
+	// Variant served to the matching requests
+	Variant string `json:"variant,omitempty"`
+	// Rollout serves the variants to a percent of the matching requests instead
+	Rollout map[string]float64 `json:"rollout,omitempty"`
+}
+
+const (
+	OpIn       = "in"
+	OpNotIn    = "not_in"
+	OpPrefix   = "prefix"
+	OpSuffix   = "suffix"
+	OpContains = "contains"
+	OpExists   = "exists"
+	OpRegex    = "regex"
+)
+
+const (
+	// ReasonMissing is the reason of the flags which are not defined
+	ReasonMissing  = "missing"
+	ReasonDisabled = "disabled"
+	ReasonRule     = "rule"
+	ReasonRollout  = "rollout"
+	ReasonDefault  = "default"
+)
+
+// Evaluation is the variant of a flag served to a request
+type Evaluation struct {
+	Flag    string      `json:"flag"`
+	Variant string      `json:"variant"`
+	Value   interface{} `json:"value"`
+	// Reason is why the variant is served
+	Reason string `json:"reason"`
+}
+
+// State is a flag with the count of its evaluations by variant
+type State struct {
+	*Flag
+	Evaluations map[string]uint64 `json:"evaluations"`
+	// Error is why the flag is invalid, the invalid flags are not served
+	Error string `json:"error,omitempty"`
+}
+
+// share is the upper bound of the bucket of a variant in a rollout
+type share struct {
+	variant string
+	upto    float64
+}
+
+type rule struct {
+	*Rule
+	values  map[string]bool
+	re      *regexp.Regexp
+	rollout []share
+}
+
+// flag is a validated Flag ready to be evaluated
+type flag struct {
+	*Flag
+	rules   []*rule
+	rollout []share
+}
+
+// Manager evaluates the flags, it is safe to use per request
+type Manager struct {
+	opts Options
+
+	sync.RWMutex
+	flags map[string]*flag
+	// the invalid flags with their error
+	invalid map[string]*State
+
+	// the evaluations of the flags by variant, name/variant keyed
+	counters sync.Map
+
+	sub *config.Subscription
+}
+
+func rollout(f *Flag, in map[string]float64) ([]share, error) {
+	variants := make([]string, 0, len(in))
+	total := 0.0
+	for variant, percent := range in {
+		if _, ok := f.Variants[variant]; !ok {
+			return nil, fmt.Errorf("rollout of unknown variant %s", variant)
+		}
+		if percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("rollout of variant %s is not a percent", variant)
+		}
+		total += percent
+		variants = append(variants, variant)
+	}
+	if total > 100 {
+		return nil, fmt.Errorf("rollout is over 100 percent")
+	}
+
+	sort.Strings(variants)
+	shares := make([]share, 0, len(variants))
+	upto := 0.0
+	for _, variant := range variants {
+		upto += in[variant]
+		shares = append(shares, share{variant: variant, upto: upto})
+	}
+	return shares, nil
+}
+
+// compile validates the flag, the defaults are set on a copy of it
+func compile(in *Flag, key string) (*flag, error) {
+	f := *in
+	if len(f.Variants) == 0 {
+		f.Variants = map[string]interface{}{"on": true, "off": false}
+		if f.Default == "" {
+			f.Default = "off"
+		}
+	}
+	if _, ok := f.Variants[f.Default]; !ok {
+		return nil, fmt.Errorf("default %q is not a variant", f.Default)
+	}
+	if f.Key == "" {
+		f.Key = key
+	}
+
+	out := &flag{Flag: &f}
+	var err error
+	if out.rollout, err = rollout(&f, f.Rollout); err != nil {
+		return nil, err
+	}
+
+	for i, r := range f.Rules {
+		if r == nil {
+			continue
+		}
+		cr := &rule{Rule: r, values: make(map[string]bool, len(r.Values))}
+		for _, v := range r.Values {
+			cr.values[v] = true
+		}
+		switch r.Operator {
+		case "", OpIn, OpNotIn, OpPrefix, OpSuffix, OpContains, OpExists:
+		case OpRegex:
+			if cr.re, err = regexp.Compile(strings.Join(r.Values, "|")); err != nil {
+				return nil, fmt.Errorf("rule %d: %v", i, err)
+			}
+		default:
+			return nil, fmt.Errorf("rule %d: unknown operator %s", i, r.Operator)
+		}
+		if len(r.Rollout) > 0 {
+			if cr.rollout, err = rollout(&f, r.Rollout); err != nil {
+				return nil, fmt.Errorf("rule %d: %v", i, err)
+			}
+		} else if _, ok := f.Variants[r.Variant]; !ok {
+			return nil, fmt.Errorf("rule %d: %q is not a variant", i, r.Variant)
+		}
+		out.rules = append(out.rules, cr)
+	}
+
+	return out, nil
+}
+
+func (r *rule) match(md metadata.Metadata) bool {
+	v, ok := md.Get(r.Attribute)
+	switch r.Operator {
+	case OpExists:
+		return ok
+	case OpNotIn:
+		return !r.values[v]
+	}
+	if !ok {
+		return false
+	}
+
+	switch r.Operator {
+	case OpPrefix, OpSuffix, OpContains:
+		for _, value := range r.Values {
+			if (r.Operator == OpPrefix && strings.HasPrefix(v, value)) ||
+				(r.Operator == OpSuffix && strings.HasSuffix(v, value)) ||
+				(r.Operator == OpContains && strings.Contains(v, value)) {
+				return true
+			}
+		}
+		return false
+	case OpRegex:
+		return r.re.MatchString(v)
+	}
+	return r.values[v]
+}
+
+// bucket places the id in [0, 100), an id stays in its bucket of a flag
+func bucket(name, id string) float64 {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{'/'})
+	h.Write([]byte(id))
+	return float64(h.Sum32()%10000) / 100
+}
+
+func pick(shares []share, b float64) (string, bool) {
+	for _, s := range shares {
+		if b < s.upto {
+			return s.variant, true
+		}
+	}
+	return "", false
+}
+
+func (f *flag) evaluate(md metadata.Metadata) (string, string) {
+	if !f.Enabled {
+		return f.Default, ReasonDisabled
+	}
+
+	id, _ := md.Get(f.Key)
+	for _, r := range f.rules {
+		if !r.match(md) {
+			continue
+		}
+		if len(r.rollout) == 0 {
+			return r.Variant, ReasonRule
+		}
+		if len(id) > 0 {
+			if variant, ok := pick(r.rollout, bucket(f.Name, id)); ok {
+				return variant, ReasonRule
+			}
+		}
+		return f.Default, ReasonRule
+	}
+
+	if len(f.rollout) > 0 && len(id) > 0 {
+		if variant, ok := pick(f.rollout, bucket(f.Name, id)); ok {
+			return variant, ReasonRollout
+		}
+	}
+	return f.Default, ReasonDefault
+}
+
+// Evaluate returns the variant of the flag served to the request of ctx, it
+// is recorded in the metrics and in the trace of the request
+func (m *Manager) Evaluate(ctx context.Context, name string) *Evaluation {
+	m.RLock()
+	f, ok := m.flags[name]
+	m.RUnlock()
+
+	e := &Evaluation{Flag: name, Reason: ReasonMissing}
+	if ok {
+		md, _ := metadata.FromContext(ctx)
+		e.Variant, e.Reason = f.evaluate(md)
+		e.Value = f.Variants[e.Variant]
+	}
+
+	m.record(ctx, e)
+	return e
+}
+
+func (m *Manager) record(ctx context.Context, e *Evaluation) {
+	key := e.Flag + "/" + e.Variant
+	c, ok := m.counters.Load(key)
+	if !ok {
+		c, _ = m.counters.LoadOrStore(key, new(uint64))
+	}
+	atomic.AddUint64(c.(*uint64), 1)
+
+	// only the requests which are traced
+	if _, _, ok := trace.FromContext(ctx); !ok {
+		return
+	}
+	t := m.opts.Tracer
+	if t == nil {
+		t = trace.DefaultTracer
+	}
+	_, span := t.Start(ctx, "flags."+e.Flag)
+	if span == nil {
+		return
+	}
+	if span.Metadata == nil {
+		span.Metadata = make(map[string]string)
+	}
+	span.Metadata["flag"] = e.Flag
+	span.Metadata["variant"] = e.Variant
+	span.Metadata["reason"] = e.Reason
+	t.Finish(span)
+}
+
+// Bool returns whether the boolean flag is on, def when the flag is missing
+// or is not boolean
+func (m *Manager) Bool(ctx context.Context, name string, def bool) bool {
+	e := m.Evaluate(ctx, name)
+	if e.Reason == ReasonMissing {
+		return def
+	}
+	v, ok := e.Value.(bool)
+	if !ok {
+		return def
+	}
+	return v
+}
+
+// Variant returns the variant of the flag, def when the flag is missing
+func (m *Manager) Variant(ctx context.Context, name string, def string) string {
+	e := m.Evaluate(ctx, name)
+	if e.Reason == ReasonMissing {
+		return def
+	}
+	return e.Variant
+}
+
+// String returns the value of the variant of the flag, def when the flag is
+// missing or the value is not a string
+func (m *Manager) String(ctx context.Context, name string, def string) string {
+	e := m.Evaluate(ctx, name)
+	if e.Reason == ReasonMissing {
+		return def
+	}
+	v, ok := e.Value.(string)
+	if !ok {
+		return def
+	}
+	return v
+}
+
+// Update replaces the flags, the invalid ones are reported by States and are
+// not served
+func (m *Manager) Update(flags ...*Flag) {
+	valid := make(map[string]*flag, len(flags))
+	invalid := make(map[string]*State)
+	for _, f := range flags {
+		if f == nil || f.Name == "" {
+			continue
+		}
+		cf, err := compile(f, m.opts.Key)
+		if err != nil {
+			logger.Errorf("invalid flag %s: %v", f.Name, err)
+			invalid[f.Name] = &State{Flag: f, Error: err.Error()}
+			continue
+		}
+		valid[f.Name] = cf
+	}
+
+	m.Lock()
+	m.flags = valid
+	m.invalid = invalid
+	m.Unlock()
+}
+
+// States returns the flags in use and the invalid ones sorted by name
+func (m *Manager) States() []*State {
+	counts := make(map[string]map[string]uint64)
+	m.counters.Range(func(k, v interface{}) bool {
+		key := k.(string)
+		i := strings.LastIndex(key, "/")
+		if counts[key[:i]] == nil {
+			counts[key[:i]] = make(map[string]uint64)
+		}
+		counts[key[:i]][key[i+1:]] = atomic.LoadUint64(v.(*uint64))
+		return true
+	})
+
+	m.RLock()
+	states := make([]*State, 0, len(m.flags)+len(m.invalid))
+	for name, f := range m.flags {
+		states = append(states, &State{Flag: f.Flag, Evaluations: counts[name]})
+	}
+	for name, s := range m.invalid {
+		states = append(states, &State{Flag: s.Flag, Evaluations: counts[name], Error: s.Error})
+	}
+	m.RUnlock()
+
+	for _, s := range states {
+		if s.Evaluations == nil {
+			s.Evaluations = map[string]uint64{}
+		}
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Name < states[j].Name
+	})
+	return states
+}
+
+func (m *Manager) load(v reader.Value) {
+	flags := make(map[string]*Flag)
+	if err := v.Scan(&flags); err != nil {
+		logger.Errorf("unable to load flags: %v", err)
+		return
+	}
+	list := make([]*Flag, 0, len(flags))
+	for name, f := range flags {
+		if f == nil {
+			continue
+		}
+		f.Name = name
+		list = append(list, f)
+	}
+	m.Update(list...)
+}
+
+// Close stops watching the config
+func (m *Manager) Close() error {
+	if m.sub != nil {
+		return m.sub.Stop()
+	}
+	return nil
+}
+
+// NewManager returns a Manager, the flags are loaded from the config when one is given
+func NewManager(opts ...Option) *Manager {
+	options := NewOptions(opts...)
+	m := &Manager{
+		opts:  options,
+		flags: map[string]*flag{},
+	}
+	m.Update(options.Flags...)
+
+	if options.Config != nil {
+		sub, err := config.Follow(options.Config, m.load, options.Path...)
+		if err != nil {
+			logger.Errorf("unable to watch flags: %v", err)
+		}
+		m.sub = sub
+	}
+
+	return m
+}
@@ -0,0 +1,191 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package flags
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+
+	cmemory "github.com/vine-io/vine/lib/config/memory"
+	"github.com/vine-io/vine/lib/config/source"
+	smemory "github.com/vine-io/vine/lib/config/source/memory"
+	"github.com/vine-io/vine/lib/trace"
+	tmemory "github.com/vine-io/vine/lib/trace/memory"
+	"github.com/vine-io/vine/util/context/metadata"
+)
+
+func withMetadata(kv ...string) context.Context {
+	md := metadata.Metadata{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		md[kv[i]] = kv[i+1]
+	}
+	return metadata.NewContext(context.Background(), md)
+}
+
+func TestEvaluate(t *testing.T) {
+	m := NewManager(WithFlags(
+		&Flag{
+			Name:    "new-checkout",
+			Enabled: true,
+			Rules: []*Rule{
+				{Attribute: "Vine-Region", Values: []string{"eu-west"}, Variant: "on"},
+				{Attribute: "Vine-Plan", Operator: OpPrefix, Values: []string{"beta-"}, Rollout: map[string]float64{"on": 100}},
+			},
+			Rollout: map[string]float64{"on": 20},
+		},
+		&Flag{
+			Name:     "theme",
+			Enabled:  true,
+			Variants: map[string]interface{}{"blue": "#00f", "red": "#f00"},
+			Default:  "blue",
+			Rules:    []*Rule{{Attribute: "Vine-Email", Operator: OpRegex, Values: []string{`@example\.com$`}, Variant: "red"}},
+		},
+		&Flag{Name: "disabled", Variants: map[string]interface{}{"a": 1, "b": 2}, Default: "b"},
+		&Flag{Name: "invalid", Enabled: true, Rollout: map[string]float64{"on": 120}},
+	))
+	defer m.Close()
+
+	ctx := context.Background()
+	assert.False(t, m.Bool(ctx, "new-checkout", true))
+	assert.True(t, m.Bool(withMetadata("Vine-Region", "eu-west"), "new-checkout", false))
+	assert.True(t, m.Bool(withMetadata("Vine-Plan", "beta-1", DefaultKey, "u1"), "new-checkout", false))
+	assert.Equal(t, "#f00", m.String(withMetadata("Vine-Email", "a@example.com"), "theme", ""))
+	assert.Equal(t, "blue", m.Variant(withMetadata("Vine-Email", "a@vine.io"), "theme", ""))
+
+	e := m.Evaluate(ctx, "disabled")
+	assert.Equal(t, &Evaluation{Flag: "disabled", Variant: "b", Value: 2, Reason: ReasonDisabled}, e)
+
+	// missing and invalid flags serve the defaults of the caller
+	assert.True(t, m.Bool(ctx, "missing", true))
+	assert.True(t, m.Bool(ctx, "invalid", true))
+	assert.Equal(t, ReasonMissing, m.Evaluate(ctx, "invalid").Reason)
+
+	// the rollout is stable by id and close to its percent
+	on := 0
+	for i := 0; i < 10000; i++ {
+		ctx := withMetadata(DefaultKey, fmt.Sprintf("user-%d", i))
+		v := m.Bool(ctx, "new-checkout", false)
+		assert.Equal(t, v, m.Bool(ctx, "new-checkout", false))
+		if v {
+			on++
+		}
+	}
+	assert.InDelta(t, 2000, on, 300)
+
+	states := m.States()
+	if assert.Len(t, states, 4) {
+		assert.Equal(t, "disabled", states[0].Name)
+		assert.Equal(t, "invalid", states[1].Name)
+		assert.NotEmpty(t, states[1].Error)
+		assert.Equal(t, uint64(1), states[0].Evaluations["b"])
+		// the three requests above and the two of each id
+		assert.Equal(t, uint64(20003), states[2].Evaluations["on"]+states[2].Evaluations["off"])
+	}
+}
+
+func TestTrace(t *testing.T) {
+	tr := tmemory.NewTracer()
+	m := NewManager(WithTracer(tr), WithFlags(&Flag{Name: "feature", Enabled: true, Default: "on"}))
+	defer m.Close()
+
+	// untraced requests are not recorded
+	assert.True(t, m.Bool(context.Background(), "feature", false))
+	spans, _ := tr.Read()
+	assert.Len(t, spans, 0)
+
+	ctx := trace.ToContext(context.Background(), "trace-1", "span-1")
+	assert.True(t, m.Bool(ctx, "feature", false))
+	spans, _ = tr.Read()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, "trace-1", spans[0].Trace)
+		assert.Equal(t, "span-1", spans[0].Parent)
+		assert.Equal(t, map[string]string{"flag": "feature", "variant": "on", "reason": ReasonDefault}, spans[0].Metadata)
+	}
+}
+
+func TestReload(t *testing.T) {
+	src := smemory.NewSource(smemory.WithJSON([]byte(`{"flags":{"feature":{"enabled":false}}}`)))
+	c := cmemory.NewConfig()
+	if err := c.Load(src); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	m := NewManager(WithConfig(c), WithAdminToken("admin"))
+	defer m.Close()
+
+	ctx := context.Background()
+	assert.Equal(t, ReasonDisabled, m.Evaluate(ctx, "feature").Reason)
+
+	// the source starts watching in the background, keep pushing the change until it lands
+	assert.Eventually(t, func() bool {
+		src.(interface{ Update(*source.ChangeSet) }).Update(&source.ChangeSet{
+			Data:   []byte(`{"flags":{"feature":{"enabled":true,"default":"on"}}}`),
+			Format: "json",
+		})
+		return m.Bool(ctx, "feature", false)
+	}, time.Second*5, time.Millisecond*50)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	RegisterHandler(m, router)
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if len(token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rsp := httptest.NewRecorder()
+		router.ServeHTTP(rsp, req)
+		return rsp
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, get(DefaultAdminPath, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, get(DefaultAdminPath+"/feature", "wrong").Code)
+
+	rsp := get(DefaultAdminPath, "admin")
+	assert.Equal(t, http.StatusOK, rsp.Code)
+	var out struct {
+		Flags []*State `json:"flags"`
+	}
+	if assert.NoError(t, json.Unmarshal(rsp.Body.Bytes(), &out)) && assert.Len(t, out.Flags, 1) {
+		assert.Equal(t, "feature", out.Flags[0].Name)
+		assert.True(t, out.Flags[0].Enabled)
+		assert.Equal(t, "on", out.Flags[0].Default)
+	}
+
+	assert.Equal(t, http.StatusNotFound, get(DefaultAdminPath+"/missing", "admin").Code)
+
+	// nothing is served without an admin token
+	router = gin.New()
+	RegisterHandler(NewManager(), router)
+	assert.Equal(t, http.StatusNotFound, get(DefaultAdminPath, "").Code)
+}
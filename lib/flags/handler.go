@@ -0,0 +1,67 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package flags
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authorize rejects the requests without the admin token as a bearer Authorization
+func authorize(token string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		v := ctx.GetHeader("Authorization")
+		if !strings.HasPrefix(v, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(v, "Bearer ")), []byte(token)) != 1 {
+			ctx.Header("WWW-Authenticate", "Bearer")
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, "invalid admin token")
+		}
+	}
+}
+
+// RegisterHandler lists the states of the flags at DefaultAdminPath and the
+// state of a flag at DefaultAdminPath/:name. The requests carry the admin
+// token of the manager as a bearer Authorization, nothing is served without one.
+func RegisterHandler(m *Manager, router gin.IRoutes) {
+	token := m.opts.AdminToken
+	if len(token) == 0 {
+		return
+	}
+	router.GET(DefaultAdminPath, authorize(token), func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"flags": m.States()})
+	})
+	router.GET(path.Join(DefaultAdminPath, ":name"), authorize(token), func(ctx *gin.Context) {
+		name := ctx.Param("name")
+		for _, s := range m.States() {
+			if s.Name == name {
+				ctx.JSON(http.StatusOK, s)
+				return
+			}
+		}
+		ctx.JSON(http.StatusNotFound, "flag "+name+" not found")
+	})
+}
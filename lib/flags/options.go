@@ -0,0 +1,110 @@
+// MIT License
+//
+// Copyright (c) 2020 The vine Authors
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package flags
+
+import (
+	"github.com/vine-io/vine/lib/config"
+	"github.com/vine-io/vine/lib/trace"
+)
+
+var (
+	// DefaultPath is where the flags are read from the config
+	DefaultPath = []string{"flags"}
+	// DefaultKey is the metadata key of the stable id the rollouts are keyed on
+	DefaultKey = "Vine-User-Id"
+	// DefaultAdminPath is where the states of the flags are listed
+	DefaultAdminPath = "/admin/flags"
+)
+
+type Options struct {
+	// Config the flags are loaded from and watched in
+	Config config.Config
+	// Path of the flags in the config
+	Path []string
+	// Flags used when there is no config
+	Flags []*Flag
+	// Key is the metadata key of the stable id of the flags without one
+	Key string
+	// Tracer records the evaluations of the traced requests, trace.DefaultTracer by default
+	Tracer trace.Tracer
+	// AdminToken is the bearer token of the requests listing the flags, empty disables them
+	AdminToken string
+}
+
+type Option func(o *Options)
+
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Path: DefaultPath,
+		Key:  DefaultKey,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithConfig loads the flags from the config and reloads them on change
+func WithConfig(c config.Config) Option {
+	return func(o *Options) {
+		o.Config = c
+	}
+}
+
+// WithPath sets the path of the flags in the config
+func WithPath(path ...string) Option {
+	return func(o *Options) {
+		o.Path = path
+	}
+}
+
+// WithFlags sets static flags
+func WithFlags(flags ...*Flag) Option {
+	return func(o *Options) {
+		o.Flags = flags
+	}
+}
+
+// WithKey sets the metadata key of the stable id
+func WithKey(key string) Option {
+	return func(o *Options) {
+		o.Key = key
+	}
+}
+
+// WithTracer sets the tracer of the evaluations
+func WithTracer(t trace.Tracer) Option {
+	return func(o *Options) {
+		o.Tracer = t
+	}
+}
+
+// WithAdminToken sets the bearer token required by the admin handler, which
+// is only served with one
+func WithAdminToken(token string) Option {
+	return func(o *Options) {
+		o.AdminToken = token
+	}
+}
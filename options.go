@@ -294,6 +294,13 @@ func WrapCall(w ...client.CallWrapper) Option {
 	}
 }
 
+// WrapCallStream is a convenience method for wrapping the Client Streams
+func WrapCallStream(w ...client.StreamWrapper) Option {
+	return func(o *Options) {
+		_ = o.Client.Init(client.WrapStream(w...))
+	}
+}
+
 // WrapHandler adds a handler Wrapper to a list of options passed into the server
 func WrapHandler(w ...server.HandlerWrapper) Option {
 	return func(o *Options) {
@@ -322,6 +329,20 @@ func WrapSubscriber(w ...server.SubscriberWrapper) Option {
 	}
 }
 
+// WrapStream adds stream Wrapper to a list of options passed into the server
+func WrapStream(w ...server.StreamWrapper) Option {
+	return func(o *Options) {
+		var wrappers []server.Option
+
+		for _, wrap := range w {
+			wrappers = append(wrappers, server.WrapStream(wrap))
+		}
+
+		// Init once
+		_ = o.Server.Init(wrappers...)
+	}
+}
+
 // Before and Afters
 
 // BeforeStart run functions before service starts
@@ -26,11 +26,22 @@ import (
 	"context"
 	"net/http"
 	"net/textproto"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/vine-io/vine/util/context/metadata"
 )
 
+const (
+	// DeadlineKey is the metadata key carrying the absolute deadline of a request
+	DeadlineKey = "Vine-Deadline"
+	// TimeoutKey is the metadata key carrying the request timeout in nanoseconds
+	TimeoutKey = "Timeout"
+	// GrpcTimeoutKey is the gRPC timeout header, e.g. 100m
+	GrpcTimeoutKey = "Grpc-Timeout"
+)
+
 func FromRequest(r *http.Request) context.Context {
 	md, ok := metadata.FromContext(r.Context())
 	if !ok {
@@ -59,3 +70,81 @@ func FromRequest(r *http.Request) context.Context {
 	}
 	return metadata.NewContext(r.Context(), md)
 }
+
+// FormatDeadline formats the deadline as carried by the DeadlineKey metadata
+func FormatDeadline(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// ParseGrpcTimeout parses the value of a grpc-timeout header
+func ParseGrpcTimeout(s string) (time.Duration, bool) {
+	if len(s) < 2 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	var unit time.Duration
+	switch s[len(s)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// DeadlineFromMetadata returns the earliest deadline carried by the metadata,
+// looking at the DeadlineKey, TimeoutKey and GrpcTimeoutKey keys
+func DeadlineFromMetadata(md metadata.Metadata) (time.Time, bool) {
+	var deadline time.Time
+	earliest := func(t time.Time) {
+		if deadline.IsZero() || t.Before(deadline) {
+			deadline = t
+		}
+	}
+
+	now := time.Now()
+	if v, ok := md.Get(DeadlineKey); ok && len(v) > 0 {
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			earliest(t)
+		}
+	}
+	if v, ok := md.Get(TimeoutKey); ok && len(v) > 0 {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			earliest(now.Add(time.Duration(n)))
+		}
+	}
+	if v, ok := md.Get(GrpcTimeoutKey); ok && len(v) > 0 {
+		if d, ok := ParseGrpcTimeout(v); ok {
+			earliest(now.Add(d))
+		}
+	}
+
+	return deadline, !deadline.IsZero()
+}
+
+// WithMetadataDeadline returns a copy of ctx which expires at the deadline carried
+// by its metadata. The context is returned as is when there is none.
+func WithMetadataDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	md, ok := metadata.FromContext(ctx)
+	if !ok {
+		return ctx, func() {}
+	}
+	d, ok := DeadlineFromMetadata(md)
+	if !ok {
+		return ctx, func() {}
+	}
+	return context.WithDeadline(ctx, d)
+}
@@ -1,8 +1,10 @@
 package context
 
 import (
+	"context"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/vine-io/vine/util/context/metadata"
 )
@@ -39,3 +41,45 @@ func TestRequestToContext(t *testing.T) {
 		}
 	}
 }
+
+func TestDeadlineFromMetadata(t *testing.T) {
+	now := time.Now()
+	deadline := now.Add(time.Minute)
+
+	testData := []struct {
+		md     metadata.Metadata
+		expect time.Duration
+		ok     bool
+	}{
+		{metadata.Metadata{}, 0, false},
+		{metadata.Metadata{"vine-deadline": FormatDeadline(deadline)}, time.Minute, true},
+		{metadata.Metadata{"timeout": "1000000000"}, time.Second, true},
+		{metadata.Metadata{"grpc-timeout": "100m"}, 100 * time.Millisecond, true},
+		{metadata.Metadata{"vine-deadline": FormatDeadline(deadline), "grpc-timeout": "2S"}, 2 * time.Second, true},
+	}
+
+	for _, d := range testData {
+		got, ok := DeadlineFromMetadata(d.md)
+		if ok != d.ok {
+			t.Fatalf("Expected ok %v for md %+v, got %v", d.ok, d.md, ok)
+		}
+		if !ok {
+			continue
+		}
+		if diff := got.Sub(now) - d.expect; diff < -time.Second || diff > time.Second {
+			t.Fatalf("Expected deadline in %v for md %+v, got %v", d.expect, d.md, got.Sub(now))
+		}
+	}
+}
+
+func TestWithMetadataDeadline(t *testing.T) {
+	ctx := metadata.NewContext(context.Background(), metadata.Metadata{"timeout": "1"})
+	ctx, cancel := WithMetadataDeadline(ctx)
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected context to expire")
+	}
+}
@@ -23,7 +23,7 @@
 package is
 
 import (
-	"errors"
+	"fmt"
 	"reflect"
 	"regexp"
 	"strings"
@@ -119,16 +119,72 @@ func Crontab(s string) bool {
 	return err == nil
 }
 
+// FieldError is a single violation reported by a generated validator
+type FieldError struct {
+	// Field is the json path of the field, e.g. "user.name"
+	Field string
+	// Rule is the tag which failed, e.g. "min_len"
+	Rule string
+	// Value is the rejected value
+	Value string
+	// Message describes the constraint
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field '%s' %s", e.Field, e.Message)
+}
+
+// Invalid creates a FieldError for the given field, rule and value
+func Invalid(field, rule string, value interface{}, message string) error {
+	return &FieldError{
+		Field:   field,
+		Rule:    rule,
+		Value:   fmt.Sprintf("%v", value),
+		Message: message,
+	}
+}
+
+// Errors is the result of MargeErr, it keeps every error merged into it
+type Errors []error
+
+func (e Errors) Error() string {
+	parts := make([]string, 0, len(e))
+	for _, err := range e {
+		parts = append(parts, err.Error())
+	}
+	return strings.Join(parts, "; ")
+}
+
 func MargeErr(errs ...error) error {
-	parts := make([]string, 0)
+	merged := make(Errors, 0)
 	for _, err := range errs {
 		if err == nil {
 			continue
 		}
-		parts = append(parts, err.Error())
+		// flatten the errors returned by nested messages
+		if es, ok := err.(Errors); ok {
+			merged = append(merged, es...)
+			continue
+		}
+		merged = append(merged, err)
 	}
-	if len(parts) == 0 {
+	if len(merged) == 0 {
 		return nil
 	}
-	return errors.New(strings.Join(parts, "; "))
+	return merged
+}
+
+// FieldErrors returns the field violations carried by err
+func FieldErrors(err error) []*FieldError {
+	out := make([]*FieldError, 0)
+	switch e := err.(type) {
+	case *FieldError:
+		out = append(out, e)
+	case Errors:
+		for _, item := range e {
+			out = append(out, FieldErrors(item)...)
+		}
+	}
+	return out
 }
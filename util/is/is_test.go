@@ -287,6 +287,27 @@ func TestValidate(t *testing.T) {
 	t.Log(err)
 }
 
+func TestFieldErrors(t *testing.T) {
+	nested := MargeErr(Invalid("user.email", "email", "foo", "is not a valid email"))
+	err := MargeErr(nil, Invalid("name", "required", "", "is required"), nested, errors.New("other"))
+	if err == nil {
+		t.Fatal("MargeErr() = nil, want error")
+	}
+
+	want := "field 'name' is required; field 'user.email' is not a valid email; other"
+	if err.Error() != want {
+		t.Fatalf("MargeErr() = %q, want %q", err.Error(), want)
+	}
+
+	fields := FieldErrors(err)
+	if len(fields) != 2 {
+		t.Fatalf("FieldErrors() = %d violations, want 2", len(fields))
+	}
+	if fields[1].Field != "user.email" || fields[1].Rule != "email" || fields[1].Value != "foo" {
+		t.Fatalf("FieldErrors()[1] = %+v", fields[1])
+	}
+}
+
 func TestCrontab(t *testing.T) {
 	type args struct {
 		s string
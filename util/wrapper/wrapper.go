@@ -28,8 +28,10 @@ import (
 
 	"github.com/vine-io/vine/core/client"
 	"github.com/vine-io/vine/core/server"
+	"github.com/vine-io/vine/lib/errors"
 	"github.com/vine-io/vine/lib/trace"
 	"github.com/vine-io/vine/util/context/metadata"
+	"github.com/vine-io/vine/util/is"
 )
 
 type fromServiceWrapper struct {
@@ -131,6 +133,35 @@ func TraceHandler(t trace.Tracer) server.HandlerWrapper {
 	}
 }
 
+type validator interface {
+	Validate() error
+}
+
+// ValidateHandler wraps a server handler to call Validate() on requests
+// generated by protoc-gen-validator, bad requests never reach the handler
+func ValidateHandler() server.HandlerWrapper {
+	return func(h server.HandlerFunc) server.HandlerFunc {
+		return func(ctx context.Context, req server.Request, rsp interface{}) error {
+			if v, ok := req.Body().(validator); ok {
+				if err := v.Validate(); err != nil {
+					return ValidateError(req.Service(), err)
+				}
+			}
+			return h(ctx, req, rsp)
+		}
+	}
+}
+
+// ValidateError converts the error returned by Validate() to a BadRequest
+// which lists each field violation
+func ValidateError(id string, err error) *errors.Error {
+	e := errors.BadRequest(id, err.Error())
+	for _, fe := range is.FieldErrors(err) {
+		e.WithViolation(fe.Field, fe.Rule, fe.Value)
+	}
+	return e
+}
+
 type staticClient struct {
 	address string
 	client.Client